@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var capFlag string
+
+// analyzeCmd represents the analyze command
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze --pcap <capture.pcap>",
+	Short: "Report on the pings of a packet capture, as on a run",
+	Long: `analyze reads a pcap capture, such as tcpdump -w writes, and matches the ICMP
+Echo Requests in it with the Echo Replies and ICMP errors they got, by address,
+identifier and sequence number: pings of pinger, ping(8) or anything else.
+It then reports on them as on a run, with the probes of every target and the
+statistics, for captures taken during an incident. Requests with no answer in
+the capture are timeouts. --format, -n and --histogram apply as for ping;
+pcapng captures have to be converted first, with editcap -F pcap.`,
+	Args: cobra.NoArgs,
+	Example: `./pinger analyze --pcap incident.pcap
+./pinger analyze --pcap incident.pcap --format json > incident.jsonl`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.Exit(helpers.AnalyzeHandler(capFlag, helpers.ICMPInfo{
+			Format:    fmtFlag,
+			RTT:       rttFormat(),
+			Numeric:   numFlag,
+			Histogram: histFlag,
+			Intervals: ivlFlag,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+
+	analyzeCmd.Flags().StringVar(&capFlag, "pcap", "", "Capture to read, in pcap format")
+	analyzeCmd.Flags().BoolVarP(&numFlag, "numeric", "n", false, "Print the addresses replies come from without looking their names up")
+	analyzeCmd.MarkFlagRequired("pcap")
+	analyzeCmd.MarkFlagFilename("pcap", "pcap", "cap")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flag of the api subcommand
+var apiFlag string
+
+// apiCmd represents the api command
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Run ping jobs for other programs, over an HTTP API",
+	Long: `api runs pinger as an agent, as serve does, but over plain HTTP and JSON rather
+than gRPC, for clients as simple as curl:
+
+  POST /jobs               start a job: {"target": "example.com", "count": 5,
+                           "interval_ms": 1000, "timeout_ms": 4000,
+                           "ipv6": false, "iface": "", "ttl": 0}, of which
+                           only target is needed
+  GET  /jobs               every job kept
+  GET  /jobs/{id}          a job: its state, and the statistics of its results
+  GET  /jobs/{id}/results  its results, from the ?since=<n>th on, to poll
+  POST /jobs/{id}/stop     stop it
+
+At most --max-jobs run at once, later ones are refused with 429 Too Many
+Requests, and jobs are kept for --retention once over. Jobs ping as for serve.
+The API has no authentication: it listens on localhost unless --listen says
+otherwise.`,
+	Args:    cobra.NoArgs,
+	PreRunE: validateJobFlags,
+	Example: `./pinger api
+curl -d '{"target": "example.com", "count": 10}' localhost:8080/jobs
+curl 'localhost:8080/jobs/<id>/results?since=0'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.ServeREST(apiFlag, jobConfig())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(apiCmd)
+	apiCmd.Flags().StringVar(&apiFlag, "listen", "127.0.0.1:8080", "Address and port to serve the API on")
+	apiCmd.Flags().IntVar(&jobsFlag, "max-jobs", 16, "Jobs to run at once at most, 0 for no limit")
+	apiCmd.Flags().DurationVar(&keepFlag, "retention", time.Hour, "How long to keep jobs, and their results, once over")
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// arpCmd represents the arp command
+var arpCmd = &cobra.Command{
+	Use:   "arp <address>",
+	Short: "Ping a host on the local network with ARP / Neighbor Solicitations",
+	Long: `arp resolves the link layer address of a host on a directly connected network
+once per second, with ARP requests for IPv4 and Neighbor Solicitations for IPv6,
+and reports how long the answers took. Hosts that drop ICMP must still answer
+these to be reachable at all. The interface is found from the address, or given
+with -I (needed for IPv6 link-local addresses). -4, -6, -c and --format apply as
+for ping. Needs root or CAP_NET_RAW; ARP works on Linux only.`,
+	Args: cobra.ExactArgs(1),
+	Example: `./pinger arp 192.168.1.1
+./pinger arp -6 -I eth0 fe80::1`,
+	Run: func(cmd *cobra.Command, args []string) {
+		verified := resolveTarget(args[0])
+
+		helpers.Exit(helpers.ARPHandler(helpers.ICMPInfo{
+			IP:        verified.Addr,
+			Iface:     ifaceFor(verified),
+			CNT:       probeCount(),
+			Format:    fmtFlag,
+			RTT:       rttFormat(),
+			Histogram: histFlag,
+			Intervals: ivlFlag,
+			HostsFile: verified.HostsFile,
+
+			SummaryEvery: sumFlag,
+		}, verified.IsIPv6))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(arpCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// completeIfaces completes -I with the network interfaces of this host,
+// described by their addresses
+func completeIfaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, iface := range ifaces {
+		if !strings.HasPrefix(iface.Name, toComplete) {
+			continue
+		}
+		var addrs []string
+		ifAddrs, _ := iface.Addrs()
+		for _, addr := range ifAddrs {
+			addrs = append(addrs, addr.String())
+		}
+		names = append(names, iface.Name+"\t"+strings.Join(addrs, " "))
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeHosts completes destinations with the hostnames of the hosts files
+// and of ~/.ssh/config
+func completeHosts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, name := range helpers.HostNames(hostsFlag) {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeHost is completeHosts, for commands taking a single destination
+func completeHost(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeHosts(cmd, args, toComplete)
+}
+
+// completeTargets completes the last of the comma separated --targets
+func completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	given, last := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		given, last = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	names, directive := completeHosts(cmd, args, last)
+	for i := range names {
+		names[i] = given + names[i]
+	}
+	return names, directive | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeProfiles completes --profile with the profiles of the configuration file
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	config, err := helpers.LoadConfig(cfgFlag)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range config.Profiles {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerCompletions completes destinations and flag values, once the flags are defined
+func registerCompletions() {
+	rootCmd.ValidArgsFunction = completeHosts
+	traceCmd.ValidArgsFunction = completeHost
+	arpCmd.ValidArgsFunction = completeHost
+
+	rootCmd.RegisterFlagCompletionFunc("iface", completeIfaces)
+	rootCmd.RegisterFlagCompletionFunc("targets", completeTargets)
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+}
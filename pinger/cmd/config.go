@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix starts the names of the environment variables flags are read
+// from, e.g. PINGER_COUNT for --count
+const envPrefix = "PINGER_"
+
+// applyConfig sets the flags the command line does not give from the
+// environment, the configuration file and the --profile of it, before the
+// arguments are checked
+func applyConfig() {
+	if err := applyEnv(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(helpers.ExitUsage)
+	}
+
+	config, err := helpers.LoadConfig(cfgFlag)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(helpers.ExitUsage)
+	}
+
+	if profFlag != "" {
+		profile, err := config.Profile(profFlag)
+		if err == nil {
+			err = applyProfile(profile)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+	}
+
+	flags := rootCmd.PersistentFlags()
+	for name, value := range map[string]string{
+		"count":  strconv.Itoa(config.Count),
+		"iface":  config.Interface,
+		"format": config.Format,
+	} {
+		if value == "" || value == "0" || flags.Changed(name) {
+			continue
+		}
+		if err := flags.Lookup(name).Value.Set(value); err != nil {
+			slog.Error(fmt.Sprintf("invalid %s %q in the configuration: %v", name, value, err))
+			os.Exit(helpers.ExitUsage)
+		}
+	}
+	helpers.SetRunDefaults(config.Interval, config.Size)
+}
+
+// applyEnv sets the flags of pinger the command line does not give from their
+// PINGER_ environment variables, as if they were given on it, for containers:
+// those of the subcommand run too, e.g. --listen of serve
+func applyEnv() error {
+	flagSets := []*pflag.FlagSet{rootCmd.PersistentFlags(), rootCmd.Flags()}
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && cmd != rootCmd {
+		flagSets = append(flagSets, cmd.LocalFlags())
+	}
+
+	var err error
+	for _, flags := range flagSets {
+		flags.VisitAll(func(f *pflag.Flag) {
+			value, ok := os.LookupEnv(envName(f))
+			if !ok || f.Changed || err != nil {
+				return
+			}
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s %q: %v", envName(f), value, setErr)
+			}
+		})
+	}
+	return err
+}
+
+// envName is the environment variable of flag *f*, e.g. PINGER_SUMMARY_EVERY
+// for --summary-every
+func envName(f *pflag.Flag) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+}
+
+// applyProfile adds the targets of *profile* to --targets, and sets its flags,
+// but for those given on the command line
+func applyProfile(profile helpers.Profile) error {
+	flags := pflag.NewFlagSet("profile", pflag.ContinueOnError)
+	flags.AddFlagSet(rootCmd.Flags())
+
+	given := make(map[*pflag.Flag][]string)
+	rootCmd.Flags().Visit(func(f *pflag.Flag) { given[f] = flagValues(f) })
+
+	if err := flags.Parse(profile.Args); err != nil {
+		return fmt.Errorf("profile %s: %v", profFlag, err)
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("profile %s: give targets with targets, not args: %v", profFlag, flags.Args())
+	}
+
+	for f, values := range given {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(values)
+		} else {
+			f.Value.Set(values[0])
+		}
+	}
+
+	multiFlag = append(multiFlag, profile.Targets...)
+	return nil
+}
+
+// flagValues is the value of *f*, as it can be set again
+func flagValues(f *pflag.Flag) []string {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		return slice.GetSlice()
+	}
+	return []string{f.Value.String()}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var qtypeFlag string
+
+// dnsCmd represents the dns command
+var dnsCmd = &cobra.Command{
+	Use:   "dns <name> [@<server>[:<port>]]",
+	Short: "Measure DNS query latency against a resolver",
+	Long: `dns sends a query for a name to a resolver once per second, over UDP, and
+reports each query's RTT, the response code and the answer, loss, and whenever
+the answer changes. Comparing with a ping of the resolver tells DNS slowness from
+network slowness. The resolver defaults to the one of --dns, or else the first
+nameserver of /etc/resolv.conf.
+-I, -c and --format apply as for ping.`,
+	Args: cobra.RangeArgs(1, 2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		qtypeFlag = strings.ToUpper(qtypeFlag)
+		if qtypeFlag != "A" && qtypeFlag != "AAAA" {
+			return fmt.Errorf("invalid --type %q: want A or AAAA", qtypeFlag)
+		}
+		if len(args) == 2 && !strings.HasPrefix(args[1], "@") {
+			return fmt.Errorf("invalid server %q: want @<server>[:<port>]", args[1])
+		}
+		return nil
+	},
+	Example: `./pinger dns nitk.ac.in
+./pinger dns -c 10 --type AAAA example.com @9.9.9.9`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server, err := dnsServer(), error(nil)
+		if len(args) == 2 {
+			host, port := splitHostPort(strings.TrimPrefix(args[1], "@"), 53)
+			server = net.JoinHostPort(resolveTarget(host).Addr, fmt.Sprint(port))
+		} else if server == "" {
+			if server, err = helpers.DefaultResolver(); err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		helpers.Exit(helpers.DNSHandler(helpers.ICMPInfo{
+			Iface:  ifaceFlag,
+			CNT:    probeCount(),
+			Format: fmtFlag,
+			RTT:    rttFormat(),
+
+			Histogram:    histFlag,
+			Intervals:    ivlFlag,
+			SummaryEvery: sumFlag,
+		}, helpers.DNSOptions{
+			Name:   args[0],
+			Server: server,
+			Type:   qtypeFlag,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+
+	dnsCmd.Flags().StringVar(&qtypeFlag, "type", "A", "Query type: A or AAAA")
+}
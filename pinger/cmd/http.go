@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	methodFlag      string
+	httpTimeoutFlag time.Duration
+)
+
+// httpCmd represents the http command
+var httpCmd = &cobra.Command{
+	Use:   "http <url>",
+	Short: "Time HTTP(S) requests, step by step",
+	Long: `http sends a HEAD (or GET) request to a URL once per second, each over a new
+connection, and reports how long DNS, the TCP connect, the TLS handshake and the
+first response byte (TTFB) took, with statistics per step. Comparing connect times
+with TTFB tells network latency from application latency. Redirects are not followed.
+-4, -6, -I, -c and --format apply as for ping.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		methodFlag = strings.ToUpper(methodFlag)
+		if methodFlag != "HEAD" && methodFlag != "GET" {
+			return fmt.Errorf("invalid --method %q: want HEAD or GET", methodFlag)
+		}
+		if v4Flag && v6Flag {
+			return fmt.Errorf("only one -4 or -6 option may be specified")
+		}
+		return nil
+	},
+	Example: `./pinger http -c 4 https://nitk.ac.in
+./pinger http -X GET -6 https://example.com/health`,
+	Run: func(cmd *cobra.Command, args []string) {
+		network := "tcp"
+		if v4Flag {
+			network = "tcp4"
+		} else if v6Flag {
+			network = "tcp6"
+		}
+
+		helpers.Exit(helpers.HTTPHandler(args[0], helpers.ICMPInfo{
+			Iface:  ifaceFlag,
+			CNT:    probeCount(),
+			Format: fmtFlag,
+			RTT:    rttFormat(),
+
+			Histogram:    histFlag,
+			Intervals:    ivlFlag,
+			SummaryEvery: sumFlag,
+		}, helpers.HTTPOptions{
+			Method:  methodFlag,
+			Timeout: httpTimeoutFlag,
+			Network: network,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(httpCmd)
+
+	httpCmd.Flags().StringVarP(&methodFlag, "method", "X", "HEAD", "Request method: HEAD or GET")
+	httpCmd.Flags().DurationVarP(&httpTimeoutFlag, "timeout", "w", 10*time.Second, "Time to wait for each response")
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// ifacesCmd represents the ifaces command
+var ifacesCmd = &cobra.Command{
+	Use:   "ifaces",
+	Short: "List network interfaces usable with -I",
+	Long: `ifaces lists the network interfaces with their state, MTU and addresses, and
+which kinds of ICMP socket pinger can use over IPv4 / IPv6 through each:
+"raw" needs root or CAP_NET_RAW, "unprivileged" needs the group to be in
+net.ipv4.ping_group_range on Linux. "-" means pinging that family via the
+interface will not work: it is down, has no such address, or no socket is allowed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.IfacesHandler(fmtFlag)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ifacesCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// macCmd represents the mac command
+var macCmd = &cobra.Command{
+	Use:   "mac <mac-address>",
+	Short: "Ping a device on the local network by its MAC address",
+	Long: `mac finds the IP address bound to a MAC address, and pings it. The address is
+taken from the kernel's neighbor (ARP / NDP) table if it is there; otherwise it
+is discovered on the interface of -I, with an ARP request to every address of
+its IPv4 subnets (up to /22), then an Echo Request to all IPv6 nodes. IPv4 is
+preferred: -4 and -6 restrict the search to one family. The flags of ping apply
+to the ping that follows. Discovery needs root or CAP_NET_RAW; the neighbor
+table is only read on Linux.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := net.ParseMAC(args[0]); err != nil {
+			return fmt.Errorf("invalid MAC address %q: want e.g. aa:bb:cc:dd:ee:ff", args[0])
+		}
+		return nil
+	},
+	Example: `./pinger mac -I eth0 aa:bb:cc:dd:ee:ff
+./pinger mac -6 -c 10 -I eth0 aa:bb:cc:dd:ee:ff`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mac, _ := net.ParseMAC(args[0])
+
+		verified, err := helpers.MACAddr(mac, ifaceFlag, resolveOptions())
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+		if !helpers.MachineFormat(fmtFlag) {
+			fmt.Printf("%s is at %s\n", mac, verified.Addr)
+		}
+
+		pingTarget(verified)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(macCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the monitor subcommand
+var (
+	downFlag int
+	windFlag time.Duration
+	lossFlag float64
+	mrttFlag time.Duration
+	hookFlag string
+	tfilFlag string
+)
+
+// fileTargets are the hosts of the --targets-file of monitor
+var fileTargets []string
+
+// monitorCmd represents the monitor command
+var monitorCmd = &cobra.Command{
+	Use:   "monitor [<host>...]",
+	Short: "Watch hosts forever, logging when they go UP, DEGRADED or DOWN",
+	Long: `monitor pings every host at once, forever, as a lightweight reachability
+monitor: rather than a line per probe, it prints a line, timestamped, whenever
+a target changes state. A target is DOWN after --down-after unanswered probes
+in a row, DEGRADED if the loss over the last --window is above --max-loss
+(judged from 10 probes on), or its average RTT over it above --max-rtt, and
+UP otherwise. --webhook POSTs a JSON alert of every change to a URL, Slack-
+compatible, retrying with backoff while it fails. With --format json, the
+changes are events among the probes records, of kind state_change. It runs
+until Ctrl + C, or for -c probes if given, then prints the statistics. -I, -S,
+-4 / -6, --notify, --sink and the probe options apply as for ping.
+
+The hosts can be listed in a --targets-file too, one a line, # for comments.
+As a systemd service of Type=notify, monitor tells systemd once it is probing,
+and the count of targets in every state; with WatchdogSec=, it feeds the
+watchdog as long as probes get outcomes. On SIGHUP, as ExecReload=kill -HUP
+$MAINPID sends, it restarts in place, reading the --targets-file again.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && tfilFlag == "" {
+			return fmt.Errorf("requires a host, or a --targets-file")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if tfilFlag != "" {
+			var err error
+			if fileTargets, err = helpers.ReadTargetsFile(tfilFlag); err != nil {
+				return fmt.Errorf("invalid --targets-file: %v", err)
+			}
+			if len(args)+len(fileTargets) == 0 {
+				return fmt.Errorf("invalid --targets-file %s: lists no host", tfilFlag)
+			}
+			args = append(args, fileTargets...)
+		}
+		if downFlag < 1 {
+			return fmt.Errorf("invalid --down-after %d: must be at least 1", downFlag)
+		}
+		if windFlag <= 0 {
+			return fmt.Errorf("invalid --window %v: must be positive", windFlag)
+		}
+		if lossFlag < 0 || lossFlag > 100 {
+			return fmt.Errorf("invalid --max-loss %g: must be between 0 and 100", lossFlag)
+		}
+		if mrttFlag < 0 {
+			return fmt.Errorf("invalid --max-rtt %v: must be positive, or 0 for none", mrttFlag)
+		}
+		if err := validateFlags(args); err != nil {
+			return err
+		}
+
+		// A log is read after the fact: its lines need their time
+		if !helpers.MachineFormat(fmtFlag) && stampFlag == "" {
+			helpers.SetLineTimestamps(helpers.TimestampRFC3339)
+		}
+		helpers.SetMonitor(helpers.MonitorRules{DownAfter: downFlag, Window: windFlag, MaxLoss: lossFlag, MaxRTT: mrttFlag})
+		if hookFlag != "" {
+			return helpers.SetWebhook(hookFlag)
+		}
+		return nil
+	},
+	ValidArgsFunction: completeHosts,
+	Example: `./pinger monitor 10.0.0.1 gateway.lan example.com
+./pinger monitor --max-loss 5 --window 10m --max-rtt 150ms --down-after 5 example.com >> reachability.log
+./pinger monitor --webhook https://hooks.slack.com/services/T000/B000/XXXX 10.0.0.1
+./pinger monitor --targets-file /etc/pinger/targets --syslog local`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.ReloadOnHangup()
+		multiPing(resolveTargets(append(args, fileTargets...)), probeCount())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+
+	monitorCmd.Flags().IntVar(&downFlag, "down-after", 3, "Unanswered probes in a row after which a target is DOWN")
+	monitorCmd.Flags().DurationVar(&windFlag, "window", 5*time.Minute, "How far back the loss and average RTT of a target are judged")
+	monitorCmd.Flags().Float64Var(&lossFlag, "max-loss", 10, "Percentage of loss over --window above which a target is DEGRADED")
+	monitorCmd.Flags().DurationVar(&mrttFlag, "max-rtt", 0, "Average RTT over --window above which a target is DEGRADED, 0 for no limit")
+	monitorCmd.Flags().StringVar(&tfilFlag, "targets-file", "", "Monitor the hosts this file lists too, one a line, read again on SIGHUP")
+	monitorCmd.Flags().StringVar(&hookFlag, "webhook", "", "POST a JSON alert to this URL whenever a target changes state, retrying with backoff")
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// playCmd represents the play command
+var playCmd = &cobra.Command{
+	Use:   "play <playbook.yaml>",
+	Short: "Run a troubleshooting playbook of measurements, and check them against SLOs",
+	Long: `play runs the steps of a YAML playbook in order: pings, traces and sweeps, each
+with the objectives its results must meet, then reports which steps met them.
+Every step is a pinger run of its own, with the flags of the playbook's and the
+step's args. The exit status is 0 if every step met its objectives, 1 if not.
+
+  name: branch office
+  args: [-I, eth0]
+  steps:
+    - ping: 10.0.0.1
+      args: [-c, "10"]
+      slo: {max_loss_pct: 0, max_avg_ms: 20, max_rtt_ms: 50}
+    - trace: example.com
+      slo: {reached: true, max_hops: 15}
+    - sweep: 10.0.0.0/24
+      slo: {min_alive: 3}
+
+--format applies to the report: json prints a record per step, with the step's
+own summary record in it.`,
+	Args: cobra.ExactArgs(1),
+	Example: `./pinger play runbooks/branch.yaml
+./pinger play --format json runbooks/branch.yaml > report.jsonl`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pb, err := helpers.LoadPlaybook(args[0])
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+
+		// Steps print what the report quotes: they have to mask it too
+		if len(rdctFlag) > 0 {
+			pb.Args = append(pb.Args, "--redact", strings.Join(rdctFlag, ","))
+		}
+
+		os.Exit(helpers.PlayHandler(pb, helpers.ICMPInfo{Format: fmtFlag}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(playCmd)
+}
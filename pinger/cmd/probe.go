@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// probeCmd represents the probe command
+var probeCmd = &cobra.Command{
+	Use:   "probe <name>[:<config>] <target>",
+	Short: "Run a probe type provided by a plugin",
+	Long: `probe measures a target once per second with a probe type a Go plugin loaded
+with --plugin provides, and reports the results with the usual statistics. The
+plugin is given the target as written, not resolved, and what follows the
+colon of the probe name as its configuration. -c and --format apply as for
+ping, and --sink sends the results to the sinks of plugins too. See package
+ext for how to write plugins.`,
+	Args: cobra.ExactArgs(2),
+	Example: `./pinger probe --plugin ./redis.so redis cache.internal:6379
+./pinger probe --plugin ./quic.so quic:alpn=h3 example.com -c 10`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.Exit(helpers.PluginProbeHandler(args[0], helpers.ICMPInfo{
+			IP:     args[1],
+			CNT:    probeCount(),
+			Format: fmtFlag,
+			RTT:    rttFormat(),
+
+			Histogram:    histFlag,
+			Intervals:    ivlFlag,
+			SummaryEvery: sumFlag,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(probeCmd)
+}
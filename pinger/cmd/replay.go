@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Print a run recorded before again, with its statistics computed anew",
+	Long: `replay reads a run recorded before and prints it again, the probes of every
+target then the statistics, computed anew, as if it ran now: a pcap capture,
+such as --pcap or tcpdump -w writes, whose Echo Requests are matched with what
+they got as analyze does, or a results file, the JSON lines of --format json
+or --log-results, gzipped or not, as rotated. It is for post-mortems, to read
+a run in another --format, and for regression tests, to compare what pinger
+makes of the same run before and after a change. --format, -n and --histogram
+apply as for ping.`,
+	Args: cobra.ExactArgs(1),
+	Example: `./pinger replay incident.pcap
+./pinger replay --format text results.jsonl.20261015T150521Z.gz
+./pinger --format json 10.0.0.1 -c 10 > run.jsonl; ./pinger replay --format json run.jsonl | diff run.jsonl -`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.Exit(helpers.ReplayHandler(args[0], helpers.ICMPInfo{
+			Format:    fmtFlag,
+			RTT:       rttFormat(),
+			Numeric:   numFlag,
+			Histogram: histFlag,
+			Intervals: ivlFlag,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().BoolVarP(&numFlag, "numeric", "n", false, "Print the addresses replies come from without looking their names up")
+}
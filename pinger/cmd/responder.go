@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flag of the responder subcommand
+var listenFlag string
+
+// responderCmd represents the responder command
+var responderCmd = &cobra.Command{
+	Use:   "responder",
+	Short: "Answer pinger udp probes, for measurements that need the far end",
+	Long: `responder listens for UDP probes and answers them, until killed: the requests of
+pinger udp --asymmetric get replies of the size they ask for, and any other
+datagram is echoed back, so that plain pinger udp measures it too. Run it on the
+far end of the link to measure. No privileges are needed.`,
+	Args: cobra.NoArgs,
+	Example: `./pinger responder
+./pinger responder --listen 192.168.1.1:9000`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.ResponderHandler(listenFlag)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(responderCmd)
+	responderCmd.Flags().StringVar(&listenFlag, "listen", net.JoinHostPort("", strconv.Itoa(helpers.DefaultResponderPort)), "Address and port to listen on")
+}
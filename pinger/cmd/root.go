@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
+	"time"
 
 	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
 	"github.com/spf13/cobra"
@@ -12,8 +15,62 @@ var (
 	v4Flag    bool
 	v6Flag    bool
 	ifaceFlag string
-	ttlFlag   int8
-	cntFlag   int8
+	ttlFlag   int
+	ttl4Flag  int
+	ttl6Flag  int
+	cntFlag   int
+	fmtFlag   string
+	privFlag  bool
+	cmpFlag   string
+	selfFlag  bool
+	precFlag  int
+	siFlag    bool
+	multiFlag []string
+	verbFlag  int
+	failFlag  string
+	bcastFlag bool
+	rrFlag    bool
+	tsFlag    string
+	cnameFlag bool
+	numFlag   bool
+	srcFlag   string
+	hostsFlag string
+	dnsFlag   string
+	dohFlag   string
+	dotFlag   string
+	ctlFlag   string
+	allFlag   bool
+	strcFlag  bool
+	hwtsFlag  bool
+	stampFlag string
+	plugFlag  []string
+	sinkFlag  []string
+	rdctFlag  []string
+	histFlag  bool
+	ivlFlag   time.Duration
+	sumFlag   time.Duration
+	runtFlag  time.Duration
+	pktsFlag  int
+	ntfyFlag  string
+	syslFlag  string
+	rlogFlag  string
+	rsizFlag  int
+	rageFlag  time.Duration
+	rkeepFlag int
+	rgzFlag   bool
+	pcapFlag  string
+	lineFlag  bool
+	logFlag   string
+	cfgFlag   string
+	profFlag  string
+	nat64Flag string
+	ctosFlag  string
+	tosFlag   string
+	tosValue  int // tosFlag, parsed, with the ECN bits of ecnFlag
+	ecnFlag   string
+	paceFlag  string
+	tmpFlag   bool
+	pubFlag   bool
 )
 
 // rootCmd represents the base command
@@ -23,59 +80,581 @@ var rootCmd = &cobra.Command{
 	Long: `pinger is a custom ping clone to send ICMP ECHO_REQUEST to network hosts, built in Golang! 
 It supports: 
 - IPv4, IPv6 [-4|-6]
-- Sending to a specific network interface[-I <iface-name>]
+- Sending to a specific network interface[-I <iface-name>], or from a specific address [-S <addr>]
 - Number of echo requests [-c <number>]
-- Setting Time to Live [-t <ttl>].`,
-	Args: cobra.ExactArgs(1),
+- Setting Time to Live [-t <ttl>], or per family [--ttl4 <ttl>] [--ttl6 <hop-limit>]
+- Marking probes with a TOS / DSCP value [-Q <tos>], and ECN bits [--ecn <codepoint>]
+- Machine-readable JSON lines output [--format json]
+- Running without root, over ICMP datagram sockets [--privileged=false]
+- Comparing a tunnel against its underlay [--compare-path inner=wg0,outer=eth0]
+- Comparing how the path treats two TOS markings [--compare-tos <tos>]
+- RTT precision and units [--precision <digits>] [--si]
+- Pinging several targets at once [pinger <host> <host>...] [--targets <host>,<host>]
+- Pinging a broadcast address [-b] or a multicast group, reporting every host that answers
+- Recording the route of IPv4 probes and their replies [-R], or timestamps on the way [--ip-timestamp tsonly|tsandaddr].`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args)+len(multiFlag) == 0 {
+			return fmt.Errorf("requires at least 1 destination, as an argument or with --targets")
+		}
+		return nil
+	},
+	// Validates the persistent flags, for every subcommand too
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := helpers.SetLogging(verbFlag, logFlag); err != nil {
+			return err
+		}
+		for name, ttl := range map[string]int{"ttl": ttlFlag, "ttl4": ttl4Flag, "ttl6": ttl6Flag} {
+			if ttl < 0 || ttl > 255 {
+				return fmt.Errorf("invalid --%s %d: must be between 1 and 255, or 0 for the kernel's default", name, ttl)
+			}
+		}
+		if cntFlag < 0 {
+			return fmt.Errorf("invalid -c %d: must be at least 1, or 0 to go on until interrupted", cntFlag)
+		}
+		if err := helpers.ValidFormat(fmtFlag); err != nil {
+			return err
+		}
+		if precFlag < 0 || precFlag > 9 {
+			return fmt.Errorf("invalid --precision %d: must be between 0 and 9", precFlag)
+		}
+		var err error
+		if tosValue, err = helpers.ParseTOS(tosFlag); err != nil {
+			return err
+		}
+		if nat64Flag != "" {
+			if v4Flag {
+				return fmt.Errorf("--nat64 translates IPv4 addresses to IPv6: it cannot be given with -4")
+			}
+			if nat64Flag != helpers.NAT64Discover {
+				if _, err := helpers.ParseNAT64Prefix(nat64Flag); err != nil {
+					return err
+				}
+			}
+		}
+		if sumFlag < 0 {
+			return fmt.Errorf("invalid --summary-every %v: must be positive", sumFlag)
+		}
+		if ivlFlag < 0 {
+			return fmt.Errorf("invalid --interval-table %v: must be positive, or 0 for none", ivlFlag)
+		}
+		if runtFlag < 0 {
+			return fmt.Errorf("invalid --max-runtime %v: must be positive, or 0 for none", runtFlag)
+		}
+		if pktsFlag < 0 {
+			return fmt.Errorf("invalid --max-packets %d: must be positive, or 0 for none", pktsFlag)
+		}
+		helpers.SetLimits(runtFlag, pktsFlag)
+		if tmpFlag && pubFlag {
+			return fmt.Errorf("--prefer-temporary and --prefer-public cannot both be given")
+		}
+		if ecnFlag != "" {
+			ecn, err := helpers.ParseECN(ecnFlag)
+			if err != nil {
+				return err
+			}
+			if tosValue&0x03 != 0 {
+				return fmt.Errorf("-Q %s sets the ECN bits already: drop them, or --ecn", tosFlag)
+			}
+			tosValue |= ecn
+		}
+		if stampFlag != "" {
+			format, err := helpers.ParseTimestampFormat(stampFlag)
+			if err != nil {
+				return err
+			}
+			if helpers.MachineFormat(fmtFlag) {
+				return fmt.Errorf("-D prefixes text lines: JSON records carry their time already")
+			}
+			helpers.SetLineTimestamps(format)
+		}
+		if len(rdctFlag) > 0 {
+			redaction, err := helpers.ParseRedaction(rdctFlag)
+			if err != nil {
+				return err
+			}
+			helpers.SetRedaction(redaction)
+		}
+		if ntfyFlag != "" {
+			if err := helpers.SetNotify(ntfyFlag); err != nil {
+				return err
+			}
+		}
+		if syslFlag != "" {
+			if err := helpers.SetSyslog(syslFlag); err != nil {
+				return err
+			}
+		}
+		if rlogFlag != "" {
+			if rsizFlag < 0 || rageFlag < 0 || rkeepFlag < 0 {
+				return fmt.Errorf("invalid --log-max-size, --log-max-age or --log-keep: must be positive, or 0 for no limit")
+			}
+			err := helpers.SetResultLog(rlogFlag, helpers.ResultLogOptions{
+				MaxSize:  int64(rsizFlag) << 20,
+				Every:    rageFlag,
+				Keep:     rkeepFlag,
+				Compress: rgzFlag,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if pcapFlag != "" {
+			if err := helpers.SetPcap(pcapFlag); err != nil {
+				return err
+			}
+		}
+		if lineFlag {
+			if err := helpers.SetStatusLine(); err != nil {
+				return err
+			}
+		}
+		for _, path := range plugFlag {
+			if err := helpers.LoadPlugin(path); err != nil {
+				return err
+			}
+		}
+		for _, spec := range sinkFlag {
+			if err := helpers.OpenSink(spec); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	// Reports every conflict among the flags at once, before anything is sent
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateFlags(append(args, multiFlag...))
+	},
 	Example: `./pinger -I wlp45s0 -c 4 -4 nitk.ac.in
 
-(You will likely need root privileges, since pinger opens raw sockets...)`,
+(pinger opens raw sockets if it can, and otherwise falls back to unprivileged
+ICMP datagram sockets, which Linux allows for groups in net.ipv4.ping_group_range)`,
 	// Single action for this application
 	Run: func(cmd *cobra.Command, args []string) {
-		addr := args[0]
+		hosts := append(args, multiFlag...)
+		if len(hosts) > 1 || allFlag {
+			if targets := resolveTargets(hosts); len(targets) > 1 {
+				multiPing(targets, probeCount())
+				return
+			}
+		}
+		addr := hosts[0]
+
+		addrOptions := resolveOptions()
+
+		if cmpFlag != "" {
+			paths, err := helpers.ParseComparePath(cmpFlag)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(helpers.ExitUsage)
+			}
 
-		addrOptions := helpers.AddrOptions{
-			V4: v4Flag,
-			V6: v6Flag,
+			os.Exit(helpers.CompareHandler(addr, paths, addrOptions, helpers.ICMPInfo{
+				TTL:         ttlFor(addrOptions.V6),
+				TOS:         tosValue,
+				ECN:         ecnFlag != "",
+				CNT:         probeCount(),
+				RecordRoute: rrFlag,
+				Timestamp:   ipTimestamp(),
+				Format:      fmtFlag,
+				RTT:         rttFormat(),
+				Privileged:  privFlag,
+				PreferSrc:   preferSrc(),
+				Numeric:     numFlag,
+				DNSServer:   dnsServer(),
+			}))
 		}
 
-		verified, err := helpers.AddrResolution(addr, addrOptions)
+		// Simulated failures go through the same output, without sending anything
+		if failFlag == helpers.FailResolve {
+			slog.Error(helpers.ForcedResolveError(addr).Error())
+			os.Exit(helpers.ExitUsage)
+		}
+
+		pingTarget(resolveTarget(addr))
+	},
+}
+
+// pingTarget pings *verified*, the one destination, as the flags ask, and
+// exits with the status of the run
+func pingTarget(verified helpers.UnMarshalledAddr) {
+	ipaddr, isIPv6 := verified.Addr, verified.IsIPv6
+
+	// Like ping(8), only ping a broadcast address when asked to
+	if helpers.IsBroadcastAddr(ipaddr) && !bcastFlag {
+		slog.Error(fmt.Sprintf("%v is a broadcast address: ping it with -b", ipaddr))
+		os.Exit(helpers.ExitUsage)
+	}
+
+	if (rrFlag || tsFlag != "") && isIPv6 {
+		slog.Error(fmt.Sprintf("%v is an IPv6 address: -R and --ip-timestamp are IPv4 header options", ipaddr))
+		os.Exit(helpers.ExitUsage)
+	}
+
+	iface := ifaceFor(verified)
+
+	// Every member of a multicast group answers: link-scope groups need an interface
+	isMulticast := net.ParseIP(ipaddr).IsMulticast()
+	if isMulticast && iface == "" && net.ParseIP(ipaddr).IsLinkLocalMulticast() && isIPv6 {
+		slog.Error(fmt.Sprintf("%v is a link-local multicast group: give the interface with -I", ipaddr))
+		os.Exit(helpers.ExitUsage)
+	}
+
+	// Pinging ourselves is fine, but easy to mistake for network health
+	isSelf, _ := helpers.IsLocalAddr(ipaddr)
+	if isSelf && selfFlag {
+		slog.Error(fmt.Sprintf("%v is an address of this host, and --forbid-self is set", ipaddr))
+		os.Exit(helpers.ExitUsage)
+	}
+
+	icmpInfo := helpers.ICMPInfo{
+		IP:     ipaddr,
+		CNAMEs: verified.CNAMEs,
+		Iface:  iface,
+		Source: sourceFor(ipaddr, isIPv6),
+		TTL:    ttlFor(isIPv6),
+		TOS:    tosValue,
+		ECN:    ecnFlag != "",
+		CNT:    probeCount(),
+		Format: fmtFlag,
+		RTT:    rttFormat(),
+
+		RecordRoute: rrFlag,
+		Timestamp:   ipTimestamp(),
+		Privileged:  privFlag,
+		PreferSrc:   preferSrc(),
+		HostsFile:   verified.HostsFile,
+		NAT64:       verified.NAT64,
+		Control:     ctlFlag,
+		Strict:      strcFlag,
+		Histogram:   histFlag,
+		Intervals:   ivlFlag,
+		SelfTarget:  isSelf,
+		Broadcast:   bcastFlag,
+		Multicast:   isMulticast,
+		Verbose:     verbFlag > 0,
+		Numeric:     numFlag,
+		DNSServer:   dnsServer(),
+
+		SummaryEvery: sumFlag,
+		HWTimestamps: hwtsFlag,
+	}
+
+	var status int
+	if failFlag != "" {
+		status = helpers.ForceFailHandler(icmpInfo, failFlag)
+	} else if ctosFlag != "" {
+		tos, _ := helpers.ParseTOS(ctosFlag)
+		status = helpers.CompareTOSHandler(icmpInfo, isIPv6, tos)
+	} else if !isIPv6 {
+		status = helpers.ICMP4Handler(icmpInfo)
+	} else {
+		status = helpers.ICMP6Handler(icmpInfo)
+	}
+	helpers.Exit(status)
+}
+
+// resolveTargets resolves every one of *hosts*, as resolveTarget does, or to
+// all of its addresses with --all
+func resolveTargets(hosts []string) []helpers.UnMarshalledAddr {
+	var targets []helpers.UnMarshalledAddr
+	for _, host := range hosts {
+		if !allFlag {
+			targets = append(targets, resolveTarget(host))
+			continue
+		}
+
+		addrs, err := helpers.AllAddrs(host, resolveOptions())
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+		for i := range addrs {
+			addrs[i] = translateNAT64(host, addrs[i])
+		}
+		if addrs[0].Fallback != nil {
+			slog.Warn("resolved with the system resolver instead", "host", host, "err", addrs[0].Fallback)
 		}
+		targets = append(targets, addrs...)
+	}
+	return targets
+}
 
-		ipaddr, isIPv6 := verified.Addr, verified.IsIPv6
+// multiPing pings all *targets* at once, *count* times each
+func multiPing(targets []helpers.UnMarshalledAddr, count int) {
+	for i := range targets {
+		if net.ParseIP(targets[i].Addr).IsMulticast() {
+			slog.Error(fmt.Sprintf("%v is a multicast group: ping it on its own", targets[i].Addr))
+			os.Exit(helpers.ExitUsage)
+		}
 
-		icmpInfo := helpers.ICMPInfo{
-			IP:    ipaddr,
-			Iface: ifaceFlag,
-			TTL:   int(ttlFlag),
-			CNT:   int(cntFlag),
+		if isSelf, _ := helpers.IsLocalAddr(targets[i].Addr); isSelf && selfFlag {
+			slog.Error(fmt.Sprintf("%v is an address of this host, and --forbid-self is set", targets[i].Addr))
+			os.Exit(helpers.ExitUsage)
 		}
+	}
+
+	info := helpers.ICMPInfo{
+		Iface:  ifaceFlag,
+		Source: srcFlag,
+		TOS:    tosValue,
+		ECN:    ecnFlag != "",
+		CNT:    count,
+		Format: fmtFlag,
+		RTT:    rttFormat(),
+
+		RecordRoute: rrFlag,
+		Timestamp:   ipTimestamp(),
+		Privileged:  privFlag,
+		PreferSrc:   preferSrc(),
+		Verbose:     verbFlag > 0,
+		Numeric:     numFlag,
+		DNSServer:   dnsServer(),
+		Strict:      strcFlag,
+		Histogram:   histFlag,
+		Intervals:   ivlFlag,
+
+		SummaryEvery: sumFlag,
+	}
+	info4, info6 := info, info
+	info4.TTL, info6.TTL = ttlFor(false), ttlFor(true)
+	for _, target := range targets {
+		sourceFor(target.Addr, target.IsIPv6)
+		iface := ifaceFor(target)
 
-		if !isIPv6 {
-			helpers.ICMP4Handler(icmpInfo)
-		} else {
-			helpers.ICMP6Handler(icmpInfo)
+		// Zones are IPv6 only, and all IPv6 targets share a socket
+		if target.Zone != "" {
+			if info6.Iface != ifaceFlag && info6.Iface != target.Zone {
+				slog.Error(fmt.Sprintf("%v%%%v and %v are on different interfaces: ping them separately", target.Addr, target.Zone, info6.Iface))
+				os.Exit(helpers.ExitUsage)
+			}
+			info6.Iface = iface
 		}
+	}
+	info6.RecordRoute, info6.Timestamp = false, ""
 
-	},
+	var pace helpers.AutoInterval
+	if paceFlag != "" {
+		var err error
+		if pace, err = helpers.ParseAutoInterval(paceFlag); err != nil {
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+	}
+
+	helpers.Exit(helpers.MultiHandler(targets, info4, info6, pace))
+}
+
+// resolveTarget resolves the destination argument as -4 / -6 ask, or exits
+func resolveTarget(addr string) helpers.UnMarshalledAddr {
+	verified, err := helpers.AddrResolution(addr, resolveOptions())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(helpers.ExitUsage)
+	}
+
+	if verified.Fallback != nil {
+		slog.Warn("resolved with the system resolver instead", "host", addr, "err", verified.Fallback)
+	}
+
+	if cnameFlag && net.ParseIP(addr) == nil {
+		verified.CNAMEs = helpers.CNAMEChain(addr, verified.IsIPv6, dnsServer())
+	}
+
+	return translateNAT64(addr, verified)
+}
+
+// translateNAT64 is the IPv6 address behind NAT64 of *verified*, with
+// --nat64, if *host* is an IPv4 literal this host cannot reach otherwise, or
+// -6 is given; or exits
+func translateNAT64(host string, verified helpers.UnMarshalledAddr) helpers.UnMarshalledAddr {
+	if nat64Flag == "" || net.ParseIP(host) == nil {
+		return verified
+	}
+
+	translated, err := helpers.SynthesizeNAT64(verified, nat64Flag, v6Flag, dnsServer())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(helpers.ExitUsage)
+	}
+	return translated
+}
+
+// resolveOptions is how hostnames are resolved, as -4 / -6 and the resolver flags ask
+func resolveOptions() helpers.AddrOptions {
+	return helpers.AddrOptions{
+		V4:        v4Flag,
+		V6:        v6Flag,
+		HostsFile: hostsFlag,
+		DNSServer: dnsServer(),
+		DoH:       dohFlag,
+		DoT:       dotFlag,
+	}
+}
+
+// dnsServer is the --dns resolver as "host:port", "" for the system's: pinger
+// exits if it is not an IP address
+func dnsServer() string {
+	if dnsFlag == "" {
+		return ""
+	}
+	server, err := helpers.ParseDNSServer(dnsFlag)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(helpers.ExitUsage)
+	}
+	return server
+}
+
+// ifaceFor is the interface to ping *target* through: the zone of an IPv6
+// address like fe80::1%eth0, or -I. pinger exits if both are given, and differ,
+// or if -I has no address of the family of *target*.
+func ifaceFor(target helpers.UnMarshalledAddr) string {
+	if target.Zone == "" {
+		if ifaceFlag != "" {
+			if err := helpers.CheckIfaceFamily(ifaceFlag, target.IsIPv6); err != nil {
+				slog.Error(err.Error())
+				os.Exit(helpers.ExitUsage)
+			}
+		}
+		return ifaceFlag
+	}
+	if ifaceFlag != "" && ifaceFlag != target.Zone {
+		slog.Error(fmt.Sprintf("-I %s and the zone of %s%%%s disagree", ifaceFlag, target.Addr, target.Zone))
+		os.Exit(helpers.ExitUsage)
+	}
+	return target.Zone
+}
+
+// sourceFor is the -S address to ping *dst* from, an IPv6 address if *isIPv6*:
+// pinger exits if it is not of the family of *dst*
+func sourceFor(dst string, isIPv6 bool) string {
+	if srcFlag == "" {
+		return ""
+	}
+	if (net.ParseIP(srcFlag).To4() == nil) != isIPv6 {
+		slog.Error(fmt.Sprintf("-S %s and %s are not of the same IP family", srcFlag, dst))
+		os.Exit(helpers.ExitUsage)
+	}
+	return srcFlag
+}
+
+// preferSrc is the IPv6 source address kind --prefer-temporary / --prefer-public ask for
+func preferSrc() string {
+	switch {
+	case tmpFlag:
+		return helpers.PreferTemporary
+	case pubFlag:
+		return helpers.PreferPublic
+	}
+	return ""
+}
+
+// ipTimestamp is the Timestamp option kind --ip-timestamp asks for, checked by validateFlags
+func ipTimestamp() string {
+	kind, _ := helpers.ParseIPTimestamp(tsFlag)
+	return kind
+}
+
+// probeCount is how many probes -c asks for: without it, or with 0, as many
+// as it takes to be interrupted
+func probeCount() int {
+	if cntFlag == 0 {
+		return helpers.Continuous
+	}
+	return cntFlag
+}
+
+// rttFormat is how --precision and --si ask for round trip times to be printed
+func rttFormat() helpers.RTTFormat {
+	return helpers.RTTFormat{Precision: precFlag, SI: siFlag}
+}
+
+// ttlFor picks the TTL / Hop Limit for an address family:
+// -t applies to both families, --ttl4 / --ttl6 override it per family
+func ttlFor(isIPv6 bool) int {
+	if !isIPv6 && ttl4Flag != 0 {
+		return ttl4Flag
+	} else if isIPv6 && ttl6Flag != 0 {
+		return ttl6Flag
+	}
+	return ttlFlag
 }
 
 // Adds all child commands to the root command and sets flags appropriately. Called by main.
 func Execute() {
+	// until the flags say where, and how much, to log
+	helpers.SetLogging(0, "")
+
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		helpers.Exit(helpers.ExitUsage)
 	}
+	helpers.CloseSinks()
 }
 
 func init() {
+	cobra.OnInitialize(applyConfig)
 
 	rootCmd.PersistentFlags().BoolVarP(&v4Flag, "ipv4", "4", false, "Use IPv4 for address / hostname resolution")
 	rootCmd.PersistentFlags().BoolVarP(&v6Flag, "ipv6", "6", false, "Use IPv6 for address / hostname resolution")
 	rootCmd.PersistentFlags().StringVarP(&ifaceFlag, "iface", "I", "", "Specify the network device name")
-	rootCmd.PersistentFlags().Int8VarP(&ttlFlag, "ttl", "t", 64, "Define the time to live")
-	rootCmd.PersistentFlags().Int8VarP(&cntFlag, "count", "c", 5, "Stop after <count tries>")
+	rootCmd.PersistentFlags().StringVarP(&srcFlag, "source", "S", "", "Send probes from this local address, on hosts with several")
+	rootCmd.PersistentFlags().StringVar(&dnsFlag, "dns", "", "Resolve names with this DNS server (<addr>[:<port>]) instead of the system's resolver")
+	rootCmd.PersistentFlags().StringVar(&dohFlag, "doh", "", "Resolve hostnames with this DNS-over-HTTPS server URL, falling back to the system's resolver")
+	rootCmd.PersistentFlags().StringVar(&dotFlag, "dot", "", "Resolve hostnames with this DNS-over-TLS server (<host>[:<port>]), falling back to the system's resolver")
+	rootCmd.PersistentFlags().StringVar(&nat64Flag, "nat64", "", "On IPv6-only hosts (or with -6), ping IPv4 addresses through NAT64, with this prefix (e.g. 64:ff9b::/96), or the one of the DNS64 resolver with a bare --nat64 (RFC 7050)")
+	rootCmd.PersistentFlags().Lookup("nat64").NoOptDefVal = helpers.NAT64Discover
+	rootCmd.PersistentFlags().StringVar(&hostsFlag, "hosts-file", "", "Resolve hostnames with this hosts(5) file first, before the system's and DNS")
+	rootCmd.PersistentFlags().IntVarP(&ttlFlag, "ttl", "t", 0, "Define the time to live (default: the kernel's)")
+	rootCmd.PersistentFlags().IntVar(&ttl4Flag, "ttl4", 0, "Define the time to live for IPv4 only, overriding -t")
+	rootCmd.PersistentFlags().IntVar(&ttl6Flag, "ttl6", 0, "Define the hop limit for IPv6 only, overriding -t")
+	rootCmd.PersistentFlags().StringVarP(&tosFlag, "tos", "Q", "0", "Set the IPv4 TOS byte / IPv6 Traffic Class of probes: 0-255 (0x.. for hex), or a DSCP name such as ef or af41")
+	rootCmd.PersistentFlags().BoolVar(&tmpFlag, "prefer-temporary", false, "Send IPv6 probes from a temporary (privacy extensions) address, if the interface has one")
+	rootCmd.PersistentFlags().BoolVar(&pubFlag, "prefer-public", false, "Send IPv6 probes from a public (stable) address, rather than a temporary one")
+	rootCmd.PersistentFlags().StringVar(&ecnFlag, "ecn", "", "Set the ECN bits of probes: ect0, ect1, ce or not-ect; and report those of replies, to find paths that clear or change them")
+	rootCmd.PersistentFlags().IntVarP(&cntFlag, "count", "c", 0, "Stop after <count tries>; without it, ping until interrupted (Ctrl + C), as ping(8) does")
+	rootCmd.PersistentFlags().BoolVar(&privFlag, "privileged", true, "Use raw sockets, falling back to ICMP datagram sockets if not permitted; =false always uses datagram sockets")
+	rootCmd.PersistentFlags().StringVar(&cmpFlag, "compare-path", "", "Probe via a tunnel and its underlay at once, and report the tunnel overhead: inner=<iface>,outer=<iface>")
+	rootCmd.PersistentFlags().BoolVar(&selfFlag, "forbid-self", false, "Exit with an error if the destination is an address of this host")
+	rootCmd.PersistentFlags().IntVar(&precFlag, "precision", helpers.DefaultRTTFormat.Precision, "Digits after the decimal point in round trip times, for every output format")
+	rootCmd.PersistentFlags().BoolVar(&siFlag, "si", false, "Print round trip times in s / ms / µs / ns, whichever fits, instead of always ms")
+	rootCmd.Flags().StringVar(&ctosFlag, "compare-tos", "", "Probe with the -Q marking and this TOS / DSCP value in turns, and test whether the path treats them differently")
+	rootCmd.Flags().BoolVar(&strcFlag, "strict", false, "Fail on the first answer that is not a matching Echo Reply (Unreachable, Time Exceeded, wrong or duplicate reply), for conformance testing")
+	rootCmd.Flags().BoolVar(&hwtsFlag, "hw-timestamps", false, "Time RTTs by the hardware timestamps of the -I NIC, with PTP support, turning them on for it; or else by the kernel's, sent and received: each reply says which clock timed it")
+	rootCmd.Flags().StringVar(&ctlFlag, "control", "", "Listen on this Unix socket for commands changing the interval, size and timeout of probes while running")
+	rootCmd.Flags().BoolVar(&allFlag, "all", false, "Ping every address a hostname resolves to at the same time, with per-address statistics, instead of the first")
+	rootCmd.Flags().StringSliceVar(&multiFlag, "targets", nil, "More destinations to ping at the same time, comma separated, with per-target statistics")
+	rootCmd.Flags().StringVar(&paceFlag, "auto-interval", "", "With several destinations, share a budget of pps=<probes per second> or target-cpu=<percent>% between them, probing the most variable ones the most often")
+	rootCmd.Flags().BoolVarP(&bcastFlag, "broadcast", "b", false, "Allow pinging an IPv4 broadcast address, and report every host that answers")
+	rootCmd.Flags().BoolVarP(&rrFlag, "record-route", "R", false, "Record the route of IPv4 probes and their replies, with the Record Route IP option, and print it like ping(8)")
+	rootCmd.Flags().StringVar(&tsFlag, "ip-timestamp", "", "Send IPv4 probes with the Timestamp IP option: tsonly or tsandaddr; and print the timestamps of replies, with how far ahead of our clock each host stamped")
+	rootCmd.Flags().BoolVarP(&numFlag, "numeric", "n", false, "Print the addresses replies come from without looking their names up")
+	rootCmd.Flags().BoolVar(&cnameFlag, "show-cname", false, "Show the CNAME chain a hostname resolves through, down to the canonical name probed")
+	rootCmd.Flags().StringVar(&failFlag, "force-fail", "", "Simulate a failure, without sending anything, to test scripts: timeout, unreachable or resolve")
+	rootCmd.PersistentFlags().CountVarP(&verbFlag, "verbose", "v", "Report more details, e.g. the MAC address and vendor of targets on the local network, or the MPLS labels routers report in ICMP errors, and log how sockets are set up; -vv also logs the control messages and headers of every packet read")
+	rootCmd.PersistentFlags().StringVar(&cfgFlag, "config", "", "Read flag defaults and profiles from this YAML file, instead of "+helpers.ConfigPath())
+	rootCmd.Flags().StringVar(&profFlag, "profile", "", "Ping the targets of this profile of the configuration file, with its flags; those given here win")
+	rootCmd.PersistentFlags().StringVar(&logFlag, "log-file", "", "Append diagnostics (warnings, errors, and what -v / -vv log) to this file, instead of stderr")
+	rootCmd.PersistentFlags().StringVarP(&stampFlag, "timestamps", "D", "", "Prefix every line of results with the time it was printed at: unix (the default of a bare -D) or rfc3339, e.g. --timestamps=rfc3339")
+	rootCmd.PersistentFlags().Lookup("timestamps").NoOptDefVal = helpers.TimestampUnix
+	rootCmd.PersistentFlags().DurationVar(&ivlFlag, "interval-table", time.Minute, "Start the statistics of runs longer than this with a table of the loss and average RTT of every such interval, to see how they went over time; 0 for none")
+	rootCmd.PersistentFlags().BoolVar(&histFlag, "histogram", false, "End the statistics with a histogram of the round trip times, to see the shape of their distribution")
+	rootCmd.PersistentFlags().DurationVar(&runtFlag, "max-runtime", 0, "Stop whatever runs after this long (e.g. 10m), as on Ctrl + C, a failsafe against probes left running; 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&pktsFlag, "max-packets", 0, "Stop whatever runs once it has sent this many probes, and never send more, a failsafe for fast runs and sweeps; 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&sumFlag, "summary-every", 0, "Also print the statistics of every such period of the run (e.g. 60s), for long runs: loss and min/avg/max over the period")
+	rootCmd.PersistentFlags().StringSliceVar(&rdctFlag, "redact", nil, "Mask target identifiers in the results, keeping the statistics, to share them: last-octet (10.1.2.x, 2001:db8::x) and / or hostnames (host-1, host-2...)")
+	rootCmd.PersistentFlags().StringVar(&ntfyFlag, "notify", "", "Raise a notification when a target goes down (3 probes unanswered in a row) or comes back up: desktop (D-Bus on Linux, osascript on macOS)")
+	rootCmd.PersistentFlags().StringVar(&syslFlag, "syslog", "", "Also send every result, event (e.g. the state changes of monitor) and summary to syslog, as RFC 5424: local, udp://<host>[:<port>] or tcp://<host>[:<port>]")
+	rootCmd.PersistentFlags().StringVar(&rlogFlag, "log-results", "", "Also append every result, event and summary to this file, as JSON lines, rotating it as --log-max-size and --log-max-age say")
+	rootCmd.PersistentFlags().IntVar(&rsizFlag, "log-max-size", 100, "Rotate the --log-results file once it holds this many MiB; 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&rageFlag, "log-max-age", 24*time.Hour, "Rotate the --log-results file once written to for this long; 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&rkeepFlag, "log-keep", 10, "Keep this many rotated --log-results files, removing the oldest; 0 to keep them all")
+	rootCmd.PersistentFlags().BoolVar(&rgzFlag, "log-compress", false, "Gzip the rotated --log-results files")
+	rootCmd.PersistentFlags().StringVar(&pcapFlag, "pcap", "", "Write every Echo Request sent and ICMP packet received, with its time, to this pcap file, to look into in Wireshark")
+	rootCmd.PersistentFlags().BoolVar(&lineFlag, "statusline", false, "Keep a line of running statistics (sent, received, loss, avg) at the bottom of the terminal, on stderr, below the results")
+	rootCmd.PersistentFlags().StringSliceVar(&plugFlag, "plugin", nil, "Load this Go plugin (.so), adding the probe types and output sinks it provides; may be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&sinkFlag, "sink", nil, "Also send results to this output sink of a plugin: <name>[:<config>]; may be repeated")
+	rootCmd.PersistentFlags().StringVar(&fmtFlag, "format", "text", "Output format: text, json (one object per line, with a reason for every non-reply) or atlas-json (a RIPE Atlas ping result per target)")
+
+	registerCompletions()
 }
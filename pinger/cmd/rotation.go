@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var everyFlag time.Duration
+
+// rotationCmd represents the rotation command
+var rotationCmd = &cobra.Command{
+	Use:   "rotation <hostname>",
+	Short: "Check whether DNS round robin sends clients to slow addresses",
+	Long: `rotation resolves a hostname -c times, every --every, and each time sends an
+ICMP ECHO_REQUEST to every address it has resolved to so far. Clients connect to
+the first address of an answer, so rotation then compares the RTT they would
+see with that of the addresses spread evenly, and tells whether the order of
+the answers favours slow or fast members, or does not follow latency at all.
+The resolver of --dns, or else the first of /etc/resolv.conf, is asked for A
+records (AAAA with -6) directly, as the system's resolver sorts the answers.
+-I and --format apply as for ping. Resolvers that cache an answer rotate it
+only when it expires: space the lookups out accordingly.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if everyFlag <= 0 {
+			return fmt.Errorf("invalid --every %v: must be positive", everyFlag)
+		}
+		if dohFlag != "" || dotFlag != "" || hostsFlag != "" {
+			return fmt.Errorf("rotation asks a resolver over UDP: use --dns, not --doh, --dot or --hosts-file")
+		}
+		if v4Flag && v6Flag {
+			return fmt.Errorf("only one -4 or -6 option may be specified")
+		}
+		return nil
+	},
+	Example: `./pinger rotation -c 30 pool.ntp.org
+./pinger rotation -c 20 --every 30s --format json cdn.example.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.Exit(helpers.RotationHandler(args[0], helpers.ICMPInfo{
+			Iface:      ifaceFlag,
+			Source:     srcFlag,
+			TTL:        ttlFlag,
+			TOS:        tosValue,
+			CNT:        probeCount(),
+			Format:     fmtFlag,
+			RTT:        rttFormat(),
+			Privileged: privFlag,
+		}, helpers.RotationOptions{
+			Every:  everyFlag,
+			Server: dnsServer(),
+			IPv6:   v6Flag,
+		}))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotationCmd)
+
+	rotationCmd.Flags().DurationVar(&everyFlag, "every", 2*time.Second, "Time between lookups")
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// selfTestCmd represents the self-test command
+var selfTestCmd = &cobra.Command{
+	Use:   "self-test",
+	Short: "Check that pinger works on this system",
+	Long: `self-test runs pinger through its paces without leaving the host: a ping to
+127.0.0.1 and one to ::1, resolving localhost, probes over an in-memory
+transport, which replies, stays silent, sends an ICMP error or fails to send,
+simulated timeouts, and the statistics. It prints PASS or FAIL for each, and
+exits with status 1 if any failed. A loopback ping failing usually means no ICMP socket is allowed: see
+pinger ifaces.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.SelfTestHandler(fmtFlag, privFlag)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(selfTestCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the serve subcommand, and of api for --max-jobs and --retention
+var (
+	srvFlag  string
+	jobsFlag int
+	keepFlag time.Duration
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run ping jobs for other programs, over gRPC",
+	Long: `serve runs pinger as an agent, until killed: other programs start ping jobs on
+it over gRPC, stop them, and stream their results as they come, for on-demand
+reachability measurements from where the agent runs. The service is described
+in helpers/pinger.proto; it is served over HTTP/2 without TLS, on localhost
+unless --listen says otherwise: put it behind a proxy, or on a trusted network. Jobs send from -I / -S, with the TTL,
+TOS and --privileged of the flags, unless they ask for others, and resolve
+hostnames as -4 / -6 and the resolver flags ask. At most --max-jobs run at
+once, and jobs are kept for --retention once over. See api for the same
+over plain HTTP and JSON.`,
+	Args:    cobra.NoArgs,
+	PreRunE: validateJobFlags,
+	Example: `./pinger serve
+./pinger serve --listen :50051 -I eth0
+grpcurl -plaintext -proto pinger.proto -d '{"target": "example.com"}' localhost:50051 pinger.v1.Pinger/StartJob
+grpcurl -plaintext -proto pinger.proto -d '{"id": "<id>"}' localhost:50051 pinger.v1.Pinger/StreamResults`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.ServeGRPC(srvFlag, jobConfig())
+	},
+}
+
+// validateJobFlags checks the flags of the servers of ping jobs
+func validateJobFlags(cmd *cobra.Command, args []string) error {
+	if jobsFlag < 0 {
+		return fmt.Errorf("invalid --max-jobs %d: must be positive, or 0 for no limit", jobsFlag)
+	}
+	if keepFlag <= 0 {
+		return fmt.Errorf("invalid --retention %v: must be positive", keepFlag)
+	}
+	return nil
+}
+
+// jobConfig is how the servers of ping jobs run them, as the flags ask
+func jobConfig() helpers.JobConfig {
+	info := helpers.ICMPInfo{
+		Iface:      ifaceFlag,
+		Source:     srcFlag,
+		TOS:        tosValue,
+		ECN:        ecnFlag != "",
+		Privileged: privFlag,
+		PreferSrc:  preferSrc(),
+		DNSServer:  dnsServer(),
+	}
+	config := helpers.JobConfig{
+		Info4:     info,
+		Info6:     info,
+		Resolve:   resolveOptions(),
+		MaxJobs:   jobsFlag,
+		Retention: keepFlag,
+	}
+	config.Info4.TTL, config.Info6.TTL = ttlFor(false), ttlFor(true)
+	return config
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&srvFlag, "listen", "127.0.0.1:50051", "Address and port to serve gRPC on")
+	serveCmd.Flags().IntVar(&jobsFlag, "max-jobs", 16, "Jobs to run at once at most, 0 for no limit")
+	serveCmd.Flags().DurationVar(&keepFlag, "retention", time.Hour, "How long to keep jobs, and their results, once over")
+}
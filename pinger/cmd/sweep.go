@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	probesFlag       int
+	parallelFlag     int
+	sweepTimeoutFlag time.Duration
+	inventoryFlag    bool
+)
+
+// sweepCmd represents the sweep command
+var sweepCmd = &cobra.Command{
+	Use:   "sweep <prefix>",
+	Short: "Find the hosts alive in an address range",
+	Long: `sweep sends a few ICMP ECHO_REQUEST to every address of an IPv4 / IPv6 prefix,
+many addresses at a time, and lists the ones that replied with their best RTT.
+IPv4 network and broadcast addresses are skipped, and at most 65536 addresses
+are swept. -I, --privileged and --format apply as for ping, and -v adds the
+MAC address and vendor of hosts on the local network. --inventory lists the
+hosts that replied as a table of address, hostname (reverse DNS), MAC address,
+vendor and RTT: a light network inventory.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if probesFlag < 1 {
+			return fmt.Errorf("invalid --probes %d: must be at least 1", probesFlag)
+		}
+		if parallelFlag < 1 || parallelFlag > 1024 {
+			return fmt.Errorf("invalid --parallel %d: must be between 1 and 1024", parallelFlag)
+		}
+		if fmtFlag == helpers.AtlasJSON {
+			return fmt.Errorf("sweep has no --format %s: want text or json", fmtFlag)
+		}
+		return nil
+	},
+	Example: `./pinger sweep 192.168.1.0/24
+./pinger sweep --inventory --format json 192.168.1.0/24 > inventory.jsonl`,
+	Run: func(cmd *cobra.Command, args []string) {
+		helpers.SweepHandler(args[0], helpers.ICMPInfo{
+			Iface:      ifaceFlag,
+			TTL:        ttlFlag,
+			TOS:        tosValue,
+			Format:     fmtFlag,
+			RTT:        rttFormat(),
+			Privileged: privFlag,
+			Verbose:    verbFlag > 0,
+		}, helpers.SweepOptions{
+			Probes:   probesFlag,
+			Timeout:  sweepTimeoutFlag,
+			Parallel: parallelFlag,
+
+			Inventory: inventoryFlag,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sweepCmd)
+
+	sweepCmd.Flags().IntVarP(&probesFlag, "probes", "p", 2, "Number of probes per address")
+	sweepCmd.Flags().IntVar(&parallelFlag, "parallel", 256, "Number of addresses probed at the same time")
+	sweepCmd.Flags().BoolVar(&inventoryFlag, "inventory", false, "Name the hosts that replied by reverse DNS and their MAC address, in a table")
+	sweepCmd.Flags().DurationVarP(&sweepTimeoutFlag, "timeout", "w", time.Second, "Time to wait for each probe's reply")
+}
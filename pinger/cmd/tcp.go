@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// tcpCmd represents the tcp command
+var tcpCmd = &cobra.Command{
+	Use:   "tcp <host>:<port>",
+	Short: "Measure TCP connect latency, for networks that filter ICMP",
+	Long: `tcp opens (and closes) a TCP connection to a port of a network host once per
+second, and reports how long each handshake took, with the usual statistics.
+A refused connection still measures a round trip, and shows the host is up.
+Use [<IPv6 address>]:<port> for IPv6 literals. -4, -6, -I, -c and --format apply
+as for ping; no privileges are needed.`,
+	Args: cobra.ExactArgs(1),
+	Example: `./pinger tcp -c 4 nitk.ac.in:443
+./pinger tcp [::1]:22`,
+	Run: func(cmd *cobra.Command, args []string) {
+		host, port := splitHostPort(args[0], 0)
+
+		verified := resolveTarget(host)
+		isSelf, _ := helpers.IsLocalAddr(verified.Addr)
+
+		helpers.Exit(helpers.TCPHandler(helpers.ICMPInfo{
+			IP:         verified.Addr,
+			Iface:      ifaceFor(verified),
+			Source:     sourceFor(verified.Addr, verified.IsIPv6),
+			PreferSrc:  preferSrc(),
+			CNT:        probeCount(),
+			Format:     fmtFlag,
+			RTT:        rttFormat(),
+			Histogram:  histFlag,
+			Intervals:  ivlFlag,
+			Port:       port,
+			HostsFile:  verified.HostsFile,
+			NAT64:      verified.NAT64,
+			SelfTarget: isSelf,
+
+			SummaryEvery: sumFlag,
+		}, verified.IsIPv6))
+	},
+}
+
+// splitHostPort splits a "host:port" argument, or exits. Without a port,
+// *defaultPort* is used, unless it is 0.
+func splitHostPort(arg string, defaultPort int) (string, int) {
+	host, portStr, err := net.SplitHostPort(arg)
+	if err != nil {
+		if defaultPort == 0 {
+			slog.Error(err.Error())
+			os.Exit(helpers.ExitUsage)
+		}
+		return strings.Trim(arg, "[]"), defaultPort
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		slog.Error(fmt.Sprintf("invalid port %q: must be between 1 and 65535", portStr))
+		os.Exit(helpers.ExitUsage)
+	}
+	return host, port
+}
+
+func init() {
+	rootCmd.AddCommand(tcpCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxHopsFlag      int
+	queriesFlag      int
+	traceTimeoutFlag time.Duration
+)
+
+// traceCmd represents the trace command
+var traceCmd = &cobra.Command{
+	Use:   "trace <host>",
+	Short: "Print the route to a network host, traceroute style",
+	Long: `trace sends ICMP ECHO_REQUEST with an incrementing TTL / Hop Limit, and prints
+the router that answers each hop with a Time Exceeded, along with the RTTs.
+It stops when the destination replies, or is reported unreachable.
+With -v, the extensions routers add to Time Exceeded (RFC 4884) are printed
+after the RTT of the probe, like traceroute -e does: the MPLS label stack the
+probe carried, and the interface it came in by.
+-4, -6, -I and --format apply as for ping.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if maxHopsFlag < 1 || maxHopsFlag > 255 {
+			return fmt.Errorf("invalid --max-hops %d: must be between 1 and 255", maxHopsFlag)
+		}
+		if queriesFlag < 1 {
+			return fmt.Errorf("invalid --queries %d: must be at least 1", queriesFlag)
+		}
+		return nil
+	},
+	Example: `./pinger trace -q 1 -m 20 nitk.ac.in
+
+(needs a raw socket: run as root, or with CAP_NET_RAW)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		verified := resolveTarget(args[0])
+
+		helpers.TraceHandler(helpers.ICMPInfo{
+			IP:         verified.Addr,
+			Iface:      ifaceFor(verified),
+			Source:     sourceFor(verified.Addr, verified.IsIPv6),
+			PreferSrc:  preferSrc(),
+			TOS:        tosValue,
+			ECN:        ecnFlag != "",
+			Format:     fmtFlag,
+			RTT:        rttFormat(),
+			Privileged: true,
+			Verbose:    verbFlag > 0,
+			HostsFile:  verified.HostsFile,
+			NAT64:      verified.NAT64,
+		}, verified.IsIPv6, helpers.TraceOptions{
+			MaxHops: maxHopsFlag,
+			Queries: queriesFlag,
+			Timeout: traceTimeoutFlag,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+
+	traceCmd.Flags().IntVarP(&maxHopsFlag, "max-hops", "m", 30, "Give up after this many hops")
+	traceCmd.Flags().IntVarP(&queriesFlag, "queries", "q", 3, "Number of probes per hop")
+	traceCmd.Flags().DurationVarP(&traceTimeoutFlag, "timeout", "w", 2*time.Second, "Time to wait for each probe's reply")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:   "tui <host>...",
+	Short: "Ping hosts with a live full-screen view of their results",
+	Long: `tui pings every host at once, as ping does several, and shows the results in a
+full-screen view refreshed in place rather than as scrolling lines: a row per
+target with the last and average RTT, a gauge of the loss, the counts of
+probes and a sparkline of the last RTTs, blank where probes were lost. It runs
+until Ctrl + C, or for -c probes if given, then leaves the view for the usual
+statistics. -I, -S, -4 / -6 and the probe options apply as for ping.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if helpers.MachineFormat(fmtFlag) {
+			return fmt.Errorf("tui draws text: --format %s cannot be combined with it", fmtFlag)
+		}
+		if lineFlag {
+			return fmt.Errorf("tui shows the running statistics already: --statusline cannot be combined with it")
+		}
+		if err := validateFlags(args); err != nil {
+			return err
+		}
+		return helpers.SetTUI()
+	},
+	ValidArgsFunction: completeHosts,
+	Example: `./pinger tui 10.0.0.1 10.0.1.1 example.com
+./pinger tui -c 60 -I eth0 gateway.lan`,
+	Run: func(cmd *cobra.Command, args []string) {
+		multiPing(resolveTargets(args), probeCount())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flag of the udp subcommand
+var asymFlag bool
+
+// udpCmd represents the udp command
+var udpCmd = &cobra.Command{
+	Use:   "udp <host>[:<port>]",
+	Short: "Measure UDP round trips, for paths where ICMP Echo is blocked",
+	Long: `udp sends a datagram to a port of a network host once per second, and
+reports how long the answer took: the application's reply, if something listens
+(e.g. an echo service), or else the ICMP Port Unreachable from the host, like
+traceroute(8)'s UDP probes. Port Unreachables count as replies in the statistics.
+The port defaults to 33434. Use [<IPv6 address>]:<port> for IPv6 literals.
+-4, -6, -I, -c, -t and --format apply as for ping; no privileges are needed.
+
+With --asymmetric, the far end must run pinger responder (port 7047 by default):
+each round then times a small request for a small reply, a full-sized request for
+a small reply, and a small request for a full-sized reply, to estimate the
+upstream and downstream serialization delay and rate separately, as on DOCSIS or
+DSL links.`,
+	Args: cobra.ExactArgs(1),
+	Example: `./pinger udp -c 4 nitk.ac.in
+./pinger udp 192.168.1.1:7
+./pinger udp --asymmetric -c 20 192.168.1.1`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defaultPort := helpers.DefaultUDPPort
+		if asymFlag {
+			defaultPort = helpers.DefaultResponderPort
+		}
+		host, port := splitHostPort(args[0], defaultPort)
+
+		verified := resolveTarget(host)
+		isSelf, _ := helpers.IsLocalAddr(verified.Addr)
+
+		info := helpers.ICMPInfo{
+			IP:         verified.Addr,
+			Iface:      ifaceFor(verified),
+			Source:     sourceFor(verified.Addr, verified.IsIPv6),
+			PreferSrc:  preferSrc(),
+			TOS:        tosValue,
+			TTL:        ttlFor(verified.IsIPv6),
+			CNT:        probeCount(),
+			Format:     fmtFlag,
+			RTT:        rttFormat(),
+			Histogram:  histFlag,
+			Intervals:  ivlFlag,
+			Port:       port,
+			HostsFile:  verified.HostsFile,
+			NAT64:      verified.NAT64,
+			SelfTarget: isSelf,
+
+			SummaryEvery: sumFlag,
+		}
+		if asymFlag {
+			helpers.Exit(helpers.AsymmetricHandler(info, verified.IsIPv6))
+		}
+		helpers.Exit(helpers.UDPHandler(info, verified.IsIPv6))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(udpCmd)
+	udpCmd.Flags().BoolVar(&asymFlag, "asymmetric", false, "Measure upstream and downstream delay separately, against pinger responder")
+}
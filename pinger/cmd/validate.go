@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+)
+
+// flagConflicts lists every contradictory combination of flags and
+// destinations of a ping to *hosts*, so that they can all be fixed at once
+// rather than one run at a time
+func flagConflicts(hosts []string) []string {
+	var conflicts []string
+	conflict := func(format string, a ...any) {
+		conflicts = append(conflicts, fmt.Sprintf(format, a...))
+	}
+
+	if v4Flag && v6Flag {
+		conflict("-4 and -6 cannot both be given")
+	}
+	// Without this, --max-runtime would silently cut the run short of its count
+	if interval := helpers.RunInterval(); cntFlag > 0 && runtFlag > 0 && time.Duration(cntFlag) > runtFlag/interval {
+		conflict("-c %d, one every %v, takes longer than --max-runtime %v: lower -c, or raise --max-runtime", cntFlag, interval, runtFlag)
+	}
+
+	if failFlag != "" {
+		if err := helpers.ValidateForceFail(failFlag); err != nil {
+			conflict("%v", err)
+		}
+		if cmpFlag != "" {
+			conflict("--force-fail cannot be combined with --compare-path")
+		}
+	}
+	if bcastFlag && cmpFlag != "" {
+		conflict("-b cannot be combined with --compare-path")
+	}
+	if bcastFlag && v6Flag {
+		conflict("-b with -6: IPv6 has no broadcast, ping a multicast address such as ff02::1 instead")
+	}
+
+	if rrFlag && v6Flag {
+		conflict("-R with -6: IPv6 has no Record Route option")
+	}
+	if tsFlag != "" {
+		if _, err := helpers.ParseIPTimestamp(tsFlag); err != nil {
+			conflict("%v", err)
+		}
+		if rrFlag {
+			conflict("-R cannot be combined with --ip-timestamp: both options need all the room of the IPv4 header")
+		}
+		if v6Flag {
+			conflict("--ip-timestamp with -6: IPv6 has no Timestamp option")
+		}
+	}
+
+	if dnsFlag != "" {
+		if _, err := helpers.ParseDNSServer(dnsFlag); err != nil {
+			conflict("%v", err)
+		}
+	}
+	resolvers := 0
+	for _, flag := range []string{dnsFlag, dohFlag, dotFlag} {
+		if flag != "" {
+			resolvers++
+		}
+	}
+	if resolvers > 1 {
+		conflict("only one of --dns, --doh and --dot may be given")
+	}
+	if dohFlag != "" && !strings.HasPrefix(dohFlag, "https://") {
+		conflict("invalid --doh %q: want an https:// URL, e.g. https://dns.quad9.net/dns-query", dohFlag)
+	}
+
+	src := net.ParseIP(srcFlag)
+	if srcFlag != "" {
+		switch {
+		case src == nil:
+			conflict("-S %s: not an IP address", srcFlag)
+		case src.To4() != nil && v6Flag:
+			conflict("-6 with IPv4 source address %s", srcFlag)
+		case src.To4() == nil && v4Flag:
+			conflict("-4 with IPv6 source address %s", srcFlag)
+		}
+		if cmpFlag != "" {
+			conflict("-S cannot be combined with --compare-path, which sends from each interface's own address")
+		}
+	}
+
+	if ctosFlag != "" {
+		if tos, err := helpers.ParseTOS(ctosFlag); err != nil {
+			conflict("%v", err)
+		} else if tos&^0x03 == tosValue&^0x03 {
+			conflict("--compare-tos %s: same marking as -Q, nothing to compare", ctosFlag)
+		}
+		if cmpFlag != "" {
+			conflict("--compare-tos cannot be combined with --compare-path")
+		}
+		if failFlag != "" {
+			conflict("--compare-tos cannot be combined with --force-fail")
+		}
+		if bcastFlag {
+			conflict("--compare-tos cannot be combined with -b")
+		}
+	}
+
+	if ctlFlag != "" && (cmpFlag != "" || ctosFlag != "" || failFlag != "") {
+		conflict("--control only changes plain pings, not --compare-path, --compare-tos or --force-fail")
+	}
+
+	if strcFlag && (cmpFlag != "" || ctosFlag != "" || failFlag != "" || bcastFlag) {
+		conflict("--strict checks plain pings, not --compare-path, --compare-tos, --force-fail or -b")
+	}
+
+	if hwtsFlag {
+		if cmpFlag != "" || ctosFlag != "" || failFlag != "" {
+			conflict("--hw-timestamps times plain pings, not --compare-path, --compare-tos or --force-fail")
+		}
+		zoned := len(hosts) == 1 && strings.Contains(hosts[0], "%")
+		if ifaceFlag == "" && !zoned {
+			conflict("--hw-timestamps needs the NIC that stamps the packets: give it with -I")
+		}
+	}
+
+	if paceFlag != "" {
+		if _, err := helpers.ParseAutoInterval(paceFlag); err != nil {
+			conflict("%v", err)
+		}
+		if len(hosts) < 2 {
+			conflict("--auto-interval shares its budget between several destinations, not 1")
+		}
+	}
+
+	for _, single := range []struct {
+		name string
+		set  bool
+	}{{"--force-fail", failFlag != ""}, {"--compare-path", cmpFlag != ""}, {"-b", bcastFlag}, {"--compare-tos", ctosFlag != ""}, {"--control", ctlFlag != ""}, {"--hw-timestamps", hwtsFlag}} {
+		switch {
+		case !single.set:
+		case len(hosts) > 1:
+			conflict("%s takes a single destination, not %d", single.name, len(hosts))
+		case allFlag:
+			conflict("%s takes a single destination, not all the addresses of --all", single.name)
+		}
+	}
+
+	// -I must have an address of the family asked for
+	if ifaceFlag != "" && v4Flag != v6Flag {
+		if err := helpers.CheckIfaceFamily(ifaceFlag, v6Flag); err != nil {
+			conflict("%v", err)
+		}
+	}
+
+	// Literal addresses must be of the family asked for
+	for _, host := range hosts {
+		addr, zone := helpers.SplitZone(host)
+		ip := net.ParseIP(addr)
+		if ip != nil && zone != "" && ifaceFlag != "" && zone != ifaceFlag {
+			conflict("-I %s with %s: the zone names another interface", ifaceFlag, host)
+		}
+		switch {
+		case ip == nil:
+		case ip.To4() != nil && v6Flag && nat64Flag == "":
+			conflict("-6 with IPv4 address %s (add --nat64 to ping it through NAT64)", host)
+		case ip.To4() == nil && v4Flag:
+			conflict("-4 with IPv6 address %s", host)
+		case ip.To4() == nil && bcastFlag:
+			conflict("-b with IPv6 address %s: IPv6 has no broadcast", host)
+		case ip.To4() == nil && rrFlag:
+			conflict("-R with IPv6 address %s: IPv6 has no Record Route option", host)
+		case ip.To4() == nil && tsFlag != "":
+			conflict("--ip-timestamp with IPv6 address %s: IPv6 has no Timestamp option", host)
+		case src != nil && (src.To4() == nil) != (ip.To4() == nil):
+			conflict("-S %s with %s: not of the same IP family", srcFlag, host)
+		}
+	}
+
+	return conflicts
+}
+
+// validateFlags reports all the conflicts among the flags, if any, as one error
+func validateFlags(hosts []string) error {
+	conflicts := flagConflicts(hosts)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("conflicting options:\n  - %s", strings.Join(conflicts, "\n  - "))
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+	"github.com/spf13/cobra"
+)
+
+// Flag of the web subcommand
+var webFlag string
+
+// webCmd represents the web command
+var webCmd = &cobra.Command{
+	Use:   "web <host>...",
+	Short: "Ping hosts, and serve a live web dashboard of their results",
+	Long: `web pings every host at once, as ping does several, and serves a web page on
+--listen with a live RTT chart and the loss of every target, for a wall
+monitor in the NOC: results are streamed to it as Server-Sent Events, at
+/events, and pages opened mid-run start with the last probes. The results are
+printed as usual too. It runs until Ctrl + C, or for -c probes if given.
+The dashboard has no authentication: it is served on localhost unless
+--listen says otherwise, e.g. --listen :8080 for every address of the host.
+-I, -S, -4 / -6, --format and the probe options apply as for ping.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateFlags(args); err != nil {
+			return err
+		}
+		url, err := helpers.ServeDashboard(webFlag)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Dashboard on %s\n", url)
+		return nil
+	},
+	ValidArgsFunction: completeHosts,
+	Example: `./pinger web 10.0.0.1 10.0.1.1 example.com
+./pinger web --listen :8080 -I eth0 gateway.lan > /dev/null`,
+	Run: func(cmd *cobra.Command, args []string) {
+		multiPing(resolveTargets(args), probeCount())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(webCmd)
+
+	webCmd.Flags().StringVar(&webFlag, "listen", "127.0.0.1:8080", "Address and port to serve the dashboard on")
+}
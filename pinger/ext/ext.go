@@ -0,0 +1,112 @@
+// Package ext is the stable API pinger extensions are written against: custom
+// probe types, which measure a target in a way pinger does not know, and
+// output sinks, which receive everything pinger observes, e.g. to forward it
+// to a database.
+//
+// An extension is a Go plugin (go build -buildmode=plugin) exporting:
+//
+//	var PingerAPI = ext.APIVersion
+//	func Register(r ext.Registry)
+//
+// pinger loads it with --plugin <file.so>, checks PingerAPI, and calls
+// Register, which adds the extension's probes and sinks to the registry by
+// name. A probe is then run with "pinger probe <name>[:<config>] <target>", and
+// a sink attached to any ping with --sink <name>[:<config>].
+//
+// Everything in this package is covered by APIVersion: fields and methods are
+// only ever added to it in a compatible way, and APIVersion is bumped when
+// they cannot be. Go plugins must still be built with the same Go toolchain,
+// and the same versions of the packages they share with pinger, as pinger
+// itself: build them in a module requiring the pinger release they are for.
+package ext
+
+import "time"
+
+// APIVersion is the version of this API. Plugins export it as PingerAPI, and
+// are refused by a pinger of another version.
+const APIVersion = 1
+
+// Reasons why a probe got no answer, for Result.Reason. They are those of
+// pinger's JSON output; others may be used, and are taken as an unexpected
+// answer.
+const (
+	ReasonNone        = ""                 // the probe was answered
+	ReasonTimeout     = "timeout"          // no answer in time
+	ReasonRefused     = "refused"          // the target is up, but refused the probe
+	ReasonUnreachable = "unreachable_host" // the target could not be reached
+	ReasonSendError   = "send_error"       // the probe could not be sent
+	ReasonRecvError   = "recv_error"       // the answer could not be read
+)
+
+// Result is the outcome of one probe, as a Prober returns it and a Sink
+// receives it
+type Result struct {
+	Transport string        // what probed: "" for ICMP Echo, "tcp", "http"... or the name of a plugin probe
+	Target    string        // what was probed
+	Seq       int           // number of the probe, from 0
+	Peer      string        // who answered, if anyone
+	RTT       time.Duration // how long the answer took
+	Reason    string        // why there is no answer: ReasonNone if there is one
+	Detail    string        // human readable detail of a Reason
+	Time      time.Time     // when the outcome was observed
+	Duplicate bool          // another answer to an answered probe, which is not counted
+
+	Fields map[string]string // anything else worth keeping, e.g. "status"
+}
+
+// Event is something worth noting that is not the outcome of a probe, e.g.
+// the local address changing mid-run
+type Event struct {
+	Time    time.Time
+	Kind    string            // machine-readable, snake_case
+	Message string            // human readable
+	Fields  map[string]string // details
+}
+
+// Summary is the statistics of the probes of a target, at the end of a run
+type Summary struct {
+	Target      string
+	Transmitted int
+	Received    int
+	Errors      int
+	Loss        float64       // percentage of probes without an answer
+	Min         time.Duration // RTT statistics, zero without answers
+	Avg         time.Duration
+	Max         time.Duration
+	StdDev      time.Duration
+	Jitter      time.Duration // RFC 3550 interarrival jitter, zero without two answers
+	EWMA        time.Duration // moving average of RTTs, weighting recent answers: each counts for 1/8
+}
+
+// Sink receives what pinger observes, in order, as it does. Its methods are
+// never called concurrently. Close is called once, at the end of the run.
+type Sink interface {
+	Result(res Result)
+	Event(ev Event)
+	Summary(sum Summary)
+	Close() error
+}
+
+// Prober is a custom probe type, bound to one target. Probe is called once
+// per second, and has to return within *timeout*, with ReasonTimeout if
+// there is no answer by then. Close is called once, at the end of the run.
+type Prober interface {
+	Probe(seq int, timeout time.Duration) Result
+	Close() error
+}
+
+// SinkFactory opens a sink, as --sink <name>:<config> asks. *config* is ""
+// without a colon.
+type SinkFactory func(config string) (Sink, error)
+
+// ProbeFactory binds a probe to *target*, as "pinger probe <name>:<config>
+// <target>" asks. *config* is "" without a colon.
+type ProbeFactory func(target, config string) (Prober, error)
+
+// Registry is what a plugin's Register function adds its extensions to.
+// Names are lowercase words; registering a name taken already is an error
+// pinger reports when loading the plugin.
+type Registry interface {
+	RegisterSink(name string, open SinkFactory)
+	RegisterProbe(name string, open ProbeFactory)
+}
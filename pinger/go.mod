@@ -1,16 +1,15 @@
 module github.com/Vishy70/custom-ping-utility-Vishy70/pinger
 
-go 1.23.2
+go 1.24
 
 replace github.com/Vishy70/custom-ping-utility-Vishy70/pinger => ../pinger
 
 require (
 	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
 	golang.org/x/net v0.37.0
+	golang.org/x/sys v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.6 // indirect
-	golang.org/x/sys v0.31.0 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
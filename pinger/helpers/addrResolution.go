@@ -1,9 +1,12 @@
 package helpers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -14,13 +17,25 @@ import (
 type AddrOptions struct {
 	V4 bool // use IPv4
 	V6 bool // use IPv6
+
+	HostsFile string // hosts(5) file consulted before the system's, and DNS
+	DNSServer string // resolver ("host:port") to query instead of the system's, see ParseDNSServer
+	DoH       string // DNS-over-HTTPS server URL to query instead, falling back to the system's resolver
+	DoT       string // DNS-over-TLS server ("host[:port]") to query instead, falling back likewise
 }
 
 // UnMarshalledAddr is the type returned after preprocessing the user - given
 // hostname or address.
 type UnMarshalledAddr struct {
-	Addr   string // address for pinger to use
-	IsIPv6 bool   // protocol used: default is IPv4!
+	Addr   string   // address for pinger to use
+	IsIPv6 bool     // protocol used: default is IPv4!
+	CNAMEs []string // names of the host, from the one given to the canonical one, if asked for: see CNAMEChain
+	Zone   string   // interface named by the zone of an IPv6 address like fe80::1%eth0
+
+	HostsFile string // hosts file Addr was taken from, instead of DNS
+	Fallback  error  // why the DoH / DoT lookup failed, if the system's resolver answered instead
+
+	NAT64 *NAT64Mapping // how Addr was synthesized from an IPv4 address, see SynthesizeNAT64
 }
 
 // UnMarshalledAddr setter function.
@@ -32,7 +47,8 @@ func (setAddr *UnMarshalledAddr) set(addr string, isIPv6 bool) {
 // Validate an IPv6 address
 //
 // valIPv6 validates a colon - delimited string to be IPv6 or not.
-// It's a simple wrapper, using package net's ParseIP() and To16() methods.
+// It's a simple wrapper, using package net's ParseIP() and To4() methods:
+// To16() succeeds for IPv4 addresses too, so it cannot tell them apart.
 func valIPv6(addr string) (bool, error) {
 	ip := net.ParseIP(addr)
 
@@ -40,7 +56,7 @@ func valIPv6(addr string) (bool, error) {
 		return false, fmt.Errorf("%v is not a valid IP address", addr)
 	}
 
-	if ip.To16() != nil {
+	if ip.To4() == nil {
 		return true, nil
 	}
 
@@ -67,6 +83,31 @@ func valIPv4(addr string) (bool, error) {
 
 //TODO: reduce code duplication in above 2 functions
 
+// SplitZone splits the zone, if any, off an IPv6 address like fe80::1%eth0
+func SplitZone(host string) (string, string) {
+	addr, zone, _ := strings.Cut(host, "%")
+	return addr, zone
+}
+
+// Find the interface of a zone
+//
+// zoneInterface is the name of the interface *zone* stands for: its name,
+// or its index, as in fe80::1%2.
+func zoneInterface(zone string) (string, error) {
+	if index, err := strconv.Atoi(zone); err == nil {
+		iface, err := net.InterfaceByIndex(index)
+		if err != nil {
+			return "", fmt.Errorf("zone %v: no interface with index %d", zone, index)
+		}
+		return iface.Name, nil
+	}
+
+	if _, err := net.InterfaceByName(zone); err != nil {
+		return "", fmt.Errorf("zone %v: no such interface", zone)
+	}
+	return zone, nil
+}
+
 // Check if hostname follows RFC 1123-ish format
 //
 // validateHostname sees if a given *host* string is a plausible domain name.
@@ -90,11 +131,13 @@ func validateHostname(host string) bool {
 // Resolve a hostname using the local DNS resolver
 //
 // HostToAddr utilizes package net's LookupHost() method, to
-// to determine an IP address for the given *host*.
+// to determine an IP address for the given *host*, from options.DNSServer
+// if set.
 // *options* specify whether to choose an IPv4 or IPv6 address.
 // The default is Ipv4, different from normal ping.
 func HostToAddr(host string, options AddrOptions) (UnMarshalledAddr, error) {
 	var addr UnMarshalledAddr
+	redactName(host)
 
 	//TODO: Convert to error? Currently exiting...
 	if options.V4 && options.V6 {
@@ -102,8 +145,7 @@ func HostToAddr(host string, options AddrOptions) (UnMarshalledAddr, error) {
 		return addr, fmt.Errorf("only one -4 or -6 option may be specified")
 	}
 
-	listOfIPs, err := net.LookupHost(host)
-
+	listOfIPs, err := addr.lookup(host, options)
 	if err != nil {
 		return UnMarshalledAddr{}, err
 	}
@@ -130,6 +172,97 @@ func HostToAddr(host string, options AddrOptions) (UnMarshalledAddr, error) {
 	return UnMarshalledAddr{}, fmt.Errorf("could not resolve hostname %v. Please ensure a valid hostname is used", host)
 }
 
+// Resolve every address of a hostname
+//
+// AllAddrs is every address *host* resolves to, in resolver order, of the
+// family -4 / -6 in *options* ask for, or of both if neither. A literal address
+// resolves to itself.
+func AllAddrs(host string, options AddrOptions) ([]UnMarshalledAddr, error) {
+	redactName(host)
+	if !validateHostname(host) {
+		addr, err := AddrResolution(host, options)
+		if err != nil {
+			return nil, err
+		}
+		return []UnMarshalledAddr{addr}, nil
+	}
+
+	var meta UnMarshalledAddr
+	listOfIPs, err := meta.lookup(host, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []UnMarshalledAddr
+	for _, ip := range listOfIPs {
+		isIPv6, _ := valIPv6(ip)
+		if (options.V4 && isIPv6) || (options.V6 && !isIPv6) {
+			continue
+		}
+		addr := meta
+		addr.set(ip, isIPv6)
+		addrs = append(addrs, addr)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("could not resolve hostname %v. Please ensure a valid hostname is used", host)
+	}
+	return addrs, nil
+}
+
+// Find the addresses of a hostname
+//
+// lookup is the addresses of *host*, from the hosts files if they name it,
+// or else from the resolver of *options*. It notes where they came from
+// in *addr*.
+func (addr *UnMarshalledAddr) lookup(host string, options AddrOptions) ([]string, error) {
+	// Hosts files override DNS: say so, as they are easily forgotten
+	listOfIPs, hostsFile, err := hostsOverride(host, options.HostsFile)
+	if err != nil {
+		return nil, err
+	}
+	addr.HostsFile = hostsFile
+	if len(listOfIPs) != 0 {
+		return listOfIPs, nil
+	}
+
+	listOfIPs, addr.Fallback, err = lookupHost(host, options)
+	return listOfIPs, err
+}
+
+// Look a hostname up with the resolver of *options*
+//
+// lookupHost asks the DoH or DoT server of *options*, if any, for the addresses
+// of *host*; should that fail, the system's resolver answers, and *fallback*
+// says why. Otherwise options.DNSServer, or the system's resolver, answers.
+func lookupHost(host string, options AddrOptions) (addrs []string, fallback error, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+
+	switch {
+	case options.DoH != "":
+		addrs, fallback = dohLookupHost(ctx, options.DoH, host)
+	case options.DoT != "":
+		addrs, fallback = dotResolver(options.DoT).LookupHost(ctx, host)
+		// Which server failed, rather than the one of resolv.conf the resolver names
+		var dnsErr *net.DNSError
+		if errors.As(fallback, &dnsErr) {
+			fallback = fmt.Errorf("lookup %s on %s: %s", host, options.DoT, dnsErr.Err)
+		}
+	default:
+		addrs, err = lookupResolver(options.DNSServer).LookupHost(context.Background(), host)
+		return addrs, nil, err
+	}
+
+	if fallback == nil {
+		return addrs, nil, nil
+	}
+	if addrs, err = net.LookupHost(host); err != nil {
+		return nil, fallback, fmt.Errorf("%v, then %v", fallback, err)
+	}
+	return addrs, fallback, nil
+}
+
 // Resolve a *host* string to an appropriate Internet Protocol Address
 //
 // Determine if *host* string is a domain name or IP address
@@ -138,6 +271,24 @@ func HostToAddr(host string, options AddrOptions) (UnMarshalledAddr, error) {
 func AddrResolution(host string, options AddrOptions) (UnMarshalledAddr, error) {
 	var addr UnMarshalledAddr
 
+	// A zone picks the interface of a (link-local) IPv6 address
+	if host, zone := SplitZone(host); zone != "" {
+		if isIPv6, err := valIPv6(host); err != nil || !isIPv6 {
+			return addr, fmt.Errorf("%v: only IPv6 addresses take a %%zone", host+"%"+zone)
+		}
+		if options.V4 {
+			return addr, fmt.Errorf("option -4 specified does not match given IP: %v", host)
+		}
+
+		iface, err := zoneInterface(zone)
+		if err != nil {
+			return addr, err
+		}
+		addr.set(host, true)
+		addr.Zone = iface
+		return addr, nil
+	}
+
 	if validateHostname(host) {
 		finalAddr, err := HostToAddr(host, options)
 
@@ -150,7 +301,8 @@ func AddrResolution(host string, options AddrOptions) (UnMarshalledAddr, error)
 		return addr, err
 	}
 
-	if options.V6 && isIPv6 {
+	// An IPv6 literal can only be pinged over IPv6, -6 or not
+	if isIPv6 && !options.V4 {
 		addr.set(host, true)
 		return addr, nil
 	}
@@ -167,12 +319,40 @@ func AddrResolution(host string, options AddrOptions) (UnMarshalledAddr, error)
 		return addr, nil
 	}
 
-	if options.V6 != isIPv6 {
-		return addr, fmt.Errorf("option -6 specified does not match given IP: %v", host)
-	} else if options.V4 != isIPv4 {
+	if options.V4 != isIPv4 {
 		return addr, fmt.Errorf("option -4 specified does not match given IP: %v", host)
+	} else if options.V6 != isIPv6 {
+		return addr, fmt.Errorf("option -6 specified does not match given IP: %v", host)
 	} else {
 		return addr, fmt.Errorf("warning: an unexpected error occurred")
 	}
 
 }
+
+// Check if an address belongs to this host
+//
+// IsLocalAddr reports whether *addr* is a loopback address, or assigned to one
+// of the host's interfaces. Pinging such an address never leaves the host.
+func IsLocalAddr(addr string) (bool, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false, fmt.Errorf("%v is not a valid IP address", addr)
+	}
+
+	if ip.IsLoopback() {
+		return true, nil
+	}
+
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, err
+	}
+
+	for _, ifaceAddr := range ifaceAddrs {
+		if ipnet, ok := ifaceAddr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
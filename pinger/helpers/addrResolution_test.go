@@ -0,0 +1,174 @@
+package helpers
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testHostsFile writes a hosts(5) file for the tests to resolve with, without DNS
+func testHostsFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts")
+	hosts := "# test hosts\n" +
+		"192.0.2.10 both.test\n" +
+		"2001:db8::10 both.test\n" +
+		"2001:db8::20 v6only.test\n" +
+		"192.0.2.30 v4only.test\n"
+	if err := os.WriteFile(path, []byte(hosts), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loopbackName is the name of a loopback interface, for zones: lo on Linux, lo0 elsewhere
+func loopbackName(t *testing.T) (string, int) {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name, iface.Index
+		}
+	}
+	t.Skip("no loopback interface")
+	return "", 0
+}
+
+func TestAddrResolutionLiterals(t *testing.T) {
+	for _, tc := range []struct {
+		host       string
+		options    AddrOptions
+		addr       string
+		ipv6       bool
+		errPattern string
+	}{
+		{host: "192.0.2.1", addr: "192.0.2.1"},
+		{host: "192.0.2.1", options: AddrOptions{V4: true}, addr: "192.0.2.1"},
+		{host: "2001:db8::1", addr: "2001:db8::1", ipv6: true},
+		{host: "2001:db8::1", options: AddrOptions{V6: true}, addr: "2001:db8::1", ipv6: true},
+		{host: "::ffff:192.0.2.1", addr: "::ffff:192.0.2.1"}, // IPv4-mapped: pinged over IPv4
+		{host: "2001:db8::1", options: AddrOptions{V4: true}, errPattern: "-4"},
+		{host: "192.0.2.256", errPattern: "not a valid IP address"},
+		{host: "2001:db8:::1", errPattern: "not a valid IP address"},
+		{host: "192.0.2.1%eth0", errPattern: "only IPv6 addresses take a %zone"},
+		{host: "fe80::1%no-such-iface0", errPattern: "no such interface"},
+	} {
+		addr, err := AddrResolution(tc.host, tc.options)
+		switch {
+		case tc.errPattern != "" && (err == nil || !strings.Contains(err.Error(), tc.errPattern)):
+			t.Errorf("%s %+v: %+v, %v, want an error with %q", tc.host, tc.options, addr, err, tc.errPattern)
+		case tc.errPattern == "" && err != nil:
+			t.Errorf("%s %+v: %v", tc.host, tc.options, err)
+		case tc.errPattern == "" && (addr.Addr != tc.addr || addr.IsIPv6 != tc.ipv6):
+			t.Errorf("%s %+v: %s, IPv6: %v, want %s, IPv6: %v", tc.host, tc.options, addr.Addr, addr.IsIPv6, tc.addr, tc.ipv6)
+		}
+	}
+}
+
+func TestAddrResolutionZone(t *testing.T) {
+	name, index := loopbackName(t)
+
+	for _, zone := range []string{name, strconv.Itoa(index)} {
+		addr, err := AddrResolution("fe80::1%"+zone, AddrOptions{})
+		if err != nil {
+			t.Fatalf("fe80::1%%%s: %v", zone, err)
+		}
+		if addr.Addr != "fe80::1" || !addr.IsIPv6 || addr.Zone != name {
+			t.Errorf("fe80::1%%%s: %+v, want fe80::1 over IPv6 through %s", zone, addr, name)
+		}
+	}
+
+	if _, err := AddrResolution("fe80::1%"+name, AddrOptions{V4: true}); err == nil {
+		t.Errorf("fe80::1%%%s with -4: no error", name)
+	}
+}
+
+func TestAddrResolutionHostsFile(t *testing.T) {
+	hosts := testHostsFile(t)
+
+	for _, tc := range []struct {
+		host    string
+		options AddrOptions
+		addr    string
+		ipv6    bool
+		fails   bool
+	}{
+		{host: "both.test", addr: "192.0.2.10"},
+		{host: "both.test", options: AddrOptions{V4: true}, addr: "192.0.2.10"},
+		{host: "both.test", options: AddrOptions{V6: true}, addr: "2001:db8::10", ipv6: true},
+		{host: "v6only.test", addr: "2001:db8::20", ipv6: true},
+		{host: "v6only.test", options: AddrOptions{V4: true}, fails: true},
+		{host: "v4only.test", options: AddrOptions{V6: true}, fails: true},
+		{host: "both.test", options: AddrOptions{V4: true, V6: true}, fails: true},
+	} {
+		tc.options.HostsFile = hosts
+		addr, err := AddrResolution(tc.host, tc.options)
+		switch {
+		case tc.fails && err == nil:
+			t.Errorf("%s -4 %v -6 %v: %+v, want an error", tc.host, tc.options.V4, tc.options.V6, addr)
+		case !tc.fails && err != nil:
+			t.Errorf("%s -4 %v -6 %v: %v", tc.host, tc.options.V4, tc.options.V6, err)
+		case !tc.fails && (addr.Addr != tc.addr || addr.IsIPv6 != tc.ipv6 || addr.HostsFile != hosts):
+			t.Errorf("%s -4 %v -6 %v: %+v, want %s, IPv6: %v, from %s", tc.host, tc.options.V4, tc.options.V6, addr, tc.addr, tc.ipv6, hosts)
+		}
+	}
+}
+
+func TestAllAddrs(t *testing.T) {
+	hosts := testHostsFile(t)
+
+	for _, tc := range []struct {
+		options AddrOptions
+		want    []string
+	}{
+		{AddrOptions{}, []string{"192.0.2.10", "2001:db8::10"}},
+		{AddrOptions{V4: true}, []string{"192.0.2.10"}},
+		{AddrOptions{V6: true}, []string{"2001:db8::10"}},
+	} {
+		tc.options.HostsFile = hosts
+		addrs, err := AllAddrs("both.test", tc.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, addr := range addrs {
+			if ipv6, _ := valIPv6(addr.Addr); ipv6 != addr.IsIPv6 {
+				t.Errorf("%s: IPv6 %v", addr.Addr, addr.IsIPv6)
+			}
+			got = append(got, addr.Addr)
+		}
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("both.test -4 %v -6 %v: %v, want %v", tc.options.V4, tc.options.V6, got, tc.want)
+		}
+	}
+
+	// A literal resolves to itself
+	if addrs, err := AllAddrs("2001:db8::1", AddrOptions{}); err != nil || len(addrs) != 1 || addrs[0].Addr != "2001:db8::1" {
+		t.Errorf("2001:db8::1: %+v, %v, want itself", addrs, err)
+	}
+}
+
+func TestValidateHostname(t *testing.T) {
+	for host, want := range map[string]bool{
+		"example.com":    true,
+		"nitk.ac.in":     true,
+		"localhost":      true,
+		"a-b.example":    true,
+		"192.0.2.1":      false,
+		"2001:db8::1":    false,
+		"-a.example":     false,
+		"a..example":     false,
+		"example.com123": false,
+		"":               false,
+	} {
+		if got := validateHostname(host); got != want {
+			t.Errorf("validateHostname(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchLocalAddrs subscribes to rtnetlink address notifications, and annotates
+// the result stream when an address is added to or removed from *ifaceName*
+// (any interface but loopback, if empty). A DHCP renew or PPPoE reconnect
+// changing the local address often explains a short burst of loss.
+// The returned function stops watching.
+func watchLocalAddrs(ifaceName string, printer Printer) func() {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return func() {}
+	}
+
+	groups := uint32(unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}); err != nil {
+		unix.Close(fd)
+		return func() {}
+	}
+
+	// Wake up now and then, to notice being stopped
+	timeout := unix.NsecToTimeval(int64(500 * time.Millisecond))
+	unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout)
+
+	var stopped atomic.Bool
+
+	go func() {
+		defer unix.Close(fd)
+
+		buf := make([]byte, 1<<16)
+		for !stopped.Load() {
+			n, _, err := unix.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == unix.EAGAIN || err == unix.EINTR {
+					continue
+				}
+				return
+			}
+
+			messages, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			for _, m := range messages {
+				if note, ok := addrAnnotation(&m, ifaceName); ok && !stopped.Load() {
+					printer.Annotate(note)
+				}
+			}
+		}
+	}()
+
+	return func() { stopped.Store(true) }
+}
+
+// addrAnnotation turns an RTM_NEWADDR / RTM_DELADDR message about a watched
+// interface into an Annotation
+func addrAnnotation(m *syscall.NetlinkMessage, ifaceName string) (Annotation, bool) {
+	var verb, kind string
+
+	switch m.Header.Type {
+	case syscall.RTM_NEWADDR:
+		verb, kind = "added to", "local_address_added"
+	case syscall.RTM_DELADDR:
+		verb, kind = "removed from", "local_address_removed"
+	default:
+		return Annotation{}, false
+	}
+
+	if len(m.Data) < syscall.SizeofIfAddrmsg {
+		return Annotation{}, false
+	}
+	ifa := (*syscall.IfAddrmsg)(unsafe.Pointer(&m.Data[0]))
+
+	iface, err := net.InterfaceByIndex(int(ifa.Index))
+	if err != nil || iface.Flags&net.FlagLoopback != 0 || (ifaceName != "" && iface.Name != ifaceName) {
+		return Annotation{}, false
+	}
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return Annotation{}, false
+	}
+
+	// IFA_LOCAL is the local address on point-to-point links, IFA_ADDRESS elsewhere
+	var ip net.IP
+	for _, attr := range attrs {
+		switch attr.Attr.Type {
+		case syscall.IFA_LOCAL:
+			ip = net.IP(attr.Value)
+		case syscall.IFA_ADDRESS:
+			if ip == nil {
+				ip = net.IP(attr.Value)
+			}
+		}
+	}
+	if ip == nil {
+		return Annotation{}, false
+	}
+
+	addr := fmt.Sprintf("%s/%d", ip, ifa.Prefixlen)
+
+	return Annotation{
+		Time:    clk.Now(),
+		Kind:    kind,
+		Message: fmt.Sprintf("local address %s %s %s", addr, verb, iface.Name),
+		Fields:  map[string]string{"iface": iface.Name, "addr": addr},
+	}, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package helpers
+
+// watchLocalAddrs needs rtnetlink, so it only annotates local address changes on Linux
+func watchLocalAddrs(ifaceName string, printer Printer) func() {
+	return func() {}
+}
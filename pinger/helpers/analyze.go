@@ -0,0 +1,224 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// echoKey is what an Echo Request, and the Echo Reply or ICMP error it gets,
+// are matched by: one target is pinged by several runs at once with other
+// identifiers, and several targets by one run with the same identifier
+type echoKey struct {
+	src, dst netip.Addr
+	id, seq  int
+}
+
+// capturedProbe is an Echo Request of a capture, and what it got
+type capturedProbe struct {
+	key     echoKey
+	sent    time.Time
+	ttl     int
+	size    int
+	results []ProbeResult
+}
+
+// capturedTarget is a destination of the Echo Requests of a capture
+type capturedTarget struct {
+	probes int // how many of them it was sent
+	stats  *PingStats
+}
+
+// AnalyzeHandler reads the pcap capture *path*, of pinger, ping(8) or any
+// other ICMP Echo, and reports on it as on a run: the probes of every target
+// with what they got, Echo Replies or ICMP errors, matched by address,
+// identifier and sequence number, then the statistics. Echo Requests the
+// capture has no answer to are timeouts. It returns the exit status.
+func AnalyzeHandler(path string, info ICMPInfo) int {
+	probes, err := readCapture(path)
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+	if len(probes) == 0 {
+		slog.Error(fmt.Sprintf("%s: no ICMP Echo Requests in the capture", path))
+		return ExitNoReply
+	}
+
+	// Targets, in the order they are first pinged in
+	var ips []string
+	targets := make(map[string]*capturedTarget)
+	for _, probe := range probes {
+		ip := probe.key.dst.String()
+		if targets[ip] == nil {
+			ips = append(ips, ip)
+			stats := newPingStats(info)
+			targets[ip] = &capturedTarget{stats: &stats}
+		}
+		targets[ip].probes++
+	}
+
+	printer, err := newPrinter(info.Format, info.RTT, stdout, len(ips) > 1)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	// The header of a target says what its first probe was like
+	headed := make(map[string]bool)
+	for _, probe := range probes {
+		ip := probe.key.dst.String()
+		if headed[ip] {
+			continue
+		}
+		headed[ip] = true
+
+		targetInfo := info
+		targetInfo.IP, targetInfo.Source = ip, probe.key.src.String()
+		targetInfo.TTL, targetInfo.CNT = probe.ttl, targets[ip].probes
+		targetInfo.Privileged = true
+		startSize = probe.size
+		printer.Header(targetInfo)
+	}
+
+	for _, probe := range probes {
+		stats := targets[probe.key.dst.String()].stats
+		stats.transmitted++
+
+		if len(probe.results) == 0 {
+			probe.results = []ProbeResult{{
+				Target: probe.key.dst.String(),
+				Seq:    probe.key.seq,
+				Reason: ReasonTimeout,
+				Detail: "no reply in the capture",
+				Time:   probe.sent,
+			}}
+		}
+		for _, res := range probe.results {
+			stats.record(res)
+			printer.Probe(res)
+		}
+	}
+
+	stats := make([]*PingStats, len(ips))
+	for i, ip := range ips {
+		stats[i] = targets[ip].stats
+	}
+	if len(ips) == 1 {
+		printer.Summary(ips[0], stats[0])
+	} else {
+		printer.SummaryTable(ips, stats)
+	}
+
+	return exitStatus(stats...)
+}
+
+// readCapture is the Echo Requests of the pcap capture *path*, in the order
+// they were sent, with the outcomes the capture has of them
+func readCapture(path string) ([]*capturedProbe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading capture: %v", err)
+	}
+	defer f.Close()
+
+	pr, err := newPcapReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var probes []*capturedProbe
+	pending := make(map[echoKey]*capturedProbe)
+	for {
+		packet, err := pr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A capture cut short, e.g. by a full disk, is still worth the packets before
+			slog.Warn("capture ends early", "path", path, "err", err)
+			break
+		}
+
+		msg, ok := parseIPICMP(packet.data)
+		if !ok {
+			continue
+		}
+		typ, code, err := icmpHeader(msg.proto, msg.data)
+		if err != nil {
+			continue
+		}
+		id, seq, ok := echoHeader(msg.data)
+		if !ok {
+			continue
+		}
+
+		res := ProbeResult{
+			Peer:  msg.src.String(),
+			Bytes: len(msg.data),
+			TTL:   msg.ttl,
+			Time:  packet.time,
+		}
+
+		var key echoKey
+		switch typ {
+		case ipv4.ICMPTypeEcho, ipv6.ICMPTypeEchoRequest:
+			// A new probe: the sequence numbers of long runs wrap around
+			probe := &capturedProbe{
+				key:  echoKey{src: msg.src, dst: msg.dst, id: id, seq: seq},
+				sent: packet.time,
+				ttl:  msg.ttl,
+				size: len(msg.data),
+			}
+			probes = append(probes, probe)
+			pending[probe.key] = probe
+			continue
+
+		case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+			key = echoKey{src: msg.dst, dst: msg.src, id: id, seq: seq}
+
+		case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable,
+			ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+			// The error quotes the probe it is about, after its own header
+			quoted, id, seq, ok := quotedEcho(msg.data)
+			if !ok {
+				continue
+			}
+			key = echoKey{src: quoted.src, dst: quoted.dst, id: id, seq: seq}
+
+			if typ == ipv4.ICMPTypeTimeExceeded || typ == ipv6.ICMPTypeTimeExceeded {
+				res.Reason = ReasonTTLExceeded
+				if msg.proto == protocolICMP {
+					res.Detail = "Time To Live Exceeded"
+				} else {
+					res.Detail = "Hop Limit Exceeded"
+				}
+			} else {
+				res.Reason = unreachableReason(msg.proto, code)
+				res.Detail = unreachableMessage(res.Reason, code)
+			}
+			res.Extensions = icmpExtensions(msg.proto, msg.data)
+
+		default:
+			continue
+		}
+
+		probe := pending[key]
+		if probe == nil {
+			continue
+		}
+		res.Target, res.Seq = probe.key.dst.String(), probe.key.seq
+		res.RTT = float64(packet.time.Sub(probe.sent).Nanoseconds()) / 1e6
+		res.Duplicate = len(probe.results) > 0
+		probe.results = append(probe.results, res)
+	}
+
+	return probes, nil
+}
@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// linkProber sends address resolution requests for one on-link target,
+// and times the answers: ARP for IPv4, Neighbor Discovery for IPv6
+type linkProber interface {
+	probe(seq int, timeout time.Duration) ProbeResult
+	close() error
+}
+
+// onLinkInterface finds the interface *target* is directly connected through,
+// the named one if given, and our address on it in the same family
+func onLinkInterface(name string, target net.IP) (*net.Interface, net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isIPv6 := target.To4() == nil
+	for _, iface := range ifaces {
+		if (name != "" && iface.Name != name) || iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || (ipnet.IP.To4() == nil) != isIPv6 {
+				continue
+			}
+			// Link-local targets are on-link on whichever interface we were told
+			if ipnet.Contains(target) || (isIPv6 && target.IsLinkLocalUnicast() && name != "") {
+				return &iface, ipnet.IP, nil
+			}
+		}
+	}
+
+	if name != "" {
+		return nil, nil, fmt.Errorf("%v is not on a network directly connected to %s", target, name)
+	}
+	return nil, nil, fmt.Errorf("%v is not on a directly connected network (use -I for link-local addresses)", target)
+}
+
+// ndpProber sends Neighbor Solicitations over a raw ICMPv6 socket
+type ndpProber struct {
+	conn   *icmp.PacketConn
+	iface  *net.Interface
+	target net.IP
+}
+
+func newNDPProber(iface *net.Interface, target net.IP) (linkProber, error) {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, fmt.Errorf("error creating ICMPv6 connection (Neighbor Discovery needs root or CAP_NET_RAW): %v", err)
+	}
+
+	// RFC 4861: Neighbor Discovery messages are sent, and only accepted, with Hop Limit 255
+	p := conn.IPv6PacketConn()
+	p.SetMulticastHopLimit(255)
+	p.SetHopLimit(255)
+	p.SetMulticastInterface(iface)
+
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	p.SetICMPFilter(&filter)
+
+	return &ndpProber{conn: conn, iface: iface, target: target}, nil
+}
+
+// solicitedNodeAddr is the multicast group *target* listens on for Neighbor Solicitations
+func solicitedNodeAddr(target net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], target.To16()[13:])
+	return addr
+}
+
+func (p *ndpProber) probe(seq int, timeout time.Duration) ProbeResult {
+	res := ProbeResult{Transport: "ndp", Seq: seq, Peer: p.target.String()}
+
+	// Reserved, Target Address, and a Source Link-Layer Address option for the answer
+	body := make([]byte, 4, 4+16+8)
+	body = append(body, p.target.To16()...)
+	body = append(body, 1, 1)
+	body = append(body, p.iface.HardwareAddr...)
+
+	solicitation, err := (&icmp.Message{Type: ipv6.ICMPTypeNeighborSolicitation, Body: &icmp.RawBody{Data: body}}).Marshal(nil)
+	if err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	deadline := time.Now().Add(timeout)
+	p.conn.SetReadDeadline(deadline)
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	start := time.Now()
+	dst := &net.IPAddr{IP: solicitedNodeAddr(p.target), Zone: p.iface.Name}
+	if _, err := p.conn.WriteTo(solicitation, dst); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, _, err := p.conn.ReadFrom(reply)
+		res.Time = time.Now()
+		if err != nil {
+			res.Reason, res.Detail = readErrorResult(err, seq).Reason, err.Error()
+			return res
+		}
+
+		msg, err := icmp.ParseMessage(protocolICMPv6, reply[:n])
+		if err != nil || msg.Type != ipv6.ICMPTypeNeighborAdvertisement {
+			continue
+		}
+		raw, ok := msg.Body.(*icmp.RawBody)
+		if !ok || len(raw.Data) < 20 || !net.IP(raw.Data[4:20]).Equal(p.target) {
+			continue
+		}
+
+		res.RTT = elapsedMsSince(start)
+		res.HWAddr = targetLinkLayerAddr(raw.Data[20:])
+		return res
+	}
+}
+
+// targetLinkLayerAddr finds the Target Link-Layer Address option of a Neighbor Advertisement
+func targetLinkLayerAddr(options []byte) string {
+	for len(options) >= 8 {
+		length := int(options[1]) * 8
+		if length == 0 || length > len(options) {
+			break
+		}
+		if options[0] == 2 {
+			return net.HardwareAddr(bytes.Clone(options[2:length])).String()
+		}
+		options = options[length:]
+	}
+	return ""
+}
+
+func (p *ndpProber) close() error {
+	return p.conn.Close()
+}
+
+// ARPHandler checks that on-link info.IP is up by resolving its link layer
+// address once per second, with ARP requests (IPv4) or Neighbor Solicitations
+// (IPv6), and times the answers: hosts that drop ICMP still have to answer these.
+// It returns the exit status.
+func ARPHandler(info ICMPInfo, isIPv6 bool) int {
+	stats := newPingStats(info)
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	target := net.ParseIP(info.IP)
+	iface, src, err := onLinkInterface(info.Iface, target)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	var prober linkProber
+	if isIPv6 {
+		info.Transport = "ndp"
+		prober, err = newNDPProber(iface, target)
+	} else {
+		info.Transport = "arp"
+		prober, err = newARPProber(iface, src, target)
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer prober.close()
+
+	// Graceful termination, the usual ending, with Ctrl + C: the statistics
+	// are printed as at the end, once the request in flight got its answer
+	// or timed out
+	stop := onInterrupt(nil)
+
+	info.Iface = iface.Name
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() { printInterim(printer, info.IP, &stats) })
+	defer summarizeEvery(info.SummaryEvery, printer, []string{info.IP}, &stats)()
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
+		report(info, prober.probe(i, replyTimeout), &stats, printer)
+		stop.sleep(time.Second)
+	}
+
+	printer.Summary(info.IP, &stats)
+	return exitStatus(&stats)
+}
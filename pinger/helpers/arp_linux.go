@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// arpProber sends ARP requests over an AF_PACKET socket bound to the interface
+type arpProber struct {
+	fd     int
+	iface  *net.Interface
+	src    net.IP
+	target net.IP
+}
+
+// htons converts a 16 bit value to network byte order, as AF_PACKET wants its protocol
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+func newARPProber(iface *net.Interface, src net.IP, target net.IP) (linkProber, error) {
+	fd, err := openARPSocket(iface)
+	if err != nil {
+		return nil, err
+	}
+	return &arpProber{fd: fd, iface: iface, src: src.To4(), target: target.To4()}, nil
+}
+
+// openARPSocket opens an AF_PACKET socket for ARP, bound to *iface*
+func openARPSocket(iface *net.Interface) (int, error) {
+	if len(iface.HardwareAddr) != 6 {
+		return -1, fmt.Errorf("interface %s has no Ethernet address to ARP from", iface.Name)
+	}
+
+	// SOCK_DGRAM: the kernel adds and strips the Ethernet header
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return -1, fmt.Errorf("error creating ARP socket (ARP needs root or CAP_NET_RAW): %v", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: iface.Index}); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("error binding ARP socket to %s: %v", iface.Name, err)
+	}
+
+	return fd, nil
+}
+
+// arpRequest is an RFC 826 request: Ethernet / IPv4, "who has target? tell src"
+func arpRequest(iface *net.Interface, src, target net.IP) []byte {
+	request := make([]byte, 28)
+	binary.BigEndian.PutUint16(request[0:2], 1)
+	binary.BigEndian.PutUint16(request[2:4], unix.ETH_P_IP)
+	request[4], request[5] = 6, 4
+	binary.BigEndian.PutUint16(request[6:8], 1)
+	copy(request[8:14], iface.HardwareAddr)
+	copy(request[14:18], src.To4())
+	copy(request[24:28], target.To4())
+	return request
+}
+
+// arpBroadcast is where ARP requests go out to on *iface*
+func arpBroadcast(iface *net.Interface) *unix.SockaddrLinklayer {
+	broadcast := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ARP), Ifindex: iface.Index, Halen: 6}
+	copy(broadcast.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	return broadcast
+}
+
+// recvARPReply waits until *deadline* for an ARP reply on *fd*, and returns
+// its sender's link layer and IPv4 addresses
+func recvARPReply(fd int, deadline time.Time) (net.HardwareAddr, net.IP, error) {
+	reply := make([]byte, 1500)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, nil, errARPTimeout
+		}
+		tv := unix.NsecToTimeval(remaining.Nanoseconds())
+		unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+
+		n, _, err := unix.Recvfrom(fd, reply, 0)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if n < 28 || binary.BigEndian.Uint16(reply[6:8]) != 2 {
+			continue
+		}
+		return bytes.Clone(reply[8:14]), net.IP(bytes.Clone(reply[14:18])), nil
+	}
+}
+
+// errARPTimeout is recvARPReply's error when no reply came before the deadline
+var errARPTimeout = errors.New("no reply")
+
+func (p *arpProber) probe(seq int, timeout time.Duration) ProbeResult {
+	res := ProbeResult{Transport: "arp", Seq: seq, Peer: p.target.String()}
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	deadline := time.Now().Add(timeout)
+	start := time.Now()
+	if err := unix.Sendto(p.fd, arpRequest(p.iface, p.src, p.target), 0, arpBroadcast(p.iface)); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	for {
+		mac, sender, err := recvARPReply(p.fd, deadline)
+		res.Time = time.Now()
+		if err == errARPTimeout {
+			res.Reason, res.Detail = ReasonTimeout, err.Error()
+			return res
+		} else if err != nil {
+			res.Reason, res.Detail = ReasonRecvError, err.Error()
+			return res
+		}
+
+		// An ARP reply, from the target
+		if !sender.Equal(p.target) {
+			continue
+		}
+
+		res.RTT = elapsedMsSince(start)
+		res.HWAddr = mac.String()
+		return res
+	}
+}
+
+func (p *arpProber) close() error {
+	return unix.Close(p.fd)
+}
+
+// arpSweep sends an ARP request from *src* on *iface* to every one of
+// *targets*, and returns the first of them to answer from *mac* within
+// *timeout*, or nil if none did
+func arpSweep(iface *net.Interface, src net.IP, targets []net.IP, mac net.HardwareAddr, timeout time.Duration) (net.IP, error) {
+	fd, err := openARPSocket(iface)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	deadline := time.Now().Add(timeout)
+	found := make(chan net.IP, 1)
+	go func() {
+		defer close(found)
+		for {
+			sender, ip, err := recvARPReply(fd, deadline)
+			if err != nil {
+				return
+			}
+			if bytes.Equal(sender, mac) {
+				found <- ip
+				return
+			}
+		}
+	}()
+
+	broadcast := arpBroadcast(iface)
+	for _, target := range targets {
+		if err := unix.Sendto(fd, arpRequest(iface, src, target), 0, broadcast); err != nil {
+			return nil, fmt.Errorf("error sending ARP requests on %s: %v", iface.Name, err)
+		}
+	}
+
+	if ip, ok := <-found; ok {
+		return ip, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// newARPProber needs AF_PACKET sockets, so ARP requests can only be sent on Linux
+func newARPProber(iface *net.Interface, src net.IP, target net.IP) (linkProber, error) {
+	return nil, fmt.Errorf("ARP ping is only supported on Linux")
+}
+
+// arpSweep needs AF_PACKET sockets too
+func arpSweep(iface *net.Interface, src net.IP, targets []net.IP, mac net.HardwareAddr, timeout time.Duration) (net.IP, error) {
+	return nil, fmt.Errorf("ARP discovery is only supported on Linux")
+}
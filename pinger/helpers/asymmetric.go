@@ -0,0 +1,318 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"time"
+)
+
+// asymKind is one of the three probes of an --asymmetric round
+type asymKind int
+
+const (
+	asymBase asymKind = iota // small request, small reply
+	asymUp                   // big request, small reply
+	asymDown                 // small request, big reply
+)
+
+var asymKindNames = [3]string{"base", "up", "down"}
+
+// asymSmall is the size of small requests and replies: the responder header alone
+const asymSmall = responderHeaderLen
+
+// asymBig is the size of big requests and replies: as large as fits an
+// Ethernet frame unfragmented, so that serialization, not fragmentation, shows
+func asymBig(isIPv6 bool) int {
+	if isIPv6 {
+		return 1500 - 40 - 8
+	}
+	return 1500 - 20 - 8
+}
+
+// asymSizes are the request and reply sizes of a probe of *kind*
+func asymSizes(kind asymKind, isIPv6 bool) (request, reply int) {
+	switch kind {
+	case asymUp:
+		return asymBig(isIPv6), asymSmall
+	case asymDown:
+		return asymSmall, asymBig(isIPv6)
+	}
+	return asymSmall, asymSmall
+}
+
+// asymRound is one probe of each kind, sent back to back
+type asymRound struct {
+	seq    int
+	probes [3]ProbeResult
+}
+
+// asymProbe sends a *size* bytes request for a *replySize* bytes reply to the
+// responder over the connected *conn*, and times the reply
+func asymProbe(conn *net.UDPConn, seq, size, replySize int) ProbeResult {
+	res := ProbeResult{Transport: "udp", Seq: seq, Peer: conn.RemoteAddr().String()}
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	conn.SetReadDeadline(time.Now().Add(replyTimeout))
+
+	start := time.Now()
+	if _, err := conn.Write(responderRequest(seq, size, replySize)); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	reply := make([]byte, maxUDPPayload)
+	for {
+		n, err := conn.Read(reply)
+		res.RTT = elapsedMsSince(start)
+		res.Time = time.Now()
+
+		if err != nil {
+			res.Reason, res.Detail = udpReadError(err)
+			return res
+		}
+
+		// A late reply to an earlier probe: keep waiting for ours
+		if replySeq, _, ok := parseResponderHeader(reply[:n]); ok && replySeq != seq&0xffff {
+			continue
+		}
+
+		res.Bytes = n
+		return res
+	}
+}
+
+// checkResponder exits if *res* shows no pinger responder answers at the
+// target: a Port Unreachable, or a reply of the wrong size, from a plain echo
+// service
+func checkResponder(res ProbeResult, replySize int, target string) {
+	switch {
+	case res.Reason == ReasonPortUnreachable:
+		slog.Error(fmt.Sprintf("Nothing listens on %s: run pinger responder there", target))
+	case res.Reason == ReasonNone && res.Bytes != replySize:
+		slog.Error(fmt.Sprintf("%s answered %d bytes instead of %d: it is not a pinger responder", target, res.Bytes, replySize))
+	default:
+		return
+	}
+	Exit(1)
+}
+
+// AsymmetricHandler measures upstream and downstream serialization delay
+// separately, against a pinger responder at info.IP port info.Port: each
+// round times a small request for a small reply, a big request for a small
+// reply, and a small request for a big reply. What the big requests add to
+// the smallest RTT is the upstream's, what the big replies add the
+// downstream's, as on DOCSIS or DSL links. It returns the exit status.
+func AsymmetricHandler(info ICMPInfo, isIPv6 bool) int {
+	var (
+		stats  [3]PingStats
+		labels [3]string
+	)
+
+	printer, err := newAsymPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	conn, target, err := dialUDP(info, isIPv6)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	for kind := range labels {
+		request, reply := asymSizes(asymKind(kind), isIPv6)
+		labels[kind] = fmt.Sprintf("%s %s (%d -> %d bytes)", target, asymKindNames[kind], request, reply)
+	}
+
+	// Graceful termination, the usual ending, with Ctrl + C: the round in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() { conn.SetReadDeadline(time.Now()) })
+
+	printer.header(target, isIPv6)
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		round := asymRound{seq: i}
+
+		// Back to back, taking turns at going first, so that no kind gains
+		// from another warming up the path
+		for j := range 3 {
+			kind := (i + j) % 3
+			request, reply := asymSizes(asymKind(kind), isIPv6)
+			round.probes[kind] = asymProbe(conn, i, request, reply)
+			checkResponder(round.probes[kind], reply, target)
+		}
+		// Cut short by Ctrl + C: not a whole round
+		if stop.stopped() && slices.ContainsFunc(round.probes[:], func(res ProbeResult) bool { return res.Reason != ReasonNone }) {
+			break
+		}
+
+		for kind, res := range round.probes {
+			stats[kind].transmitted++
+			stats[kind].record(res)
+		}
+
+		printer.round(round)
+		stop.sleep(time.Second)
+	}
+
+	printer.summary(labels, &stats, isIPv6)
+	return exitStatus(&stats[0], &stats[1], &stats[2])
+}
+
+// asymMinDelay is the smallest extra delay taken as measured, in ms: below it,
+// clock resolution makes up the rate
+const asymMinDelay = 0.001
+
+// asymDelay is the extra delay of the big probes of one direction, from the
+// smallest RTTs, and the rate at which the link serializes the extra bytes
+type asymDelay struct {
+	Extra float64 `json:"extra_ms"`
+	Bytes int     `json:"extra_bytes"`
+	Mbps  float64 `json:"mbit_per_s,omitempty"` // 0 if there is no measurable delay
+}
+
+// asymDelays are the upstream and downstream delays. ok is false until all
+// three kinds have been answered.
+func asymDelays(stats *[3]PingStats, isIPv6 bool) (up, down asymDelay, ok bool) {
+	for kind := range stats {
+		if stats[kind].received == 0 {
+			return up, down, false
+		}
+	}
+
+	delay := func(kind asymKind) asymDelay {
+		d := asymDelay{Extra: stats[kind].min - stats[asymBase].min, Bytes: asymBig(isIPv6) - asymSmall}
+		if d.Extra >= asymMinDelay {
+			d.Mbps = float64(d.Bytes) * 8 / (d.Extra * 1000)
+		}
+		return d
+	}
+	return delay(asymUp), delay(asymDown), true
+}
+
+// asymPrinter renders --asymmetric rounds, as text or JSON lines
+type asymPrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+	rtt RTTFormat
+}
+
+func newAsymPrinter(format string, rtt RTTFormat, w io.Writer) (*asymPrinter, error) {
+	switch format {
+	case "", "text":
+		return &asymPrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &asymPrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *asymPrinter) header(target string, isIPv6 bool) {
+	target = redact(target)
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type  string `json:"type"`
+			IP    string `json:"target"`
+			Small int    `json:"small_bytes"`
+			Big   int    `json:"big_bytes"`
+		}{"asymmetric_start", target, asymSmall, asymBig(isIPv6)})
+		return
+	}
+
+	fmt.Fprintf(p.w, "ASYMMETRY %s: %d and %d bytes requests and replies\n", target, asymSmall, asymBig(isIPv6))
+}
+
+func (p *asymPrinter) round(round asymRound) {
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type string    `json:"type"`
+			Seq  int       `json:"seq"`
+			Base jsonProbe `json:"base"`
+			Up   jsonProbe `json:"up"`
+			Down jsonProbe `json:"down"`
+		}{"asymmetric", round.seq,
+			newJSONProbe(redactResult(round.probes[asymBase]), p.rtt),
+			newJSONProbe(redactResult(round.probes[asymUp]), p.rtt),
+			newJSONProbe(redactResult(round.probes[asymDown]), p.rtt)})
+		return
+	}
+
+	fmt.Fprintf(p.w, "seq=%d", round.seq)
+	for kind, res := range round.probes {
+		fmt.Fprintf(p.w, " %s=%s", asymKindNames[kind], p.asymRTT(res))
+	}
+	fmt.Fprintln(p.w)
+}
+
+// asymRTT is the RTT of a probe, or why there is none
+func (p *asymPrinter) asymRTT(res ProbeResult) string {
+	if res.Reason != ReasonNone {
+		return res.Reason.String()
+	}
+	return p.rtt.format(res.RTT)
+}
+
+func (p *asymPrinter) summary(labels [3]string, stats *[3]PingStats, isIPv6 bool) {
+	for kind := range labels {
+		labels[kind] = redact(labels[kind])
+	}
+	up, down, ok := asymDelays(stats, isIPv6)
+
+	if p.enc != nil {
+		out := struct {
+			Type       string      `json:"type"`
+			Base       jsonSummary `json:"base"`
+			Up         jsonSummary `json:"up"`
+			Down       jsonSummary `json:"down"`
+			Upstream   *asymDelay  `json:"upstream,omitempty"`
+			Downstream *asymDelay  `json:"downstream,omitempty"`
+		}{
+			Type: "asymmetric_summary",
+			Base: newJSONSummary(labels[asymBase], &stats[asymBase], p.rtt),
+			Up:   newJSONSummary(labels[asymUp], &stats[asymUp], p.rtt),
+			Down: newJSONSummary(labels[asymDown], &stats[asymDown], p.rtt),
+		}
+		if ok {
+			up.Extra, down.Extra = p.rtt.round(up.Extra), p.rtt.round(down.Extra)
+			out.Upstream, out.Downstream = &up, &down
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	text := &textPrinter{w: p.w, rtt: p.rtt}
+	for kind := range labels {
+		text.Summary(labels[kind], &stats[kind])
+	}
+
+	fmt.Fprintln(p.w, "\n--- asymmetry ---")
+	if !ok {
+		fmt.Fprintln(p.w, "not every kind of probe was answered: no estimate")
+		return
+	}
+	p.direction("upstream", "sent", up)
+	p.direction("downstream", "received", down)
+}
+
+// direction prints the delay estimate of one direction
+func (p *asymPrinter) direction(name, verb string, d asymDelay) {
+	if d.Mbps == 0 {
+		fmt.Fprintf(p.w, "%s: no measurable delay for %d more bytes %s\n", name, d.Bytes, verb)
+		return
+	}
+	fmt.Fprintf(p.w, "%s: +%s for %d more bytes %s (~%.2f Mbit/s)\n", name, p.rtt.format(d.Extra), d.Bytes, verb, d.Mbps)
+}
@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// AtlasJSON is the output format of RIPE Atlas ping measurement results
+const AtlasJSON = "atlas-json"
+
+// atlasFirmware is the Atlas probe firmware whose result format is followed:
+// tools reading Atlas results, e.g. Sagan, pick the parser by "fw"
+const atlasFirmware = 5080
+
+// MachineFormat reports whether *format* is one read by programs, rather than people:
+// warnings and other text lines must then stay out of the output.
+func MachineFormat(format string) bool {
+	return format == "json" || format == AtlasJSON
+}
+
+// atlasResult is a ping measurement result of RIPE Atlas, with the fields
+// that make sense outside of Atlas: there is no measurement or probe ID here
+type atlasResult struct {
+	Firmware  int     `json:"fw"`
+	Type      string  `json:"type"`
+	AF        int     `json:"af"`
+	Proto     string  `json:"proto"`
+	DstAddr   string  `json:"dst_addr"`
+	DstName   string  `json:"dst_name"`
+	SrcAddr   string  `json:"src_addr,omitempty"`
+	From      string  `json:"from,omitempty"`
+	Timestamp int64   `json:"timestamp"`
+	Size      int     `json:"size"`
+	TTL       int     `json:"ttl,omitempty"`
+	Sent      int     `json:"sent"`
+	Rcvd      int     `json:"rcvd"`
+	Dup       int     `json:"dup"`
+	Min       float64 `json:"min"`
+	Avg       float64 `json:"avg"`
+	Max       float64 `json:"max"`
+	LTS       int     `json:"lts"`
+	Step      *int    `json:"step"`
+
+	Result []atlasReply `json:"result"`
+}
+
+// atlasReply is one entry of the "result" array: the RTT of a reply,
+// "x": "*" for a probe that timed out, or the error it met
+type atlasReply struct {
+	RTT     *float64 `json:"rtt,omitempty"`
+	X       string   `json:"x,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	TTL     int      `json:"ttl,omitempty"`
+	Dup     int      `json:"dup,omitempty"`
+	SrcAddr string   `json:"srcaddr,omitempty"`
+}
+
+// atlasPrinter collects the probes of each target, and emits them with
+// its statistics as a RIPE Atlas ping result, when the run is summed up
+type atlasPrinter struct {
+	enc *recordEncoder
+	rtt RTTFormat
+
+	results map[string]*atlasResult // by target
+}
+
+func (p *atlasPrinter) Header(info ICMPInfo) {
+	out := &atlasResult{
+		Firmware:  atlasFirmware,
+		Type:      "ping",
+		AF:        4,
+		Proto:     "ICMP",
+		DstAddr:   info.IP,
+		DstName:   info.IP, // the hostname given is not kept past resolution
+		SrcAddr:   atlasSource(info),
+		Timestamp: time.Now().Unix(),
+		Size:      startSize,
+		LTS:       -1,
+		Result:    []atlasReply{},
+	}
+	out.From = out.SrcAddr
+	if addr, err := netip.ParseAddr(info.IP); err == nil && !addr.Unmap().Is4() {
+		out.AF = 6
+	}
+	if info.Transport != "" {
+		out.Proto = strings.ToUpper(info.Transport)
+	}
+	p.results[info.IP] = out
+}
+
+// atlasSource is the local address probes to *info*.IP leave from: the one
+// given, or else the one of the route to it
+func atlasSource(info ICMPInfo) string {
+	if info.Source != "" {
+		return info.Source
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(info.IP, "9"))
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+func (p *atlasPrinter) Probe(res ProbeResult) {
+	out := p.results[res.Target]
+	if out == nil {
+		return
+	}
+
+	var reply atlasReply
+	switch {
+	case res.Reason == ReasonNone:
+		rtt := p.rtt.round(res.RTT)
+		reply.RTT = &rtt
+		if out.TTL == 0 {
+			out.TTL = res.TTL
+		} else if res.TTL != out.TTL {
+			reply.TTL = res.TTL
+		}
+		if res.Duplicate {
+			reply.Dup = 1
+		}
+		if res.Peer != "" && res.Peer != res.Target {
+			reply.SrcAddr = res.Peer
+		}
+	case res.Reason == ReasonTimeout:
+		reply.X = "*"
+	default:
+		reply.Error = res.Detail
+		if reply.Error == "" {
+			reply.Error = res.Reason.String()
+		}
+	}
+	out.Result = append(out.Result, reply)
+}
+
+// Annotate does nothing: Atlas results have no room for annotations
+func (p *atlasPrinter) Annotate(note Annotation) {}
+
+// Interim does nothing: an Atlas result is only complete at the end of the run
+func (p *atlasPrinter) Interim(target string, stats *PingStats) {}
+
+// Periodic does nothing, as Interim
+func (p *atlasPrinter) Periodic(target string, window *PingStats, every time.Duration) {}
+
+func (p *atlasPrinter) Summary(target string, stats *PingStats) {
+	out := p.results[target]
+	if out == nil {
+		return
+	}
+
+	out.Sent, out.Rcvd, out.Dup = stats.transmitted, stats.received, stats.duplicates
+	out.Min, out.Avg, out.Max = -1, -1, -1
+	if stats.received > 0 {
+		stats.finalStats()
+		out.Min, out.Avg, out.Max = p.rtt.round(stats.min), p.rtt.round(stats.mean), p.rtt.round(stats.max)
+	}
+	p.enc.Encode(out)
+}
+
+// SummaryTable emits a result per target
+func (p *atlasPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	for i, target := range targets {
+		p.Summary(target, stats[i])
+	}
+}
@@ -0,0 +1,157 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minAutoInterval = 100 * time.Millisecond // fastest a target is probed with --auto-interval
+	maxAutoInterval = 10 * time.Second       // slowest
+	cpuSamplePeriod = time.Second            // how often the CPU budget is checked
+)
+
+// AutoInterval is the budget --auto-interval shares between targets: a total
+// rate of probes, or a share of one CPU for pinger. The zero value is off.
+type AutoInterval struct {
+	PPS float64 // probes per second, for all targets together
+	CPU float64 // fraction of one CPU, e.g. 0.02 for target-cpu=2%
+}
+
+// ParseAutoInterval reads --auto-interval: pps=<probes per second> or target-cpu=<percent>%
+func ParseAutoInterval(s string) (AutoInterval, error) {
+	key, value, _ := strings.Cut(s, "=")
+
+	switch key {
+	case "pps":
+		pps, err := strconv.ParseFloat(value, 64)
+		if err != nil || pps <= 0 {
+			return AutoInterval{}, fmt.Errorf("invalid --auto-interval %q: pps wants a positive number", s)
+		}
+		return AutoInterval{PPS: pps}, nil
+
+	case "target-cpu":
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return AutoInterval{}, fmt.Errorf("invalid --auto-interval %q: target-cpu wants a percentage, e.g. 2%%", s)
+		}
+		return AutoInterval{CPU: percent / 100}, nil
+	}
+
+	return AutoInterval{}, fmt.Errorf("invalid --auto-interval %q: want pps=<rate> or target-cpu=<percent>%%", s)
+}
+
+// pacer spreads the probe budget over targets: the more variable the RTTs of a
+// target, the more often it is probed, as its samples say the most. With a CPU
+// budget, the total rate is adjusted as pinger's CPU usage is measured.
+type pacer struct {
+	budget AutoInterval
+
+	mu    sync.Mutex
+	rate  float64 // total probes per second
+	count []int   // RTT samples per target
+	mean  []float64
+	m2    []float64 // sum of squared differences from the mean (Welford)
+
+	stop chan struct{}
+}
+
+// newPacer shares *budget* between *targets* targets, starting them all at
+// once per second under a CPU budget
+func newPacer(budget AutoInterval, targets int) *pacer {
+	p := &pacer{
+		budget: budget,
+		rate:   budget.PPS,
+		count:  make([]int, targets),
+		mean:   make([]float64, targets),
+		m2:     make([]float64, targets),
+		stop:   make(chan struct{}),
+	}
+
+	if budget.CPU > 0 {
+		p.rate = float64(targets)
+		go p.followCPU()
+	}
+
+	return p
+}
+
+// observe notes the outcome of a probe to target *i*
+func (p *pacer) observe(i int, res ProbeResult) {
+	if res.Reason != ReasonNone {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.count[i]++
+	delta := res.RTT - p.mean[i]
+	p.mean[i] += delta / float64(p.count[i])
+	p.m2[i] += delta * (res.RTT - p.mean[i])
+}
+
+// weight is how variable the RTTs of target *i* are: 1 plus their coefficient of variation.
+// p.mu must be held.
+func (p *pacer) weight(i int) float64 {
+	if p.count[i] < 2 || p.mean[i] <= 0 {
+		return 1
+	}
+	return 1 + math.Sqrt(p.m2[i]/float64(p.count[i]))/p.mean[i]
+}
+
+// interval is how long target *i* waits before its next probe
+func (p *pacer) interval(i int) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total := 0.0
+	for t := range p.count {
+		total += p.weight(t)
+	}
+
+	rate := p.rate * p.weight(i) / total
+	interval := time.Duration(float64(time.Second) / rate)
+
+	return min(max(interval, minAutoInterval), maxAutoInterval)
+}
+
+// followCPU adjusts the total rate every cpuSamplePeriod, to keep the CPU time
+// pinger uses under budget: down fast when over, up slowly when under
+func (p *pacer) followCPU() {
+	ticker := time.NewTicker(cpuSamplePeriod)
+	defer ticker.Stop()
+
+	lastCPU, lastWall := processCPUTime(), time.Now()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		cpu, wall := processCPUTime(), time.Now()
+		usage := float64(cpu-lastCPU) / float64(wall.Sub(lastWall))
+		lastCPU, lastWall = cpu, wall
+
+		p.mu.Lock()
+		if usage > p.budget.CPU {
+			p.rate *= 0.7
+		} else {
+			p.rate *= 1.1
+		}
+		// Within what the interval bounds allow anyway
+		targets := float64(len(p.count))
+		p.rate = min(max(p.rate, targets/maxAutoInterval.Seconds()), targets/minAutoInterval.Seconds())
+		p.mu.Unlock()
+	}
+}
+
+// close stops following the CPU usage
+func (p *pacer) close() {
+	close(p.stop)
+}
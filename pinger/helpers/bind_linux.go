@@ -0,0 +1,8 @@
+package helpers
+
+import "golang.org/x/sys/unix"
+
+// bindToDevice restricts socket *fd* to interface *name*, for sending and receiving
+func bindToDevice(fd uintptr, name string) error {
+	return unix.SetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE, name)
+}
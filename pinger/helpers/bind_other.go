@@ -0,0 +1,9 @@
+//go:build !linux
+
+package helpers
+
+// bindToDevice has no equivalent beyond Linux: control messages (or, on Windows,
+// binding to the interface's address) are all the steering there is
+func bindToDevice(fd uintptr, name string) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"log/slog"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/icmp"
+)
+
+// acceptAll is what a BPF program returns to pass a packet whole
+const acceptAll = maxICMPPacket
+
+// filterEcho attaches a classic BPF program to raw socket *conn*, of *proto*,
+// passing only the packets that concern its Echo Requests, of identifier
+// *id*: the Echo Replies carrying it, and the ICMP errors quoting a request
+// carrying it. A raw socket gets every ICMP packet the host receives
+// otherwise: on a busy host, the replies to other pings, Neighbor Discovery
+// and the like would wake pinger up, to be parsed and thrown away. Datagram
+// sockets need none: the kernel passes them their own replies only.
+// Where BPF is not supported, everything is read, as before.
+func filterEcho(conn *icmp.PacketConn, proto int, id int) {
+	program, err := bpf.Assemble(echoFilter(proto, uint32(id)))
+	if err == nil {
+		if proto == protocolICMPv6 {
+			err = conn.IPv6PacketConn().SetBPF(program)
+		} else {
+			err = conn.IPv4PacketConn().SetBPF(program)
+		}
+	}
+	slog.Debug("echo filter", "id", id, "attached", err == nil, "err", err)
+}
+
+// echoFilter is the program of filterEcho. Raw IPv4 sockets read the IP
+// header, of any length, before the ICMP message; raw IPv6 sockets the
+// ICMPv6 message alone. An ICMP error quotes the IP header of the request it
+// is about, then its ICMP header: of 20 bytes or more, or 40 for IPv6 without
+// extension headers.
+func echoFilter(proto int, id uint32) []bpf.Instruction {
+	if proto == protocolICMPv6 {
+		return []bpf.Instruction{
+			bpf.LoadAbsolute{Off: 0, Size: 1},                      // 0: type
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 129, SkipTrue: 5}, // Echo Reply: to 7
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 1, SkipTrue: 6},   // Destination Unreachable: to 9
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 2, SkipTrue: 5},   // Packet Too Big
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 3, SkipTrue: 4},   // Time Exceeded
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: 4, SkipTrue: 3},   // Parameter Problem
+			bpf.RetConstant{Val: 0},
+			bpf.LoadAbsolute{Off: 4, Size: 2}, // 7: identifier of the reply
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: id, SkipTrue: 2, SkipFalse: 3},
+			bpf.LoadAbsolute{Off: 8 + 40 + 4, Size: 2}, // 9: identifier of the request quoted
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: id, SkipFalse: 1},
+			bpf.RetConstant{Val: acceptAll},
+			bpf.RetConstant{Val: 0},
+		}
+	}
+
+	return []bpf.Instruction{
+		bpf.LoadMemShift{Off: 0},                              // 0: X = length of the IP header
+		bpf.LoadIndirect{Off: 0, Size: 1},                     // type
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0, SkipTrue: 4},  // Echo Reply: to 7
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 3, SkipTrue: 5},  // Destination Unreachable: to 9
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 11, SkipTrue: 4}, // Time Exceeded
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 12, SkipTrue: 3}, // Parameter Problem
+		bpf.RetConstant{Val: 0},
+		bpf.LoadIndirect{Off: 4, Size: 2}, // 7: identifier of the reply
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: id, SkipTrue: 7, SkipFalse: 8},
+		bpf.LoadIndirect{Off: 8, Size: 1}, // 9: X += length of the IP header quoted
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0x0f},
+		bpf.ALUOpConstant{Op: bpf.ALUOpShiftLeft, Val: 2},
+		bpf.ALUOpX{Op: bpf.ALUOpAdd},
+		bpf.TAX{},
+		bpf.LoadIndirect{Off: 8 + 4, Size: 2}, // identifier of the request quoted
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: id, SkipFalse: 1},
+		bpf.RetConstant{Val: acceptAll},
+		bpf.RetConstant{Val: 0},
+	}
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+// withIPv4Header is ICMP message *msg* as raw IPv4 sockets read it: after the
+// IP header, with *options* bytes of options
+func withIPv4Header(msg []byte, options int) []byte {
+	header := make([]byte, 20+options)
+	header[0] = 0x40 | byte(len(header)/4)
+	header[8], header[9] = 64, protocolICMP
+	return append(header, msg...)
+}
+
+// withType is ICMP message *msg* made of type *typ*
+func withType(msg []byte, typ byte) []byte {
+	msg = append([]byte{}, msg...)
+	msg[0] = typ
+	return msg
+}
+
+func TestEchoFilter(t *testing.T) {
+	const id = 0x1234
+	for _, family := range []struct {
+		proto  int
+		ipv6   bool
+		reply  byte // type of Echo Replies
+		src    string
+		dst    string
+		packet func(msg []byte) []byte // as the raw socket reads *msg*
+	}{
+		{protocolICMP, false, 0, "192.0.2.1", "198.51.100.1", func(msg []byte) []byte { return withIPv4Header(msg, 0) }},
+		{protocolICMPv6, true, 129, "2001:db8::1", "2001:db8::2", func(msg []byte) []byte { return msg }},
+	} {
+		vm, err := bpf.NewVM(echoFilter(family.proto, id))
+		if err != nil {
+			t.Fatalf("%d: %v", family.proto, err)
+		}
+		ours, foreign := testEcho(t, family.ipv6, id, 3), testEcho(t, family.ipv6, id+1, 3)
+		unreachable := func(request []byte) []byte {
+			return icmpError(t, family.src, family.dst, 0, request, -1)
+		}
+		timeExceeded := byte(11)
+		if family.ipv6 {
+			timeExceeded = 3
+		}
+
+		for _, tc := range []struct {
+			name   string
+			packet []byte
+			pass   bool
+		}{
+			{"echo reply", family.packet(withType(ours, family.reply)), true},
+			{"echo reply of another identifier", family.packet(withType(foreign, family.reply)), false},
+			{"echo request", family.packet(ours), false},
+			{"unreachable", family.packet(unreachable(ours)), true},
+			{"time exceeded", family.packet(withType(unreachable(ours), timeExceeded)), true},
+			{"unreachable of another identifier", family.packet(unreachable(foreign)), false},
+			{"time exceeded of another identifier", family.packet(withType(unreachable(foreign), timeExceeded)), false},
+			// Redirect, Neighbor Advertisement
+			{"other type", family.packet(withType(ours, map[bool]byte{false: 5, true: 136}[family.ipv6])), false},
+		} {
+			n, err := vm.Run(tc.packet)
+			if err != nil {
+				t.Errorf("%d %s: %v", family.proto, tc.name, err)
+				continue
+			}
+			if pass := n > 0; pass != tc.pass {
+				t.Errorf("%d %s: passed %d bytes, want it passed: %v", family.proto, tc.name, n, tc.pass)
+			}
+			// The kernel cuts the packet to what the program returns
+			if tc.pass && n < len(tc.packet) {
+				t.Errorf("%d %s: passed %d bytes of %d, want them all", family.proto, tc.name, n, len(tc.packet))
+			}
+		}
+	}
+
+	// The IP headers of IPv4, the packet's and that quoted, may have options
+	vm, err := bpf.NewVM(echoFilter(protocolICMP, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours, foreign := testEcho(t, false, id, 3), testEcho(t, false, id+1, 3)
+	for _, tc := range []struct {
+		name   string
+		packet []byte
+		pass   bool
+	}{
+		{"echo reply", withIPv4Header(withType(ours, 0), 8), true},
+		{"echo reply of another identifier", withIPv4Header(withType(foreign, 0), 8), false},
+		{"unreachable", withIPv4Header(icmpError(t, "192.0.2.1", "198.51.100.1", 12, ours, -1), 4), true},
+		{"unreachable of another identifier", withIPv4Header(icmpError(t, "192.0.2.1", "198.51.100.1", 12, foreign, -1), 4), false},
+		{"longest options", withIPv4Header(icmpError(t, "192.0.2.1", "198.51.100.1", 40, ours, -1), 40), true},
+	} {
+		if n, err := vm.Run(tc.packet); err != nil || (n > 0) != tc.pass {
+			t.Errorf("options, %s: passed %d bytes, %v, want it passed: %v", tc.name, n, err, tc.pass)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package helpers
+
+import "time"
+
+// clock is what the send loop, RTT measurement and the statistics tell the
+// time by: the system's, or a fakeClock, for timeouts, long runs and jitter
+// to be simulated without waiting for them
+type clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After sends the time on the channel it returns once *d* has passed
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// clk is the clock in use: the system's, but in tests
+var clk clock = systemClock{}
+
+// systemClock is the clock of package time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that never waits: whatever it is asked to wait for
+// has passed at once, its time moved on by as much. A run over a fakeClock
+// and a fakeTransport takes no time, and its RTTs are exactly the delays of
+// the fake replies. It suits one goroutine waiting at a time: two would each
+// move it on by what they wait for.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{now: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.advance(d)
+	return ch
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.advance(d)
+}
+
+// advance moves the time on by *d*, if positive, and returns it
+func (c *fakeClock) advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	return c.now
+}
+
+// TestSimulatedRun pings over a fakeTransport for an hour of a fakeClock,
+// which takes no time: replies alternately 10 and 14 ms late, and every tenth
+// probe lost. The RTTs, timeouts and the time the run took are checked to the
+// nanosecond, as no real clock would allow.
+func TestSimulatedRun(t *testing.T) {
+	const probes = 3600
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	delays := []time.Duration{10 * time.Millisecond, 14 * time.Millisecond}
+	respond := func(msg []byte, dst net.Addr) []fakePacket {
+		seq := int(binary.BigEndian.Uint16(msg[6:8]))
+		if seq%10 == 9 {
+			return nil
+		}
+		return fakeEchoReplies(defaultTTL, delays[seq%2])(msg, dst)
+	}
+	records, summary, _ := pingFake(t, ICMPInfo{IP: "192.0.2.1", CNT: probes}, respond)
+	if len(records) != probes {
+		t.Fatalf("%d probe records, want %d", len(records), probes)
+	}
+
+	// Probes go out an interval apart, or at once after a timeout, which is longer
+	var want time.Duration
+	for i, res := range records {
+		if i%10 == 9 {
+			if at := start.Add(want + replyTimeout); res.Reason != ReasonTimeout.String() || !res.Time.Equal(at) {
+				t.Fatalf("probe %d: got %q at %v, want a timeout at %v", i, res.Reason, res.Time.Sub(start), at.Sub(start))
+			}
+			want += replyTimeout
+			continue
+		}
+		if rtt := durationMs(delays[i%2]); res.Reason != "" || res.RTT == nil || *res.RTT != rtt {
+			t.Fatalf("probe %d: got %+v, want a reply in %g ms", i, res, rtt)
+		}
+		want += startInterval
+	}
+
+	if took := clk.Since(start); took != want {
+		t.Errorf("the run took %v, want %v", took, want)
+	}
+	if *summary.Min != 10 || *summary.Max != 14 || math.Abs(*summary.Avg-106.0/9) > 0.0005 {
+		t.Errorf("min/avg/max %g/%g/%g ms, want 10/%g/14", *summary.Min, *summary.Avg, *summary.Max, 106.0/9)
+	}
+	if summary.Loss != 10 {
+		t.Errorf("%.1f%% loss, want 10%%", summary.Loss)
+	}
+}
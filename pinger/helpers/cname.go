@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxCNAMEs bounds the CNAME chain followed, against loops
+const maxCNAMEs = 16
+
+// CNAMEChain is the chain of names *host* goes by, from itself to the canonical
+// name whose address is probed, as *server* ("host:port"), or else the resolver of
+// /etc/resolv.conf, answers the A / AAAA (*isIPv6*) query for it. CDNs often hide
+// behind a few of those. Without an answer from it, only the canonical name the
+// resolver gives is known.
+func CNAMEChain(host string, isIPv6 bool, server string) []string {
+	host = strings.TrimSuffix(host, ".")
+	chain := []string{host}
+	defer func() {
+		for _, name := range chain {
+			redactName(name)
+		}
+	}()
+
+	aliases, err := queryCNAMEs(host, isIPv6, server)
+	if err != nil || len(aliases) == 0 {
+		if canonical, err := lookupResolver(server).LookupCNAME(context.Background(), host); err == nil {
+			if canonical = strings.TrimSuffix(canonical, "."); !strings.EqualFold(canonical, host) {
+				chain = append(chain, canonical)
+			}
+		}
+		return chain
+	}
+
+	for name := strings.ToLower(host); len(chain) <= maxCNAMEs; {
+		target, ok := aliases[name]
+		if !ok {
+			break
+		}
+		chain = append(chain, target)
+		name = strings.ToLower(target)
+	}
+	return chain
+}
+
+// queryCNAMEs asks *server*, or the default resolver, for the addresses of
+// *host*, and returns the CNAME records of the answer, from alias (lowercased)
+// to target
+func queryCNAMEs(host string, isIPv6 bool, server string) (map[string]string, error) {
+	answers, err := queryAnswers(host, isIPv6, server)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+	for _, answer := range answers {
+		if body, ok := answer.Body.(*dnsmessage.CNAMEResource); ok {
+			alias := strings.ToLower(strings.TrimSuffix(answer.Header.Name.String(), "."))
+			aliases[alias] = strings.TrimSuffix(body.CNAME.String(), ".")
+		}
+	}
+	return aliases, nil
+}
+
+// queryAddrs asks *server*, or the default resolver, for the addresses of
+// *host*, and returns them in the order of the answer, which the system's
+// resolver sorts by RFC 6724 rather than keep
+func queryAddrs(host string, isIPv6 bool, server string) ([]string, error) {
+	answers, err := queryAnswers(host, isIPv6, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, answer := range answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+	return addrs, nil
+}
+
+// queryAnswers asks *server*, or the default resolver, for the A / AAAA
+// (*isIPv6*) records of *host*, and returns the answer section of the reply
+func queryAnswers(host string, isIPv6 bool, server string) ([]dnsmessage.Resource, error) {
+	if server == "" {
+		var err error
+		if server, err = DefaultResolver(); err != nil {
+			return nil, err
+		}
+	}
+
+	qtype := dnsmessage.TypeA
+	if isIPv6 {
+		qtype = dnsmessage.TypeAAAA
+	}
+	query, id, err := dnsQuery(host, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(replyTimeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, 4096)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return nil, err
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(reply[:n]); err != nil || msg.Header.ID != id || !msg.Header.Response {
+			continue
+		}
+		return msg.Answers, nil
+	}
+}
+
+// canonicalName is the last name of the CNAME chain of *info*, if known
+func (info ICMPInfo) canonicalName() string {
+	if len(info.CNAMEs) == 0 {
+		return ""
+	}
+	return info.CNAMEs[len(info.CNAMEs)-1]
+}
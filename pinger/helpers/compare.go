@@ -0,0 +1,298 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComparePath names the two interfaces probed side by side by --compare-path:
+// the tunnel (e.g. a WireGuard / VPN device) and the underlay it runs over.
+type ComparePath struct {
+	Inner string // tunnel interface
+	Outer string // underlay interface
+}
+
+// ParseComparePath parses a "inner=wg0,outer=eth0" specification
+func ParseComparePath(spec string) (ComparePath, error) {
+	var paths ComparePath
+
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || value == "" {
+			return paths, fmt.Errorf("invalid --compare-path %q: want inner=<iface>,outer=<iface>", spec)
+		}
+
+		switch key {
+		case "inner":
+			paths.Inner = value
+		case "outer":
+			paths.Outer = value
+		default:
+			return paths, fmt.Errorf("invalid --compare-path %q: unknown path %q", spec, key)
+		}
+	}
+
+	if paths.Inner == "" || paths.Outer == "" {
+		return paths, fmt.Errorf("invalid --compare-path %q: both inner and outer are required", spec)
+	}
+
+	return paths, nil
+}
+
+// ifaceFamilies reports whether the named interface has IPv4 / IPv6 addresses
+// usable for reaching the outside world (i.e. not link-local)
+func ifaceFamilies(name string) (v4 bool, v6 bool, err error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return false, false, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+
+	return v4, v6, nil
+}
+
+// resolveForIface resolves *host* to an address of a family *iface* can reach.
+// -4 / -6 in *options* win; otherwise IPv4 is preferred, like AddrResolution.
+func resolveForIface(host string, iface string, options AddrOptions) (UnMarshalledAddr, error) {
+	if !options.V4 && !options.V6 {
+		v4, v6, err := ifaceFamilies(iface)
+		if err != nil {
+			return UnMarshalledAddr{}, fmt.Errorf("error finding interface %s: %v", iface, err)
+		}
+		options.V6 = v6 && !v4
+		options.V4 = !options.V6
+	}
+
+	return AddrResolution(host, options)
+}
+
+// compareRound is one Echo Request sent over both paths at once
+type compareRound struct {
+	seq          int
+	inner, outer ProbeResult
+}
+
+// overhead is what the tunnel adds to the round trip, if both paths replied
+func (round compareRound) overhead() (float64, bool) {
+	if round.inner.Reason != ReasonNone || round.outer.Reason != ReasonNone {
+		return 0, false
+	}
+	return round.inner.RTT - round.outer.RTT, true
+}
+
+// CompareHandler pings *host* through the paths.Inner tunnel and the paths.Outer
+// underlay simultaneously, and reports the overhead of the tunnel per probe and
+// in aggregate. Everything else in *info* applies to both paths.
+// It returns the exit status: replied if either path was answered.
+func CompareHandler(host string, paths ComparePath, options AddrOptions, info ICMPInfo) int {
+	var (
+		sessions [2]*session
+		stats    [2]PingStats
+		labels   [2]string
+		overhead PingStats
+	)
+
+	// abstracted "path" information: 0 is the tunnel, 1 the underlay
+	for i, iface := range []string{paths.Inner, paths.Outer} {
+		addr, err := resolveForIface(host, iface, options)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(ExitUsage)
+		}
+
+		pathInfo := info
+		pathInfo.IP, pathInfo.Iface = addr.Addr, iface
+
+		sessions[i], err = openSession(pathInfo, addr.IsIPv6)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		defer sessions[i].close()
+
+		labels[i] = fmt.Sprintf("%s via %s", addr.Addr, iface)
+	}
+
+	printer, err := newComparePrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Graceful termination, the usual ending, with Ctrl + C: the round in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() {
+		for _, s := range sessions {
+			s.t.SetDeadline(clk.Now())
+		}
+	})
+
+	printer.header(host, labels)
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+
+		round := compareRound{seq: i}
+
+		// Send over both paths at the same time, so they see the same network conditions
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); round.inner = sessions[0].probe(i) }()
+		go func() { defer wg.Done(); round.outer = sessions[1].probe(i) }()
+		wg.Wait()
+		if stop.stopped() {
+			break
+		}
+
+		stats[0].transmitted++
+		stats[0].record(round.inner)
+		stats[1].transmitted++
+		stats[1].record(round.outer)
+
+		overhead.transmitted++
+		if delta, ok := round.overhead(); ok {
+			overhead.record(ProbeResult{RTT: delta})
+		}
+
+		printer.round(round)
+		stop.sleep(time.Second)
+	}
+
+	printer.summary(labels, &stats, &overhead)
+
+	return exitStatus(&stats[0], &stats[1])
+}
+
+// jsonOverhead is the aggregate tunnel overhead, over probes answered on both paths
+type jsonOverhead struct {
+	Min    float64 `json:"min_ms"`
+	Avg    float64 `json:"avg_ms"`
+	Max    float64 `json:"max_ms"`
+	StdDev float64 `json:"stddev_ms"`
+}
+
+// comparePrinter renders --compare-path rounds, as text or JSON lines
+type comparePrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+	rtt RTTFormat
+}
+
+func newComparePrinter(format string, rtt RTTFormat, w io.Writer) (*comparePrinter, error) {
+	switch format {
+	case "", "text":
+		return &comparePrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &comparePrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *comparePrinter) header(host string, labels [2]string) {
+	host, labels = redact(host), [2]string{redact(labels[0]), redact(labels[1])}
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type  string `json:"type"`
+			Host  string `json:"host"`
+			Inner string `json:"inner"`
+			Outer string `json:"outer"`
+		}{"compare_start", host, labels[0], labels[1]})
+		return
+	}
+
+	fmt.Fprintf(p.w, "COMPARING %s: inner %s, outer %s, %d data bytes\n", host, labels[0], labels[1], pingDataSize)
+}
+
+func (p *comparePrinter) round(round compareRound) {
+	delta, ok := round.overhead()
+
+	if p.enc != nil {
+		out := struct {
+			Type     string    `json:"type"`
+			Seq      int       `json:"seq"`
+			Inner    jsonProbe `json:"inner"`
+			Outer    jsonProbe `json:"outer"`
+			Overhead *float64  `json:"overhead_ms,omitempty"`
+		}{Type: "compare", Seq: round.seq, Inner: newJSONProbe(redactResult(round.inner), p.rtt), Outer: newJSONProbe(redactResult(round.outer), p.rtt)}
+		if ok {
+			delta = p.rtt.round(delta)
+			out.Overhead = &delta
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	fmt.Fprintf(p.w, "icmp_seq=%d inner=%s outer=%s", round.seq, p.compareRTT(round.inner), p.compareRTT(round.outer))
+	if ok {
+		fmt.Fprintf(p.w, " overhead=%s", p.rtt.format(delta))
+	}
+	fmt.Fprintln(p.w)
+}
+
+// compareRTT is the RTT of a probe, or why there is none
+func (p *comparePrinter) compareRTT(res ProbeResult) string {
+	if res.Reason != ReasonNone {
+		return res.Reason.String()
+	}
+	return p.rtt.format(res.RTT)
+}
+
+func (p *comparePrinter) summary(labels [2]string, stats *[2]PingStats, overhead *PingStats) {
+	labels = [2]string{redact(labels[0]), redact(labels[1])}
+	if p.enc != nil {
+		out := struct {
+			Type     string        `json:"type"`
+			Inner    jsonSummary   `json:"inner"`
+			Outer    jsonSummary   `json:"outer"`
+			Paired   int           `json:"paired"`
+			Overhead *jsonOverhead `json:"overhead,omitempty"`
+		}{
+			Type:   "compare_summary",
+			Inner:  newJSONSummary(labels[0], &stats[0], p.rtt),
+			Outer:  newJSONSummary(labels[1], &stats[1], p.rtt),
+			Paired: overhead.received,
+		}
+		if overhead.received > 0 {
+			overhead.finalStats()
+			out.Overhead = &jsonOverhead{p.rtt.round(overhead.min), p.rtt.round(overhead.mean), p.rtt.round(overhead.max), p.rtt.round(overhead.stddev)}
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	text := &textPrinter{w: p.w, rtt: p.rtt}
+	text.Summary(labels[0]+" (inner)", &stats[0])
+	text.Summary(labels[1]+" (outer)", &stats[1])
+
+	fmt.Fprintf(p.w, "\n--- tunnel overhead ---\n%d of %d probes answered on both paths\n", overhead.received, overhead.transmitted)
+	if overhead.received > 0 {
+		overhead.finalStats()
+		fmt.Fprintf(p.w, "overhead min/avg/max/stddev = %s\n",
+			p.rtt.formatMany(overhead.min, overhead.mean, overhead.max, overhead.stddev))
+	}
+}
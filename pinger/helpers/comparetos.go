@@ -0,0 +1,400 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+)
+
+// significance is the p-value under which --compare-tos calls a difference real
+const significance = 0.05
+
+// tosRound is one Echo Request sent with each marking, back to back
+type tosRound struct {
+	seq         int
+	base, other ProbeResult
+}
+
+// difference is how much longer the marked probe took, if both were answered
+func (round tosRound) difference() (float64, bool) {
+	if round.base.Reason != ReasonNone || round.other.Reason != ReasonNone {
+		return 0, false
+	}
+	return round.other.RTT - round.base.RTT, true
+}
+
+// tosOutcome is the result of the experiment: the paired RTT differences,
+// and the rounds only one of the two probes was lost in
+type tosOutcome struct {
+	rounds      int
+	differences []float64 // marked RTT - default RTT, in ms
+	baseLost    int       // rounds where only the default probe was lost
+	otherLost   int       // rounds where only the marked probe was lost
+}
+
+func (out *tosOutcome) record(round tosRound) {
+	out.rounds++
+	if delta, ok := round.difference(); ok {
+		out.differences = append(out.differences, delta)
+	}
+
+	baseLost, otherLost := round.base.Reason != ReasonNone, round.other.Reason != ReasonNone
+	switch {
+	case baseLost && !otherLost:
+		out.baseLost++
+	case otherLost && !baseLost:
+		out.otherLost++
+	}
+}
+
+// rttTest is the mean RTT difference, its standard deviation, and the two-sided
+// p-value of a paired t-test of it being 0. ok is false with fewer than 2 pairs.
+func (out *tosOutcome) rttTest() (mean, stddev, p float64, ok bool) {
+	n := float64(len(out.differences))
+	if n < 2 {
+		return 0, 0, 0, false
+	}
+
+	for _, d := range out.differences {
+		mean += d
+	}
+	mean /= n
+	for _, d := range out.differences {
+		stddev += (d - mean) * (d - mean)
+	}
+	stddev = math.Sqrt(stddev / (n - 1))
+
+	if stddev == 0 {
+		if mean == 0 {
+			return mean, stddev, 1, true
+		}
+		return mean, stddev, 0, true
+	}
+	t := mean / (stddev / math.Sqrt(n))
+	return mean, stddev, studentTwoSided(t, n-1), true
+}
+
+// lossTest is the two-sided p-value of an exact McNemar test: whether losing
+// only the marked probe is more or less likely than losing only the default one
+func (out *tosOutcome) lossTest() float64 {
+	return binomialTwoSided(min(out.baseLost, out.otherLost), out.baseLost+out.otherLost)
+}
+
+// verdict says in a sentence what the experiment found about marking *tos*
+func (out *tosOutcome) verdict(tos int) string {
+	mean, _, rttP, ok := out.rttTest()
+	lossP := out.lossTest()
+
+	switch {
+	case lossP < significance && out.otherLost > out.baseLost,
+		ok && rttP < significance && mean > 0:
+		return fmt.Sprintf("the path appears to de-prioritize probes marked tos 0x%02x", tos)
+	case lossP < significance && out.otherLost < out.baseLost,
+		ok && rttP < significance && mean < 0:
+		return fmt.Sprintf("the path appears to prioritize probes marked tos 0x%02x", tos)
+	}
+	return fmt.Sprintf("no significant difference (p >= %.2f): the path appears to treat tos 0x%02x like the default", significance, tos)
+}
+
+// CompareTOSHandler pings info.IP with the TOS of *info* and with *tos* in
+// turns, and compares RTTs and loss of the two markings, to find out whether
+// the path treats the marked probes differently. With info.ECN, both carry
+// the same ECN bits. It returns the exit status: replied if either marking was answered.
+func CompareTOSHandler(info ICMPInfo, isIPv6 bool, tos int) int {
+	if info.ECN {
+		tos = tos&^ecnMask | info.TOS&ecnMask
+	}
+
+	var (
+		sessions [2]*session
+		stats    [2]PingStats
+		labels   [2]string
+		outcome  tosOutcome
+		err      error
+	)
+
+	// 0 is the default marking, 1 the one compared with it
+	for i, mark := range []int{info.TOS, tos} {
+		markInfo := info
+		markInfo.TOS = mark
+
+		sessions[i], err = openSession(markInfo, isIPv6)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		defer sessions[i].close()
+
+		labels[i] = fmt.Sprintf("%s tos 0x%02x", info.IP, mark)
+	}
+
+	printer, err := newTOSPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Graceful termination, the usual ending, with Ctrl + C: the round in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() {
+		for _, s := range sessions {
+			s.t.SetDeadline(clk.Now())
+		}
+	})
+
+	printer.header(info.IP, info.TOS, tos)
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+
+		round := tosRound{seq: i}
+
+		// Back to back, so both see the same network conditions; taking turns
+		// at going first, so neither gains from the other warming up the path
+		if i%2 == 0 {
+			round.base, round.other = sessions[0].probe(i), sessions[1].probe(i)
+		} else {
+			round.other, round.base = sessions[1].probe(i), sessions[0].probe(i)
+		}
+		if stop.stopped() {
+			break
+		}
+
+		stats[0].transmitted++
+		stats[0].record(round.base)
+		stats[1].transmitted++
+		stats[1].record(round.other)
+		outcome.record(round)
+
+		printer.round(round)
+		stop.sleep(time.Second)
+	}
+
+	printer.summary(labels, &stats, &outcome, tos)
+
+	return exitStatus(&stats[0], &stats[1])
+}
+
+// tosPrinter renders --compare-tos rounds, as text or JSON lines
+type tosPrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+	rtt RTTFormat
+}
+
+func newTOSPrinter(format string, rtt RTTFormat, w io.Writer) (*tosPrinter, error) {
+	switch format {
+	case "", "text":
+		return &tosPrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &tosPrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *tosPrinter) header(ip string, base, tos int) {
+	ip = redact(ip)
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type string `json:"type"`
+			IP   string `json:"target"`
+			Base int    `json:"tos_default"`
+			TOS  int    `json:"tos_compared"`
+		}{"tos_compare_start", ip, base, tos})
+		return
+	}
+
+	fmt.Fprintf(p.w, "COMPARING %s: tos 0x%02x against 0x%02x, %d data bytes\n", ip, tos, base, pingDataSize)
+}
+
+func (p *tosPrinter) round(round tosRound) {
+	delta, ok := round.difference()
+
+	if p.enc != nil {
+		out := struct {
+			Type       string    `json:"type"`
+			Seq        int       `json:"seq"`
+			Base       jsonProbe `json:"default"`
+			Other      jsonProbe `json:"compared"`
+			Difference *float64  `json:"difference_ms,omitempty"`
+		}{Type: "tos_compare", Seq: round.seq, Base: newJSONProbe(redactResult(round.base), p.rtt), Other: newJSONProbe(redactResult(round.other), p.rtt)}
+		if ok {
+			delta = p.rtt.round(delta)
+			out.Difference = &delta
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	fmt.Fprintf(p.w, "icmp_seq=%d default=%s compared=%s", round.seq, p.tosRTT(round.base), p.tosRTT(round.other))
+	if ok {
+		fmt.Fprintf(p.w, " difference=%s%s", map[bool]string{true: "+", false: ""}[delta >= 0], p.rtt.format(delta))
+	}
+	fmt.Fprintln(p.w)
+}
+
+// tosRTT is the RTT of a probe, or why there is none
+func (p *tosPrinter) tosRTT(res ProbeResult) string {
+	if res.Reason != ReasonNone {
+		return res.Reason.String()
+	}
+	return p.rtt.format(res.RTT)
+}
+
+func (p *tosPrinter) summary(labels [2]string, stats *[2]PingStats, outcome *tosOutcome, tos int) {
+	labels = [2]string{redact(labels[0]), redact(labels[1])}
+	mean, stddev, rttP, ok := outcome.rttTest()
+	lossP := outcome.lossTest()
+
+	if p.enc != nil {
+		type jsonRTTTest struct {
+			Mean   float64 `json:"mean_ms"`
+			StdDev float64 `json:"stddev_ms"`
+			P      float64 `json:"p_value"`
+		}
+		out := struct {
+			Type       string       `json:"type"`
+			Base       jsonSummary  `json:"default"`
+			Other      jsonSummary  `json:"compared"`
+			Paired     int          `json:"paired"`
+			Difference *jsonRTTTest `json:"difference,omitempty"`
+			BaseLost   int          `json:"lost_default_only"`
+			OtherLost  int          `json:"lost_compared_only"`
+			LossP      float64      `json:"loss_p_value"`
+			Verdict    string       `json:"verdict"`
+		}{
+			Type:      "tos_compare_summary",
+			Base:      newJSONSummary(labels[0], &stats[0], p.rtt),
+			Other:     newJSONSummary(labels[1], &stats[1], p.rtt),
+			Paired:    len(outcome.differences),
+			BaseLost:  outcome.baseLost,
+			OtherLost: outcome.otherLost,
+			LossP:     lossP,
+			Verdict:   outcome.verdict(tos),
+		}
+		if ok {
+			out.Difference = &jsonRTTTest{p.rtt.round(mean), p.rtt.round(stddev), rttP}
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	text := &textPrinter{w: p.w, rtt: p.rtt}
+	text.Summary(labels[0]+" (default)", &stats[0])
+	text.Summary(labels[1]+" (compared)", &stats[1])
+
+	fmt.Fprintf(p.w, "\n--- tos comparison ---\n%d of %d rounds answered with both markings\n", len(outcome.differences), outcome.rounds)
+	if ok {
+		fmt.Fprintf(p.w, "difference (compared - default) mean/stddev = %s, paired t-test p = %.4f\n",
+			p.rtt.formatMany(mean, stddev), rttP)
+	}
+	fmt.Fprintf(p.w, "lost with one marking only: default %d, compared %d, McNemar p = %.4f\n",
+		outcome.baseLost, outcome.otherLost, lossP)
+	fmt.Fprintln(p.w, outcome.verdict(tos))
+}
+
+// studentTwoSided is the two-sided p-value of *t* under Student's t distribution
+// with *df* degrees of freedom
+func studentTwoSided(t, df float64) float64 {
+	return regIncBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// binomialTwoSided is the two-sided p-value of seeing *k* or fewer successes
+// out of *n* fair coin flips, *k* being the smaller of both counts
+func binomialTwoSided(k, n int) float64 {
+	if n == 0 {
+		return 1
+	}
+
+	p := 0.0
+	for i := 0; i <= k; i++ {
+		p += math.Exp(logChoose(n, i) - float64(n)*math.Ln2)
+	}
+	return math.Min(1, 2*p)
+}
+
+// logChoose is the natural logarithm of n choose k
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// regIncBeta is the regularized incomplete beta function I_x(a, b),
+// evaluated with its continued fraction (Numerical Recipes, 6.4)
+func regIncBeta(x, a, b float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+
+	la, _ := math.Lgamma(a + b)
+	lb, _ := math.Lgamma(a)
+	lc, _ := math.Lgamma(b)
+	front := math.Exp(la - lb - lc + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly on this side only
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction of regIncBeta, by Lentz's method
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		iterations = 200
+		epsilon    = 1e-12
+		tiny       = 1e-300
+	)
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1.0; m <= iterations; m++ {
+		// Even step
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// Odd step
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration file of pinger: defaults for the flags of every
+// run, and profiles, named sets of targets to ping with their own flags.
+// Flags given on the command line win over a profile's, which win over the
+// defaults. For example:
+//
+//	count: 10
+//	interval: 500ms
+//	interface: eth0
+//	format: json
+//	profiles:
+//	  gateways:
+//	    targets: [10.0.0.1, 10.0.1.1]
+//	    args: [-c, "100", --summary-every, 1m]
+type Config struct {
+	Count     int           `yaml:"count"`     // -c
+	Interval  time.Duration `yaml:"interval"`  // between a reply and the next probe, which there is no flag for
+	Size      int           `yaml:"size"`      // of Echo Requests, ICMP header included, which there is no flag for
+	Interface string        `yaml:"interface"` // -I
+	Format    string        `yaml:"format"`    // --format
+
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named set of targets, pinged with --profile
+type Profile struct {
+	Targets []string `yaml:"targets"`
+	Args    []string `yaml:"args"` // pinger flags, as on the command line
+}
+
+// ConfigPath is where pinger looks for its configuration file by default:
+// pinger/config.yaml in the user's configuration directory, e.g. ~/.config
+func ConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pinger", "config.yaml")
+}
+
+// LoadConfig reads and checks the configuration file at *path*, or at
+// ConfigPath if *path* is "", in which case there may be none.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	optional := path == ""
+	if optional {
+		path = ConfigPath()
+	}
+	data, err := os.ReadFile(path)
+	if optional && (path == "" || errors.Is(err, fs.ErrNotExist)) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return config, fmt.Errorf("error parsing configuration %s: %v", path, err)
+	}
+
+	switch {
+	case config.Count < 0:
+		return config, fmt.Errorf("configuration %s: invalid count %d: must be at least 1", path, config.Count)
+	case config.Interval != 0 && config.Interval < minControlInterval:
+		return config, fmt.Errorf("configuration %s: invalid interval %v: must be at least %v", path, config.Interval, minControlInterval)
+	case config.Size != 0 && (config.Size < 8 || config.Size > maxEchoSize):
+		return config, fmt.Errorf("configuration %s: invalid size %d: must be between 8 and %d bytes", path, config.Size, maxEchoSize)
+	}
+	for name, profile := range config.Profiles {
+		if len(profile.Targets) == 0 {
+			return config, fmt.Errorf("configuration %s: profile %s has no targets", path, name)
+		}
+	}
+
+	return config, nil
+}
+
+// Profile is the profile *name* of the configuration
+func (config Config) Profile(name string) (Profile, error) {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(config.Profiles))
+		if len(names) == 0 {
+			return profile, fmt.Errorf("unknown profile %q: the configuration has none", name)
+		}
+		return profile, fmt.Errorf("unknown profile %q: want one of %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// startInterval and startSize are the interval and size runs start with,
+// before their control socket changes them, see SetRunDefaults
+var (
+	startInterval = time.Second
+	startSize     = pingDataSize
+)
+
+// RunInterval is the interval runs start with, see SetRunDefaults
+func RunInterval() time.Duration {
+	return startInterval
+}
+
+// SetRunDefaults starts runs with *interval* and *size* rather than the
+// defaults, where they are not 0, as the configuration file says
+func SetRunDefaults(interval time.Duration, size int) {
+	if interval != 0 {
+		startInterval = interval
+	}
+	if size != 0 {
+		startSize = size
+	}
+}
@@ -0,0 +1,150 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minControlInterval = 10 * time.Millisecond // fastest a run can be made to probe
+	maxControlTimeout  = time.Minute           // longest a run can be made to wait for a reply
+	maxEchoSize        = maxUDPPayload         // largest Echo Request, ICMP header included
+)
+
+// runParams are the settings of a running ping that its control socket can
+// change, without restarting the statistics
+type runParams struct {
+	mu       sync.Mutex
+	interval time.Duration // between a reply and the next probe
+	timeout  time.Duration // to wait for each reply
+	size     int           // of Echo Requests, ICMP header included
+}
+
+func newRunParams() *runParams {
+	return &runParams{interval: startInterval, timeout: replyTimeout, size: startSize}
+}
+
+// get is the settings of the next probe
+func (p *runParams) get() (interval, timeout time.Duration, size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval, p.timeout, p.size
+}
+
+// set changes setting *name* to *value*, returning the old and new values
+func (p *runParams) set(name, value string) (string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch name {
+	case "interval", "timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s %q: want a duration, e.g. 500ms", name, value)
+		}
+		if name == "interval" {
+			if d < minControlInterval {
+				return "", "", fmt.Errorf("invalid interval %v: must be at least %v", d, minControlInterval)
+			}
+			old := p.interval
+			p.interval = d
+			return old.String(), d.String(), nil
+		}
+		if d <= 0 || d > maxControlTimeout {
+			return "", "", fmt.Errorf("invalid timeout %v: must be between 0 and %v", d, maxControlTimeout)
+		}
+		old := p.timeout
+		p.timeout = d
+		return old.String(), d.String(), nil
+	case "size":
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 8 || size > maxEchoSize {
+			return "", "", fmt.Errorf("invalid size %q: must be between 8 and %d bytes", value, maxEchoSize)
+		}
+		old := p.size
+		p.size = size
+		return strconv.Itoa(old), strconv.Itoa(size), nil
+	}
+
+	return "", "", fmt.Errorf("unknown setting %q: want interval, timeout or size", name)
+}
+
+// echoPayload is the payload of an Echo Request of *size* bytes, ICMP header
+// included: the default payload, repeated or cut to fit
+func echoPayload(size int) []byte {
+	payload := make([]byte, size-8)
+	for i := range payload {
+		payload[i] = defaultEchoPayload[i%len(defaultEchoPayload)]
+	}
+	return payload
+}
+
+// serveControl listens on the Unix socket *path* for commands changing
+// *params*, one per line, each answered with "ok" or an error:
+//
+//	get
+//	set interval|timeout <duration>
+//	set size <bytes>
+//
+// Every change is annotated in the result stream. The returned function stops
+// listening.
+func serveControl(path string, params *runParams, printer Printer) (func(), error) {
+	// A socket left behind by a run that was killed
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating control socket: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleControl(conn, params, printer)
+		}
+	}()
+
+	return func() { listener.Close() }, nil
+}
+
+// handleControl runs the commands of one control connection
+func handleControl(conn net.Conn, params *runParams, printer Printer) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && fields[0] == "get":
+			interval, timeout, size := params.get()
+			fmt.Fprintf(conn, "ok interval=%v timeout=%v size=%d\n", interval, timeout, size)
+		case len(fields) == 3 && fields[0] == "set":
+			old, updated, err := params.set(fields[1], fields[2])
+			if err != nil {
+				fmt.Fprintf(conn, "error: %v\n", err)
+				continue
+			}
+			printer.Annotate(Annotation{
+				Time:    clk.Now(),
+				Kind:    "config_changed",
+				Message: fmt.Sprintf("%s changed from %s to %s", fields[1], old, updated),
+				Fields:  map[string]string{"param": fields[1], "old": old, "new": updated},
+			})
+			fmt.Fprintln(conn, "ok")
+		case len(fields) == 0:
+		default:
+			fmt.Fprintf(conn, "error: unknown command %q: want get, or set <interval|timeout|size> <value>\n", scanner.Text())
+		}
+	}
+}
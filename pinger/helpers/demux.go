@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// matchKey identifies the probe an Echo Reply answers: the one sent to the
+// address the reply comes from, with the same identifier and sequence number.
+// That an ICMP error is about is the request it quotes.
+type matchKey struct {
+	dst netip.Addr
+	id  int
+	seq int
+}
+
+// matchAddr normalizes an address for matchKey: no zone, and IPv4-mapped
+// IPv6 addresses as plain IPv4. It is the zero Addr if *ip* does not parse.
+func matchAddr(ip string) netip.Addr {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.WithZone("").Unmap()
+}
+
+// waiter is a probe in flight, waiting for its reply
+type waiter struct {
+	sent  time.Time
+	reply chan ProbeResult
+}
+
+// demux is one ICMP socket shared by many targets. Probes to any of them go out
+// through it, and a single reader hands every Echo Reply to the probe it
+// answers, and every ICMP error to the probe it quotes. Replies are matched on
+// their source address too, so that a host echoing someone else's identifier
+// and sequence number is not credited with another target's reply. Sequence
+// numbers are still allocated per socket.
+type demux struct {
+	s *session
+
+	mu      sync.Mutex
+	seq     int
+	waiting map[matchKey]*waiter
+
+	done <-chan struct{} // closed to cut short the probes in flight, e.g. on Ctrl + C; nil never is
+}
+
+// newDemux opens a shared socket for one address family, and starts reading from it
+func newDemux(info ICMPInfo, isIPv6 bool) (*demux, error) {
+	s, err := openSession(info, isIPv6)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &demux{s: s, waiting: make(map[matchKey]*waiter)}
+	go d.read()
+
+	return d, nil
+}
+
+// probe sends one Echo Request to *ip* and waits for its outcome.
+// *targetSeq* is the probe's number for that target, as reported in the result.
+func (d *demux) probe(ip string, targetSeq int) ProbeResult {
+	w := &waiter{reply: make(chan ProbeResult, 1)}
+
+	d.mu.Lock()
+	key := matchKey{dst: matchAddr(ip), id: d.s.id, seq: d.seq}
+	d.seq = (d.seq + 1) & 0xffff
+	d.waiting[key] = w
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.waiting, key)
+		d.mu.Unlock()
+	}()
+
+	tag := func(res ProbeResult) ProbeResult {
+		res.Target, res.Seq = ip, targetSeq
+		return res
+	}
+
+	request, err := BuildEchoRequest(WithIPv6(d.s.isIPv6()), WithID(key.id), WithSeq(key.seq))
+	if err != nil {
+		return tag(sendErrorResult(err, targetSeq))
+	}
+
+	// The reply may be read before sendICMPRequest even returns
+	d.mu.Lock()
+	w.sent = clk.Now()
+	d.mu.Unlock()
+	if _, err := sendICMPRequest(d.s.destination(), ip, d.s.t, request); err != nil {
+		return tag(sendErrorResult(err, targetSeq))
+	}
+
+	select {
+	case res := <-w.reply:
+		return tag(res)
+	case <-clk.After(d.s.timeout):
+		return tag(ProbeResult{Time: clk.Now(), Reason: ReasonTimeout, Detail: "no reply"})
+	case <-d.done:
+		return tag(ProbeResult{Time: clk.Now(), Reason: ReasonTimeout, Detail: "interrupted"})
+	}
+}
+
+// read hands Echo Replies to their waiters, until the socket is closed, and
+// ICMP errors to the waiters of the requests they quote.
+func (d *demux) read() {
+	for {
+		data, meta, peer, err := readICMPPacket(d.s.t)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+		received := clk.Now()
+
+		key, ok := d.match(data, peer)
+		if !ok {
+			putPacket(data)
+			continue
+		}
+
+		d.mu.Lock()
+		w := d.waiting[key]
+		var sent time.Time
+		if w != nil {
+			sent = w.sent
+		}
+		d.mu.Unlock()
+		if w == nil {
+			putPacket(data)
+			continue
+		}
+
+		elapsedMs := float64(received.Sub(sent).Nanoseconds()) / 1e6
+		if kernelMs, ok := kernelElapsedMs(sent, meta); ok {
+			elapsedMs = kernelMs
+		}
+		res, final := parseICMPResponse(d.s.proto, key.id, data, peer, key.seq, meta, elapsedMs)
+		putPacket(data)
+		if final {
+			select {
+			case w.reply <- res:
+			default:
+			}
+		}
+	}
+}
+
+// match is the probe packet *data*, from *peer*, is the outcome of: that
+// whose Echo Reply it is, or that whose request an ICMP error quotes
+func (d *demux) match(data []byte, peer net.Addr) (matchKey, bool) {
+	typ, _, err := icmpHeader(d.s.proto, data)
+	if err != nil {
+		return matchKey{}, false
+	}
+
+	switch typ {
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+		echoID, echoSeq, ok := echoHeader(data)
+		return matchKey{dst: matchAddr(peerIP(peer)), id: echoID, seq: echoSeq}, ok
+
+	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable,
+		ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		request, echoID, echoSeq, ok := quotedEcho(data)
+		return matchKey{dst: request.dst.WithZone("").Unmap(), id: echoID, seq: echoSeq}, ok
+	}
+	return matchKey{}, false
+}
+
+// close shuts the socket, which stops the reader
+func (d *demux) close() error {
+	return d.s.close()
+}
@@ -0,0 +1,236 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSOptions are the settings of `pinger dns`
+type DNSOptions struct {
+	Name   string // name to resolve
+	Server string // resolver, "host:port"
+	Type   string // query type: A or AAAA
+}
+
+// DefaultResolver is the first nameserver of /etc/resolv.conf, as "host:53"
+func DefaultResolver() (string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("no resolver configured (%v): give one as @<server>", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+
+	return "", fmt.Errorf("no nameserver in /etc/resolv.conf: give one as @<server>")
+}
+
+// dnsQuery builds a recursive query for *name*, returning it with its ID
+func dnsQuery(name string, qtype dnsmessage.Type) ([]byte, uint16, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	id := uint16(rand.UintN(1 << 16))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+
+	query, err := msg.Pack()
+	return query, id, err
+}
+
+// dnsProbe sends one query for *name* over *conn*, and times the response. Any
+// response counts as a reply, NXDOMAIN included, except server failures and refusals.
+func dnsProbe(conn net.Conn, name string, qtype dnsmessage.Type, seq int) ProbeResult {
+	res := ProbeResult{Transport: "dns", Seq: seq, Peer: conn.RemoteAddr().String()}
+
+	query, id, err := dnsQuery(name, qtype)
+	if err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	conn.SetReadDeadline(time.Now().Add(replyTimeout))
+
+	start := time.Now()
+	if _, err := conn.Write(query); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	reply := make([]byte, 4096)
+	for {
+		n, err := conn.Read(reply)
+		res.RTT = elapsedMsSince(start)
+		res.Time = time.Now()
+		if err != nil {
+			failed := readErrorResult(err, seq)
+			res.Reason, res.Detail = failed.Reason, failed.Detail
+			return res
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(reply[:n]); err != nil || msg.Header.ID != id || !msg.Header.Response {
+			// A late response to an earlier query, or garbage
+			continue
+		}
+
+		res.Bytes = n
+		res.Detail = rcodeName(msg.Header.RCode)
+		switch msg.Header.RCode {
+		case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+		default:
+			res.Reason = ReasonDNSError
+			return res
+		}
+
+		for _, answer := range msg.Answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				res.Answers = append(res.Answers, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				res.Answers = append(res.Answers, net.IP(body.AAAA[:]).String())
+			case *dnsmessage.CNAMEResource:
+				res.Answers = append(res.Answers, "CNAME "+strings.TrimSuffix(body.CNAME.String(), "."))
+				redactName(body.CNAME.String())
+			}
+		}
+		slices.Sort(res.Answers)
+
+		return res
+	}
+}
+
+// rcodeName is the usual mnemonic of a response code, e.g. NXDOMAIN
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// answerChange notes that the answer to the query differs from the previous one
+func answerChange(name string, previous []string, current []string) Annotation {
+	before, after := strings.Join(previous, " "), strings.Join(current, " ")
+	return Annotation{
+		Time:    clk.Now(),
+		Kind:    "answer_changed",
+		Message: fmt.Sprintf("answer for %s changed from [%s] to [%s]", name, before, after),
+		Fields:  map[string]string{"name": name, "previous": before, "current": after},
+	}
+}
+
+// DNSHandler queries opts.Server for opts.Name once per second, and reports
+// the query RTT and loss, and when the answer changes: to tell DNS slowness
+// from network slowness. info.CNT, info.Iface, info.Format and info.RTT apply.
+// It returns the exit status.
+func DNSHandler(info ICMPInfo, opts DNSOptions) int {
+	stats := newPingStats(info)
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	qtype := dnsmessage.TypeA
+	if opts.Type == "AAAA" {
+		qtype = dnsmessage.TypeAAAA
+	}
+
+	host, _, err := net.SplitHostPort(opts.Server)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	info.Transport = "udp"
+	dialer, err := localDialer(info, strings.Contains(host, ":"))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	conn, err := dialer.Dial("udp", opts.Server)
+	if err != nil {
+		slog.Error("creating DNS connection", "err", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	target := fmt.Sprintf("%s %s @%s", opts.Name, opts.Type, opts.Server)
+	redactName(opts.Name)
+
+	// Graceful termination, the usual ending, with Ctrl + C: the query in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() { conn.SetReadDeadline(time.Now()) })
+
+	info.IP, info.Transport = target, "dns"
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() { printInterim(printer, target, &stats) })
+	defer summarizeEvery(info.SummaryEvery, printer, []string{target}, &stats)()
+
+	var previous []string
+	answered := false
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
+		res := dnsProbe(conn, opts.Name, qtype, i)
+		// Cut short by Ctrl + C: not an outcome of the probe
+		if res.Reason != ReasonNone && stop.stopped() {
+			break
+		}
+		report(info, res, &stats, printer)
+
+		if res.Reason == ReasonNone {
+			if answered && !slices.Equal(previous, res.Answers) {
+				printer.Annotate(answerChange(opts.Name, previous, res.Answers))
+			}
+			previous, answered = res.Answers, true
+		}
+
+		stop.sleep(time.Second)
+	}
+
+	printer.Summary(target, &stats)
+	return exitStatus(&stats)
+}
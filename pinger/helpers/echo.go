@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"fmt"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// defaultEchoPayload fills Echo Requests up to pingDataSize bytes, with the ICMP header
+var defaultEchoPayload = []byte("Never stop learning because life never stops teaching!!!")
+
+// echoRequest is what BuildEchoRequest is asked to build
+type echoRequest struct {
+	id, seq int
+	payload []byte
+	ipv6    bool
+}
+
+// EchoOption configures an Echo Request built by BuildEchoRequest
+type EchoOption func(*echoRequest)
+
+// WithID sets the identifier of the Echo Request (0 by default). On Linux
+// ICMP datagram sockets, the kernel replaces it with the socket's own.
+func WithID(id int) EchoOption {
+	return func(r *echoRequest) { r.id = id }
+}
+
+// WithSeq sets the sequence number of the Echo Request (0 by default)
+func WithSeq(seq int) EchoOption {
+	return func(r *echoRequest) { r.seq = seq }
+}
+
+// wireSeq is the Echo sequence number probe *seq* of a run goes out with:
+// past 65535, they wrap around to 0
+func wireSeq(seq int) int {
+	return seq & 0xffff
+}
+
+// WithPayload replaces the default payload of the Echo Request
+func WithPayload(payload []byte) EchoOption {
+	return func(r *echoRequest) { r.payload = payload }
+}
+
+// WithIPv6 builds an ICMPv6 Echo Request (type 128) instead of an ICMP one (type 8)
+func WithIPv6(isIPv6 bool) EchoOption {
+	return func(r *echoRequest) { r.ipv6 = isIPv6 }
+}
+
+// BuildEchoRequest marshals an ICMP / ICMPv6 Echo Request, ready to be sent on
+// the wire, as configured by *opts*. The ICMPv6 checksum is left to the kernel,
+// which computes it over the pseudo-header it alone knows.
+func BuildEchoRequest(opts ...EchoOption) ([]byte, error) {
+	r := echoRequest{payload: defaultEchoPayload}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	if r.id < 0 || r.id > 0xffff {
+		return nil, fmt.Errorf("invalid Echo identifier %d: must be between 0 and 65535", r.id)
+	}
+	if r.seq < 0 || r.seq > 0xffff {
+		return nil, fmt.Errorf("invalid Echo sequence number %d: must be between 0 and 65535", r.seq)
+	}
+
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
+	if r.ipv6 {
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+
+	request := icmp.Message{
+		Type: msgType,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   r.id,
+			Seq:  r.seq,
+			Data: r.payload,
+		},
+	}
+
+	return request.Marshal(nil)
+}
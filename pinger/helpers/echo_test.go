@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestBuildEchoRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []EchoOption
+		ipv6    bool
+		id, seq int
+		payload []byte
+	}{
+		{"defaults", nil, false, 0, 0, defaultEchoPayload},
+		{"id and seq", []EchoOption{WithID(0x1234), WithSeq(7)}, false, 0x1234, 7, defaultEchoPayload},
+		{"largest id and seq", []EchoOption{WithID(0xffff), WithSeq(0xffff)}, false, 0xffff, 0xffff, defaultEchoPayload},
+		{"payload", []EchoOption{WithPayload([]byte("abc"))}, false, 0, 0, []byte("abc")},
+		{"empty payload", []EchoOption{WithPayload(nil)}, false, 0, 0, nil},
+		{"ipv6", []EchoOption{WithIPv6(true), WithID(1), WithSeq(2)}, true, 1, 2, defaultEchoPayload},
+		{"ipv6 payload", []EchoOption{WithIPv6(true), WithPayload([]byte{0, 1, 2, 3})}, true, 0, 0, []byte{0, 1, 2, 3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := BuildEchoRequest(tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proto, wantType := protocolICMP, icmp.Type(ipv4.ICMPTypeEcho)
+			if tc.ipv6 {
+				proto, wantType = protocolICMPv6, ipv6.ICMPTypeEchoRequest
+			}
+			msg, err := icmp.ParseMessage(proto, b)
+			if err != nil {
+				t.Fatalf("% x: %v", b, err)
+			}
+			if msg.Type != wantType || msg.Code != 0 {
+				t.Errorf("type %v code %d, want %v code 0", msg.Type, msg.Code, wantType)
+			}
+			echo, ok := msg.Body.(*icmp.Echo)
+			if !ok {
+				t.Fatalf("body %T, want *icmp.Echo", msg.Body)
+			}
+			if echo.ID != tc.id || echo.Seq != tc.seq || !bytes.Equal(echo.Data, tc.payload) {
+				t.Errorf("id %d seq %d data %q, want id %d seq %d data %q", echo.ID, echo.Seq, echo.Data, tc.id, tc.seq, tc.payload)
+			}
+
+			// The ICMP checksum covers the message, and makes it sum to 0;
+			// that of ICMPv6 is left to the kernel
+			if !tc.ipv6 && onesComplementSum(0, b) != 0xffff {
+				t.Errorf("bad checksum %#04x", msg.Checksum)
+			}
+			if tc.ipv6 && msg.Checksum != 0 {
+				t.Errorf("ICMPv6 checksum %#04x, want 0 for the kernel to fill in", msg.Checksum)
+			}
+		})
+	}
+}
+
+func TestBuildEchoRequestInvalid(t *testing.T) {
+	for _, opts := range [][]EchoOption{
+		{WithID(-1)},
+		{WithID(0x10000)},
+		{WithSeq(-1)},
+		{WithSeq(0x10000)},
+	} {
+		var r echoRequest
+		for _, opt := range opts {
+			opt(&r)
+		}
+		if b, err := BuildEchoRequest(opts...); err == nil {
+			t.Errorf("id %d seq %d: built % x, want an error", r.id, r.seq, b)
+		}
+	}
+}
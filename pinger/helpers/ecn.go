@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// ecnMask is the ECN field: the two low bits of the TOS byte / Traffic Class (RFC 3168)
+const ecnMask = 0x03
+
+// ecnCodepoints are the values of the ECN field, by name
+var ecnCodepoints = map[string]int{"not-ect": 0, "ect1": 1, "ect0": 2, "ce": 3}
+
+// ParseECN reads the ECN codepoint for --ecn: ect0, ect1, ce or not-ect
+func ParseECN(s string) (int, error) {
+	if ecn, ok := ecnCodepoints[strings.ToLower(s)]; ok {
+		return ecn, nil
+	}
+	return 0, fmt.Errorf("invalid ECN codepoint %q: want ect0, ect1, ce or not-ect", s)
+}
+
+// ecnName is how RFC 3168 writes the ECN field of *tos*
+func ecnName(tos int) string {
+	return [...]string{"Not-ECT", "ECT(1)", "ECT(0)", "CE"}[tos&ecnMask]
+}
+
+// enableRecvTOS asks the kernel for the TOS / Traffic Class of what *conn* receives
+func enableRecvTOS(conn *icmp.PacketConn, proto int) error {
+	if proto == protocolICMPv6 {
+		return conn.IPv6PacketConn().SetControlMessage(ipv6.FlagTrafficClass, true)
+	}
+	if !ipv4HeaderReadable {
+		return fmt.Errorf("reading the TOS of IPv4 replies is only supported on Linux")
+	}
+	return controlSocket(conn, protocolICMP, setRecvTOS)
+}
+
+// checkECN flags *res* if its ECN field is not what *info* sends: routers on the
+// way bleached (cleared) or mangled it, or marked Congestion Experienced
+func checkECN(info ICMPInfo, res *ProbeResult) {
+	if info.ECN && res.TOSKnown && res.Reason == ReasonNone && res.TOS&ecnMask != info.TOS&ecnMask {
+		res.ECNChanged = true
+	}
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Exit statuses of pinger, as ping(8)'s: scripts can tell a host that does not
+// answer from a command that could not run
+const (
+	ExitReplied = 0 // at least one reply was received
+	ExitNoReply = 1 // no reply was received
+	ExitUsage   = 2 // bad usage, or the destination could not be resolved
+)
+
+// usageError is an error of the command line, rather than of the network
+type usageError struct {
+	error
+}
+
+// errorStatus is the exit status of a run that could not start because of
+// *err*: ExitUsage for usageErrors, ExitNoReply for the others, as nothing
+// could reply
+func errorStatus(err error) int {
+	var usage usageError
+	if errors.As(err, &usage) {
+		return ExitUsage
+	}
+	return ExitNoReply
+}
+
+// exitStatus is the exit status of a run with *stats*: replied if any of them
+// received a reply
+func exitStatus(stats ...*PingStats) int {
+	for _, s := range stats {
+		if s.received > 0 {
+			return ExitReplied
+		}
+	}
+	return ExitNoReply
+}
+
+// interruption ends a run early on the first of the terminationSignals (Ctrl
+// + C): the probing loop stops at its next step, and the handler prints the
+// statistics and returns its status as if the count had been reached.
+// A second signal kills pinger at once. The limits of SetLimits end runs
+// the same way.
+type interruption struct {
+	done chan struct{}
+}
+
+// onInterrupt watches for the terminationSignals. *wake*, if not nil, is
+// called on the first one to cut short what the loop waits for, e.g. by
+// setting the read deadline of its socket to now.
+func onInterrupt(wake func()) *interruption {
+	it := &interruption{done: make(chan struct{})}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, terminationSignals...)
+	go func() {
+		cut := true
+		select {
+		case <-c:
+		case <-limitReached:
+			cut = limitCuts
+		}
+		signal.Stop(c)
+		close(it.done)
+		if wake != nil && cut {
+			wake()
+		}
+	}()
+
+	return it
+}
+
+// stopped reports whether the run was interrupted
+func (it *interruption) stopped() bool {
+	select {
+	case <-it.done:
+		return true
+	case <-limitReached:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for *d*, or until the run is interrupted
+func (it *interruption) sleep(d time.Duration) {
+	select {
+	case <-it.done:
+	case <-clk.After(d):
+	}
+}
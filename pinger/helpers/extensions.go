@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/icmp"
+)
+
+// ICMPExtension is one object of the extension structure ICMP errors may carry
+// after the packet they quote (RFC 4884): routers add them to Time Exceeded to
+// say which MPLS labels the probe carried, or which interface it came in by
+type ICMPExtension struct {
+	Class  int         `json:"class"`
+	Type   int         `json:"type"`
+	Labels []MPLSLabel `json:"mpls,omitempty"`  // of an MPLS Label Stack object (RFC 4950), outermost first
+	Iface  *ExtIface   `json:"iface,omitempty"` // of an Interface Information object (RFC 5837)
+}
+
+// MPLSLabel is one entry of an MPLS label stack
+type MPLSLabel struct {
+	Label int  `json:"label"`
+	TC    int  `json:"tc"` // traffic class, formerly EXP
+	S     bool `json:"s"`  // bottom of the stack
+	TTL   int  `json:"ttl"`
+}
+
+// ExtIface is an interface of the router reporting an error, or of the next hop
+type ExtIface struct {
+	Role  string `json:"role"` // "incoming", "sub-IP", "outgoing" or "next hop"
+	Index int    `json:"index,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Addr  string `json:"addr,omitempty"`
+	MTU   int    `json:"mtu,omitempty"`
+}
+
+// ifaceRoles are the roles of the interfaces of Interface Information
+// objects, by the 2 top bits of their type
+var ifaceRoles = [4]string{"incoming", "sub-IP", "outgoing", "next hop"}
+
+// icmpExtensions are the extension objects ICMP error *data* carries, if any
+func icmpExtensions(proto int, data []byte) []ICMPExtension {
+	msg, err := icmp.ParseMessage(proto, data)
+	if err != nil {
+		return nil
+	}
+
+	var exts []icmp.Extension
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		exts = body.Extensions
+	case *icmp.DstUnreach:
+		exts = body.Extensions
+	}
+
+	var out []ICMPExtension
+	for _, ext := range exts {
+		switch ext := ext.(type) {
+		case *icmp.MPLSLabelStack:
+			e := ICMPExtension{Class: ext.Class, Type: ext.Type}
+			for _, l := range ext.Labels {
+				e.Labels = append(e.Labels, MPLSLabel{Label: l.Label, TC: l.TC, S: l.S, TTL: l.TTL})
+			}
+			out = append(out, e)
+
+		case *icmp.InterfaceInfo:
+			iface := &ExtIface{Role: ifaceRoles[ext.Type>>6&3]}
+			if ext.Interface != nil {
+				iface.Index, iface.Name, iface.MTU = ext.Interface.Index, ext.Interface.Name, ext.Interface.MTU
+			}
+			if ext.Addr != nil {
+				iface.Addr = ext.Addr.String()
+			}
+			out = append(out, ICMPExtension{Class: ext.Class, Type: ext.Type, Iface: iface})
+
+		case *icmp.RawExtension:
+			// Objects of other classes, whose header is all that is known of them
+			if len(ext.Data) >= 4 {
+				out = append(out, ICMPExtension{Class: int(ext.Data[2]), Type: int(ext.Data[3])})
+			}
+		}
+	}
+	return out
+}
+
+// String renders the extension like traceroute(8) -e does, e.g.
+// MPLS:L=24001,E=0,S=1,T=1 for each label of a stack
+func (ext ICMPExtension) String() string {
+	switch {
+	case ext.Labels != nil:
+		labels := make([]string, len(ext.Labels))
+		for i, l := range ext.Labels {
+			s := 0
+			if l.S {
+				s = 1
+			}
+			labels[i] = fmt.Sprintf("MPLS:L=%d,E=%d,S=%d,T=%d", l.Label, l.TC, s, l.TTL)
+		}
+		return strings.Join(labels, "/")
+
+	case ext.Iface != nil:
+		var attrs []string
+		if ext.Iface.Name != "" {
+			attrs = append(attrs, "name="+ext.Iface.Name)
+		}
+		if ext.Iface.Index != 0 {
+			attrs = append(attrs, fmt.Sprintf("index=%d", ext.Iface.Index))
+		}
+		if ext.Iface.Addr != "" {
+			attrs = append(attrs, "addr="+ext.Iface.Addr)
+		}
+		if ext.Iface.MTU != 0 {
+			attrs = append(attrs, fmt.Sprintf("mtu=%d", ext.Iface.MTU))
+		}
+		return fmt.Sprintf("IF(%s):%s", ext.Iface.Role, strings.Join(attrs, ","))
+	}
+	return fmt.Sprintf("EXT:class=%d,type=%d", ext.Class, ext.Type)
+}
@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Extension objects, as routers send them
+var (
+	// MPLS Label Stack (RFC 4950): label 24001, TTL 1, then label 16, TC 5,
+	// bottom of the stack, TTL 255
+	mplsObject = fixture("000c 0101 05dc1001 00010bff")
+	// Interface Information (RFC 5837) of the outgoing interface: ifIndex 7,
+	// 192.0.2.1, named ge-0/0/1, MTU 1500
+	ifaceObject = fixture("0020 028f 00000007 0001 0000 c0000201 0c 67652d302f302f31 000000 000005dc")
+	// An object of a class this does not know, 200
+	unknownObject = fixture("0008 c801 deadbeef")
+)
+
+// fixture is the bytes of hexadecimal *s*, spaces aside
+func fixture(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// extensionStructure is the RFC 4884 extension structure of *objects*, its
+// checksum set
+func extensionStructure(objects ...[]byte) []byte {
+	ext := []byte{0x20, 0, 0, 0}
+	for _, object := range objects {
+		ext = append(ext, object...)
+	}
+	binary.BigEndian.PutUint16(ext[2:4], ^uint16(onesComplementSum(0, ext)))
+	return ext
+}
+
+// timeExceeded is an ICMP(v6) Time Exceeded quoting 128 bytes, the original
+// datagram padded as RFC 4884 asks, then *ext*, if not nil
+func timeExceeded(ipv6 bool, ext []byte) []byte {
+	quoted := make([]byte, 128)
+	quoted[0] = 0x45
+
+	msg := []byte{11, 0, 0, 0, 0, 0, 0, 0}
+	if ipv6 {
+		msg[0] = 3
+		quoted[0] = 0x60
+	}
+	if ext != nil {
+		// The length of the quote, in 32 bit words for ICMP, 64 for ICMPv6
+		if ipv6 {
+			msg[4] = 128 / 8
+		} else {
+			msg[5] = 128 / 4
+		}
+	}
+	msg = append(append(msg, quoted...), ext...)
+	setICMPChecksum(msg)
+	return msg
+}
+
+func TestICMPExtensions(t *testing.T) {
+	mpls := ICMPExtension{Class: 1, Type: 1, Labels: []MPLSLabel{
+		{Label: 24001, TC: 0, S: false, TTL: 1},
+		{Label: 16, TC: 5, S: true, TTL: 255},
+	}}
+	iface := ICMPExtension{Class: 2, Type: 0x8f, Iface: &ExtIface{Role: "outgoing", Index: 7, Name: "ge-0/0/1", Addr: "192.0.2.1", MTU: 1500}}
+
+	badChecksum := extensionStructure(mplsObject)
+	badChecksum[3]++
+	// The object claims 64 bytes, of the 12 there are
+	overrun := append([]byte{}, mplsObject...)
+	overrun[1] = 64
+
+	for _, tc := range []struct {
+		name  string
+		ipv6  bool
+		data  []byte
+		want  []ICMPExtension
+		print string // of the extensions, as traceroute(8) -e does
+	}{
+		{"mpls", false, timeExceeded(false, extensionStructure(mplsObject)), []ICMPExtension{mpls},
+			"MPLS:L=24001,E=0,S=0,T=1/MPLS:L=16,E=5,S=1,T=255"},
+		{"icmpv6 mpls", true, timeExceeded(true, extensionStructure(mplsObject)), []ICMPExtension{mpls},
+			"MPLS:L=24001,E=0,S=0,T=1/MPLS:L=16,E=5,S=1,T=255"},
+		{"interface information", false, timeExceeded(false, extensionStructure(ifaceObject)), []ICMPExtension{iface},
+			"IF(outgoing):name=ge-0/0/1,index=7,addr=192.0.2.1,mtu=1500"},
+		{"both", false, timeExceeded(false, extensionStructure(mplsObject, ifaceObject)), []ICMPExtension{mpls, iface}, ""},
+		{"unknown class", false, timeExceeded(false, extensionStructure(unknownObject)), []ICMPExtension{{Class: 200, Type: 1}},
+			"EXT:class=200,type=1"},
+		{"bad checksum", false, timeExceeded(false, badChecksum), nil, ""},
+		{"length overrun", false, timeExceeded(false, extensionStructure(overrun)), nil, ""},
+		{"objects up to the overrun", false, timeExceeded(false, extensionStructure(mplsObject, overrun)), []ICMPExtension{mpls}, ""},
+		{"no extension", false, timeExceeded(false, nil), nil, ""},
+		{"icmpv6 no extension", true, timeExceeded(true, nil), nil, ""},
+		{"not an error", false, fixture("0000 0000 0007 0001"), nil, ""},
+		{"truncated", false, timeExceeded(false, extensionStructure(mplsObject))[:6], nil, ""},
+	} {
+		proto := protocolICMP
+		if tc.ipv6 {
+			proto = protocolICMPv6
+		}
+		got := icmpExtensions(proto, tc.data)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: %+v, want %+v", tc.name, got, tc.want)
+			continue
+		}
+		if tc.print != "" && got[0].String() != tc.print {
+			t.Errorf("%s: printed %s, want %s", tc.name, got[0], tc.print)
+		}
+	}
+}
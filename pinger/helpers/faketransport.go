@@ -0,0 +1,186 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// fakeTransport is a transport in memory: what answers every Echo Request
+// sent through it, if anything, is up to its responder. `pinger self-test`
+// and the tests of pingICMP run probes over it, for the echo, timeout and
+// error paths to be exercised without root nor a network. Replies arrive, and deadlines pass, by clk: over
+// a fakeClock, they do at once.
+type fakeTransport struct {
+	respond fakeResponder // nil answers nothing: every probe times out
+	sendErr error         // if set, every Send fails with it
+
+	mu       sync.Mutex
+	deadline time.Time
+	pending  []fakePacket  // replies yet to be read, in the order they arrive
+	wake     chan struct{} // closed, and replaced, whenever the deadline or pending change
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// fakeResponder is the packets a fakeTransport delivers for Echo Request *msg*
+// sent to *dst*
+type fakeResponder func(msg []byte, dst net.Addr) []fakePacket
+
+// fakePacket is an ICMP message a fakeTransport delivers, *delay* after the
+// request it answers was sent
+type fakePacket struct {
+	data  []byte
+	from  net.Addr
+	meta  packetMeta
+	delay time.Duration
+
+	at time.Time // when it arrives, set on Send
+}
+
+func newFakeTransport(respond fakeResponder) *fakeTransport {
+	return &fakeTransport{
+		respond: respond,
+		wake:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (f *fakeTransport) Send(msg []byte, dst net.Addr, iface *net.Interface) error {
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	if f.respond == nil {
+		return nil
+	}
+
+	sent := clk.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, packet := range f.respond(msg, dst) {
+		packet.at = sent.Add(packet.delay)
+		i, _ := slices.BinarySearchFunc(f.pending, packet.at, func(p fakePacket, at time.Time) int {
+			// After those arriving at the same time
+			if p.at.After(at) {
+				return 1
+			}
+			return -1
+		})
+		f.pending = slices.Insert(f.pending, i, packet)
+	}
+	f.signal()
+	return nil
+}
+
+func (f *fakeTransport) Recv(b []byte) (int, packetMeta, net.Addr, error) {
+	for {
+		f.mu.Lock()
+		now, deadline, wake := clk.Now(), f.deadline, f.wake
+		if len(f.pending) > 0 && !f.pending[0].at.After(now) && (deadline.IsZero() || !f.pending[0].at.After(deadline)) {
+			packet := f.pending[0]
+			f.pending = f.pending[1:]
+			f.mu.Unlock()
+			return copy(b, packet.data), packet.meta, packet.from, nil
+		}
+		if !deadline.IsZero() && !now.Before(deadline) {
+			f.mu.Unlock()
+			return 0, packetMeta{}, nil, os.ErrDeadlineExceeded
+		}
+
+		// Wait for the next reply, or the deadline, whichever comes first
+		next := deadline
+		if len(f.pending) > 0 && (next.IsZero() || f.pending[0].at.Before(next)) {
+			next = f.pending[0].at
+		}
+		f.mu.Unlock()
+
+		var due <-chan time.Time
+		if !next.IsZero() {
+			due = clk.After(next.Sub(now))
+		}
+		select {
+		case <-due:
+		case <-wake:
+			// The deadline changed, or a reply came: wait anew
+		case <-f.closed:
+			return 0, packetMeta{}, nil, net.ErrClosed
+		}
+	}
+}
+
+func (f *fakeTransport) SetDeadline(deadline time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deadline = deadline
+	f.signal()
+	return nil
+}
+
+// signal wakes Recv up, to wait anew: f.mu is held
+func (f *fakeTransport) signal() {
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+func (f *fakeTransport) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+// fakeEchoReplies answers every Echo Request with its Echo Reply, from the
+// host it was sent to, *delay* later and with TTL *ttl*
+func fakeEchoReplies(ttl int, delay time.Duration) fakeResponder {
+	return func(msg []byte, dst net.Addr) []fakePacket {
+		if len(msg) < 8 {
+			return nil
+		}
+		reply := append([]byte(nil), msg...)
+		switch msg[0] {
+		case byte(ipv4.ICMPTypeEcho):
+			reply[0] = byte(ipv4.ICMPTypeEchoReply)
+		case byte(ipv6.ICMPTypeEchoRequest):
+			reply[0] = byte(ipv6.ICMPTypeEchoReply)
+		default:
+			return nil
+		}
+		setICMPChecksum(reply)
+		return []fakePacket{{data: reply, from: dst, meta: packetMeta{ttl: ttl}, delay: delay}}
+	}
+}
+
+// fakeUnreachable answers every ICMP Echo Request with a Destination
+// Unreachable of *code*, from router *from*, quoting it as routers do
+func fakeUnreachable(code int, from net.Addr) fakeResponder {
+	return func(msg []byte, dst net.Addr) []fakePacket {
+		ip := net.ParseIP(peerIP(dst)).To4()
+		if ip == nil || len(msg) < 8 {
+			return nil
+		}
+
+		// The IP header of the request, then its first 8 bytes at least
+		quoted := make([]byte, 20, 28+len(msg))
+		quoted[0] = 0x45
+		binary.BigEndian.PutUint16(quoted[2:4], uint16(20+len(msg)))
+		quoted[8], quoted[9] = 1, protocolICMP
+		copy(quoted[16:20], ip)
+		quoted = append(quoted, msg...)
+
+		unreachable := append([]byte{byte(ipv4.ICMPTypeDestinationUnreachable), byte(code), 0, 0, 0, 0, 0, 0}, quoted...)
+		setICMPChecksum(unreachable)
+		return []fakePacket{{data: unreachable, from: from, meta: packetMeta{ttl: defaultTTL}}}
+	}
+}
+
+// setICMPChecksum sets the checksum of ICMP message *msg*. That of ICMPv6
+// covers a pseudo-header too: those of fakeTransport are not checked.
+func setICMPChecksum(msg []byte) {
+	msg[2], msg[3] = 0, 0
+	binary.BigEndian.PutUint16(msg[2:4], ^uint16(onesComplementSum(0, msg)))
+}
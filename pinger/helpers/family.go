@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"fmt"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// family is everything pinging over IPv4 and over IPv6 differ in: the rest of
+// pingICMP, and of openSession, is the same for both
+type family struct {
+	name  string // of the protocol, for messages
+	proto int    // protocolICMP or protocolICMPv6
+	ipv6  bool   // Echo Requests are of ICMPv6 type, see WithIPv6
+
+	setHopLimit func(conn *icmp.PacketConn, ttl int) error
+	hopLimit    func(conn *icmp.PacketConn) (int, error)
+	// recvControl asks for the TTL / Hop Limit of replies, and the interface
+	// they came in through, in control messages
+	recvControl func(conn *icmp.PacketConn)
+	// options applies the settings of *info* only this family has
+	options func(conn *icmp.PacketConn, info ICMPInfo) error
+}
+
+var familyIPv4 = family{
+	name:  "ICMP",
+	proto: protocolICMP,
+
+	setHopLimit: func(conn *icmp.PacketConn, ttl int) error { return conn.IPv4PacketConn().SetTTL(ttl) },
+	hopLimit:    func(conn *icmp.PacketConn) (int, error) { return conn.IPv4PacketConn().TTL() },
+	recvControl: func(conn *icmp.PacketConn) {
+		conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL|ipv4.FlagInterface, true)
+	},
+	options: func(conn *icmp.PacketConn, info ICMPInfo) error {
+		if info.Broadcast {
+			if err := setBroadcast(conn); err != nil {
+				return fmt.Errorf("enabling broadcast: %v", err)
+			}
+		}
+		if options := info.ipOptions(); options != nil {
+			if err := enableIPOptions(conn, options); err != nil {
+				return fmt.Errorf("setting IP options: %v", err)
+			}
+		}
+		return nil
+	},
+}
+
+var familyIPv6 = family{
+	name:  "ICMPv6",
+	proto: protocolICMPv6,
+	ipv6:  true,
+
+	setHopLimit: func(conn *icmp.PacketConn, hopLimit int) error { return conn.IPv6PacketConn().SetHopLimit(hopLimit) },
+	hopLimit:    func(conn *icmp.PacketConn) (int, error) { return conn.IPv6PacketConn().HopLimit() },
+	recvControl: func(conn *icmp.PacketConn) {
+		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit|ipv6.FlagInterface, true)
+	},
+	options: func(conn *icmp.PacketConn, info ICMPInfo) error {
+		if err := preferSource(conn, info.PreferSrc); err != nil {
+			return fmt.Errorf("setting the source address preference: %v", err)
+		}
+		return nil
+	},
+}
+
+// familyOf is the family of protocol *proto*
+func familyOf(proto int) *family {
+	if proto == protocolICMPv6 {
+		return &familyIPv6
+	}
+	return &familyIPv4
+}
+
+// applyHopLimit sets the TTL / Hop Limit of *info* on *conn* or, if it has
+// none, reads back the kernel's default into it
+func (f *family) applyHopLimit(conn *icmp.PacketConn, info *ICMPInfo) {
+	if info.TTL > 0 {
+		f.setHopLimit(conn, info.TTL)
+	} else if ttl, err := f.hopLimit(conn); err == nil {
+		info.TTL, info.KernelTTL = ttl, true
+	}
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// footprint is what running costs pinger itself, for sizing the hosts that
+// measure many targets at once
+type footprint struct {
+	cpu        time.Duration // user and system CPU time
+	maxRSS     int64         // peak resident set size in bytes, 0 if unknown
+	heap       uint64        // bytes of live heap objects
+	numGC      uint32        // garbage collections so far
+	gcPause    time.Duration // total stop-the-world time of those
+	goroutines int
+}
+
+func readFootprint() footprint {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return footprint{
+		cpu:        processCPUTime(),
+		maxRSS:     processMaxRSS(),
+		heap:       mem.HeapAlloc,
+		numGC:      mem.NumGC,
+		gcPause:    time.Duration(mem.PauseTotalNs),
+		goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// annotation is the footprint as a "footprint" annotation, with the figures
+// as fields for machines
+func (f footprint) annotation() Annotation {
+	msg := fmt.Sprintf("footprint: cpu %v", f.cpu.Round(time.Millisecond))
+	fields := map[string]string{
+		"cpu_ms":      strconv.FormatInt(f.cpu.Round(time.Millisecond).Milliseconds(), 10),
+		"heap_bytes":  strconv.FormatUint(f.heap, 10),
+		"gc_count":    strconv.FormatUint(uint64(f.numGC), 10),
+		"gc_pause_us": strconv.FormatInt(f.gcPause.Microseconds(), 10),
+		"goroutines":  strconv.Itoa(f.goroutines),
+	}
+	if f.maxRSS > 0 {
+		msg += fmt.Sprintf(", max RSS %.1f MiB", float64(f.maxRSS)/(1<<20))
+		fields["max_rss_bytes"] = strconv.FormatInt(f.maxRSS, 10)
+	}
+	msg += fmt.Sprintf(", heap %.1f MiB, %d GCs (%v paused), %d goroutines",
+		float64(f.heap)/(1<<20), f.numGC, f.gcPause.Round(time.Microsecond), f.goroutines)
+
+	return Annotation{Time: clk.Now(), Kind: "footprint", Message: msg, Fields: fields}
+}
+
+// annotateFootprint notes pinger's own footprint so far, in verbose mode and
+// in JSON output
+func annotateFootprint(info ICMPInfo, printer Printer) {
+	if !info.Verbose && info.Format != "json" {
+		return
+	}
+	printer.Annotate(readFootprint().annotation())
+}
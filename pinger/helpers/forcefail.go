@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// Failures --force-fail can simulate
+const (
+	FailTimeout     = "timeout"     // no probe is answered
+	FailUnreachable = "unreachable" // every probe gets a Destination Host Unreachable
+	FailResolve     = "resolve"     // the destination cannot be resolved
+)
+
+// ValidateForceFail checks that *failure* is one --force-fail can simulate
+func ValidateForceFail(failure string) error {
+	switch failure {
+	case FailTimeout, FailUnreachable, FailResolve:
+		return nil
+	}
+	return fmt.Errorf("invalid --force-fail %q: want %s, %s or %s", failure, FailTimeout, FailUnreachable, FailResolve)
+}
+
+// ForcedResolveError is the error a failed lookup of *host* returns
+func ForcedResolveError(host string) error {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// forcedProbes is how many probes ForceFailHandler fails when the run is
+// Continuous, as it cannot wait to be interrupted
+const forcedProbes = 5
+
+// ForceFailHandler runs like ICMP4Handler / ICMP6Handler would if every probe
+// to info.IP met *failure*, without opening a socket or sending anything, and
+// without waiting: wrapper scripts get the same output and exit status,
+// deterministically and at once. It returns that exit status.
+func ForceFailHandler(info ICMPInfo, failure string) int {
+	stats := newPingStats(info)
+	if info.CNT == Continuous {
+		info.CNT = forcedProbes
+	}
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	if info.TTL == 0 {
+		info.TTL, info.KernelTTL = defaultTTL, true
+	}
+
+	printer.Header(info)
+
+	if info.SelfTarget {
+		printer.Annotate(selfTargetAnnotation(info.IP))
+	}
+
+	for i := range info.CNT {
+		stats.transmitted++
+		report(info, forcedResult(failure, info.IP, i), &stats, printer)
+	}
+
+	printer.Summary(info.IP, &stats)
+
+	return exitStatus(&stats)
+}
+
+// forcedResult is the outcome of probe *seq* to *ip*, as if it met *failure*
+func forcedResult(failure string, ip string, seq int) ProbeResult {
+	res := ProbeResult{Seq: seq, Time: clk.Now()}
+
+	switch failure {
+	case FailUnreachable:
+		res.Peer, res.Reason = ip, ReasonUnreachableHost
+		res.Detail = unreachableMessage(ReasonUnreachableHost, 1)
+	default:
+		res.Reason, res.Detail = ReasonTimeout, "no reply"
+	}
+
+	return res
+}
@@ -0,0 +1,178 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// groupWindow is how long replies to each broadcast / multicast probe are
+// collected, which is also the interval between probes
+const groupWindow = time.Second
+
+// manyResponders reports whether many hosts may answer each probe to info.IP,
+// a broadcast or multicast address
+func (info ICMPInfo) manyResponders() bool {
+	return info.Broadcast || info.Multicast
+}
+
+// IsBroadcastAddr reports whether *ip* is the broadcast address of a network
+// this host is on, which the kernel only lets us ping with SO_BROADCAST set
+func IsBroadcastAddr(ip string) bool {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return false
+	}
+	if addr.Equal(net.IPv4bcast) {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		ones, bits := ipnet.Mask.Size()
+		if bits != 32 || ones >= 31 {
+			continue
+		}
+
+		broadcast := make(net.IP, 4)
+		for i := range broadcast {
+			broadcast[i] = ipnet.IP.To4()[i] | ^ipnet.Mask[i]
+		}
+		if addr.Equal(broadcast) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setBroadcast allows sending to broadcast addresses over *conn*
+func setBroadcast(conn *icmp.PacketConn) error {
+	return controlSocket(conn, protocolICMP, setSockoptBroadcast)
+}
+
+// setMulticast sends multicast probes through *iface* (the kernel's choice if nil)
+// with TTL / Hop Limit *ttl* (the kernel's default of 1 if 0)
+func setMulticast(conn *icmp.PacketConn, proto int, iface *net.Interface, ttl int) error {
+	if proto == protocolICMPv6 {
+		p := conn.IPv6PacketConn()
+		if ttl > 0 {
+			if err := p.SetMulticastHopLimit(ttl); err != nil {
+				return err
+			}
+		}
+		if iface != nil {
+			return p.SetMulticastInterface(iface)
+		}
+		return nil
+	}
+
+	p := conn.IPv4PacketConn()
+	if ttl > 0 {
+		if err := p.SetMulticastTTL(ttl); err != nil {
+			return err
+		}
+	}
+	if iface != nil {
+		return p.SetMulticastInterface(iface)
+	}
+	return nil
+}
+
+// groupAnnotation warns that the replies come from whichever hosts care to answer
+func groupAnnotation(info ICMPInfo) Annotation {
+	kind := "broadcast"
+	if info.Multicast {
+		kind = "multicast"
+	}
+
+	return Annotation{
+		Time:    clk.Now(),
+		Kind:    kind,
+		Message: fmt.Sprintf("%s is a %s address: every host answering is reported, the first one per probe as the reply and the others as duplicates", info.IP, kind),
+		Fields:  map[string]string{"addr": info.IP},
+	}
+}
+
+// responderStats are the statistics of each host answering a broadcast / multicast ping
+type responderStats struct {
+	info  ICMPInfo // of the run, see newPingStats
+	peers []string // in order of first answer
+	stats map[string]*PingStats
+}
+
+func newResponderStats(info ICMPInfo) *responderStats {
+	return &responderStats{info: info, stats: make(map[string]*PingStats)}
+}
+
+// record folds the answer *res* into the statistics of its sender
+func (r *responderStats) record(res ProbeResult) {
+	stats, ok := r.stats[res.Peer]
+	if !ok {
+		peerStats := newPingStats(r.info)
+		stats = &peerStats
+		r.stats[res.Peer] = stats
+		r.peers = append(r.peers, res.Peer)
+	}
+
+	res.Duplicate = false
+	stats.record(res)
+}
+
+// summarize hands the statistics of every responder to *printer*, as a table:
+// each was sent all of the *transmitted* probes
+func (r *responderStats) summarize(transmitted int, printer Printer) {
+	if len(r.peers) == 0 {
+		return
+	}
+
+	stats := make([]*PingStats, len(r.peers))
+	for i, peer := range r.peers {
+		stats[i] = r.stats[peer]
+		stats[i].transmitted = transmitted
+	}
+	printer.SummaryTable(r.peers, stats)
+}
+
+// awaitAllReplies reads from *t* until its deadline, reporting every host
+// answering probe *seq*: the first as the reply, later ones as duplicates.
+// Each host is only reported once per probe, and its answer also goes to *responders*.
+func awaitAllReplies(info ICMPInfo, t transport, proto int, id int, startTime time.Time, seq int, stats *PingStats, responders *responderStats, printer Printer, stop *interruption) {
+	answered := make(map[string]bool)
+	for {
+		reply, elapsedMs, meta, peerAddr, err := recvICMPRequest(startTime, t)
+		if err != nil {
+			// The window closing is only a timeout if nobody answered, and
+			// being cut short by Ctrl + C never is
+			if stop.stopped() {
+				return
+			}
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() || len(answered) == 0 {
+				report(info, readErrorResult(err, seq), stats, printer)
+			}
+			return
+		}
+
+		res, final := parseICMPResponse(proto, id, reply, peerAddr, seq, meta, elapsedMs)
+		putPacket(reply)
+		if !final || answered[res.Peer] {
+			continue
+		}
+		res.Duplicate = len(answered) > 0
+		answered[res.Peer] = true
+		report(info, res, stats, printer)
+
+		if res.Reason == ReasonNone {
+			responders.record(res)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// grpcService is the path prefix of the methods of the service of pinger.proto
+const grpcService = "/pinger.v1.Pinger/"
+
+// grpcMaxMessage is the largest request ServeGRPC reads: they are small
+const grpcMaxMessage = 64 << 10
+
+// gRPC status codes, of the grpc-status trailer
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcNotFound        = 5
+	grpcExhausted       = 8
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+)
+
+// grpcError is a failed call, with its gRPC status code
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+// ServeGRPC serves the gRPC service of pinger.proto on *listen*, over HTTP/2
+// without TLS, until killed: for other programs to start ping jobs, stop
+// them, and stream their results. Jobs run as *config* asks, with the
+// options of the job on top.
+func ServeGRPC(listen string, config JobConfig) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		slog.Error("listening", "addr", listen, "err", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("pinger serve listening on grpc %s\n", ln.Addr())
+	notifyReady(fmt.Sprintf("listening on grpc %s", ln.Addr()), nil)
+
+	server := &http.Server{
+		Handler:   &grpcServer{jobs: newJobManager(config)},
+		Protocols: new(http.Protocols),
+	}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	err = server.Serve(ln)
+	slog.Error("serving", "err", err)
+	os.Exit(1)
+}
+
+// grpcServer runs the calls of gRPC clients, on its jobs
+type grpcServer struct {
+	jobs *jobManager
+}
+
+func (g *grpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "pinger serves gRPC only, over HTTP/2 without TLS", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	// The status of the call goes in the trailers, after the responses
+	err := g.call(w, r)
+	status := grpcOK
+	var callErr *grpcError
+	if errors.As(err, &callErr) {
+		status = callErr.code
+	} else if err != nil {
+		status = grpcInternal
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(status))
+	if err != nil {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(err.Error()))
+	}
+}
+
+// call runs the method of *r*, writing its responses to *w*
+func (g *grpcServer) call(w http.ResponseWriter, r *http.Request) error {
+	method, _ := strings.CutPrefix(r.URL.Path, grpcService)
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case "StartJob":
+		j, err := g.jobs.start(parseStartJob(req))
+		if errors.Is(err, errTooManyJobs) {
+			return &grpcError{grpcExhausted, err.Error()}
+		} else if err != nil {
+			return &grpcError{grpcInvalidArgument, err.Error()}
+		}
+		return writeGRPCMessage(w, jobMessage(j.status()))
+
+	case "GetJob", "StopJob", "StreamResults":
+		id := string(req[1].bytes)
+		var j *job
+		if method == "StopJob" {
+			j = g.jobs.stop(id)
+		} else {
+			j = g.jobs.get(id)
+		}
+		if j == nil {
+			return &grpcError{grpcNotFound, fmt.Sprintf("no job %q", id)}
+		}
+
+		if method == "StreamResults" {
+			return streamResults(w, r, j)
+		}
+		return writeGRPCMessage(w, jobMessage(j.status()))
+	}
+	return &grpcError{grpcUnimplemented, "unknown method " + r.URL.Path}
+}
+
+// streamResults writes every result of *j* to *w*, those so far, then the
+// others as they come, until the job is over or the client gone
+func streamResults(w http.ResponseWriter, r *http.Request, j *job) error {
+	rc := http.NewResponseController(w)
+	rc.Flush()
+
+	for sent := 0; ; {
+		results, over, changed := j.next(sent)
+		for _, res := range results {
+			if err := writeGRPCMessage(w, resultMessage(res)); err != nil {
+				return err
+			}
+		}
+		sent += len(results)
+		rc.Flush()
+		if over {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+// readGRPCMessage reads the one message of a unary request, or of a request
+// to stream, from *body*
+func readGRPCMessage(body io.Reader) (map[int]protoField, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "no request message"}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, &grpcError{grpcInvalidArgument, fmt.Sprintf("request of %d bytes: %d at most", size, grpcMaxMessage)}
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated request message"}
+	}
+	fields, err := parseProto(data)
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	return fields, nil
+}
+
+// writeGRPCMessage writes *m* to *w*, length-prefixed as gRPC frames messages
+func writeGRPCMessage(w io.Writer, m protoMessage) error {
+	frame := make([]byte, 5, 5+len(m))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(m)))
+	_, err := w.Write(append(frame, m...))
+	return err
+}
+
+// parseStartJob is the job a StartJobRequest asks for
+func parseStartJob(req map[int]protoField) jobSpec {
+	return jobSpec{
+		Target:   string(req[1].bytes),
+		Count:    int(int32(req[2].varint)),
+		Interval: time.Duration(int64(req[3].varint)) * time.Millisecond,
+		Timeout:  time.Duration(int64(req[4].varint)) * time.Millisecond,
+		IPv6:     req[5].varint != 0,
+		Iface:    string(req[6].bytes),
+		TTL:      int(int32(req[7].varint)),
+	}
+}
+
+// jobMessage is *status* as a Job message
+func jobMessage(status jobStatus) protoMessage {
+	var summary protoMessage
+	summary.varint(1, int64(status.Summary.Transmitted))
+	summary.varint(2, int64(status.Summary.Received))
+	summary.varint(3, int64(status.Summary.Errors))
+	summary.double(4, status.Summary.Loss)
+	summary.double(5, durationMs(status.Summary.Min))
+	summary.double(6, durationMs(status.Summary.Avg))
+	summary.double(7, durationMs(status.Summary.Max))
+	summary.double(8, durationMs(status.Summary.StdDev))
+	summary.double(9, durationMs(status.Summary.Jitter))
+
+	var m protoMessage
+	m.string(1, status.ID)
+	m.string(2, status.Spec.Target)
+	m.string(3, status.Target)
+	m.string(4, status.State)
+	m.string(5, status.Error)
+	m.varint(6, status.Started.UnixNano())
+	if !status.Ended.IsZero() {
+		m.varint(7, status.Ended.UnixNano())
+	}
+	m.message(8, summary)
+	return m
+}
+
+// resultMessage is *res* as a Result message
+func resultMessage(res ext.Result) protoMessage {
+	var m protoMessage
+	m.varint(1, int64(res.Seq))
+	m.string(2, res.Peer)
+	m.double(3, durationMs(res.RTT))
+	m.string(4, res.Reason)
+	m.string(5, res.Detail)
+	m.varint(6, res.Time.UnixNano())
+	m.bool(7, res.Duplicate)
+	m.stringMap(8, res.Fields)
+	return m
+}
+
+// durationMs is *d* in milliseconds, as pinger prints times
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	histogramBuckets = 10 // about how many buckets a histogram has
+	histogramBar     = 40 // length of the bar of the fullest bucket, in characters
+)
+
+// histogramBucket counts the RTTs in [From, To), in milliseconds
+type histogramBucket struct {
+	From  float64 `json:"from_ms"`
+	To    float64 `json:"to_ms"`
+	Count int     `json:"count"`
+}
+
+// histogram buckets the RTTs of the replies recorded, in buckets of a round
+// width (1, 2 or 5 times a power of ten) spanning min to max. It is empty
+// without replies.
+func (stats *PingStats) histogram() []histogramBucket {
+	if len(stats.samples) == 0 {
+		return nil
+	}
+
+	width := roundStep((stats.max - stats.min) / histogramBuckets)
+	start := math.Floor(stats.min/width) * width
+	n := int(math.Floor((stats.max-start)/width)) + 1
+
+	buckets := make([]histogramBucket, n)
+	for i := range buckets {
+		buckets[i].From, buckets[i].To = start+float64(i)*width, start+float64(i+1)*width
+	}
+	for _, rtt := range stats.samples {
+		i := min(max(int(math.Floor((rtt-start)/width)), 0), n-1)
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// roundStep is the smallest of 1, 2 or 5 times a power of ten that is at
+// least *x*, for bucket widths people can read. RTTs are kept to the
+// microsecond, so no step is finer.
+func roundStep(x float64) float64 {
+	if x <= 0.001 {
+		return 0.001
+	}
+	power := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*power >= x {
+			return m * power
+		}
+	}
+	return 10 * power
+}
+
+// printHistogram prints the histogram of the RTTs of *stats*, one bar per bucket
+func (p *textPrinter) printHistogram(target string, stats *PingStats) {
+	buckets := stats.histogram()
+	if len(buckets) == 0 {
+		return
+	}
+
+	fullest := 0
+	for _, b := range buckets {
+		fullest = max(fullest, b.Count)
+	}
+
+	unit, scale := p.rtt.unit(buckets[len(buckets)-1].To)
+	edges := make([]string, 0, 2*len(buckets))
+	for _, b := range buckets {
+		edges = append(edges, fmt.Sprintf("%.*f", p.rtt.Precision, b.From/scale), fmt.Sprintf("%.*f", p.rtt.Precision, b.To/scale))
+	}
+	pad := 0
+	for _, edge := range edges {
+		pad = max(pad, len(edge))
+	}
+
+	fmt.Fprintf(p.w, "RTT histogram of %s:\n", target)
+	for i, b := range buckets {
+		bar := strings.Repeat("#", int(math.Round(float64(b.Count)/float64(fullest)*histogramBar)))
+		fmt.Fprintf(p.w, "%*s - %*s %s |%-*s %d\n", pad, edges[2*i], pad, edges[2*i+1], unit, histogramBar, bar, b.Count)
+	}
+}
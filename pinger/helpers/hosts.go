@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lookupHostsFile is the addresses *path*, in hosts(5) format, gives *host*,
+// in file order: none if it does not name it
+func lookupHostsFile(path, host string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading hosts file: %v", err)
+	}
+	defer f.Close()
+
+	host = strings.TrimSuffix(host, ".")
+
+	var addrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		addr, _ := SplitZone(fields[0])
+		if _, err := valIPv6(addr); err != nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if strings.EqualFold(strings.TrimSuffix(name, "."), host) {
+				addrs = append(addrs, addr)
+				break
+			}
+		}
+	}
+
+	return addrs, scanner.Err()
+}
+
+// hostsOverride is the addresses the hosts files give *host*, and the file
+// they come from: *custom*, if set, then the system's. The resolver consults
+// the system's hosts file too, but cannot tell whether an answer came from it.
+func hostsOverride(host, custom string) ([]string, string, error) {
+	for _, path := range []string{custom, systemHostsFile} {
+		if path == "" {
+			continue
+		}
+
+		addrs, err := lookupHostsFile(path, host)
+		if err != nil {
+			// The system's hosts file is optional, a custom one not
+			if path == custom {
+				return nil, "", err
+			}
+			continue
+		}
+		if len(addrs) > 0 {
+			return addrs, path, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// HostNames are the hostnames this host knows of, for completing targets:
+// those of the hosts files, *custom* if set and the system's, and the hosts of
+// ~/.ssh/config. Unreadable files are skipped.
+func HostNames(custom string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		name = strings.TrimSuffix(name, ".")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, path := range []string{custom, systemHostsFile} {
+		if path == "" {
+			continue
+		}
+		forEachLine(path, func(line string) {
+			line, _, _ = strings.Cut(line, "#")
+			if fields := strings.Fields(line); len(fields) > 1 {
+				for _, name := range fields[1:] {
+					add(name)
+				}
+			}
+		})
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		forEachLine(filepath.Join(home, ".ssh", "config"), func(line string) {
+			// Host <pattern>..., or Host=<pattern>...
+			fields := strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' || r == '=' })
+			if len(fields) < 2 || !strings.EqualFold(fields[0], "Host") {
+				return
+			}
+			for _, pattern := range fields[1:] {
+				if !strings.ContainsAny(pattern, "*?!") {
+					add(pattern)
+				}
+			}
+		})
+	}
+
+	return names
+}
+
+// forEachLine calls *fn* with every line of the file *path*, if it can be read
+func forEachLine(path string, fn func(line string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+}
+
+// ReadTargetsFile is the hosts the file *path* lists, one a line as a rule,
+// with # comments
+func ReadTargetsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		hosts = append(hosts, strings.Fields(line)...)
+	}
+	return hosts, nil
+}
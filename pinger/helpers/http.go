@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+)
+
+// HTTPOptions are the settings of `pinger http`
+type HTTPOptions struct {
+	Method  string        // HEAD or GET
+	Timeout time.Duration // for each request, up to the first response byte
+	Network string        // "tcp", or "tcp4" / "tcp6" for -4 / -6
+}
+
+// httpPhases are the steps of an HTTP probe, in the order they happen
+var httpPhases = []string{"dns", "connect", "tls", "ttfb"}
+
+// httpTimer notes when each step of one request starts and ends
+type httpTimer struct {
+	start                    time.Time
+	dnsStart, dnsDone        time.Time
+	connectStart, connectEnd time.Time
+	tlsStart, tlsDone        time.Time
+	firstByte                time.Time
+	remote                   string
+}
+
+func (t *httpTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connectEnd = time.Now() },
+		TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		GotConn:              func(info httptrace.GotConnInfo) { t.remote = info.Conn.RemoteAddr().String() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+}
+
+// phases lists the steps that happened, skipping e.g. DNS for an IP address URL
+func (t *httpTimer) phases() []Phase {
+	var phases []Phase
+	add := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			phases = append(phases, Phase{Name: name, RTT: float64(to.Sub(from).Nanoseconds()) / 1e6})
+		}
+	}
+
+	add("dns", t.dnsStart, t.dnsDone)
+	add("connect", t.connectStart, t.connectEnd)
+	add("tls", t.tlsStart, t.tlsDone)
+	add("ttfb", t.start, t.firstByte)
+
+	return phases
+}
+
+// httpClient sends every request over a new connection, so that each probe
+// times the whole exchange, and does not follow redirects
+func httpClient(info ICMPInfo, opts HTTPOptions) (*http.Client, error) {
+	dialer, err := localDialer(info, opts.Network == "tcp6")
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, opts.Network, addr)
+		},
+		DisableKeepAlives: true,
+		Proxy:             http.ProxyFromEnvironment,
+	}
+
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       opts.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}, nil
+}
+
+// httpProbe sends request *seq* to *target*, and times its steps up to the
+// first byte of the response. Any response counts as a reply, whatever its
+// status. Cancelling *ctx* cuts it short.
+func httpProbe(ctx context.Context, client *http.Client, method string, target string, seq int) ProbeResult {
+	res := ProbeResult{Transport: "http", Seq: seq}
+
+	timer := &httpTimer{}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.trace()))
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	timer.start = time.Now()
+	resp, err := client.Do(req)
+	res.Time = time.Now()
+	res.Peer, res.Phases = timer.remote, timer.phases()
+	if res.Peer == "" {
+		res.Peer = req.URL.Host
+	}
+
+	if err != nil {
+		res.RTT = elapsedMsSince(timer.start)
+		res.Detail = err.Error()
+
+		var netErr net.Error
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			res.Reason, res.Detail = ReasonRefused, "connection refused"
+		case errors.As(err, &netErr) && netErr.Timeout():
+			res.Reason = ReasonTimeout
+		default:
+			res.Reason = ReasonSendError
+		}
+		return res
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	res.Status = resp.StatusCode
+	res.RTT = float64(timer.firstByte.Sub(timer.start).Nanoseconds()) / 1e6
+
+	return res
+}
+
+// HTTPHandler sends an HTTP request to *target* once per second, and reports
+// how long DNS, connecting, TLS and the first response byte took, to compare the
+// network RTT with the application's latency. info.CNT, info.Iface, info.Format and
+// info.RTT apply. Statistics are kept per step. It returns the exit status, of
+// the first response byte.
+func HTTPHandler(target string, info ICMPInfo, opts HTTPOptions) int {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		slog.Error(fmt.Sprintf("invalid URL %q: want http://<host>[/path] or https://<host>[/path]", target))
+		os.Exit(ExitUsage)
+	}
+	redactName(u.Hostname())
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	client, err := httpClient(info, opts)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// one PingStats per step, labelled "<url> (<step>)"
+	labels := make([]string, len(httpPhases))
+	stats := make([]*PingStats, len(httpPhases))
+	for i, phase := range httpPhases {
+		phaseStats := newPingStats(info)
+		labels[i], stats[i] = fmt.Sprintf("%s (%s)", target, phase), &phaseStats
+	}
+
+	// Steps that never happen, like DNS for an IP address, are left out of the summary
+	summary := func() {
+		var usedLabels []string
+		var usedStats []*PingStats
+		for i := range httpPhases {
+			if stats[i].received > 0 || i == len(httpPhases)-1 {
+				usedLabels, usedStats = append(usedLabels, labels[i]), append(usedStats, stats[i])
+			}
+		}
+		printer.SummaryTable(usedLabels, usedStats)
+	}
+
+	// Graceful termination, the usual ending, with Ctrl + C: the request in
+	// flight is cut short, and the statistics printed as at the end
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := onInterrupt(cancel)
+
+	info.IP, info.Transport = target, "http"
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	ttfb := len(httpPhases) - 1
+	notifyInterim(func() { printInterim(printer, labels[ttfb], stats[ttfb]) })
+	defer summarizeEvery(info.SummaryEvery, printer, labels[ttfb:], stats[ttfb])()
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		res := httpProbe(ctx, client, opts.Method, target, i)
+		// Cut short by Ctrl + C: not an outcome of the probe
+		if res.Reason != ReasonNone && stop.stopped() {
+			break
+		}
+		res.Target = target
+		printer.Probe(res)
+
+		for j, name := range httpPhases {
+			stats[j].sent()
+			if res.Reason != ReasonNone {
+				stats[j].record(res)
+				continue
+			}
+			for _, phase := range res.Phases {
+				if phase.Name == name {
+					stats[j].record(ProbeResult{RTT: phase.RTT})
+				}
+			}
+		}
+
+		stop.sleep(time.Second)
+	}
+
+	summary()
+	return exitStatus(stats[ttfb])
+}
@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Clocks the RTTs of ICMPInfo.HWTimestamps are timed by, from the best
+const (
+	ClockHardware  = "hardware"  // the PTP clock of the NIC, stamping the request out and the reply in
+	ClockKernel    = "kernel"    // the software timestamps of the kernel, of the request out and the reply in
+	ClockUserspace = "userspace" // from just before pinger sends the request, as without hardware timestamps
+)
+
+// txStampWait is how long a reply waits for the transmit timestamp of its
+// request: a NIC reports it once the request is out, which, on a fast link,
+// can be after the reply is in
+const txStampWait = 10 * time.Millisecond
+
+// txStamp is when a request left, as the error queue of its socket reports
+type txStamp struct {
+	key      uint32    // the number of the packet on the socket, SOF_TIMESTAMPING_OPT_ID
+	software time.Time // zero if not reported (yet)
+	hardware time.Time
+}
+
+// enableHWTimestamps has the NIC *iface* stamp what it sends and receives, and
+// RTTs timed by those stamps from then on: with nanosecond resolution, and
+// none of the time the request and the reply spend in the kernel and its
+// queues. NICs that cannot make the kernel stamp them in software instead.
+// The annotation returned says which clock the RTTs are timed by, and why.
+func (t *icmpTransport) enableHWTimestamps(iface *net.Interface) (Annotation, error) {
+	var hwErr error
+	err := controlSocket(t.conn, t.proto, func(fd uintptr) error {
+		var err error
+		hwErr, err = setHWTimestamping(fd, iface.Name)
+		return err
+	})
+	if err != nil {
+		return Annotation{}, fmt.Errorf("asking for the timestamps of %s: %v", iface.Name, err)
+	}
+	t.hw, t.hwClock = true, hwErr == nil
+
+	note := Annotation{Time: clk.Now(), Kind: "timestamps", Fields: map[string]string{"iface": iface.Name}}
+	if t.hwClock {
+		note.Message = fmt.Sprintf("RTTs are timed by the hardware clock of %s, stamping the probes out and the replies in", iface.Name)
+		note.Fields["clock"] = ClockHardware
+	} else {
+		note.Message = fmt.Sprintf("%s cannot stamp packets in hardware (%v): RTTs are timed by the software timestamps of the kernel instead", iface.Name, hwErr)
+		note.Fields["clock"], note.Fields["err"] = ClockKernel, hwErr.Error()
+	}
+	return note, nil
+}
+
+// beforeSend forgets the transmit timestamps of the requests sent before, so
+// that the next one read is that of the request about to be sent
+func (t *icmpTransport) beforeSend() {
+	for _, stamp := range t.readTxStamps() {
+		t.txSeen, t.txAny = stamp.key, true
+	}
+	t.tx, t.txKnown = txStamp{}, false
+}
+
+// stampRTT times the RTT of the packet of *meta* from the transmit timestamp
+// of the last request, by the best clock both have a stamp of
+func (t *icmpTransport) stampRTT(meta *packetMeta) {
+	for deadline := time.Now().Add(txStampWait); ; {
+		for _, stamp := range t.readTxStamps() {
+			if t.txAny && int32(stamp.key-t.txSeen) <= 0 {
+				continue
+			}
+			// The software and hardware stamps come one after the other
+			if !t.txKnown {
+				t.tx, t.txKnown = txStamp{key: stamp.key}, true
+			}
+			if stamp.key == t.tx.key {
+				t.tx.software = cmpOr(stamp.software, t.tx.software)
+				t.tx.hardware = cmpOr(stamp.hardware, t.tx.hardware)
+			}
+		}
+		complete := !t.tx.software.IsZero() && (!t.hwClock || !t.tx.hardware.IsZero())
+		if complete || !time.Now().Before(deadline) {
+			break
+		}
+		t.waitTxStamps(time.Until(deadline))
+	}
+
+	meta.clock = ClockUserspace
+	if !t.tx.hardware.IsZero() && !meta.hwReceived.IsZero() && meta.hwReceived.After(t.tx.hardware) {
+		meta.stampedRTT, meta.clock = meta.hwReceived.Sub(t.tx.hardware), ClockHardware
+	} else if !t.tx.software.IsZero() && !meta.received.IsZero() && meta.received.After(t.tx.software) {
+		meta.stampedRTT, meta.clock = meta.received.Sub(t.tx.software), ClockKernel
+	}
+}
+
+// readTxStamps reads the transmit timestamps the error queue of the socket
+// holds, without waiting
+func (t *icmpTransport) readTxStamps() []txStamp {
+	var stamps []txStamp
+	controlSocket(t.conn, t.proto, func(fd uintptr) error {
+		stamps = readTxTimestamps(fd)
+		return nil
+	})
+	return stamps
+}
+
+// waitTxStamps waits up to *d* for a transmit timestamp in the error queue
+func (t *icmpTransport) waitTxStamps(d time.Duration) {
+	controlSocket(t.conn, t.proto, func(fd uintptr) error {
+		waitTxTimestamps(fd, d)
+		return nil
+	})
+}
+
+// cmpOr is *a*, or *b* if *a* is zero
+func cmpOr(a, b time.Time) time.Time {
+	if a.IsZero() {
+		return b
+	}
+	return a
+}
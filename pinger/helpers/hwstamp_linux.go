@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sizeofScmTimestamping is the size of the SCM_TIMESTAMPING control message:
+// the software timestamp, a deprecated one, and the hardware timestamp
+const sizeofScmTimestamping = int(unsafe.Sizeof(unix.ScmTimestamping{}))
+
+// setHWTimestamping turns on the hardware timestamping of what NIC *ifname*
+// sends and receives, unless it is on already, and asks for SO_TIMESTAMPING
+// control messages on socket *fd*: those of the NIC, if it could, and the
+// software ones of the kernel. Transmit timestamps come on the error queue,
+// numbered, without the packet. *hwErr* is why the NIC cannot stamp.
+func setHWTimestamping(fd uintptr, ifname string) (hwErr error, err error) {
+	config, hwErr := unix.IoctlGetHwTstamp(int(fd), ifname)
+	if hwErr == nil && (config.Tx_type != unix.HWTSTAMP_TX_ON || config.Rx_filter != unix.HWTSTAMP_FILTER_ALL) {
+		config = &unix.HwTstampConfig{Tx_type: unix.HWTSTAMP_TX_ON, Rx_filter: unix.HWTSTAMP_FILTER_ALL}
+		hwErr = unix.IoctlSetHwTstamp(int(fd), ifname, config)
+	}
+
+	flags := unix.SOF_TIMESTAMPING_SOFTWARE | unix.SOF_TIMESTAMPING_TX_SOFTWARE | unix.SOF_TIMESTAMPING_RX_SOFTWARE |
+		unix.SOF_TIMESTAMPING_OPT_ID | unix.SOF_TIMESTAMPING_OPT_TSONLY
+	if hwErr == nil {
+		flags |= unix.SOF_TIMESTAMPING_RAW_HARDWARE | unix.SOF_TIMESTAMPING_TX_HARDWARE | unix.SOF_TIMESTAMPING_RX_HARDWARE
+	}
+	return hwErr, unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TIMESTAMPING, flags)
+}
+
+// readTxTimestamps reads the transmit timestamps on the error queue of socket
+// *fd*, without waiting
+func readTxTimestamps(fd uintptr) []txStamp {
+	var stamps []txStamp
+	oob := make([]byte, unix.CmsgSpace(sizeofScmTimestamping)+unix.CmsgSpace(int(unsafe.Sizeof(unix.SockExtendedErr{}))+unix.SizeofSockaddrInet6))
+	for {
+		_, oobn, _, _, err := unix.Recvmsg(int(fd), nil, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+		if err != nil {
+			return stamps
+		}
+		messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			continue
+		}
+
+		var (
+			stamp   txStamp
+			stamped bool
+		)
+		for _, m := range messages {
+			switch {
+			case m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPING && len(m.Data) >= sizeofScmTimestamping:
+				ts := (*unix.ScmTimestamping)(unsafe.Pointer(&m.Data[0]))
+				stamp.software, stamp.hardware = timespecTime(ts.Ts[0]), timespecTime(ts.Ts[2])
+			case (m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_RECVERR) ||
+				(m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_RECVERR):
+				if len(m.Data) < int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+					continue
+				}
+				ee := (*unix.SockExtendedErr)(unsafe.Pointer(&m.Data[0]))
+				if ee.Origin == unix.SO_EE_ORIGIN_TIMESTAMPING {
+					stamp.key, stamped = ee.Data, true
+				}
+			}
+		}
+		if stamped {
+			stamps = append(stamps, stamp)
+		}
+	}
+}
+
+// waitTxTimestamps waits up to *d* for the error queue of socket *fd* to
+// hold something
+func waitTxTimestamps(fd uintptr, d time.Duration) {
+	unix.Poll([]unix.PollFd{{Fd: int32(fd)}}, int(max(d.Milliseconds(), 1)))
+}
+
+// timespecTime is *ts* as a time, zero if it is
+func timespecTime(ts unix.Timespec) time.Time {
+	if ts.Sec == 0 && ts.Nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts.Unix())
+}
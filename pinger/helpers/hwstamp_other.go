@@ -0,0 +1,18 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+func setHWTimestamping(fd uintptr, ifname string) (hwErr error, err error) {
+	return nil, fmt.Errorf("SO_TIMESTAMPING is only supported on Linux")
+}
+
+func readTxTimestamps(fd uintptr) []txStamp {
+	return nil
+}
+
+func waitTxTimestamps(fd uintptr, d time.Duration) {}
@@ -2,13 +2,12 @@ package helpers
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
 	"net"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
-	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
@@ -18,378 +17,453 @@ const (
 	protocolICMPv6 = 58 // IPv6 ICMP protocol number
 	pingDataSize   = 64 // Standard ping packet size
 	defaultTTL     = 64 // Default time to live
+
+	// Linux has it as 1 second, MS as 4 seconds, so may modify...
+	replyTimeout = 4 * time.Second // how long to wait for each Echo Reply
 )
 
+// Continuous is the ICMPInfo.CNT of runs that go on until interrupted, by
+// Ctrl + C or a limit, as ping(8) does without -c: the default
+const Continuous = math.MaxInt
+
 // ICMPInfo is everything user - configurable of a PINGER
 type ICMPInfo struct {
-	IP    string
-	Iface string
-	TTL   int
-	CNT   int
+	IP          string
+	CNAMEs      []string // CNAME chain IP was resolved through, see CNAMEChain
+	HostsFile   string   // hosts file IP was taken from, instead of DNS
+	Iface       string
+	Source      string // local address to send from (-S), "" lets the kernel choose
+	TTL         int    // TTL / Hop Limit, 0 leaves the kernel's default
+	TOS         int    // IPv4 TOS byte / IPv6 Traffic Class of the probes, ECN bits included
+	ECN         bool   // report the TOS / Traffic Class of replies, and whether their ECN bits changed
+	RecordRoute bool   // send IPv4 probes with the Record Route option, and report the route replies recorded
+	Timestamp   string // send IPv4 probes with a Timestamp option of this kind, IPTimestampOnly or IPTimestampAndAddr
+	CNT         int
+	Format      string    // output format: text or json
+	RTT         RTTFormat // how to render round trip times
+
+	Transport string // "tcp" / "udp" to probe Port with TCP connects / UDP datagrams, instead of ICMP Echo
+	Port      int
+
+	Privileged bool   // try a raw socket first; false forces an ICMP datagram socket
+	PreferSrc  string // IPv6 source address kind: PreferTemporary, PreferPublic, or "" for the kernel's choice
+
+	KernelTTL  bool // TTL was not configured, and was read back from the socket
+	SelfTarget bool // IP is an address of this host, see IsLocalAddr
+	Broadcast  bool // IP is a broadcast address: collect the replies of every host, see IsBroadcastAddr
+	Multicast  bool // IP is a multicast group: collect the replies of every member
+
+	Verbose   bool   // also report details such as the MAC address of on-link targets
+	Numeric   bool   // print peers as addresses only, without reverse DNS lookups
+	DNSServer string // resolver ("host:port") of reverse DNS lookups, "" for the system's
+	Control   string // Unix socket path to take interval / size / timeout changes on, see serveControl
+	Strict    bool   // exit with an error on the first answer that is not a matching Echo Reply, see strictViolation
+	Histogram bool   // end the statistics with a histogram of the RTTs
+
+	HWTimestamps bool // time RTTs by the timestamps of the NIC of Iface, see enableHWTimestamps
+
+	NAT64        *NAT64Mapping // how IP was synthesized from the IPv4 address given, see SynthesizeNAT64
+	SummaryEvery time.Duration // print the statistics of every such window of the run, 0 for none
+	Intervals    time.Duration // start the statistics with a table of every such interval of the run, if it spans several; 0 for none
 }
 
-// getInterface checks if interfaceName device exists,
-// and returns a pointer to it if it does, else nil
-func getInterface(interfaceName string) *net.Interface {
-	var hostIface *net.Interface
-
-	if interfaceName == "" {
-		return nil
+// sendICMPRequest sends the request v4/6 Echo Request to the given ipaddr, via dst.Iface,
+// through *t*, whose socket is either raw or a datagram one, as dst says
+func sendICMPRequest(dst Destination, ipaddr string, t transport, request []byte) (time.Time, error) {
+	if err := spendPacket(); err != nil {
+		return time.Time{}, err
 	}
 
-	hostIface, err := net.InterfaceByName(interfaceName)
-	if err != nil {
-		fmt.Printf("Error finding interface %s: %v\n", interfaceName, err)
-		os.Exit(1)
-	}
+	// Before sending, as ping(8) does: the reply may be received before Send
+	// even returns, and a kernel receive timestamp then be earlier
+	start := clk.Now()
+	err := t.Send(request, dst.Addr(ipaddr), dst.Iface)
 
-	return hostIface
+	return start, err
 }
 
-// constructMarshalledMessage handles populating icmp.Message struct,
-// and marshalls it into []binary, to send on the wire
-func constructMarshalledMessage(msgType icmp.Type, seqNum int) ([]byte, error) {
-	// Construct message
-	request := icmp.Message{
-		Type: msgType,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  seqNum,
-			Data: []byte("Never stop learning because life never stops teaching!!!"),
-		},
-	}
+// recvICMPRequest receives the v4/6 Echo Reply through *t*
+// and *immediately* calculates the elapsed time since sending the Echo Request:
+// by the timestamps of both, with hardware timestamps, else until the kernel
+// received the reply, if it says, else until now
+func recvICMPRequest(startTime time.Time, t transport) ([]byte, float64, packetMeta, net.Addr, error) {
+	binReply, meta, peerAddr, err := readICMPPacket(t)
 
-	// Marshal the message
-	binRequest, err := request.Marshal(nil)
+	// End timer
+	elapsedMs := elapsedMsSince(startTime)
+	if meta.clock == ClockHardware || meta.clock == ClockKernel {
+		elapsedMs = durationMs(meta.stampedRTT)
+	} else if kernelMs, ok := kernelElapsedMs(startTime, meta); ok {
+		elapsedMs = kernelMs
+	}
 
-	return binRequest, err
+	return binReply, elapsedMs, meta, peerAddr, err
 }
 
-// sendICMPRequest sends the request v4/6 Echo Request to the given ipaddr, via the given iface,
-// using the "icmp socket" conn
-func sendICMPRequest(ipaddr string, iface *net.Interface, ifaceName string, conn *icmp.PacketConn, request []byte, proto int) (time.Time, error) {
-
-	var (
-		start time.Time
-		err   error
-	)
-	destination := &net.IPAddr{IP: net.ParseIP(ipaddr), Zone: ifaceName}
-
-	switch proto {
-	case protocolICMP:
-		var controlRequest ipv4.ControlMessage
-
-		if iface != nil {
-			controlRequest.IfIndex = iface.Index
-			_, err = conn.IPv4PacketConn().WriteTo(request, &controlRequest, destination)
-		} else {
-			_, err = conn.WriteTo(request, destination)
-		}
-
-		start = time.Now()
+// kernelElapsedMs is the time from *start* to when the kernel received the
+// packet of *meta*, in milliseconds, if it says. The wall clock is all the
+// two have in common: should it be stepped in between, making that negative,
+// there is none.
+func kernelElapsedMs(start time.Time, meta packetMeta) (float64, bool) {
+	if meta.received.IsZero() {
+		return 0, false
+	}
+	elapsed := meta.received.Sub(start)
+	if elapsed < 0 {
+		return 0, false
+	}
+	return durationMs(elapsed), true
+}
 
-	case protocolICMPv6:
-		var controlRequest ipv6.ControlMessage
-		if iface != nil {
-			controlRequest.IfIndex = iface.Index
-			_, err = conn.IPv6PacketConn().WriteTo(request, &controlRequest, destination)
-		} else {
-			_, err = conn.WriteTo(request, destination)
-		}
-		start = time.Now()
+// packetMeta is what the IP header of a received packet says, besides its payload
+type packetMeta struct {
+	ttl      int    // TTL / Hop Limit
+	tos      int    // TOS / Traffic Class, if tosKnown
+	tosKnown bool   // only if asked for, see enableRecvTOS
+	options  []byte // IPv4 header options, if asked for, see enableIPOptions
 
-	}
+	received time.Time // when the kernel received it, zero if it does not say, see kernelTimestamps
 
-	return start, err
+	// With hardware timestamps, see enableHWTimestamps
+	hwReceived time.Time     // when the NIC received it, by its own clock
+	stampedRTT time.Duration // from the transmit timestamp of the last request, by clock
+	clock      string        // ClockHardware, ClockKernel or ClockUserspace
 }
 
-// recvICMPRequest receives the v4/6 Echo Reply from the given "icmp socket" conn
-// and *immediately* calculates the elapsed time since sending the Echo Request
-func recvICMPRequest(startTime time.Time, proto int, conn *icmp.PacketConn) ([]byte, float64, int, net.Addr, error) {
-
-	var (
-		receivedTTL = defaultTTL
-		numBytes    int
-		binReply    = make([]byte, 1500)
-		peerAddr    net.Addr
-		err         error
-	)
-
-	switch proto {
-	case protocolICMP:
-		// Read ttl from reply IP header
-		// Handled by this control message
-		var controlMessage *ipv4.ControlMessage
-
-		// Receive response
-		numBytes, controlMessage, peerAddr, err = conn.IPv4PacketConn().ReadFrom(binReply)
-
-		if controlMessage != nil {
-			receivedTTL = controlMessage.TTL
-		}
+// maxICMPPacket is the largest packet read, IP header included: Echo Requests
+// can be made that large through the control socket
+const maxICMPPacket = 1 << 16
 
-	case protocolICMPv6:
-		// Read ttl from reply IP header
-		// Handled by this control message
-		var controlMessage *ipv6.ControlMessage
-		// Receive response
-		numBytes, controlMessage, peerAddr, err = conn.IPv6PacketConn().ReadFrom(binReply)
-		if controlMessage != nil {
-			receivedTTL = controlMessage.HopLimit
-		}
+// readICMPPacket reads the next v4/6 ICMP packet through *t*, along with the
+// TTL / Hop Limit it arrived with, and the other header fields the transport
+// was asked for
+func readICMPPacket(t transport) ([]byte, packetMeta, net.Addr, error) {
+	binReply := getPacket()
+	numBytes, meta, peerAddr, err := t.Recv(binReply)
+	if err != nil {
+		putPacket(binReply)
+		return nil, meta, peerAddr, err
 	}
+	return binReply[:numBytes], meta, peerAddr, nil
+}
 
-	// End timer
-	elapsed := time.Since(startTime)
-	elapsedMs := float64(elapsed.Microseconds()) / 1000.0 // Convert to milliseconds
-
-	return binReply[:numBytes], elapsedMs, receivedTTL, peerAddr, err
+// elapsedMsSince converts the time since *start* to milliseconds, keeping sub-µs resolution
+func elapsedMsSince(start time.Time) float64 {
+	return float64(clk.Since(start).Nanoseconds()) / 1e6
 }
 
-// printICMPResponse handles the different types of ICMP replies received
-func printICMPResponse(proto int, data []byte, peer net.Addr, seq int, receivedTTL int, elapsedMs float64, stats *PingStats) {
+// parseICMPResponse classifies the different types of ICMP replies received
+// into the outcome of probe *seq*. The returned bool is false when the packet
+// says nothing about this probe (someone else's reply, ND chatter...), and the
+// caller should keep reading. *id* is the Echo identifier our replies carry.
+func parseICMPResponse(proto int, id int, data []byte, peer net.Addr, seq int, meta packetMeta, elapsedMs float64) (ProbeResult, bool) {
+	res := ProbeResult{
+		Seq:      seq,
+		Peer:     peerIP(peer),
+		Bytes:    len(data),
+		TTL:      meta.ttl,
+		TOS:      meta.tos,
+		TOSKnown: meta.tosKnown,
+		Route:    recordedRoute(meta.options),
+		RTT:      elapsedMs,
+		Clock:    meta.clock,
+		Time:     clk.Now(),
+	}
+	res.Timestamps, res.TimestampsLost = ipTimestamps(meta.options)
 
-	// Parse the response
-	reply, err := icmp.ParseMessage(proto, data)
+	// Parse the response, in place
+	typ, code, err := icmpHeader(proto, data)
 	if err != nil {
-		fmt.Printf("Error parsing ICMP response: %v\n", err)
-		stats.errors++
-		return
+		slog.Debug("unparsable reply", "peer", res.Peer, "bytes", len(data), "data", fmt.Sprintf("%x", data), "err", err)
+		res.Reason, res.Detail = ReasonParseError, err.Error()
+		return res, true
 	}
+	slog.Debug("parsed reply", "peer", res.Peer, "bytes", len(data), "type", typ, "code", code)
 
-	switch reply.Type {
+	switch typ {
 	// Expected case
 	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
 		// data parse
-		echo, ok := reply.Body.(*icmp.Echo)
+		echoID, echoSeq, ok := echoHeader(data)
 		if !ok {
-			fmt.Printf("Invalid ICMP echo reply\n")
-			stats.errors++
-			return
+			res.Reason, res.Detail = ReasonParseError, "invalid ICMP echo reply"
+			return res, true
 		}
 
-		stats.received++
-		// valid receipt => update statistics
-		stats.iterativeStats(elapsedMs)
-		// Print to stdout
-		fmt.Printf("%d bytes from %s: icmp_seq=%d ttl=%d time=%.3f ms\n",
-			len(data), peer.String(), echo.Seq, receivedTTL, elapsedMs)
+		slog.Debug("parsed echo reply", "id", echoID, "seq", echoSeq, "payload", len(data)-8)
 
-	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		// Someone else's ping, or a late reply to an earlier probe
+		if echoID != id || echoSeq != wireSeq(seq) {
+			res.Reason = ReasonMismatchedReply
+			res.Detail = fmt.Sprintf("Mismatched Echo Reply (id=%d seq=%d)", echoID, echoSeq)
+			return res, false
+		}
 
-		// error receipt => do nothing
-		stats.errors++
-		// Print to stdout
-		fmt.Printf("From %s icmp_seq=%d: Destination Host Unreachable\n",
-			peer.String(), seq)
+		// valid receipt
 
-	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		res.Reason = unreachableReason(proto, code)
+		res.Detail = unreachableMessage(res.Reason, code)
+		res.Extensions = icmpExtensions(proto, data)
+		return res, quotesProbe(&res, data, id, seq)
 
-		// error receipt => do nothing
-		stats.errors++
-		// Print to stdout
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		res.Reason = ReasonTTLExceeded
 		if proto == protocolICMP {
-			fmt.Printf("From %s icmp_seq=%d: Time To Live Exceeded\n",
-				peer.String(), seq)
+			res.Detail = "Time To Live Exceeded"
 		} else {
-			fmt.Printf("From %s icmp_seq=%d: Hop Limit Exceeded\n",
-				peer.String(), seq)
+			res.Detail = "Hop Limit Exceeded"
 		}
+		res.Extensions = icmpExtensions(proto, data)
+		return res, quotesProbe(&res, data, id, seq)
 
 	case ipv6.ICMPTypeNeighborAdvertisement, ipv6.ICMPTypeNeighborSolicitation, ipv6.ICMPTypeRouterAdvertisement, ipv6.ICMPTypeRouterSolicitation:
 
-		//BUG: Unknown if the actual ICMPv6 reply is lost, due to this meta control-message received
-		fmt.Printf("From %s icmp_seq=%d: IPv6 specific information: %v\n",
-			peer.String(), seq, reply.Type)
+		// Not a reply to us: note it, and keep waiting for the actual ICMPv6 reply
+		res.Reason = ReasonNeighborDiscovery
+		res.Detail = fmt.Sprintf("IPv6 specific information: %v", typ)
+		return res, false
+
+	case ipv4.ICMPTypeEcho, ipv6.ICMPTypeEchoRequest:
+		// Our own request, looped back when pinging a local address
+		return res, false
 
 	default:
 		// Uncaught error...
-		stats.errors++
-		// Print to stdout
-		fmt.Printf("From %s icmp_seq=%d: ICMP type: %v\n",
-			peer.String(), seq, reply.Type)
+		res.Reason = ReasonUnexpectedType
+		res.Detail = fmt.Sprintf("ICMP type: %v", typ)
 	}
-}
 
-// printReadError is an error handler for receiving the ICMP(4/6) reply
-func printReadError(err error, seq int, stats *PingStats) {
-	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-		fmt.Printf("Request timeout for icmp_seq %d\n", seq)
-		stats.errors++
-	} else {
-		fmt.Printf("Error reading ICMP response: %v\n", err)
-		stats.errors++
-	}
+	return res, true
 }
 
-// ICMP6Handler handles PINGER when using AF_INET6
-func ICMP6Handler(info ICMPInfo) {
-	// iteratively calculated statistics
-	stats := PingStats{min: -1}
-
-	// returned pointer may be nil...
-	var hostIface *net.Interface = getInterface(info.Iface)
-
-	// Set up signal handling for graceful termination: usual ending with Ctl + C
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		stats.finalStats()
-		PrintStatistics(&stats)
-		os.Exit(0)
-	}()
+// quotesProbe tells whether the ICMP error *data*, of result *res*, is about
+// probe *seq*, by the Echo Request it quotes. One about an earlier probe, of
+// identifier *id* too, turns *res* into a mismatched reply, as late Echo
+// Replies are; one about anything else, such as another program's traffic
+// seen through a raw socket, is not reported at all.
+func quotesProbe(res *ProbeResult, data []byte, id int, seq int) bool {
+	_, quotedID, quotedSeq, ok := quotedEcho(data)
+	if ok && quotedID == id && quotedSeq == wireSeq(seq) {
+		return true
+	}
 
-	// Start pinging
-	if info.Iface != "" {
-		fmt.Printf("PINGERING %s: %d data bytes (via %s)\n", info.IP, pingDataSize, info.Iface)
-	} else {
-		fmt.Printf("PINGERING %s: %d data bytes\n", info.IP, pingDataSize)
+	if ok && quotedID == id {
+		res.Reason = ReasonMismatchedReply
+		res.Detail = fmt.Sprintf("%s (for icmp_seq=%d)", res.Detail, quotedSeq)
+		return false
 	}
+	slog.Debug("ignored error about another flow", "peer", res.Peer, "detail", res.Detail, "quotes_echo", ok, "id", quotedID)
+	res.Reason, res.Detail = ReasonNone, ""
+	return false
+}
 
-	// abstracted "socket" information
-	var (
-		proto      int    = protocolICMPv6
-		network    string = "ip6:ipv6-icmp"
-		listenAddr string = "::"
-	)
+// readErrorResult is the outcome of probe *seq* when receiving the ICMP(4/6) reply failed
+func readErrorResult(err error, seq int) ProbeResult {
+	res := ProbeResult{Seq: seq, Time: clk.Now(), Detail: err.Error()}
 
-	// setup one end of connection
-	conn, err := icmp.ListenPacket(network, listenAddr)
-	if err != nil {
-		fmt.Printf("Error creating ICMPv6 connection: %v\n", err)
-		os.Exit(1)
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		res.Reason = ReasonTimeout
+	} else {
+		res.Reason = ReasonRecvError
 	}
-	defer conn.Close()
 
-	// Set Hop Limit
-	conn.IPv6PacketConn().SetHopLimit(info.TTL)
-	// **Set control message flags to receive hop limit info**
-	conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit|ipv6.FlagInterface, true)
+	return res
+}
 
-	//Send ICMPv6 packet loop
-	for i := range info.CNT {
-		stats.transmitted++
+// sendErrorResult is the outcome of probe *seq* when the request never made it onto the wire
+func sendErrorResult(err error, seq int) ProbeResult {
+	return ProbeResult{Seq: seq, Time: clk.Now(), Reason: ReasonSendError, Detail: err.Error()}
+}
 
-		// Construct the required message
-		request, err := constructMarshalledMessage(ipv6.ICMPTypeEchoRequest, i)
+// awaitReply reads from *t* until the outcome of probe *seq* is known,
+// reporting unrelated packets seen on the way
+func awaitReply(info ICMPInfo, t transport, proto int, id int, startTime time.Time, seq int, stats *PingStats, printer Printer, stop *interruption) {
+	for {
+		reply, elapsedMs, meta, peerAddr, err := recvICMPRequest(startTime, t)
 		if err != nil {
-			fmt.Printf("Error generating ICMP message: %v\n", err)
-			stats.errors++
-			continue
+			// Cut short by Ctrl + C: not an outcome of the probe
+			if !stop.stopped() {
+				report(info, readErrorResult(err, seq), stats, printer)
+			}
+			return
 		}
 
-		// Set read deadline
-		// Linux has it as 1 second, MS as 4 seconds, so may modify...
-		conn.SetReadDeadline(time.Now().Add(4 * time.Second))
-
-		startTime, err := sendICMPRequest(info.IP, hostIface, info.Iface, conn, request, proto)
-		if err != nil {
-			fmt.Printf("Error sending ICMP packet: %v\n", err)
-			stats.errors++
-			continue
+		res, final := parseICMPResponse(proto, id, reply, peerAddr, seq, meta, elapsedMs)
+		putPacket(reply)
+		if res.Reason != ReasonNone || final {
+			report(info, res, stats, printer)
 		}
-
-		// Receive the required response
-		reply, elapsedMs, receivedTTL, peerAddr, err := recvICMPRequest(startTime, proto, conn)
-		if err != nil {
-			printReadError(err, i, &stats)
-			continue
+		if final {
+			return
 		}
+	}
+}
 
-		//Format what was received
-		printICMPResponse(proto, reply, peerAddr, i, receivedTTL, elapsedMs, &stats)
-		time.Sleep(time.Second)
+// report hands one probe outcome to the statistics and the printer
+func report(info ICMPInfo, res ProbeResult, stats *PingStats, printer Printer) {
+	res.Target = info.IP
+	checkECN(info, &res)
+	stats.record(res)
+	printer.Probe(res)
+
+	if note, ok := strictViolation(res); ok && info.Strict {
+		printer.Annotate(note)
+		printer.Summary(info.IP, stats)
+		Exit(1)
 	}
+}
 
-	stats.finalStats()
-	PrintStatistics(&stats)
+// selfTargetAnnotation warns that pinging *ip* only measures this host's network stack
+func selfTargetAnnotation(ip string) Annotation {
+	return Annotation{
+		Time:    clk.Now(),
+		Kind:    "self_target",
+		Message: fmt.Sprintf("%s is an address of this host: replies never leave it, so RTTs say nothing about the network", ip),
+		Fields:  map[string]string{"addr": ip},
+	}
 }
 
-// ICMP4Handler handles PINGER when using AF_INET
-func ICMP4Handler(info ICMPInfo) {
+// annotateNeighbor notes the MAC address and vendor of an on-link target, in verbose mode
+func annotateNeighbor(info ICMPInfo, printer Printer) {
+	if !info.Verbose || info.SelfTarget {
+		return
+	}
 
-	stats := PingStats{}
+	if note, ok := neighborAnnotation(info.IP); ok {
+		printer.Annotate(note)
+	}
+}
 
-	// returned pointer may be nil...
-	var hostIface *net.Interface = getInterface(info.Iface)
+// ICMP6Handler handles PINGER when using AF_INET6, and returns the exit status
+func ICMP6Handler(info ICMPInfo) int {
+	return handleICMP(info, &familyIPv6)
+}
 
-	// Set up signal handling for graceful termination: usual ending with Ctl + C
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		stats.finalStats()
-		PrintStatistics(&stats)
-		os.Exit(0)
-	}()
+// ICMP4Handler handles PINGER when using AF_INET, and returns the exit status
+func ICMP4Handler(info ICMPInfo) int {
+	return handleICMP(info, &familyIPv4)
+}
 
-	// Start pinging
-	if info.Iface != "" {
-		fmt.Printf("PINGERING %s: %d data bytes (via %s)\n", info.IP, pingDataSize, info.Iface)
-	} else {
-		fmt.Printf("PINGERING %s: %d data bytes\n", info.IP, pingDataSize)
+// handleICMP sets up the socket of PINGER over address family *f*, as *info*
+// configures it, and pings through it. It returns the exit status.
+func handleICMP(info ICMPInfo, f *family) int {
+	s, err := openSession(info, f.ipv6)
+	if err != nil {
+		slog.Error(err.Error())
+		return errorStatus(err)
 	}
+	defer s.close()
 
-	// abstracted "socket" information
-	var (
-		proto      int    = protocolICMP
-		network    string = "ip4:icmp"
-		listenAddr string = "0.0.0.0"
-	)
+	return pingICMP(s.info, f, s.t, s.destination(), s.id, s.notes...)
+}
+
+// pingICMP pings info.IP over address family *f*, through *t*, set up as
+// *info* says, with Echo Requests of identifier *id* sent to *dst*. *notes*
+// on how *t* was set up are printed after the header. It returns the exit
+// status.
+func pingICMP(info ICMPInfo, f *family, t transport, dst Destination, id int, notes ...Annotation) int {
+	// iteratively calculated statistics
+	stats := newPingStats(info)
+	// and those of every host answering, for broadcast / multicast pings
+	responders := newResponderStats(info)
 
-	// setup one end of connection
-	conn, err := icmp.ListenPacket(network, listenAddr)
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
 	if err != nil {
-		fmt.Printf("Error creating ICMP connection: %v\n", err)
-		os.Exit(1)
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
 	}
-	defer conn.Close()
 
-	// Set TTL
-	conn.IPv4PacketConn().SetTTL(info.TTL)
+	// Graceful termination, the usual ending, with Ctrl + C: the probe in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() { t.SetDeadline(clk.Now()) })
 
-	// **Set control message flags to receive TTL info**
-	conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL|ipv4.FlagInterface, true)
+	// Start pinging
+	printer.Header(info)
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() {
+		printInterim(printer, info.IP, &stats)
+		annotateFootprint(info, printer)
+	})
+	defer summarizeEvery(info.SummaryEvery, printer, []string{info.IP}, &stats)()
+
+	// Annotate the results if the local address changes mid-run (DHCP renew...)
+	stopWatching := watchLocalAddrs(info.Iface, printer)
+	defer stopWatching()
+
+	// Interval, size and timeout can be changed while running, through the control socket
+	params := newRunParams()
+	if info.Control != "" {
+		stopControl, err := serveControl(info.Control, params, printer)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		defer stopControl()
+	}
+
+	if info.SelfTarget {
+		printer.Annotate(selfTargetAnnotation(info.IP))
+	}
+	for _, note := range notes {
+		printer.Annotate(note)
+	}
+	if info.manyResponders() {
+		printer.Annotate(groupAnnotation(info))
+	}
+
+	// How late probes go out, against their interval
+	var schedule sendSchedule
+	// When the next probe is due: an interval after the last one was sent,
+	// whether it was answered, timed out or failed to go out
+	var next time.Time
 
-	//Send ICMPv4 packet loop
+	//Send packet loop
 	for i := range info.CNT {
-		stats.transmitted++
+		if wait := next.Sub(clk.Now()); wait > 0 {
+			schedule.expect(wait)
+			stop.sleep(wait)
+		}
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
 
 		// Construct the required message
-		request, err := constructMarshalledMessage(ipv4.ICMPTypeEcho, i)
+		interval, timeout, size := params.get()
+		next = clk.Now().Add(interval)
+		request, err := BuildEchoRequest(WithIPv6(f.ipv6), WithID(id), WithSeq(wireSeq(i)), WithPayload(echoPayload(size)))
 		if err != nil {
-			fmt.Printf("Error generating ICMP message: %v\n", err)
-			stats.errors++
+			report(info, sendErrorResult(err, i), &stats, printer)
 			continue
 		}
 
 		// Set read deadline
-		// Linux has it as 1 second, MS as 4 seconds, so may modify...
-		//TODO: Change location
-		conn.SetReadDeadline(time.Now().Add(4 * time.Second))
-
-		startTime, err := sendICMPRequest(info.IP, hostIface, info.Iface, conn, request, proto)
+		t.SetDeadline(clk.Now().Add(timeout))
 
+		startTime, err := sendICMPRequest(dst, info.IP, t, request)
 		if err != nil {
-			fmt.Printf("Error sending ICMP packet: %v\n", err)
-			stats.errors++
+			report(info, sendErrorResult(err, i), &stats, printer)
 			continue
 		}
+		schedule.sent(startTime)
 
-		// Receive the required response
-		reply, elapsedMs, receivedTTL, peerAddr, err := recvICMPRequest(startTime, proto, conn)
-		if err != nil {
-			printReadError(err, i, &stats)
+		// Listen to the whole window: the window is the interval too
+		if info.manyResponders() {
+			t.SetDeadline(startTime.Add(groupWindow))
+			awaitAllReplies(info, t, f.proto, id, startTime, i, &stats, responders, printer, stop)
 			continue
 		}
 
-		//Format what was received
-		printICMPResponse(proto, reply, peerAddr, i, receivedTTL, elapsedMs, &stats)
-		time.Sleep(time.Second)
+		// Receive the required response, and format what was received
+		awaitReply(info, t, f.proto, id, startTime, i, &stats, printer, stop)
 	}
 
-	stats.finalStats()
-	PrintStatistics(&stats)
+	annotateNeighbor(info, printer)
+	printer.Summary(info.IP, &stats)
+	responders.summarize(stats.transmitted, printer)
+	annotateSchedule(info, printer, &schedule, &stats)
+	annotateFootprint(info, printer)
+
+	return exitStatus(&stats)
 }
@@ -0,0 +1,217 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeRecord is what the tests read of the JSON records of a run
+type fakeRecord struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Target    string    `json:"target"`
+	Seq       int       `json:"seq"`
+	Peer      string    `json:"peer"`
+	RTT       *float64  `json:"rtt_ms"`
+	Reason    string    `json:"reason"`
+	Duplicate bool      `json:"duplicate"`
+
+	Transmitted int      `json:"transmitted"`
+	Received    int      `json:"received"`
+	Errors      int      `json:"errors"`
+	Duplicates  int      `json:"duplicates"`
+	Loss        float64  `json:"loss_pct"`
+	Min         *float64 `json:"rtt_min_ms"`
+	Avg         *float64 `json:"rtt_avg_ms"`
+	Max         *float64 `json:"rtt_max_ms"`
+}
+
+// pingFake runs pingICMP, as configured by *info*, over a fakeTransport
+// answering with *respond*, on a fakeClock, and returns the probe records and
+// the first summary record it printed, with its exit status
+func pingFake(t *testing.T, info ICMPInfo, respond fakeResponder) ([]fakeRecord, fakeRecord, int) {
+	t.Helper()
+	return pingOver(t, info, newFakeTransport(respond))
+}
+
+// pingOver is pingFake over *ft*, for tests setting it up further
+func pingOver(t *testing.T, info ICMPInfo, ft *fakeTransport) ([]fakeRecord, fakeRecord, int) {
+	t.Helper()
+
+	var out bytes.Buffer
+	savedClk, savedStdout := clk, stdout
+	clk, stdout = newFakeClock(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)), &out
+	t.Cleanup(func() { clk, stdout = savedClk, savedStdout })
+
+	defer ft.Close()
+	info.Format, info.RTT = "json", DefaultRTTFormat
+	status := pingICMP(info, &familyIPv4, ft, Destination{}, 7)
+
+	var (
+		probes  []fakeRecord
+		summary *fakeRecord
+	)
+	lines := bufio.NewScanner(&out)
+	for lines.Scan() {
+		var record fakeRecord
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil {
+			t.Fatalf("%v: %s", err, lines.Bytes())
+		}
+		switch {
+		case record.Type == "probe":
+			probes = append(probes, record)
+		case record.Type == "summary" && summary == nil:
+			summary = &record
+		}
+	}
+	if summary == nil {
+		t.Fatalf("no summary in:\n%s", out.String())
+	}
+	return probes, *summary, status
+}
+
+func TestPingICMPReplies(t *testing.T) {
+	probes, summary, status := pingFake(t, ICMPInfo{IP: "192.0.2.1", CNT: 3}, fakeEchoReplies(57, 5*time.Millisecond))
+
+	if len(probes) != 3 {
+		t.Fatalf("%d probe records, want 3", len(probes))
+	}
+	for i, probe := range probes {
+		if probe.Seq != i || probe.Peer != "192.0.2.1" || probe.Reason != "" || probe.RTT == nil || *probe.RTT != 5 {
+			t.Errorf("probe %d: %+v, want a reply from 192.0.2.1 in 5 ms", i, probe)
+		}
+	}
+	if summary.Transmitted != 3 || summary.Received != 3 || summary.Errors != 0 {
+		t.Errorf("summary %+v, want 3 transmitted and received", summary)
+	}
+	if status != ExitReplied {
+		t.Errorf("exit status %d, want %d", status, ExitReplied)
+	}
+}
+
+func TestPingICMPTimeouts(t *testing.T) {
+	probes, summary, status := pingFake(t, ICMPInfo{IP: "192.0.2.1", CNT: 2}, nil)
+
+	if len(probes) != 2 {
+		t.Fatalf("%d probe records, want 2", len(probes))
+	}
+	for i, probe := range probes {
+		if probe.Seq != i || probe.Reason != ReasonTimeout.String() || probe.RTT != nil {
+			t.Errorf("probe %d: %+v, want a timeout", i, probe)
+		}
+	}
+	if summary.Transmitted != 2 || summary.Received != 0 || summary.Errors != 2 {
+		t.Errorf("summary %+v, want 2 transmitted, none received, 2 errors", summary)
+	}
+	if status != ExitNoReply {
+		t.Errorf("exit status %d, want %d", status, ExitNoReply)
+	}
+}
+
+func TestPingICMPErrorReplies(t *testing.T) {
+	router := &net.UDPAddr{IP: net.ParseIP("198.51.100.1")}
+	probes, summary, status := pingFake(t, ICMPInfo{IP: "192.0.2.1", CNT: 2}, fakeUnreachable(1, router))
+
+	if len(probes) != 2 {
+		t.Fatalf("%d probe records, want 2", len(probes))
+	}
+	for i, probe := range probes {
+		if probe.Seq != i || probe.Peer != "198.51.100.1" || probe.Reason != ReasonUnreachableHost.String() {
+			t.Errorf("probe %d: %+v, want a Host Unreachable from 198.51.100.1", i, probe)
+		}
+	}
+	if summary.Received != 0 || summary.Errors != 2 {
+		t.Errorf("summary %+v, want none received, 2 errors", summary)
+	}
+	if status != ExitNoReply {
+		t.Errorf("exit status %d, want %d", status, ExitNoReply)
+	}
+}
+
+func TestPingICMPDuplicates(t *testing.T) {
+	// Two hosts answer every probe to the broadcast address, the second later
+	first, second := &net.UDPAddr{IP: net.ParseIP("192.0.2.1")}, &net.UDPAddr{IP: net.ParseIP("192.0.2.2")}
+	respond := func(msg []byte, dst net.Addr) []fakePacket {
+		var replies []fakePacket
+		for i, from := range []net.Addr{first, second} {
+			for _, reply := range fakeEchoReplies(defaultTTL, time.Duration(i+1)*time.Millisecond)(msg, from) {
+				replies = append(replies, reply)
+			}
+		}
+		return replies
+	}
+	probes, summary, status := pingFake(t, ICMPInfo{IP: "192.0.2.255", CNT: 2, Broadcast: true}, respond)
+
+	if len(probes) != 4 {
+		t.Fatalf("%d probe records, want 4", len(probes))
+	}
+	for i, probe := range probes {
+		wantPeer, wantDup := "192.0.2.1", i%2 == 1
+		if wantDup {
+			wantPeer = "192.0.2.2"
+		}
+		if probe.Seq != i/2 || probe.Peer != wantPeer || probe.Duplicate != wantDup || probe.Reason != "" {
+			t.Errorf("record %d: %+v, want a reply from %s, duplicate: %v", i, probe, wantPeer, wantDup)
+		}
+	}
+	if summary.Target != "192.0.2.255" || summary.Transmitted != 2 || summary.Received != 2 || summary.Duplicates != 2 {
+		t.Errorf("summary %+v, want 2 transmitted and received, 2 duplicates", summary)
+	}
+	if status != ExitReplied {
+		t.Errorf("exit status %d, want %d", status, ExitReplied)
+	}
+}
+
+// pingTimes are the times of the probe records of a run, from its start
+func pingTimes(probes []fakeRecord) []time.Duration {
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var times []time.Duration
+	for _, probe := range probes {
+		times = append(times, probe.Time.Sub(start))
+	}
+	return times
+}
+
+func TestPingICMPSendErrorsKeepInterval(t *testing.T) {
+	// As with no route to the host: every send fails at once
+	ft := newFakeTransport(nil)
+	ft.sendErr = errors.New("network is unreachable")
+	probes, summary, status := pingOver(t, ICMPInfo{IP: "192.0.2.1", CNT: 4}, ft)
+
+	if len(probes) != 4 {
+		t.Fatalf("%d probe records, want 4", len(probes))
+	}
+	for i, at := range pingTimes(probes) {
+		if probes[i].Reason != ReasonSendError.String() || at != time.Duration(i)*startInterval {
+			t.Errorf("probe %d: %q at %v, want a send error at %v", i, probes[i].Reason, at, time.Duration(i)*startInterval)
+		}
+	}
+	if took := clk.Since(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)); took != 3*startInterval {
+		t.Errorf("the run took %v, want %v: no wait after the last probe", took, 3*startInterval)
+	}
+	if summary.Transmitted != 4 || summary.Errors != 4 || status != ExitNoReply {
+		t.Errorf("summary %+v, exit status %d, want 4 transmitted, 4 errors, status %d", summary, status, ExitNoReply)
+	}
+}
+
+func TestPingICMPTimeoutsKeepInterval(t *testing.T) {
+	// Timeouts shorter than the interval: probes still go out an interval apart
+	saved := startInterval
+	startInterval = 2 * replyTimeout
+	t.Cleanup(func() { startInterval = saved })
+
+	probes, _, _ := pingFake(t, ICMPInfo{IP: "192.0.2.1", CNT: 3}, nil)
+	if len(probes) != 3 {
+		t.Fatalf("%d probe records, want 3", len(probes))
+	}
+	for i, at := range pingTimes(probes) {
+		if want := time.Duration(i)*startInterval + replyTimeout; probes[i].Reason != ReasonTimeout.String() || at != want {
+			t.Errorf("probe %d: %q at %v, want a timeout at %v", i, probes[i].Reason, at, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/net/icmp"
+)
+
+// icmpSupport is which kinds of ICMP socket this process can open, per family
+type icmpSupport struct {
+	raw, dgram bool
+}
+
+// probeICMPSupport opens (and closes) each kind of ICMP socket for *proto*, to see
+// whether the kernel lets us: raw needs root / CAP_NET_RAW, datagram sockets need
+// the group to be in net.ipv4.ping_group_range on Linux
+func probeICMPSupport(proto int) icmpSupport {
+	var support icmpSupport
+
+	network, dgramNetwork := "ip4:icmp", "udp4"
+	if proto == protocolICMPv6 {
+		network, dgramNetwork = "ip6:ipv6-icmp", "udp6"
+	}
+
+	if conn, err := icmp.ListenPacket(network, wildcardAddr(proto)); err == nil {
+		conn.Close()
+		support.raw = true
+	}
+	if datagramICMP {
+		if conn, err := icmp.ListenPacket(dgramNetwork, wildcardAddr(proto)); err == nil {
+			conn.Close()
+			support.dgram = true
+		}
+	}
+
+	return support
+}
+
+// ifaceReport is one line of `pinger ifaces`
+type ifaceReport struct {
+	Name  string   `json:"name"`
+	Index int      `json:"index"`
+	State string   `json:"state"`
+	MTU   int      `json:"mtu"`
+	Addrs []string `json:"addrs"`
+	IPv4  []string `json:"ipv4"` // socket kinds pinger could ping over IPv4 with, via this interface
+	IPv6  []string `json:"ipv6"`
+}
+
+// ifaceState is "up", "down", or "no-carrier" (administratively up, but no link)
+func ifaceState(iface net.Interface) string {
+	switch {
+	case iface.Flags&net.FlagUp == 0:
+		return "down"
+	case iface.Flags&net.FlagRunning == 0:
+		return "no-carrier"
+	}
+	return "up"
+}
+
+// ifaceCapabilities lists the socket kinds usable over a family via an
+// interface: it needs to be up, have an address of that family, and the
+// kernel needs to allow the socket
+func ifaceCapabilities(up bool, hasAddr bool, support icmpSupport) []string {
+	capabilities := []string{}
+	if !up || !hasAddr {
+		return capabilities
+	}
+
+	if support.raw {
+		capabilities = append(capabilities, "raw")
+	}
+	if support.dgram {
+		capabilities = append(capabilities, "unprivileged")
+	}
+	return capabilities
+}
+
+// IfacesHandler lists the network interfaces, with their addresses, MTU and
+// state, and whether pinger can ping over IPv4 / IPv6 through each of them
+func IfacesHandler(format string) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		slog.Error("listing interfaces", "err", err)
+		os.Exit(1)
+	}
+
+	support4, support6 := probeICMPSupport(protocolICMP), probeICMPSupport(protocolICMPv6)
+
+	var reports []ifaceReport
+	for _, iface := range ifaces {
+		report := ifaceReport{
+			Name:  iface.Name,
+			Index: iface.Index,
+			State: ifaceState(iface),
+			MTU:   iface.MTU,
+			Addrs: []string{},
+		}
+
+		var has4, has6 bool
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			report.Addrs = append(report.Addrs, addr.String())
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ipnet.IP.To4() != nil {
+					has4 = true
+				} else {
+					has6 = true
+				}
+			}
+		}
+
+		up := iface.Flags&net.FlagUp != 0
+		report.IPv4 = ifaceCapabilities(up, has4, support4)
+		report.IPv6 = ifaceCapabilities(up, has6, support6)
+
+		reports = append(reports, report)
+	}
+
+	if err := printIfaces(format, reports, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// printIfaces writes the interface reports as a table, or as JSON lines
+func printIfaces(format string, reports []ifaceReport, w io.Writer) error {
+	switch format {
+	case "", "text":
+	case "json":
+		enc := newRecordEncoder(w)
+		for _, report := range reports {
+			enc.Encode(struct {
+				Type string `json:"type"`
+				ifaceReport
+			}{"iface", report})
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", format)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tSTATE\tMTU\tIPv4\tIPv6\tADDRESSES")
+	for _, report := range reports {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\t%s\n", report.Name, report.State, report.MTU,
+			capabilityList(report.IPv4), capabilityList(report.IPv6), strings.Join(report.Addrs, " "))
+	}
+	return table.Flush()
+}
+
+// capabilityList renders socket kinds for the table
+func capabilityList(capabilities []string) string {
+	if len(capabilities) == 0 {
+		return "-"
+	}
+	return strings.Join(capabilities, ",")
+}
+
+// CheckIfaceFamily makes sure that the interface *name* exists, and has an
+// address of the family pinged, IPv6 if *v6*: probes sent through it fail
+// obscurely otherwise. The error lists the interfaces that would do.
+func CheckIfaceFamily(name string, v6 bool) error {
+	family := "IPv4"
+	if v6 {
+		family = "IPv6"
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("error listing interfaces: %v", err)
+	}
+
+	var usable []string
+	found, has := false, false
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		hasFamily := false
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && (ipnet.IP.To4() == nil) == v6 {
+				hasFamily = true
+			}
+		}
+		if iface.Name == name {
+			found, has = true, hasFamily
+		}
+		if hasFamily && iface.Flags&net.FlagUp != 0 {
+			usable = append(usable, iface.Name)
+		}
+	}
+
+	choices := "none is up with an " + family + " address"
+	if len(usable) > 0 {
+		choices = "those up with an " + family + " address are " + strings.Join(usable, ", ")
+	}
+	switch {
+	case !found:
+		return fmt.Errorf("-I %s: no such interface; %s", name, choices)
+	case !has:
+		return fmt.Errorf("-I %s has no %s address to ping from; %s", name, family, choices)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"os"
+	"os/signal"
+)
+
+// notifyInterim calls *interim* every time the user asks for the statistics
+// so far with one of the interimSignals (Ctrl + \ for SIGQUIT, Ctrl + T on macOS)
+func notifyInterim(interim func()) {
+	if len(interimSignals) == 0 {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, interimSignals...)
+	go func() {
+		for range c {
+			interim()
+		}
+	}()
+}
+
+// printInterim hands a snapshot of the statistics of *target* so far to
+// *printer*: they are taken under statsMu, as the run goes on recording
+func printInterim(printer Printer, target string, stats *PingStats) {
+	snapshot := stats.snapshot()
+	printer.Interim(target, &snapshot)
+}
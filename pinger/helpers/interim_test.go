@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestPrintInterim prints the statistics so far while probes are recorded,
+// as Ctrl + \ does mid-run: every snapshot is of whole probes, never counting
+// a reply before its request. Run with -race, it checks that they are taken
+// safely too.
+func TestPrintInterim(t *testing.T) {
+	const probes = 20000
+	var out bytes.Buffer
+	printer, err := NewPrinter("json", DefaultRTTFormat, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats PingStats
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range probes {
+			stats.sent()
+			stats.record(ProbeResult{Seq: i, RTT: 1})
+		}
+	}()
+	for range 100 {
+		printInterim(printer, "192.0.2.1", &stats)
+	}
+	<-done
+
+	lines := bufio.NewScanner(&out)
+	for lines.Scan() {
+		var interim struct {
+			Transmitted int `json:"transmitted"`
+			Received    int `json:"received"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &interim); err != nil {
+			t.Fatalf("%v: %s", err, lines.Bytes())
+		}
+		if interim.Received > interim.Transmitted || interim.Transmitted > interim.Received+1 {
+			t.Errorf("interim statistics of %d transmitted, %d received", interim.Transmitted, interim.Received)
+		}
+	}
+}
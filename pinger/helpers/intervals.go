@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// interval is the statistics of a stretch of the run
+type interval struct {
+	start    time.Time
+	probes   int
+	received int
+	mean     float64 // mean RTT of the replies
+}
+
+// loss is the percentage of the probes of the interval that got no reply
+func (iv interval) loss() float64 {
+	if iv.probes == 0 {
+		return 0.0
+	}
+	return float64(iv.probes-iv.received) / float64(iv.probes) * 100.0
+}
+
+// tally counts the outcome of a probe, at *at*, into the row of the interval
+// table it falls in, from the first outcome on: rows in between are added
+// empty, where nothing was heard of any probe
+func (stats *PingStats) tally(at time.Time, rtt float64, answered bool) {
+	if stats.rowLength <= 0 {
+		return
+	}
+	if len(stats.rows) == 0 {
+		stats.rows = []interval{{start: at}}
+	}
+
+	start := stats.rows[0].start
+	i := max(int(at.Sub(start)/stats.rowLength), 0)
+	for len(stats.rows) <= i {
+		stats.rows = append(stats.rows, interval{start: start.Add(time.Duration(len(stats.rows)) * stats.rowLength)})
+	}
+
+	iv := &stats.rows[i]
+	iv.probes++
+	if answered {
+		iv.received++
+		iv.mean += (rtt - iv.mean) / float64(iv.received)
+	}
+}
+
+// printIntervals prints the loss and average RTT of every interval of the runs
+// of *targets*, a table of how they went over time before their statistics,
+// if any of them spans several intervals
+func (p *textPrinter) printIntervals(targets []string, stats []*PingStats) {
+	if p.intervals <= 0 {
+		return
+	}
+	all := make([][]interval, len(stats))
+	several := false
+	for i, st := range stats {
+		all[i] = st.rows
+		several = several || len(all[i]) > 1
+	}
+	if !several {
+		return
+	}
+
+	fmt.Fprintf(p.w, "\n--- ping statistics per %v ---\n", p.intervals)
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	if len(targets) > 1 {
+		fmt.Fprint(table, "TARGET\t")
+	}
+	fmt.Fprint(table, "START\tSENT\tRECV\tLOSS\tAVG\n")
+	for i, target := range targets {
+		for _, iv := range all[i] {
+			if len(targets) > 1 {
+				fmt.Fprintf(table, "%s\t", target)
+			}
+			fmt.Fprintf(table, "%s\t%d\t%d\t%.1f%%", iv.start.Format(time.TimeOnly), iv.probes, iv.received, iv.loss())
+			if iv.received > 0 {
+				fmt.Fprintf(table, "\t%s\n", p.rtt.format(iv.mean))
+			} else {
+				fmt.Fprint(table, "\t-\n")
+			}
+		}
+	}
+	table.Flush()
+}
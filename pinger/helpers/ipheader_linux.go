@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/sys/unix"
+)
+
+// ipv4HeaderReadable tells whether readIPv4Header works here
+const ipv4HeaderReadable = true
+
+// kernelTimestamps tells whether the kernel can stamp what sockets receive
+// here, for RTTs not to count the time replies wait to be read
+const kernelTimestamps = true
+
+// sizeofTimespec is the size of the SCM_TIMESTAMPNS control message
+const sizeofTimespec = int(unsafe.Sizeof(unix.Timespec{}))
+
+// setRecvTimestamps asks for SO_TIMESTAMPNS control messages, with the time
+// the kernel received each packet, on socket *fd*
+func setRecvTimestamps(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+}
+
+// setRecvTOS asks for IP_TOS control messages on IPv4 socket *fd*
+func setRecvTOS(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVTOS, 1)
+}
+
+// setRecvOptions asks for IP_RECVOPTS control messages, with the options of
+// received packets, on IPv4 socket *fd*
+func setRecvOptions(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVOPTS, 1)
+}
+
+// setIPOptions makes IPv4 socket *fd* send its packets with header *options*
+func setIPOptions(fd uintptr, options []byte) error {
+	return unix.SetsockoptString(int(fd), unix.IPPROTO_IP, unix.IP_OPTIONS, string(options))
+}
+
+// readIPv4Header reads an ICMP packet from IPv4 socket *conn* into b, like
+// icmpTransport.Recv, with the *want*ed header fields too: from the IP header raw
+// sockets get, or from the control messages datagram sockets get. When the
+// kernel stamps what the socket receives, the time it did comes along.
+func readIPv4Header(conn *icmp.PacketConn, b []byte, want headerFields) (int, packetMeta, net.Addr, error) {
+	meta := packetMeta{ttl: defaultTTL}
+
+	// IP_PKTINFO comes along, as icmpTransport.Recv asks for the interface
+	oob := make([]byte, unix.CmsgSpace(unix.SizeofInet4Pktinfo)+2*unix.CmsgSpace(4)+unix.CmsgSpace(ipOptionsMax)+unix.CmsgSpace(sizeofTimespec)+unix.CmsgSpace(sizeofScmTimestamping))
+	n, oobn, addr, err := readMsg(conn.IPv4PacketConn().PacketConn, b, oob)
+	if err != nil {
+		return 0, meta, addr, err
+	}
+	if err := parseControlMessages(oob[:oobn], want, &meta); err != nil {
+		return 0, meta, addr, err
+	}
+
+	if _, raw := conn.IPv4PacketConn().PacketConn.(*net.IPConn); !raw {
+		return n, meta, addr, nil
+	}
+
+	hdrlen := int(b[0]&0x0f) << 2
+	if n < 20 || n < hdrlen {
+		return 0, meta, addr, fmt.Errorf("short IPv4 packet: %d bytes", n)
+	}
+	meta.ttl = int(b[8])
+	if want&fieldTOS != 0 {
+		meta.tos, meta.tosKnown = int(b[1]), true
+	}
+	if want&fieldOptions != 0 {
+		meta.options = append([]byte(nil), b[20:hdrlen]...)
+	}
+
+	return copy(b, b[hdrlen:n]), meta, addr, nil
+}
+
+// readIPv6Header reads an ICMPv6 packet from IPv6 socket *conn* into b, like
+// icmpTransport.Recv, with the Hop Limit, the *want*ed Traffic Class, and the
+// time the kernel received it: raw IPv6 sockets get no header, so all of it
+// comes in control messages
+func readIPv6Header(conn *icmp.PacketConn, b []byte, want headerFields) (int, packetMeta, net.Addr, error) {
+	meta := packetMeta{ttl: defaultTTL}
+
+	oob := make([]byte, unix.CmsgSpace(unix.SizeofInet6Pktinfo)+2*unix.CmsgSpace(4)+unix.CmsgSpace(sizeofTimespec)+unix.CmsgSpace(sizeofScmTimestamping))
+	n, oobn, addr, err := readMsg(conn.IPv6PacketConn().PacketConn, b, oob)
+	if err != nil {
+		return 0, meta, addr, err
+	}
+	if err := parseControlMessages(oob[:oobn], want, &meta); err != nil {
+		return 0, meta, addr, err
+	}
+	return n, meta, addr, nil
+}
+
+// readMsg reads a packet, and its control messages into *oob*, from raw or
+// datagram socket *pc*
+func readMsg(pc net.PacketConn, b, oob []byte) (int, int, net.Addr, error) {
+	switch pc := pc.(type) {
+	case *net.IPConn:
+		n, oobn, _, addr, err := pc.ReadMsgIP(b, oob)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		return n, oobn, addr, nil
+	case *net.UDPConn:
+		n, oobn, _, addr, err := pc.ReadMsgUDP(b, oob)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		return n, oobn, addr, nil
+	}
+	return 0, 0, nil, fmt.Errorf("unexpected socket type %T", pc)
+}
+
+// parseControlMessages sets what the control messages *oob* say in *meta*:
+// the TTL / Hop Limit, the *want*ed TOS / Traffic Class and IPv4 options, and
+// the time the kernel, and with hardware timestamps the NIC, received the packet
+func parseControlMessages(oob []byte, want headerFields, meta *packetMeta) error {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		switch {
+		case m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPNS && len(m.Data) >= sizeofTimespec:
+			ts := (*unix.Timespec)(unsafe.Pointer(&m.Data[0]))
+			meta.received = time.Unix(ts.Unix())
+		case m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPING && len(m.Data) >= sizeofScmTimestamping:
+			ts := (*unix.ScmTimestamping)(unsafe.Pointer(&m.Data[0]))
+			meta.received = cmpOr(meta.received, timespecTime(ts.Ts[0]))
+			meta.hwReceived = timespecTime(ts.Ts[2])
+
+		case m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_TTL && len(m.Data) >= 4:
+			meta.ttl = int(int32(binary.NativeEndian.Uint32(m.Data)))
+		case m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_TOS && len(m.Data) >= 1 && want&fieldTOS != 0:
+			meta.tos, meta.tosKnown = int(m.Data[0]), true
+		case m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_RECVOPTS && want&fieldOptions != 0:
+			meta.options = append([]byte(nil), m.Data...)
+
+		case m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_HOPLIMIT && len(m.Data) >= 4:
+			meta.ttl = int(int32(binary.NativeEndian.Uint32(m.Data)))
+		case m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_TCLASS && len(m.Data) >= 4 && want&fieldTOS != 0:
+			meta.tos, meta.tosKnown = int(int32(binary.NativeEndian.Uint32(m.Data))), true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+)
+
+// ipv4HeaderReadable tells whether readIPv4Header works here: only on Linux
+const ipv4HeaderReadable = false
+
+// kernelTimestamps tells whether the kernel can stamp what sockets receive
+// here: only on Linux, elsewhere RTTs end when the reply is read
+const kernelTimestamps = false
+
+func setRecvTimestamps(fd uintptr) error {
+	return fmt.Errorf("SO_TIMESTAMPNS is not supported")
+}
+
+func setRecvTOS(fd uintptr) error {
+	return fmt.Errorf("IP_RECVTOS is not supported")
+}
+
+func setRecvOptions(fd uintptr) error {
+	return fmt.Errorf("IP_RECVOPTS is not supported")
+}
+
+func setIPOptions(fd uintptr, options []byte) error {
+	return fmt.Errorf("IP_OPTIONS is not supported")
+}
+
+func readIPv4Header(conn *icmp.PacketConn, b []byte, want headerFields) (int, packetMeta, net.Addr, error) {
+	return 0, packetMeta{}, nil, fmt.Errorf("reading the IP header of IPv4 replies is not supported")
+}
+
+func readIPv6Header(conn *icmp.PacketConn, b []byte, want headerFields) (int, packetMeta, net.Addr, error) {
+	return 0, packetMeta{}, nil, fmt.Errorf("reading the control messages of IPv6 replies is not supported")
+}
@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// IPv4 header options (RFC 791)
+const (
+	ipOptEnd         = 0
+	ipOptNop         = 1
+	ipOptRecordRoute = 7
+	ipOptTimestamp   = 68
+
+	ipOptionsMax = 40 // an IPv4 header is at most 60 bytes, 20 of them fixed
+)
+
+// What the Timestamp option asks routers to record, for --ip-timestamp
+const (
+	IPTimestampOnly    = "tsonly"    // their clocks only
+	IPTimestampAndAddr = "tsandaddr" // their addresses and clocks
+)
+
+// ParseIPTimestamp checks the kind of Timestamp option for --ip-timestamp
+func ParseIPTimestamp(s string) (string, error) {
+	switch kind := strings.ToLower(s); kind {
+	case IPTimestampOnly, IPTimestampAndAddr:
+		return kind, nil
+	}
+	return "", fmt.Errorf("invalid --ip-timestamp %q: want %s or %s", s, IPTimestampOnly, IPTimestampAndAddr)
+}
+
+// headerFields are the parts of a reply's IP header, beyond the TTL, that
+// an icmpTransport is asked to pass on. They take extra work to get at, so only
+// what the options in use need is read.
+type headerFields int
+
+const (
+	fieldTOS     headerFields = 1 << iota // TOS / Traffic Class, for --ecn
+	fieldOptions                          // IPv4 options, for -R and --ip-timestamp
+)
+
+// headerFields tells which parts of the IP header of replies *info* needs
+func (info ICMPInfo) headerFields() headerFields {
+	var want headerFields
+	if info.ECN {
+		want |= fieldTOS
+	}
+	if info.ipOptions() != nil {
+		want |= fieldOptions
+	}
+	return want
+}
+
+// ipOptions are the IPv4 header options *info* sends its probes with, if any.
+// Both options want the whole 40 bytes, so only one is ever asked for.
+func (info ICMPInfo) ipOptions() []byte {
+	switch {
+	case info.RecordRoute:
+		return recordRouteOption()
+	case info.Timestamp != "":
+		return timestampOption(info.Timestamp)
+	}
+	return nil
+}
+
+// recordRouteOption is a Record Route option with room for as many addresses
+// as the IPv4 header holds, the last byte of padding as an End of Options list
+func recordRouteOption() []byte {
+	opt := make([]byte, ipOptionsMax)
+	opt[0], opt[1], opt[2] = ipOptRecordRoute, ipOptionsMax-1, 4
+	return opt
+}
+
+// timestampOption is a Timestamp option of *kind* with room for as many
+// entries as the IPv4 header holds: 9 timestamps, or 4 address / timestamp pairs
+func timestampOption(kind string) []byte {
+	length, flag := ipOptionsMax, byte(0)
+	if kind == IPTimestampAndAddr {
+		length, flag = 4+4*8, 1
+	}
+
+	opt := make([]byte, length)
+	opt[0], opt[1], opt[2], opt[3] = ipOptTimestamp, byte(length), 5, flag
+	return opt
+}
+
+// enableIPOptions sends the probes of IPv4 socket *conn* with header *options*,
+// and asks the kernel for the options of what it receives
+func enableIPOptions(conn *icmp.PacketConn, options []byte) error {
+	if !ipv4HeaderReadable {
+		return fmt.Errorf("IPv4 header options are only supported on Linux")
+	}
+	return controlSocket(conn, protocolICMP, func(fd uintptr) error {
+		if err := setIPOptions(fd, options); err != nil {
+			return err
+		}
+		return setRecvOptions(fd)
+	})
+}
+
+// findIPOption returns the option of type *kind* among IPv4 header *options*, if any
+func findIPOption(options []byte, kind byte) []byte {
+	for i := 0; i < len(options); {
+		switch options[i] {
+		case ipOptEnd:
+			return nil
+		case ipOptNop:
+			i++
+			continue
+		}
+		if i+1 >= len(options) || options[i+1] < 2 || i+int(options[i+1]) > len(options) {
+			return nil
+		}
+
+		opt := options[i : i+int(options[i+1])]
+		if opt[0] == kind {
+			return opt
+		}
+		i += len(opt)
+	}
+	return nil
+}
+
+// recordedRoute is the list of addresses in the Record Route option among
+// IPv4 header *options*, nil if there is none
+func recordedRoute(options []byte) []string {
+	opt := findIPOption(options, ipOptRecordRoute)
+	if len(opt) < 3 {
+		return nil
+	}
+
+	// The pointer is the 1-based offset of the next free slot
+	end := min(int(opt[2])-1, len(opt))
+	route := []string{}
+	for i := 3; i+4 <= end; i += 4 {
+		route = append(route, net.IP(opt[i:i+4]).String())
+	}
+	return route
+}
+
+// IPTimestamp is one entry of the Timestamp option of a reply
+type IPTimestamp struct {
+	Addr     string // address of the host that stamped, with tsandaddr
+	MS       uint32 // its clock, in milliseconds since midnight UT, if Standard
+	Standard bool   // false when the host put a clock of its own choosing instead
+}
+
+// ipTimestamps is what the Timestamp option among IPv4 header *options* recorded,
+// and how many hosts could not stamp it for lack of room
+func ipTimestamps(options []byte) ([]IPTimestamp, int) {
+	opt := findIPOption(options, ipOptTimestamp)
+	if len(opt) < 4 {
+		return nil, 0
+	}
+
+	size := 4
+	withAddr := opt[3]&0x0f != 0
+	if withAddr {
+		size = 8
+	}
+
+	// As for Record Route, the pointer is the 1-based offset of the next free slot
+	end := min(int(opt[2])-1, len(opt))
+	stamps := []IPTimestamp{}
+	for i := 4; i+size <= end; i += size {
+		var stamp IPTimestamp
+		if withAddr {
+			stamp.Addr = net.IP(opt[i : i+4]).String()
+		}
+		ms := binary.BigEndian.Uint32(opt[i+size-4 : i+size])
+		stamp.MS, stamp.Standard = ms&0x7fffffff, ms&0x80000000 == 0
+		stamps = append(stamps, stamp)
+	}
+	return stamps, int(opt[3] >> 4)
+}
+
+// msSinceMidnightUT is *t* the way the Timestamp option counts time
+func msSinceMidnightUT(t time.Time) int64 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return t.Sub(midnight).Milliseconds()
+}
+
+// timestampOffset is how far ahead of our clock at *sent* a host stamped *ms*:
+// the one-way delay to it, plus the offset of its clock from ours
+func timestampOffset(ms uint32, sent time.Time) int64 {
+	offset := int64(ms) - msSinceMidnightUT(sent)
+
+	// Stamps taken around midnight are on the other side of it
+	const day = 24 * 60 * 60 * 1000
+	switch {
+	case offset > day/2:
+		offset -= day
+	case offset < -day/2:
+		offset += day
+	}
+	return offset
+}
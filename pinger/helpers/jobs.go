@@ -0,0 +1,310 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// Defaults of the zero values of jobSpec, those of package ping
+const (
+	jobCount    = 5
+	jobInterval = time.Second
+	jobTimeout  = 4 * time.Second
+)
+
+// jobMinInterval is the shortest interval a job may ask for: jobs are
+// measurements, not floods
+const jobMinInterval = 10 * time.Millisecond
+
+// errTooManyJobs is the error of jobs started past JobConfig.MaxJobs
+var errTooManyJobs = errors.New("too many jobs running: try again once some are over")
+
+// States of a job
+const (
+	jobRunning = "running" // still pinging
+	jobDone    = "done"    // sent all its Echo Requests
+	jobStopped = "stopped" // stopped before it did
+	jobFailed  = "failed"  // could not ping at all, e.g. without a socket
+)
+
+// jobSpec is what a job pings, and how: the zero value of every field but
+// Target is its default
+type jobSpec struct {
+	Target   string        // hostname or IP address to ping
+	Count    int           // Echo Requests to send, jobCount if 0
+	Interval time.Duration // time between Echo Requests, jobInterval if 0
+	Timeout  time.Duration // how long to wait for each reply, jobTimeout if 0
+	IPv6     bool          // resolve Target to an IPv6 address, rather than IPv4
+	Iface    string        // interface to send from, that of the server if ""
+	TTL      int           // TTL / Hop Limit, that of the server if 0
+}
+
+// withDefaults checks *spec*, and fills in the defaults of its zero fields
+func (spec jobSpec) withDefaults() (jobSpec, error) {
+	switch {
+	case spec.Target == "":
+		return spec, fmt.Errorf("no target to ping")
+	case spec.Count < 0:
+		return spec, fmt.Errorf("invalid count %d: must be at least 1", spec.Count)
+	case spec.Interval < 0 || spec.Timeout < 0:
+		return spec, fmt.Errorf("the interval and timeout cannot be negative")
+	case spec.Interval > 0 && spec.Interval < jobMinInterval:
+		return spec, fmt.Errorf("invalid interval %v: must be at least %v", spec.Interval, jobMinInterval)
+	case spec.TTL < 0 || spec.TTL > 255:
+		return spec, fmt.Errorf("invalid TTL %d: must be between 1 and 255", spec.TTL)
+	}
+
+	if spec.Count == 0 {
+		spec.Count = jobCount
+	}
+	if spec.Interval == 0 {
+		spec.Interval = jobInterval
+	}
+	if spec.Timeout == 0 {
+		spec.Timeout = jobTimeout
+	}
+	return spec, nil
+}
+
+// JobConfig is how a server runs the ping jobs other programs ask it for
+type JobConfig struct {
+	Info4, Info6 ICMPInfo      // how to ping IPv4 / IPv6 targets, as the flags of the server ask
+	Resolve      AddrOptions   // how to resolve hostnames, as the flags of the server ask
+	MaxJobs      int           // jobs running at once, 0 for no limit
+	Retention    time.Duration // how long jobs, and their results, are kept once over
+}
+
+// jobManager runs the ping jobs other programs ask a pinger server for, each
+// in its own goroutine, and keeps their results for them to fetch or stream
+type jobManager struct {
+	config JobConfig
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running int // jobs still running, of jobs
+}
+
+// newJobManager runs jobs as *config* asks
+func newJobManager(config JobConfig) *jobManager {
+	return &jobManager{config: config, jobs: make(map[string]*job)}
+}
+
+// start resolves the target of *spec*, and starts pinging it
+func (m *jobManager) start(spec jobSpec) (*job, error) {
+	spec, err := spec.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	resolve := m.config.Resolve
+	if spec.IPv6 {
+		resolve.V4, resolve.V6 = false, true
+	}
+	addr, err := AddrResolution(spec.Target, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	info := m.config.Info4
+	if addr.IsIPv6 {
+		info = m.config.Info6
+	}
+	info.IP, info.CNT = addr.Addr, spec.Count
+	switch {
+	case spec.Iface != "":
+		info.Iface = spec.Iface
+	case addr.Zone != "":
+		info.Iface = addr.Zone
+	}
+	if spec.TTL != 0 {
+		info.TTL = spec.TTL
+	}
+	if info.Source != "" && (net.ParseIP(info.Source).To4() == nil) != addr.IsIPv6 {
+		return nil, fmt.Errorf("the server sends from %s, not of the IP family of %s", info.Source, addr.Addr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		spec:    spec,
+		target:  addr.Addr,
+		started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		state:   jobRunning,
+		changed: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config.MaxJobs > 0 && m.running >= m.config.MaxJobs {
+		cancel()
+		return nil, errTooManyJobs
+	}
+	m.prune()
+	m.running++
+	// A random UUID, that other clients cannot guess to stop the job
+	j.id = newRunID()
+	m.jobs[j.id] = j
+
+	go func() {
+		j.run(ctx, info, addr.IsIPv6)
+		m.mu.Lock()
+		m.running--
+		m.mu.Unlock()
+	}()
+	return j, nil
+}
+
+// get is the job of ID *id*, nil if there is none
+func (m *jobManager) get(id string) *job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	return m.jobs[id]
+}
+
+// list is every job kept, in the order they were started
+func (m *jobManager) list() []*job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	jobs := slices.Collect(maps.Values(m.jobs))
+	slices.SortFunc(jobs, func(a, b *job) int { return a.started.Compare(b.started) })
+	return jobs
+}
+
+// prune forgets the jobs over for longer than the retention of the config;
+// m.mu is held
+func (m *jobManager) prune() {
+	for id, j := range m.jobs {
+		j.mu.Lock()
+		expired := !j.ended.IsZero() && time.Since(j.ended) > m.config.Retention
+		j.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// stop stops the job of ID *id*, if it is still running, and waits for it to
+// end: it is nil if there is no such job
+func (m *jobManager) stop(id string) *job {
+	j := m.get(id)
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	if j.state == jobRunning {
+		j.stopping = true
+	}
+	j.mu.Unlock()
+	j.cancel()
+	<-j.done
+	return j
+}
+
+// job is a ping of a target, run by a jobManager
+type job struct {
+	id      string
+	spec    jobSpec
+	target  string // address pinged, the target of spec once resolved
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once the job is over
+
+	mu       sync.Mutex
+	state    string
+	err      error
+	stopping bool // stop was asked for
+	results  []ext.Result
+	stats    PingStats
+	ended    time.Time
+	changed  chan struct{} // closed, and replaced, on every result and at the end
+}
+
+// run pings as *info* asks, until done or stopped
+func (j *job) run(ctx context.Context, info ICMPInfo, isIPv6 bool) {
+	err := pingEach(ctx, info, isIPv6, j.spec.Interval, j.spec.Timeout, j.add)
+
+	j.mu.Lock()
+	switch {
+	case err != nil:
+		j.state, j.err = jobFailed, err
+	case j.stopping:
+		j.state = jobStopped
+	default:
+		j.state = jobDone
+	}
+	j.ended = time.Now()
+	j.notify()
+	j.mu.Unlock()
+	j.cancel()
+	close(j.done)
+}
+
+// add records the outcome of an Echo Request of the job
+func (j *job) add(res ProbeResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stats.transmitted++
+	j.stats.record(res)
+	j.results = append(j.results, extResult(res))
+	j.notify()
+}
+
+// notify wakes up whoever waits for the job to change; j.mu is held
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// next is the results of the job from the *from*th on, whether the job is
+// over, and, for when there are none of them yet, a channel closed once
+// there are more or the job is over
+func (j *job) next(from int) (results []ext.Result, over bool, changed <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if from < len(j.results) {
+		results = append(results, j.results[from:]...)
+	}
+	return results, j.state != jobRunning, j.changed
+}
+
+// jobStatus is what there is to know of a job, but its results
+type jobStatus struct {
+	ID      string
+	Spec    jobSpec
+	Target  string // address pinged
+	State   string
+	Error   string // why the job failed
+	Started time.Time
+	Ended   time.Time // zero while running
+	Summary ext.Summary
+}
+
+// status is what there is to know of the job now
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := jobStatus{
+		ID:      j.id,
+		Spec:    j.spec,
+		Target:  j.target,
+		State:   j.state,
+		Started: j.started,
+		Ended:   j.ended,
+		Summary: extSummary(j.target, &j.stats),
+	}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	return status
+}
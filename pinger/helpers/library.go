@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// Ping sends info.CNT Echo Requests to info.IP, one every *interval*, waiting
+// up to *timeout* for each reply, and returns their outcomes and statistics.
+// Unlike ICMP4Handler / ICMP6Handler, it neither prints, nor exits, nor
+// watches for signals: cancelling *ctx* ends it early, with the results so
+// far. It is what package ping, the Go API of pinger, runs.
+func Ping(ctx context.Context, info ICMPInfo, isIPv6 bool, interval, timeout time.Duration) ([]ext.Result, ext.Summary, error) {
+	stats := PingStats{}
+	var results []ext.Result
+	err := pingEach(ctx, info, isIPv6, interval, timeout, func(res ProbeResult) {
+		stats.transmitted++
+		stats.record(res)
+		results = append(results, extResult(res))
+	})
+	if err != nil {
+		return nil, ext.Summary{}, err
+	}
+	return results, extSummary(info.IP, &stats), nil
+}
+
+// pingEach pings as Ping does, handing the outcome of every Echo Request to
+// *each* as it comes, rather than all of them at the end
+func pingEach(ctx context.Context, info ICMPInfo, isIPv6 bool, interval, timeout time.Duration, each func(ProbeResult)) error {
+	s, err := openSession(info, isIPv6)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	s.timeout = timeout
+
+	// Cut short the wait for a reply, as onInterrupt does for Ctrl + C
+	stop := context.AfterFunc(ctx, func() { s.t.SetDeadline(clk.Now()) })
+	defer stop()
+
+	for seq := range info.CNT {
+		if seq > 0 {
+			select {
+			case <-ctx.Done():
+			case <-clk.After(interval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		res := s.probe(seq)
+		if ctx.Err() != nil {
+			break
+		}
+		each(res)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// limitGrace is how long a run has to wind down once a limit of SetLimits is
+// reached, before pinger exits anyway
+const limitGrace = 5 * time.Second
+
+// errPacketBudget is the error of sends past the --max-packets budget
+var errPacketBudget = errors.New("packet budget of --max-packets spent")
+
+var (
+	// limitReached is closed once a limit of SetLimits is reached: runs stop
+	// as on Ctrl + C, see onInterrupt
+	limitReached = make(chan struct{})
+	limitOnce    sync.Once
+	// limitCuts tells whether the probe in flight is cut short too, as on
+	// Ctrl + C, or gets its outcome first: the packet budget was spent on it
+	limitCuts bool
+
+	// packetBudget is how many more probes may be sent, out of packetLimit
+	packetBudget atomic.Int64
+	packetLimit  int
+)
+
+// SetLimits stops whatever pinger runs once it has run for *maxRuntime*, or
+// sent *maxPackets* probes, where they are not 0: a failsafe against probes
+// left running. Runs stop as on Ctrl + C, with their statistics; those that
+// cannot are cut short limitGrace later. No probe is sent past the budget.
+func SetLimits(maxRuntime time.Duration, maxPackets int) {
+	if maxRuntime > 0 {
+		time.AfterFunc(maxRuntime, func() {
+			reachLimit(fmt.Sprintf("--max-runtime %v reached", maxRuntime), true)
+		})
+	}
+	if maxPackets > 0 {
+		packetBudget.Store(int64(maxPackets))
+		packetLimit = maxPackets
+	}
+}
+
+// spendPacket accounts for a probe about to be sent, and fails if the packet
+// budget of SetLimits is spent. The last probe of the budget stops the run.
+func spendPacket() error {
+	if packetLimit == 0 {
+		return nil
+	}
+	left := packetBudget.Add(-1)
+	if left < 0 {
+		return errPacketBudget
+	}
+	if left == 0 {
+		reachLimit(fmt.Sprintf("--max-packets %d reached", packetLimit), false)
+	}
+	return nil
+}
+
+// reachLimit stops the run for *reason*, the probe in flight with it if *cut*
+func reachLimit(reason string, cut bool) {
+	limitOnce.Do(func() {
+		slog.Warn("stopping: " + reason)
+		limitCuts = cut
+		close(limitReached)
+
+		time.AfterFunc(limitGrace, func() {
+			slog.Error(fmt.Sprintf("still running %v after %s: exiting", limitGrace, reason))
+			Exit(ExitNoReply)
+		})
+	})
+}
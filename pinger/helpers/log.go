@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logLevels are the levels of the diagnostics logged at each verbosity:
+// warnings and errors by default, how sockets are set up with -v, and the
+// control messages and headers of every packet read with -vv
+var logLevels = []slog.Level{slog.LevelWarn, slog.LevelInfo, slog.LevelDebug}
+
+// SetLogging sends the diagnostics of pinger, which go through log/slog, to
+// stderr, or appends them to the file *path* if not "", at *verbosity*
+// (the number of -v). They stay apart from the results, on stdout.
+func SetLogging(verbosity int, path string) error {
+	level := logLevels[min(max(verbosity, 0), len(logLevels)-1)]
+
+	var (
+		w        io.Writer = os.Stderr
+		noTimeOf           = dropTime // a terminal shows when it all happened already
+	)
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("--log-file: %v", err)
+		}
+		w, noTimeOf = file, nil
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: noTimeOf})))
+	return nil
+}
+
+// dropTime removes the time from log records
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return a
+}
@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	maxMACSweep     = 1 << 10         // most addresses of an IPv4 subnet MACAddr ARPs, a /22
+	macDiscoverWait = 2 * time.Second // how long MACAddr waits for answers to its discovery probes
+)
+
+// MACAddr finds the IP address bound to *mac*, of the family options.V4 / V6
+// ask for, IPv4 first, on the network of interface *iface*, "" for any.
+// The kernel's neighbor table is looked at first. If it has no entry, the
+// address is discovered on *iface*: with an ARP request to every address of its
+// IPv4 subnets, and then an Echo Request to all IPv6 nodes (ff02::1).
+func MACAddr(mac net.HardwareAddr, iface string, options AddrOptions) (UnMarshalledAddr, error) {
+	if addr, ok := neighborByMAC(mac, iface, options); ok {
+		return addr, nil
+	}
+	if iface == "" {
+		return UnMarshalledAddr{}, fmt.Errorf("%v is not in the neighbor table: give the interface to look for it on with -I", mac)
+	}
+
+	link, err := net.InterfaceByName(iface)
+	if err != nil {
+		return UnMarshalledAddr{}, fmt.Errorf("error finding interface %s: %v", iface, err)
+	}
+
+	var skipped []string
+	if !options.V6 {
+		var ip net.IP
+		ip, skipped, err = discoverIPv4(link, mac)
+		if err != nil {
+			return UnMarshalledAddr{}, err
+		}
+		if ip != nil {
+			var addr UnMarshalledAddr
+			addr.set(ip.String(), false)
+			return addr, nil
+		}
+	}
+
+	if !options.V4 {
+		if addr, ok := discoverIPv6(link, mac); ok {
+			return addr, nil
+		}
+	}
+
+	if len(skipped) > 0 {
+		return UnMarshalledAddr{}, fmt.Errorf("no host answered from %v on %s (%v not searched: larger than /22)", mac, iface, skipped)
+	}
+	return UnMarshalledAddr{}, fmt.Errorf("no host answered from %v on %s", mac, iface)
+}
+
+// neighborByMAC finds *mac* in the kernel's neighbor table, on *iface* if not
+// "": IPv4 entries first, then global IPv6 ones, then link-local ones
+func neighborByMAC(mac net.HardwareAddr, iface string, options AddrOptions) (UnMarshalledAddr, bool) {
+	var linkLocal *neighborEntry
+
+	for _, isIPv6 := range []bool{false, true} {
+		if (isIPv6 && options.V4) || (!isIPv6 && options.V6) {
+			continue
+		}
+
+		for _, entry := range neighborTable(isIPv6) {
+			if !bytes.Equal(entry.MAC, mac) || (iface != "" && entry.Iface != iface) {
+				continue
+			}
+			if entry.IP.IsLinkLocalUnicast() {
+				if linkLocal == nil {
+					linkLocal = &entry
+				}
+				continue
+			}
+
+			var addr UnMarshalledAddr
+			addr.set(entry.IP.String(), isIPv6)
+			return addr, true
+		}
+	}
+
+	if linkLocal == nil {
+		return UnMarshalledAddr{}, false
+	}
+	addr := UnMarshalledAddr{Zone: linkLocal.Iface}
+	addr.set(linkLocal.IP.String(), linkLocal.IP.To4() == nil)
+	return addr, true
+}
+
+// discoverIPv4 ARPs every address of the IPv4 subnets of *link* for the one at
+// *mac*. It also returns the subnets too large to be searched.
+func discoverIPv4(link *net.Interface, mac net.HardwareAddr) (net.IP, []string, error) {
+	addrs, err := link.Addrs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []string
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		ones, bits := ipnet.Mask.Size()
+		if bits != 32 {
+			continue
+		}
+		if 1<<(bits-ones) > maxMACSweep {
+			skipped = append(skipped, ipnet.String())
+			continue
+		}
+
+		ip, err := arpSweep(link, ipnet.IP, subnetHosts(ipnet), mac, macDiscoverWait)
+		if err != nil || ip != nil {
+			return ip, skipped, err
+		}
+	}
+
+	return nil, skipped, nil
+}
+
+// subnetHosts lists the addresses of the IPv4 subnet *ipnet*, but its network
+// and broadcast addresses and our own
+func subnetHosts(ipnet *net.IPNet) []net.IP {
+	network := ipnet.IP.To4().Mask(ipnet.Mask)
+	ones, bits := ipnet.Mask.Size()
+	size := 1 << (bits - ones)
+
+	var hosts []net.IP
+	for i := range size {
+		if size > 2 && (i == 0 || i == size-1) {
+			continue
+		}
+
+		host := make(net.IP, 4)
+		for b := range host {
+			host[b] = network[b] | byte(i>>(8*(3-b)))
+		}
+		if !host.Equal(ipnet.IP) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// discoverIPv6 sends an Echo Request to all IPv6 nodes on *link*, and
+// solicits the link layer address of every one answering, until one is *mac*
+func discoverIPv6(link *net.Interface, mac net.HardwareAddr) (UnMarshalledAddr, bool) {
+	conn, raw, err := listenICMP(protocolICMPv6, true, link, "", "")
+	if err != nil {
+		return UnMarshalledAddr{}, false
+	}
+	defer conn.Close()
+	conn.IPv6PacketConn().SetMulticastInterface(link)
+
+	request, err := BuildEchoRequest(WithIPv6(true), WithID(echoID(conn, raw)))
+	if err != nil {
+		return UnMarshalledAddr{}, false
+	}
+	dst := Destination{Iface: link, Raw: raw}.Addr(net.IPv6linklocalallnodes.String())
+	if _, err := conn.WriteTo(request, dst); err != nil {
+		return UnMarshalledAddr{}, false
+	}
+
+	conn.SetReadDeadline(time.Now().Add(macDiscoverWait))
+	reply := make([]byte, 1500)
+	solicited := make(map[string]bool)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			return UnMarshalledAddr{}, false
+		}
+		if msg, err := icmp.ParseMessage(protocolICMPv6, reply[:n]); err != nil || msg.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+
+		ip, _ := SplitZone(peerIP(peer))
+		if isSelf, _ := IsLocalAddr(ip); isSelf || solicited[ip] {
+			continue
+		}
+		solicited[ip] = true
+
+		if neighborMAC(link, net.ParseIP(ip)) == mac.String() {
+			addr := UnMarshalledAddr{Zone: link.Name}
+			addr.set(ip, true)
+			return addr, true
+		}
+	}
+}
+
+// neighborMAC is the link layer address *ip* answers a Neighbor Solicitation
+// on *link* with, "" if it does not
+func neighborMAC(link *net.Interface, ip net.IP) string {
+	prober, err := newNDPProber(link, ip)
+	if err != nil {
+		return ""
+	}
+	defer prober.close()
+
+	return prober.probe(0, time.Second).HWAddr
+}
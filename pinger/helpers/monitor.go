@@ -0,0 +1,166 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// States of a target under SetMonitor
+const (
+	StateUp       = "UP"
+	StateDegraded = "DEGRADED"
+	StateDown     = "DOWN"
+)
+
+// monitorMinProbes is how many probes the window of MonitorRules must hold
+// for its loss to be judged: a loss rate over fewer means little
+const monitorMinProbes = 10
+
+// MonitorRules is how SetMonitor judges the state of a target, from its
+// probes: DOWN after DownAfter unanswered probes in a row, DEGRADED if the
+// loss, or the average RTT, over the last Window is above its threshold, and
+// UP otherwise
+type MonitorRules struct {
+	DownAfter int           // unanswered probes in a row for DOWN
+	Window    time.Duration // how far back the loss and the average RTT are judged
+	MaxLoss   float64       // percentage of loss over Window above which DEGRADED
+	MaxRTT    time.Duration // average RTT over Window above which DEGRADED, 0 for none
+}
+
+// monitor, when set with SetMonitor, is the rules every Printer of
+// NewPrinter judges the state of its targets by
+var monitor *MonitorRules
+
+// SetMonitor judges the state of every target by *rules* as its probes come,
+// and notes every change of state: in text, only they are printed, not the
+// probes, for a log of the reachability of the targets.
+func SetMonitor(rules MonitorRules) {
+	monitor = &rules
+}
+
+// outcome is when a probe got its outcome, and its RTT if it was answered
+type outcome struct {
+	time     time.Time
+	rtt      float64
+	answered bool
+}
+
+// monitorTarget is what the rules of a monitorPrinter judge a target by
+type monitorTarget struct {
+	state  string
+	misses int       // unanswered probes in a row
+	recent []outcome // probes of the window
+	reason string    // last probe without a reply, why
+}
+
+// monitorPrinter judges the state of every target a Printer prints the
+// probes of, by its rules, and notes every change. Run as a systemd service,
+// it tells systemd when it is ready, and the states, and feeds its watchdog
+// while probes get outcomes.
+type monitorPrinter struct {
+	Printer
+	rules   MonitorRules
+	targets map[string]*monitorTarget
+	last    atomic.Int64 // when a probe last got an outcome, in Unix nanoseconds
+}
+
+func (p *monitorPrinter) Header(info ICMPInfo) {
+	p.Printer.Header(info)
+	p.last.CompareAndSwap(0, time.Now().UnixNano())
+	notifyReady("monitoring", p.alive)
+}
+
+// alive reports whether a probe got an outcome *within* the last while, for
+// the watchdog of systemd
+func (p *monitorPrinter) alive(within time.Duration) bool {
+	return time.Since(time.Unix(0, p.last.Load())) < within
+}
+
+func (p *monitorPrinter) Probe(res ProbeResult) {
+	p.Printer.Probe(res)
+	if res.Duplicate || res.Reason == ReasonNeighborDiscovery || res.Reason == ReasonMismatchedReply {
+		return
+	}
+
+	p.last.Store(time.Now().UnixNano())
+	t := p.targets[res.Target]
+	if t == nil {
+		t = &monitorTarget{}
+		p.targets[res.Target] = t
+	}
+
+	o := outcome{time: res.Time}
+	switch res.Reason {
+	case ReasonNone, ReasonPortUnreachable:
+		o.rtt, o.answered = res.RTT, true
+		t.misses = 0
+	default:
+		t.misses++
+		t.reason = res.Reason.String()
+	}
+	t.recent = append(t.recent, o)
+	for len(t.recent) > 0 && res.Time.Sub(t.recent[0].time) > p.rules.Window {
+		t.recent = t.recent[1:]
+	}
+
+	state, why := p.judge(t)
+	if state == t.state {
+		return
+	}
+
+	message := fmt.Sprintf("%s is %s", res.Target, state)
+	fields := map[string]string{"target": res.Target, "state": state}
+	if t.state != "" {
+		message += ", was " + t.state
+		fields["previous"] = t.state
+	}
+	if why != "" {
+		message += ": " + why
+		fields["why"] = why
+	}
+	p.Printer.Annotate(Annotation{Time: clk.Now(), Kind: "state_change", Message: message, Fields: fields})
+	t.state = state
+	sdNotify("STATUS=" + p.status())
+}
+
+// status counts the targets in every state, e.g. "2 UP, 1 DOWN"
+func (p *monitorPrinter) status() string {
+	var counts []string
+	for _, state := range []string{StateUp, StateDegraded, StateDown} {
+		n := 0
+		for _, t := range p.targets {
+			if t.state == state {
+				n++
+			}
+		}
+		if n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, state))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
+
+// judge is the state of *t* by the rules, and why, if it is not UP
+func (p *monitorPrinter) judge(t *monitorTarget) (string, string) {
+	if t.misses >= p.rules.DownAfter {
+		return StateDown, fmt.Sprintf("no reply to the last %d probes (%s)", t.misses, t.reason)
+	}
+
+	received, mean := 0, 0.0
+	for _, o := range t.recent {
+		if o.answered {
+			received++
+			mean += (o.rtt - mean) / float64(received)
+		}
+	}
+	loss := float64(len(t.recent)-received) / float64(len(t.recent)) * 100
+	if len(t.recent) >= monitorMinProbes && loss > p.rules.MaxLoss {
+		return StateDegraded, fmt.Sprintf("%.1f%% loss over the last %v, above %g%%", loss, p.rules.Window, p.rules.MaxLoss)
+	}
+	if p.rules.MaxRTT > 0 && received > 0 && msDuration(mean) > p.rules.MaxRTT {
+		return StateDegraded, fmt.Sprintf("average RTT %v over the last %v, above %v", msDuration(mean).Round(time.Microsecond), p.rules.Window, p.rules.MaxRTT)
+	}
+	return StateUp, ""
+}
@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// MultiHandler pings all *targets* at once, fping(8) style: each gets info.CNT
+// Echo Requests, one per second, and its own statistics, printed as a table at
+// the end. Probes share one socket per address family, whatever the number of
+// targets. Everything but IP in *info4* / *info6* applies to every target of
+// that family.
+// With a *pace* budget, targets are not probed once per second each, but as
+// often as the budget allows, the most variable ones the most often.
+// It returns the exit status: replied if any target did.
+func MultiHandler(targets []UnMarshalledAddr, info4 ICMPInfo, info6 ICMPInfo, pace AutoInterval) int {
+	info := info4
+	printer, err := newPrinter(info.Format, info.RTT, stdout, true)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	// Graceful termination, the usual ending, with Ctrl + C: probes in flight
+	// are cut short, and the statistics printed as at the end
+	stop := onInterrupt(nil)
+
+	// abstracted "socket" information: one shared socket per family in use
+	var demuxes [2]*demux
+	family := func(isIPv6 bool) int {
+		if isIPv6 {
+			return 1
+		}
+		return 0
+	}
+
+	ips := make([]string, len(targets))
+	stats := make([]*PingStats, len(targets))
+	for i, target := range targets {
+		targetStats := newPingStats(info)
+		ips[i], stats[i] = target.Addr, &targetStats
+
+		f := family(target.IsIPv6)
+		if demuxes[f] != nil {
+			continue
+		}
+
+		familyInfo := info4
+		if target.IsIPv6 {
+			familyInfo = info6
+		}
+		familyInfo.IP = target.Addr
+		demuxes[f], err = newDemux(familyInfo, target.IsIPv6)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		defer demuxes[f].close()
+		demuxes[f].done = stop.done
+	}
+
+	for _, target := range targets {
+		targetInfo := demuxes[family(target.IsIPv6)].s.info
+		targetInfo.IP, targetInfo.CNAMEs, targetInfo.HostsFile = target.Addr, target.CNAMEs, target.HostsFile
+		targetInfo.NAT64 = target.NAT64
+		printer.Header(targetInfo)
+
+		if isSelf, _ := IsLocalAddr(target.Addr); isSelf {
+			printer.Annotate(selfTargetAnnotation(target.Addr))
+		}
+	}
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() {
+		for i, ip := range ips {
+			printInterim(printer, ip, stats[i])
+		}
+		annotateFootprint(info, printer)
+	})
+	defer summarizeEvery(info.SummaryEvery, printer, ips, stats...)()
+
+	// Annotate the results if the local address changes mid-run (DHCP renew...)
+	stopWatching := watchLocalAddrs(info.Iface, printer)
+	defer stopWatching()
+
+	var p *pacer
+	if pace != (AutoInterval{}) {
+		p = newPacer(pace, len(targets))
+		defer p.close()
+	}
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		d := demuxes[family(target.IsIPv6)]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for seq := range info.CNT {
+				if stop.stopped() {
+					return
+				}
+
+				stats[i].sent()
+				res := d.probe(target.Addr, seq)
+				if stop.stopped() {
+					return
+				}
+				stats[i].record(res)
+				printer.Probe(res)
+
+				if note, ok := strictViolation(res); ok && info.Strict {
+					printer.Annotate(note)
+					printer.SummaryTable(ips, stats)
+					Exit(1)
+				}
+
+				if p == nil {
+					stop.sleep(time.Second)
+					continue
+				}
+				p.observe(i, res)
+				stop.sleep(p.interval(i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, target := range targets {
+		targetInfo := info4
+		targetInfo.IP, targetInfo.CNAMEs = target.Addr, target.CNAMEs
+		targetInfo.SelfTarget, _ = IsLocalAddr(target.Addr)
+		annotateNeighbor(targetInfo, printer)
+	}
+
+	printer.SummaryTable(ips, stats)
+	annotateFootprint(info, printer)
+
+	return exitStatus(stats...)
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+)
+
+// NAT64Discover asks --nat64 to discover the NAT64 prefix, RFC 7050, rather
+// than take one
+const NAT64Discover = "auto"
+
+// nat64Positions are the bytes of an IPv6 address an IPv4 address goes in,
+// by length of the NAT64 prefix, RFC 6052: bits 64 to 71 are always left zero
+var nat64Positions = map[int][4]int{
+	32: {4, 5, 6, 7},
+	40: {5, 6, 7, 9},
+	48: {6, 7, 9, 10},
+	56: {7, 9, 10, 11},
+	64: {9, 10, 11, 12},
+	96: {12, 13, 14, 15},
+}
+
+// ipv4onlyArpa is the name DNS64 resolvers answer with the NAT64 prefix,
+// RFC 7050, and ipv4onlyArpaAddrs the IPv4 addresses it has
+const ipv4onlyArpa = "ipv4only.arpa"
+
+var ipv4onlyArpaAddrs = [][4]byte{{192, 0, 0, 170}, {192, 0, 0, 171}}
+
+// NAT64Mapping is how the IPv6 address probed was synthesized from the IPv4
+// address given
+type NAT64Mapping struct {
+	From       string       `json:"from"`       // the IPv4 address given
+	Prefix     netip.Prefix `json:"prefix"`     // NAT64 prefix it was embedded in
+	Discovered bool         `json:"discovered"` // Prefix was discovered with ipv4only.arpa, rather than given
+}
+
+// SynthesizeNAT64 replaces the IPv4 *addr* by the IPv6 address of it behind
+// NAT64, when this host cannot reach IPv4 addresses (it is IPv6-only), or *v6*
+// asks for IPv6. The prefix is *spec* ("<prefix>/<length>"), or, if it is
+// NAT64Discover, the one the DNS64 resolver *server* ("" for the default one)
+// has. addr.NAT64 notes the translation.
+func SynthesizeNAT64(addr UnMarshalledAddr, spec string, v6 bool, server string) (UnMarshalledAddr, error) {
+	if addr.IsIPv6 || (!v6 && ipv4Routable(addr.Addr)) {
+		return addr, nil
+	}
+
+	mapping := &NAT64Mapping{From: addr.Addr, Discovered: spec == NAT64Discover}
+	var err error
+	if mapping.Discovered {
+		mapping.Prefix, err = discoverNAT64Prefix(server)
+	} else {
+		mapping.Prefix, err = ParseNAT64Prefix(spec)
+	}
+	if err != nil {
+		return addr, err
+	}
+
+	v4, err := netip.ParseAddr(addr.Addr)
+	if err != nil || !v4.Is4() {
+		return addr, fmt.Errorf("%v is not an IPv4 address", addr.Addr)
+	}
+	addr.set(nat64Embed(mapping.Prefix, v4.As4()).String(), true)
+	addr.NAT64 = mapping
+	return addr, nil
+}
+
+// ParseNAT64Prefix parses a NAT64 prefix, like 64:ff9b::/96: an IPv6 prefix
+// of one of the lengths of RFC 6052
+func ParseNAT64Prefix(spec string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(spec)
+	if err != nil || !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+		return netip.Prefix{}, fmt.Errorf("invalid NAT64 prefix %q: want an IPv6 prefix such as 64:ff9b::/96, or %s", spec, NAT64Discover)
+	}
+	if _, ok := nat64Positions[prefix.Bits()]; !ok {
+		return netip.Prefix{}, fmt.Errorf("invalid NAT64 prefix %q: its length must be 32, 40, 48, 56, 64 or 96", spec)
+	}
+	return prefix.Masked(), nil
+}
+
+// discoverNAT64Prefix finds the NAT64 prefix from the AAAA records of
+// ipv4only.arpa, which a DNS64 resolver synthesizes, RFC 7050: the prefix is
+// whatever the well-known IPv4 addresses of the name are embedded behind
+func discoverNAT64Prefix(server string) (netip.Prefix, error) {
+	addrs, err := queryAddrs(ipv4onlyArpa, true, server)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("error discovering the NAT64 prefix, is the resolver DNS64? %v", err)
+	}
+
+	for _, a := range addrs {
+		ip, err := netip.ParseAddr(a)
+		if err != nil {
+			continue
+		}
+		for _, bits := range []int{96, 64, 56, 48, 40, 32} {
+			embedded := nat64Extract(ip, bits)
+			for _, known := range ipv4onlyArpaAddrs {
+				if embedded == known {
+					return netip.PrefixFrom(ip, bits).Masked(), nil
+				}
+			}
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("error discovering the NAT64 prefix: no address of %s embeds 192.0.0.170 or 192.0.0.171", ipv4onlyArpa)
+}
+
+// nat64Embed is the IPv6 address of *v4* behind *prefix*
+func nat64Embed(prefix netip.Prefix, v4 [4]byte) netip.Addr {
+	addr := prefix.Masked().Addr().As16()
+	for i, pos := range nat64Positions[prefix.Bits()] {
+		addr[pos] = v4[i]
+	}
+	return netip.AddrFrom16(addr)
+}
+
+// nat64Extract is the IPv4 address *addr* embeds behind a prefix of *bits*
+func nat64Extract(addr netip.Addr, bits int) [4]byte {
+	var v4 [4]byte
+	a := addr.As16()
+	for i, pos := range nat64Positions[bits] {
+		v4[i] = a[pos]
+	}
+	return v4
+}
+
+// ipv4Routable reports whether the host has a route to the IPv4 *addr*:
+// connecting a UDP socket sends nothing, but fails without one
+func ipv4Routable(addr string) bool {
+	conn, err := net.Dial("udp4", net.JoinHostPort(addr, "9"))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// printNAT64 says, in the header of a run, that the address probed is the
+// IPv6 one of the IPv4 address given behind NAT64, if it is
+func printNAT64(w io.Writer, info ICMPInfo) {
+	if info.NAT64 == nil {
+		return
+	}
+	how := "given"
+	if info.NAT64.Discovered {
+		how = "discovered from " + ipv4onlyArpa
+	}
+	fmt.Fprintf(w, "NAT64: %s translated to %s, with prefix %s (%s)\n", info.NAT64.From, info.IP, info.NAT64.Prefix, how)
+}
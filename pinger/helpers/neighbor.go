@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Neighbor is the link layer address behind an on-link target
+type Neighbor struct {
+	MAC   net.HardwareAddr
+	Iface string // interface the neighbor was seen on
+}
+
+// neighborEntry is one resolved entry of the kernel's neighbor table
+type neighborEntry struct {
+	IP net.IP
+	Neighbor
+}
+
+// lookupNeighbor finds *ip* in the kernel's neighbor table. It only has an
+// entry once we talked to an on-link address, so look it up after probing.
+func lookupNeighbor(ip net.IP) (Neighbor, bool) {
+	for _, entry := range neighborTable(ip.To4() == nil) {
+		if entry.IP.Equal(ip) {
+			return entry.Neighbor, true
+		}
+	}
+	return Neighbor{}, false
+}
+
+// ouiDatabases are where OUI to vendor lists are commonly installed, in the
+// IEEE oui.txt, Wireshark manuf, or nmap-mac-prefixes formats
+var ouiDatabases = []string{
+	"/usr/share/hwdata/oui.txt",
+	"/usr/share/ieee-data/oui.txt",
+	"/usr/share/misc/oui.txt",
+	"/usr/share/wireshark/manuf",
+	"/usr/share/nmap/nmap-mac-prefixes",
+	"/usr/local/share/nmap/nmap-mac-prefixes",
+}
+
+// ouiVendor names the organization that the first 3 bytes of *mac* are assigned to.
+// Locally administered addresses (e.g. randomized by phones) have no vendor.
+func ouiVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	if mac[0]&0x02 != 0 {
+		return "locally administered"
+	}
+
+	oui := fmt.Sprintf("%02X%02X%02X", mac[0], mac[1], mac[2])
+	for _, path := range ouiDatabases {
+		if vendor, ok := searchOUIDatabase(path, oui); ok {
+			return vendor
+		}
+	}
+
+	return ""
+}
+
+// searchOUIDatabase looks for *oui* (6 upper case hex digits) in one database file
+func searchOUIDatabase(path string, oui string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		// "00-00-0C   (hex)\t\tCisco Systems, Inc", "00:00:0C\tCisco\tCisco Systems, Inc"
+		// or "00000C Cisco Systems": the vendor is the last tab separated field, if any
+		var prefix, vendor string
+		if fields := strings.Split(line, "\t"); len(fields) > 1 {
+			prefix, _, _ = strings.Cut(fields[0], " ")
+			vendor = fields[len(fields)-1]
+		} else {
+			prefix, vendor, _ = strings.Cut(line, " ")
+		}
+
+		prefix = strings.ToUpper(strings.NewReplacer("-", "", ":", "").Replace(prefix))
+		vendor = strings.TrimSpace(vendor)
+		if prefix == oui && vendor != "" {
+			return vendor, true
+		}
+	}
+
+	return "", false
+}
+
+// neighborAnnotation notes the MAC address and vendor of an on-link target,
+// found in the neighbor table after probing it
+func neighborAnnotation(ip string) (Annotation, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return Annotation{}, false
+	}
+
+	neighbor, ok := lookupNeighbor(addr)
+	if !ok {
+		return Annotation{}, false
+	}
+
+	mac := neighbor.MAC.String()
+	note := Annotation{
+		Time:    clk.Now(),
+		Kind:    "neighbor",
+		Message: fmt.Sprintf("%s is at %s", ip, mac),
+		Fields:  map[string]string{"addr": ip, "mac": mac},
+	}
+	if neighbor.Iface != "" {
+		note.Message += " on " + neighbor.Iface
+		note.Fields["iface"] = neighbor.Iface
+	}
+	if vendor := ouiVendor(neighbor.MAC); vendor != "" {
+		note.Message += " (" + vendor + ")"
+		note.Fields["vendor"] = vendor
+	}
+
+	return note, true
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// neighborTable dumps the resolved entries of the kernel's neighbor table (ARP
+// for IPv4, NDP for IPv6) over rtnetlink
+func neighborTable(isIPv6 bool) []neighborEntry {
+	family := unix.AF_INET
+	if isIPv6 {
+		family = unix.AF_INET6
+	}
+
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, family)
+	if err != nil {
+		return nil
+	}
+	messages, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil
+	}
+
+	var entries []neighborEntry
+	for _, m := range messages {
+		if m.Header.Type != unix.RTM_NEWNEIGH || len(m.Data) < unix.SizeofNdMsg {
+			continue
+		}
+		ndm := m.Data[:unix.SizeofNdMsg]
+		if ndm[0] != byte(family) {
+			continue
+		}
+		state := binary.NativeEndian.Uint16(ndm[8:10])
+		if state&(unix.NUD_INCOMPLETE|unix.NUD_FAILED|unix.NUD_NOARP) != 0 {
+			continue
+		}
+
+		dst, lladdr := neighborAttrs(m.Data[unix.SizeofNdMsg:])
+		if dst == nil || len(lladdr) == 0 {
+			continue
+		}
+
+		entry := neighborEntry{IP: dst, Neighbor: Neighbor{MAC: lladdr}}
+		if iface, err := net.InterfaceByIndex(int(binary.NativeEndian.Uint32(ndm[4:8]))); err == nil {
+			entry.Iface = iface.Name
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// neighborAttrs picks NDA_DST and NDA_LLADDR out of the attributes of an
+// RTM_NEWNEIGH message: syscall.ParseNetlinkRouteAttr does not handle those
+func neighborAttrs(b []byte) (net.IP, net.HardwareAddr) {
+	var (
+		dst    net.IP
+		lladdr net.HardwareAddr
+	)
+
+	for len(b) >= unix.SizeofRtAttr {
+		length := int(binary.NativeEndian.Uint16(b[0:2]))
+		kind := binary.NativeEndian.Uint16(b[2:4])
+		if length < unix.SizeofRtAttr || length > len(b) {
+			break
+		}
+
+		value := b[unix.SizeofRtAttr:length]
+		switch kind {
+		case unix.NDA_DST:
+			dst = net.IP(value)
+		case unix.NDA_LLADDR:
+			lladdr = net.HardwareAddr(value)
+		}
+
+		// attributes are aligned to 4 bytes
+		aligned := (length + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+
+	return dst, lladdr
+}
@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NotifyDesktop is the --notify kind raising desktop notifications
+const NotifyDesktop = "desktop"
+
+// downAfter is how many probes in a row must go unanswered for a target to
+// be down
+const downAfter = 3
+
+// notifier, when set with SetNotify, is told when targets go down or come
+// back up, by every Printer of NewPrinter
+var notifier func(title, body string) error
+
+// SetNotify raises notifications of *kind* when targets go down or come back
+// up. The only kind is NotifyDesktop: through D-Bus on Linux and the BSDs,
+// osascript on macOS.
+func SetNotify(kind string) error {
+	if kind != NotifyDesktop {
+		return fmt.Errorf("invalid --notify %q: want %s", kind, NotifyDesktop)
+	}
+	if err := desktopNotifierAvailable(); err != nil {
+		return fmt.Errorf("--notify %s: %v", kind, err)
+	}
+	notifier = desktopNotify
+	return nil
+}
+
+// reachability is whether a target is down, going by its last probes
+type reachability struct {
+	misses int       // unanswered probes in a row
+	down   bool      // since downAfter misses, until a reply
+	since  time.Time // when the first of the misses was observed
+}
+
+// notifyPrinter follows the reachability of every target a Printer prints
+// the probes of, and notes, and notifies, when one goes down or comes back up
+type notifyPrinter struct {
+	Printer
+	notify  func(title, body string) error
+	targets map[string]*reachability
+	failed  sync.Once // the first notification that could not be raised is reported, not every one
+}
+
+func (p *notifyPrinter) Probe(res ProbeResult) {
+	p.Printer.Probe(res)
+	if res.Duplicate {
+		return
+	}
+
+	r := p.targets[res.Target]
+	if r == nil {
+		r = &reachability{}
+		p.targets[res.Target] = r
+	}
+
+	switch res.Reason {
+	case ReasonNone, ReasonPortUnreachable:
+		if r.down {
+			p.transition(res.Target, "up", fmt.Sprintf("%s is UP", res.Target),
+				fmt.Sprintf("replying again, after being down for %v", time.Since(r.since).Round(time.Second)))
+		}
+		r.misses, r.down = 0, false
+	case ReasonNeighborDiscovery, ReasonMismatchedReply:
+	default:
+		if r.misses == 0 {
+			r.since = time.Now()
+		}
+		r.misses++
+		if r.misses == downAfter {
+			r.down = true
+			p.transition(res.Target, "down", fmt.Sprintf("%s is DOWN", res.Target),
+				fmt.Sprintf("no reply to the last %d probes: %s", downAfter, res.Reason))
+		}
+	}
+}
+
+// transition annotates the output with target *state*, and raises a
+// notification of it
+func (p *notifyPrinter) transition(target, state, title, body string) {
+	p.Printer.Annotate(Annotation{
+		Time:    clk.Now(),
+		Kind:    "target_" + state,
+		Message: title + ": " + body,
+		Fields:  map[string]string{"target": target, "state": state},
+	})
+
+	if err := p.notify("pinger: "+title, body); err != nil {
+		p.failed.Do(func() {
+			p.Printer.Annotate(Annotation{
+				Time:    clk.Now(),
+				Kind:    "notify_error",
+				Message: fmt.Sprintf("error raising a desktop notification: %v", err),
+			})
+		})
+	}
+}
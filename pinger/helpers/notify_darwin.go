@@ -0,0 +1,25 @@
+package helpers
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// desktopNotifierAvailable checks that notifications can be raised, with
+// osascript, which macOS always has
+func desktopNotifierAvailable() error {
+	_, err := exec.LookPath("osascript")
+	return err
+}
+
+// desktopNotify raises a notification from an AppleScript
+func desktopNotify(title, body string) error {
+	script := "display notification " + strconv.Quote(body) + " with title " + strconv.Quote(title)
+	out, err := exec.Command("osascript", "-e", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
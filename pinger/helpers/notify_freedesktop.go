@@ -0,0 +1,36 @@
+//go:build !darwin && !windows
+
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// desktopNotifierAvailable checks that notifications can be sent to the
+// desktop session, over D-Bus
+func desktopNotifierAvailable() error {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" && os.Getenv("XDG_RUNTIME_DIR") == "" {
+		return fmt.Errorf("no D-Bus session bus to send notifications on: is there a desktop session?")
+	}
+	if _, err := exec.LookPath("gdbus"); err != nil {
+		return fmt.Errorf("gdbus, which sends notifications over D-Bus, is not installed")
+	}
+	return nil
+}
+
+// desktopNotify raises a notification through the freedesktop.org
+// notification service of the session bus
+func desktopNotify(title, body string) error {
+	out, err := exec.Command("gdbus", "call", "--session", "--timeout", "2",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"--", "pinger", "0", "network-error", title, body, "[]", "{}", "-1").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package helpers
+
+import "fmt"
+
+// desktopNotifierAvailable reports that desktop notifications are not
+// supported on Windows
+func desktopNotifierAvailable() error {
+	return fmt.Errorf("desktop notifications are not supported on Windows")
+}
+
+func desktopNotify(title, body string) error {
+	return desktopNotifierAvailable()
+}
@@ -0,0 +1,762 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ProbeResult is the outcome of a single Echo Request, as handed to a Printer.
+// Reason is ReasonNone for a matching Echo Reply.
+type ProbeResult struct {
+	Transport string // "tcp", "udp", "http", "arp", "ndp" or "dns" for those probes, empty for ICMP Echo
+
+	Target string    // destination address being pinged
+	Seq    int       // icmp_seq of the request
+	Peer   string    // address the reply (or error) came from, if any
+	Bytes  int       // size of the ICMP reply
+	TTL    int       // TTL / Hop Limit of the reply
+	RTT    float64   // round trip time in milliseconds
+	Clock  string    // what timed RTT, with ICMPInfo.HWTimestamps: ClockHardware, ClockKernel or ClockUserspace
+	Reason Reason    // why this is not a reply, if it is not
+	Detail string    // human readable detail for non-replies
+	Time   time.Time // when the outcome was observed
+
+	Duplicate bool // another reply to an answered probe, e.g. from a second host on a broadcast
+
+	TOS        int  // TOS / Traffic Class of the reply, if TOSKnown
+	TOSKnown   bool // only read with ICMPInfo.ECN
+	ECNChanged bool // the ECN bits of the reply differ from those of the probe
+
+	Route          []string      // addresses recorded by the Record Route option of the reply, with ICMPInfo.RecordRoute
+	Timestamps     []IPTimestamp // entries of the Timestamp option of the reply, with ICMPInfo.Timestamp
+	TimestampsLost int           // hosts that found no room left to stamp
+
+	Extensions []ICMPExtension // objects an ICMP error carries after the packet it quotes (RFC 4884)
+
+	HWAddr  string   // link layer address of the peer, for ARP / NDP probes
+	Answers []string // addresses (and CNAMEs) answered, for DNS probes
+	Status  int      // HTTP status code, for HTTP probes
+	Phases  []Phase  // how long the steps of the probe took, e.g. DNS, connect and TLS for HTTP
+
+	Fields map[string]string // anything else a plugin probe reports
+}
+
+// Phase is one timed step of a probe
+type Phase struct {
+	Name string
+	RTT  float64 // in milliseconds
+}
+
+// sent is when the probe went out, going by its RTT
+func (res ProbeResult) sent() time.Time {
+	return res.Time.Add(-time.Duration(res.RTT * float64(time.Millisecond)))
+}
+
+// answered reports whether the probe got an ICMP answer to time: an Echo Reply,
+// or an error from a router on the way
+func (res ProbeResult) answered() bool {
+	switch res.Reason {
+	case ReasonNone, ReasonTTLExceeded, ReasonUnreachableHost, ReasonUnreachableNet, ReasonUnreachable, ReasonAdminProhibited, ReasonRefused, ReasonPortUnreachable, ReasonDNSError:
+		return true
+	}
+	return false
+}
+
+// Annotation is something worth noting in the result stream, which is not
+// the outcome of a probe, e.g. the local address changing mid-run.
+type Annotation struct {
+	Time    time.Time
+	Kind    string            // machine-readable, snake_case
+	Message string            // human readable
+	Fields  map[string]string // details, e.g. "iface" and "addr"
+}
+
+// Printer renders what pinger observes. Implementations exist for
+// people (text, ping(8) style) and for machines (JSON lines).
+// Printers returned by NewPrinter are safe for concurrent use.
+type Printer interface {
+	Header(info ICMPInfo)
+	Probe(res ProbeResult)
+	Annotate(note Annotation)
+	Interim(target string, stats *PingStats)
+	Periodic(target string, window *PingStats, every time.Duration)
+	Summary(target string, stats *PingStats)
+	SummaryTable(targets []string, stats []*PingStats)
+}
+
+// ValidFormat returns an error if *format* is not one NewPrinter knows.
+func ValidFormat(format string) error {
+	_, err := formatPrinter(format, DefaultRTTFormat, io.Discard, false)
+	return err
+}
+
+// NewPrinter returns the Printer for the given *format*, writing RTTs as *rtt* says to w.
+func NewPrinter(format string, rtt RTTFormat, w io.Writer) (Printer, error) {
+	return newPrinter(format, rtt, w, false)
+}
+
+// newPrinter is NewPrinter, optionally prefixing text lines about a probe with its target,
+// for when the lines of several targets are interleaved.
+// What is printed is masked after SetRedaction. The sinks opened with OpenSink get a copy of everything printed, and
+// targets going down or up are notified of, after SetNotify, the state of
+// targets judged after SetMonitor, and the
+// status line of SetStatusLine kept up to date, or the view of SetTUI drawn,
+// and the web dashboard of ServeDashboard sent the probes.
+func newPrinter(format string, rtt RTTFormat, w io.Writer, tagged bool) (Printer, error) {
+	printer, err := formatPrinter(format, rtt, w, tagged)
+	if err != nil {
+		return nil, err
+	}
+
+	if tuiScreen != nil && !MachineFormat(format) {
+		printer = &tuiPrinter{Printer: printer, w: tuiScreen, rtt: rtt}
+	}
+	if redaction != nil {
+		printer = &redactPrinter{Printer: printer}
+	}
+
+	if len(sinks) > 0 {
+		printer = &sinkPrinter{Printer: printer, sinks: sinks}
+	}
+	if notifier != nil {
+		printer = &notifyPrinter{Printer: printer, notify: notifier, targets: make(map[string]*reachability)}
+	}
+	if monitor != nil {
+		printer = &monitorPrinter{Printer: printer, rules: *monitor, targets: make(map[string]*monitorTarget)}
+	}
+	if statusLine != nil {
+		printer = &statusPrinter{Printer: printer, w: statusLine, rtt: rtt, targets: make(map[string]bool)}
+	}
+	if dashboard != nil {
+		printer = &webPrinter{Printer: printer, hub: dashboard, stats: make(map[string]*PingStats)}
+	}
+	return &syncPrinter{printer: printer}, nil
+}
+
+// formatPrinter is the Printer of *format* alone, without the ones newPrinter wraps it in
+func formatPrinter(format string, rtt RTTFormat, w io.Writer, tagged bool) (Printer, error) {
+	switch format {
+	case "", "text":
+		return &textPrinter{w: w, rtt: rtt, tagged: tagged, quiet: monitor != nil}, nil
+	case "json":
+		return &jsonPrinter{enc: newRecordEncoder(w), rtt: rtt}, nil
+	case AtlasJSON:
+		return &atlasPrinter{enc: newRecordEncoder(w), rtt: rtt, results: make(map[string]*atlasResult)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want text, json or %s)", format, AtlasJSON)
+}
+
+// syncPrinter serializes calls to a Printer, so that background watchers
+// can annotate the stream without tearing lines apart
+type syncPrinter struct {
+	mu      sync.Mutex
+	printer Printer
+}
+
+func (p *syncPrinter) Header(info ICMPInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Header(info)
+}
+
+func (p *syncPrinter) Probe(res ProbeResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Probe(res)
+}
+
+func (p *syncPrinter) Annotate(note Annotation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Annotate(note)
+}
+
+func (p *syncPrinter) Interim(target string, stats *PingStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Interim(target, stats)
+}
+
+func (p *syncPrinter) Periodic(target string, window *PingStats, every time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Periodic(target, window, every)
+}
+
+func (p *syncPrinter) Summary(target string, stats *PingStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.Summary(target, stats)
+}
+
+func (p *syncPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.printer.SummaryTable(targets, stats)
+}
+
+// textPrinter prints ping(8) style lines
+type textPrinter struct {
+	w      io.Writer
+	rtt    RTTFormat
+	tagged bool // prefix probe lines with "[target] "
+
+	routes map[string]string // last recorded route printed, by target
+	names  *nameCache        // names of ICMP peers, nil with ICMPInfo.Numeric
+
+	histogram bool          // ICMPInfo.Histogram
+	intervals time.Duration // ICMPInfo.Intervals
+	verbose   bool          // ICMPInfo.Verbose: print the extensions of ICMP errors
+	quiet     bool          // print the changes of state of SetMonitor, not the probes
+}
+
+func (p *textPrinter) Header(info ICMPInfo) {
+	p.histogram, p.intervals, p.verbose = info.Histogram, info.Intervals, info.Verbose
+
+	if info.Transport != "" {
+		fmt.Fprintf(p.w, "PINGERING %s", info.IP)
+		if info.Port != 0 {
+			fmt.Fprintf(p.w, " port %d", info.Port)
+		}
+		fmt.Fprintf(p.w, " over %s", strings.ToUpper(info.Transport))
+	} else {
+		fmt.Fprintf(p.w, "PINGERING %s: %d data bytes", info.IP, startSize)
+	}
+	if info.Source != "" {
+		fmt.Fprintf(p.w, " from %s", info.Source)
+	}
+	if info.Iface != "" {
+		fmt.Fprintf(p.w, " (via %s)", info.Iface)
+	}
+	if info.KernelTTL {
+		fmt.Fprintf(p.w, ", ttl %d (kernel default)", info.TTL)
+	}
+	if info.TOS != 0 {
+		fmt.Fprintf(p.w, ", tos 0x%02x", info.TOS)
+	}
+	if !info.Privileged && info.Transport == "" {
+		fmt.Fprint(p.w, ", unprivileged")
+	}
+	fmt.Fprintln(p.w)
+
+	// The target's name is looked up now, the names of other peers as they show up
+	if !info.Numeric && info.Transport == "" {
+		if p.names == nil {
+			p.names = newNameCache(info.DNSServer)
+		}
+		p.names.resolve(info.IP)
+	}
+
+	switch {
+	case len(info.CNAMEs) > 1:
+		fmt.Fprintf(p.w, "CNAME chain: %s\n", strings.Join(info.CNAMEs, " -> "))
+	case len(info.CNAMEs) == 1:
+		fmt.Fprintf(p.w, "CNAME chain: none, %s is the canonical name\n", info.CNAMEs[0])
+	}
+	if info.HostsFile != "" {
+		fmt.Fprintf(p.w, "Address from hosts file %s, not DNS\n", info.HostsFile)
+	}
+	printNAT64(p.w, info)
+}
+
+func (p *textPrinter) Probe(res ProbeResult) {
+	if p.quiet {
+		return
+	}
+	if p.tagged {
+		fmt.Fprintf(p.w, "[%s] ", res.Target)
+	}
+
+	// ping(8) speaks of icmp_seq, tcping-like tools of seq
+	seq := "icmp_seq"
+	if res.Transport != "" {
+		seq = "seq"
+	}
+
+	switch res.Reason {
+	case ReasonNone:
+		if res.Transport == "tcp" {
+			fmt.Fprintf(p.w, "connected to %s: seq=%d time=%s\n", res.Peer, res.Seq, p.rtt.format(res.RTT))
+			break
+		}
+		if res.Transport == "http" {
+			fmt.Fprintf(p.w, "%d from %s: seq=%d", res.Status, res.Peer, res.Seq)
+			for _, phase := range res.Phases {
+				fmt.Fprintf(p.w, " %s=%s", phase.Name, p.rtt.format(phase.RTT))
+			}
+			fmt.Fprintln(p.w)
+			break
+		}
+		if res.Transport == "dns" {
+			fmt.Fprintf(p.w, "%d bytes from %s: seq=%d time=%s %s", res.Bytes, res.Peer, res.Seq, p.rtt.format(res.RTT), res.Detail)
+			if len(res.Answers) > 0 {
+				fmt.Fprintf(p.w, " %s", strings.Join(res.Answers, " "))
+			}
+			fmt.Fprintln(p.w)
+			break
+		}
+		if res.Transport == "arp" || res.Transport == "ndp" {
+			fmt.Fprintf(p.w, "reply from %s [%s]: seq=%d time=%s\n", res.Peer, res.HWAddr, res.Seq, p.rtt.format(res.RTT))
+			break
+		}
+		if res.Transport == "udp" {
+			fmt.Fprintf(p.w, "%d bytes from %s: seq=%d time=%s\n", res.Bytes, res.Peer, res.Seq, p.rtt.format(res.RTT))
+			break
+		}
+		if res.Transport != "" {
+			// a plugin probe, see PluginProbeHandler
+			fmt.Fprintf(p.w, "reply from %s: seq=%d time=%s", res.Peer, res.Seq, p.rtt.format(res.RTT))
+			for _, name := range slices.Sorted(maps.Keys(res.Fields)) {
+				fmt.Fprintf(p.w, " %s=%s", name, res.Fields[name])
+			}
+			fmt.Fprintln(p.w)
+			break
+		}
+		fmt.Fprintf(p.w, "%d bytes from %s: icmp_seq=%d ttl=%d time=%s",
+			res.Bytes, p.names.label(res.Peer), res.Seq, res.TTL, p.rtt.format(res.RTT))
+		if res.Clock != "" {
+			fmt.Fprintf(p.w, " clock=%s", res.Clock)
+		}
+		if res.TOSKnown {
+			fmt.Fprintf(p.w, " tos=0x%02x ecn=%s", res.TOS, ecnName(res.TOS))
+		}
+		if res.ECNChanged {
+			fmt.Fprint(p.w, " (ECN changed)")
+		}
+		if res.Duplicate {
+			fmt.Fprint(p.w, " (DUP!)")
+		}
+		fmt.Fprintln(p.w)
+		p.route(res)
+		p.timestamps(res)
+	case ReasonPortUnreachable:
+		fmt.Fprintf(p.w, "From %s seq=%d: Port Unreachable time=%s\n", res.Peer, res.Seq, p.rtt.format(res.RTT))
+	case ReasonRefused:
+		fmt.Fprintf(p.w, "%s refused the connection: seq=%d time=%s\n", res.Peer, res.Seq, p.rtt.format(res.RTT))
+	case ReasonTimeout:
+		fmt.Fprintf(p.w, "Request timeout for %s %d\n", seq, res.Seq)
+	case ReasonSendError:
+		if res.Transport != "" {
+			fmt.Fprintf(p.w, "Error connecting to %s: %s\n", res.Peer, res.Detail)
+			break
+		}
+		fmt.Fprintf(p.w, "Error sending ICMP packet: %s\n", res.Detail)
+	case ReasonRecvError:
+		fmt.Fprintf(p.w, "Error reading ICMP response: %s\n", res.Detail)
+	case ReasonParseError:
+		fmt.Fprintf(p.w, "Error parsing ICMP response: %s\n", res.Detail)
+	default:
+		peer := res.Peer
+		if res.Transport == "" {
+			peer = p.names.label(peer)
+		}
+		fmt.Fprintf(p.w, "From %s %s=%d: %s\n", peer, seq, res.Seq, res.Detail)
+		p.extensions(res)
+	}
+}
+
+// extensions prints the extension objects of an ICMP error, in verbose mode
+func (p *textPrinter) extensions(res ProbeResult) {
+	if !p.verbose {
+		return
+	}
+	for i, ext := range res.Extensions {
+		prefix := "\t"
+		if i == 0 {
+			prefix = "EXT: \t"
+		}
+		fmt.Fprintf(p.w, "%s%s\n", prefix, ext)
+	}
+}
+
+// route prints the addresses recorded in the reply like ping(8) does,
+// or "(same route)" when they are the same as last time
+func (p *textPrinter) route(res ProbeResult) {
+	if res.Route == nil {
+		return
+	}
+
+	route := strings.Join(res.Route, "\n\t")
+	if p.routes == nil {
+		p.routes = make(map[string]string)
+	}
+	if last, ok := p.routes[res.Target]; ok && last == route {
+		fmt.Fprintln(p.w, "\t(same route)")
+		return
+	}
+	p.routes[res.Target] = route
+
+	fmt.Fprintf(p.w, "RR: \t%s\n", route)
+}
+
+// timestamps prints the entries of the Timestamp option of the reply like
+// ping(8) does, the first as is and the others relative to the previous one,
+// with how far ahead of our clock at sending time each host stamped
+func (p *textPrinter) timestamps(res ProbeResult) {
+	if res.Timestamps == nil {
+		return
+	}
+
+	sent := res.sent()
+	for i, stamp := range res.Timestamps {
+		prefix := "\t"
+		if i == 0 {
+			prefix = "TS: \t"
+		}
+		if stamp.Addr != "" {
+			prefix += stamp.Addr + "\t"
+		}
+
+		switch {
+		case !stamp.Standard:
+			fmt.Fprintf(p.w, "%s%d (non-standard)\n", prefix, stamp.MS)
+			continue
+		case i == 0 || !res.Timestamps[i-1].Standard:
+			fmt.Fprintf(p.w, "%s%d absolute", prefix, stamp.MS)
+		default:
+			fmt.Fprintf(p.w, "%s%+d", prefix, int64(stamp.MS)-int64(res.Timestamps[i-1].MS))
+		}
+		fmt.Fprintf(p.w, "\t(%+d ms from us)\n", timestampOffset(stamp.MS, sent))
+	}
+	if res.TimestampsLost > 0 {
+		fmt.Fprintf(p.w, "Unrecorded hops: %d\n", res.TimestampsLost)
+	}
+}
+
+func (p *textPrinter) Annotate(note Annotation) {
+	fmt.Fprintf(p.w, "*** %s\n", note.Message)
+}
+
+// Interim is a one line summary of the statistics so far, as ping(8) prints
+// on SIGQUIT: the EWMA shows the recent RTTs, which the average hides in long runs
+func (p *textPrinter) Interim(target string, stats *PingStats) {
+	if p.tagged {
+		fmt.Fprintf(p.w, "[%s] ", target)
+	}
+	fmt.Fprintf(p.w, "%d/%d packets, %d errors, %.1f%% loss",
+		stats.received, stats.transmitted, stats.errors, stats.loss())
+	if loss, n := stats.recentLoss(); n == lossWindow && stats.transmitted > n {
+		fmt.Fprintf(p.w, " (%.1f%% of the last %d)", loss, n)
+	}
+
+	if stats.received > 0 {
+		stats.finalStats()
+		fmt.Fprintf(p.w, ", min/avg/ewma/max = %s",
+			p.rtt.formatMany(stats.min, stats.mean, stats.ewma, stats.max))
+	}
+	fmt.Fprintln(p.w)
+}
+
+// Summary is used to summarize all calculated RTT statistics
+func (p *textPrinter) Summary(target string, stats *PingStats) {
+	p.printIntervals([]string{target}, []*PingStats{stats})
+	fmt.Fprintf(p.w, "\n--- %s ping statistics ---\n", target)
+	fmt.Fprintf(p.w, "%d packets transmitted, %d received, ", stats.transmitted, stats.received)
+	if stats.duplicates > 0 {
+		fmt.Fprintf(p.w, "+%d duplicates, ", stats.duplicates)
+	}
+	fmt.Fprintf(p.w, "%d errors, %.1f%% packet loss\n", stats.errors, stats.loss())
+
+	if stats.received > 0 {
+		stats.finalStats()
+		fmt.Fprintf(p.w, "round-trip min/avg/max/stddev = %s\n",
+			p.rtt.formatMany(stats.min, stats.mean, stats.max, stats.stddev))
+	}
+	if stats.received > 0 {
+		fmt.Fprintf(p.w, "ewma = %s (moving average, weighting recent replies)\n", p.rtt.format(stats.ewma))
+	}
+	if stats.received > 1 {
+		fmt.Fprintf(p.w, "jitter = %s (RFC 3550)\n", p.rtt.format(stats.jitter))
+		fmt.Fprintf(p.w, "ipdv min/avg/max = %s (RFC 3393, between successive replies; avg of its size)\n",
+			p.rtt.formatMany(stats.ipdvMin, stats.ipdvMean(), stats.ipdvMax))
+	}
+	if stats.ecnChanged > 0 {
+		fmt.Fprintf(p.w, "%d replies with their ECN bits changed on the way\n", stats.ecnChanged)
+	}
+	if p.histogram {
+		p.printHistogram(target, stats)
+	}
+	fmt.Fprintf(p.w, "run %s\n", RunID)
+}
+
+// SummaryTable summarizes several targets, one row each
+func (p *textPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.printIntervals(targets, stats)
+	fmt.Fprintf(p.w, "\n--- ping statistics ---\n")
+
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "TARGET\tSENT\tRECV\tERRORS\tLOSS\tMIN\tAVG\tEWMA\tMAX\tSTDDEV\tJITTER\n")
+	for i, target := range targets {
+		st := stats[i]
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%.1f%%", target, st.transmitted, st.received, st.errors, st.loss())
+
+		if st.received > 0 {
+			st.finalStats()
+			fmt.Fprintf(table, "\t%s\t%s\t%s\t%s\t%s",
+				p.rtt.format(st.min), p.rtt.format(st.mean), p.rtt.format(st.ewma), p.rtt.format(st.max), p.rtt.format(st.stddev))
+		} else {
+			fmt.Fprintf(table, "\t-\t-\t-\t-\t-")
+		}
+		if st.received > 1 {
+			fmt.Fprintf(table, "\t%s\n", p.rtt.format(st.jitter))
+		} else {
+			fmt.Fprintf(table, "\t-\n")
+		}
+	}
+	table.Flush()
+	if p.histogram {
+		for i, target := range targets {
+			p.printHistogram(target, stats[i])
+		}
+	}
+	fmt.Fprintf(p.w, "run %s\n", RunID)
+}
+
+// jsonPrinter emits one JSON object per line, tagged by "type"
+type jsonPrinter struct {
+	enc *recordEncoder
+	rtt RTTFormat
+
+	histogram bool // ICMPInfo.Histogram
+}
+
+type jsonStart struct {
+	Type      string `json:"type"`
+	Target    string `json:"target"`
+	Transport string `json:"transport,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Iface     string `json:"iface,omitempty"`
+	Source    string `json:"source,omitempty"`
+	DataBytes int    `json:"data_bytes"`
+	TTL       int    `json:"ttl"`
+	KernelTTL bool   `json:"ttl_kernel_default"`
+	TOS       int    `json:"tos,omitempty"`
+	Raw       bool   `json:"privileged"`
+
+	CNAMEs    []string `json:"cname_chain,omitempty"`
+	Canonical string   `json:"canonical_name,omitempty"`
+	HostsFile string   `json:"hosts_file,omitempty"`
+
+	NAT64 *NAT64Mapping `json:"nat64,omitempty"`
+}
+
+type jsonProbe struct {
+	Type      string    `json:"type"`
+	Transport string    `json:"transport,omitempty"`
+	Time      time.Time `json:"time"`
+	Target    string    `json:"target"`
+	Seq       int       `json:"seq"`
+	Peer      string    `json:"peer,omitempty"`
+	Bytes     int       `json:"bytes,omitempty"`
+	TTL       int       `json:"ttl,omitempty"`
+	RTT       *float64  `json:"rtt_ms,omitempty"`
+	Reason    Reason    `json:"reason,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Duplicate bool      `json:"duplicate,omitempty"`
+	Clock     string    `json:"clock,omitempty"` // what timed the RTT, with hardware timestamps
+
+	TOS        *int   `json:"tos,omitempty"`
+	ECN        string `json:"ecn,omitempty"`
+	ECNChanged bool   `json:"ecn_changed,omitempty"`
+
+	Route          []string        `json:"route,omitempty"`
+	Timestamps     []jsonTimestamp `json:"timestamps,omitempty"`
+	TimestampsLost int             `json:"timestamps_unrecorded,omitempty"`
+	Extensions     []ICMPExtension `json:"extensions,omitempty"`
+
+	HWAddr  string             `json:"hwaddr,omitempty"`
+	Answers []string           `json:"answers,omitempty"`
+	Status  int                `json:"status,omitempty"`
+	Phases  map[string]float64 `json:"phases_ms,omitempty"`
+	Fields  map[string]string  `json:"fields,omitempty"`
+}
+
+// jsonTimestamp is an entry of the Timestamp option of a reply.
+// Offset is how far ahead of our clock at sending time the host stamped, for
+// standard stamps: the one-way delay to it plus the offset of its clock.
+type jsonTimestamp struct {
+	Addr     string `json:"addr,omitempty"`
+	MS       uint32 `json:"ms"`
+	Standard bool   `json:"standard"`
+	Offset   *int64 `json:"offset_ms,omitempty"`
+}
+
+type jsonAnnotation struct {
+	Type    string            `json:"type"`
+	Time    time.Time         `json:"time"`
+	Kind    string            `json:"kind"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type jsonSummary struct {
+	Type        string   `json:"type"`
+	Target      string   `json:"target"`
+	Transmitted int      `json:"transmitted"`
+	Received    int      `json:"received"`
+	Errors      int      `json:"errors"`
+	Duplicates  int      `json:"duplicates,omitempty"`
+	ECNChanged  int      `json:"ecn_changed,omitempty"`
+	Loss        float64  `json:"loss_pct"`
+	LossRecent  *float64 `json:"loss_recent_pct,omitempty"`
+	Recent      int      `json:"loss_recent_probes,omitempty"` // the last probes LossRecent is over
+	Min         *float64 `json:"rtt_min_ms,omitempty"`
+	Avg         *float64 `json:"rtt_avg_ms,omitempty"`
+	Max         *float64 `json:"rtt_max_ms,omitempty"`
+	StdDev      *float64 `json:"rtt_stddev_ms,omitempty"`
+	Jitter      *float64 `json:"jitter_ms,omitempty"`   // RFC 3550 interarrival jitter
+	IPDVMin     *float64 `json:"ipdv_min_ms,omitempty"` // RFC 3393 delay variation between successive replies
+	IPDVAvg     *float64 `json:"ipdv_avg_ms,omitempty"` // of its size
+	IPDVMax     *float64 `json:"ipdv_max_ms,omitempty"`
+	EWMA        *float64 `json:"rtt_ewma_ms,omitempty"` // exponentially weighted moving average
+	Window      float64  `json:"window_s,omitempty"`    // of window records, the time they cover
+
+	Histogram []histogramBucket `json:"histogram,omitempty"` // with ICMPInfo.Histogram
+}
+
+func (p *jsonPrinter) Header(info ICMPInfo) {
+	p.histogram = info.Histogram
+	p.enc.Encode(jsonStart{
+		Type:      "start",
+		Target:    info.IP,
+		Transport: info.Transport,
+		Port:      info.Port,
+		Iface:     info.Iface,
+		Source:    info.Source,
+		DataBytes: startSize,
+		TTL:       info.TTL,
+		KernelTTL: info.KernelTTL,
+		TOS:       info.TOS,
+		Raw:       info.Privileged,
+		CNAMEs:    info.CNAMEs,
+		Canonical: info.canonicalName(),
+		HostsFile: info.HostsFile,
+		NAT64:     info.NAT64,
+	})
+}
+
+func (p *jsonPrinter) Probe(res ProbeResult) {
+	p.enc.Encode(newJSONProbe(res, p.rtt))
+}
+
+// newJSONProbe converts a probe result into its JSON record, rounding as *rtt* says
+func newJSONProbe(res ProbeResult, rtt RTTFormat) jsonProbe {
+	out := jsonProbe{
+		Type:      "probe",
+		Transport: res.Transport,
+		Time:      res.Time,
+		Target:    res.Target,
+		Seq:       res.Seq,
+		Peer:      res.Peer,
+		Bytes:     res.Bytes,
+		TTL:       res.TTL,
+		Reason:    res.Reason,
+		Detail:    res.Detail,
+		Duplicate: res.Duplicate,
+		HWAddr:    res.HWAddr,
+		Answers:   res.Answers,
+		Route:     res.Route,
+		Fields:    res.Fields,
+		Clock:     res.Clock,
+
+		TimestampsLost: res.TimestampsLost,
+		Extensions:     res.Extensions,
+	}
+	if res.answered() {
+		ms := rtt.round(res.RTT)
+		out.RTT = &ms
+	}
+	if res.TOSKnown {
+		tos := res.TOS
+		out.TOS, out.ECN, out.ECNChanged = &tos, ecnName(tos), res.ECNChanged
+	}
+	for _, stamp := range res.Timestamps {
+		ts := jsonTimestamp{Addr: stamp.Addr, MS: stamp.MS, Standard: stamp.Standard}
+		if stamp.Standard {
+			offset := timestampOffset(stamp.MS, res.sent())
+			ts.Offset = &offset
+		}
+		out.Timestamps = append(out.Timestamps, ts)
+	}
+	if len(res.Phases) > 0 {
+		out.Status, out.Phases = res.Status, make(map[string]float64)
+		for _, phase := range res.Phases {
+			out.Phases[phase.Name] = rtt.round(phase.RTT)
+		}
+	}
+
+	return out
+}
+
+func (p *jsonPrinter) Annotate(note Annotation) {
+	p.enc.Encode(jsonAnnotation{
+		Type:    "annotation",
+		Time:    note.Time,
+		Kind:    note.Kind,
+		Message: note.Message,
+		Fields:  note.Fields,
+	})
+}
+
+func (p *jsonPrinter) Interim(target string, stats *PingStats) {
+	out := newJSONSummary(target, stats, p.rtt)
+	out.Type = "interim"
+	p.enc.Encode(out)
+}
+
+func (p *jsonPrinter) Summary(target string, stats *PingStats) {
+	out := newJSONSummary(target, stats, p.rtt)
+	if p.histogram {
+		out.Histogram = stats.histogram()
+		for i := range out.Histogram {
+			out.Histogram[i].From, out.Histogram[i].To = p.rtt.round(out.Histogram[i].From), p.rtt.round(out.Histogram[i].To)
+		}
+	}
+	p.enc.Encode(out)
+}
+
+// SummaryTable emits a summary record per target
+func (p *jsonPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	for i, target := range targets {
+		p.Summary(target, stats[i])
+	}
+}
+
+// newJSONSummary converts the statistics of *target* into its JSON record, rounding as *rtt* says
+func newJSONSummary(target string, stats *PingStats, rtt RTTFormat) jsonSummary {
+	out := jsonSummary{
+		Type:        "summary",
+		Target:      target,
+		Transmitted: stats.transmitted,
+		Received:    stats.received,
+		Errors:      stats.errors,
+		Duplicates:  stats.duplicates,
+		ECNChanged:  stats.ecnChanged,
+		Loss:        stats.loss(),
+	}
+	if loss, n := stats.recentLoss(); n > 0 {
+		out.LossRecent, out.Recent = &loss, n
+	}
+
+	if stats.received > 0 {
+		stats.finalStats()
+		min, avg, max, stddev := rtt.round(stats.min), rtt.round(stats.mean), rtt.round(stats.max), rtt.round(stats.stddev)
+		out.Min, out.Avg, out.Max, out.StdDev = &min, &avg, &max, &stddev
+		ewma := rtt.round(stats.ewma)
+		out.EWMA = &ewma
+	}
+	if stats.received > 1 {
+		jitter := rtt.round(stats.jitter)
+		out.Jitter = &jitter
+		ipdvMin, ipdvAvg, ipdvMax := rtt.round(stats.ipdvMin), rtt.round(stats.ipdvMean()), rtt.round(stats.ipdvMax)
+		out.IPDVMin, out.IPDVAvg, out.IPDVMax = &ipdvMin, &ipdvAvg, &ipdvMax
+	}
+
+	return out
+}
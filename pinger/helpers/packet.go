@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// packets are the buffers packets are read into, reused rather than allocated
+// for every read, which adds up over sweeps and fast runs. They are as large as
+// the largest packet read, see maxICMPPacket.
+var packets = sync.Pool{
+	New: func() any {
+		b := make([]byte, maxICMPPacket)
+		return &b
+	},
+}
+
+// getPacket is a buffer of packets
+func getPacket() []byte {
+	return *packets.Get().(*[]byte)
+}
+
+// putPacket hands *b*, a buffer of getPacket, or a part of one, back to
+// packets: nothing may keep a reference into it after that
+func putPacket(b []byte) {
+	b = b[:cap(b)]
+	packets.Put(&b)
+}
+
+// errShortMessage is the error of ICMP messages too short for their header
+var errShortMessage = errors.New("message too short")
+
+// icmpHeader is the type and code of the ICMP message *data*, read in place:
+// icmp.ParseMessage would copy its body out, though they are all that replies
+// are told apart by.
+func icmpHeader(proto int, data []byte) (icmp.Type, int, error) {
+	if len(data) < 4 {
+		return nil, 0, errShortMessage
+	}
+	if proto == protocolICMPv6 {
+		return ipv6.ICMPType(data[0]), int(data[1]), nil
+	}
+	return ipv4.ICMPType(data[0]), int(data[1]), nil
+}
+
+// echoHeader is the identifier and sequence number of the Echo message
+// *data*, read in place, as icmpHeader does
+func echoHeader(data []byte) (id, seq int, ok bool) {
+	if len(data) < 8 {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(data[4:6])), int(binary.BigEndian.Uint16(data[6:8])), true
+}
+
+// quotedEcho is the Echo Request that the ICMP error *data* is about: errors
+// quote the IP header of the packet they are about, then at least its first 8
+// bytes, after their own header. false if *data* quotes anything else, or too
+// little of it to tell.
+func quotedEcho(data []byte) (request ipICMP, id, seq int, ok bool) {
+	if len(data) < 8 {
+		return ipICMP{}, 0, 0, false
+	}
+	if request, ok = parseIPICMP(data[8:]); !ok {
+		return ipICMP{}, 0, 0, false
+	}
+	typ, _, err := icmpHeader(request.proto, request.data)
+	if err != nil || (typ != ipv4.ICMPTypeEcho && typ != ipv6.ICMPTypeEchoRequest) {
+		return ipICMP{}, 0, 0, false
+	}
+	id, seq, ok = echoHeader(request.data)
+	return request, id, seq, ok
+}
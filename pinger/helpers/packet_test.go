@@ -0,0 +1,238 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// benchReply is an Echo Reply to probe *seq* of identifier *id*, as read off
+// the wire, with the default payload
+func benchReply(b *testing.B, id, seq int) []byte {
+	b.Helper()
+	request, err := BuildEchoRequest(WithID(id), WithSeq(seq))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return fakeEchoReplies(defaultTTL, 0)(request, nil)[0].data
+}
+
+func BenchmarkGetPutPacket(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		buf := getPacket()
+		buf[0] = 1
+		putPacket(buf[:64])
+	}
+}
+
+// packetSink keeps the buffers of BenchmarkMakePacket on the heap, as those
+// of reads are
+var packetSink []byte
+
+// BenchmarkMakePacket is what BenchmarkGetPutPacket saves: a buffer for every read
+func BenchmarkMakePacket(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		packetSink = make([]byte, maxICMPPacket)
+		packetSink[0] = 1
+	}
+}
+
+func BenchmarkICMPHeader(b *testing.B) {
+	reply := benchReply(b, 7, 1)
+	b.ReportAllocs()
+	for b.Loop() {
+		if typ, _, err := icmpHeader(protocolICMP, reply); err != nil || typ != ipv4.ICMPTypeEchoReply {
+			b.Fatalf("type %v, %v", typ, err)
+		}
+	}
+}
+
+func BenchmarkEchoHeader(b *testing.B) {
+	reply := benchReply(b, 7, 1)
+	b.ReportAllocs()
+	for b.Loop() {
+		if id, seq, ok := echoHeader(reply); !ok || id != 7 || seq != 1 {
+			b.Fatalf("id %d seq %d", id, seq)
+		}
+	}
+}
+
+// BenchmarkParseMessage is what BenchmarkICMPHeader and BenchmarkEchoHeader
+// read in place: icmp.ParseMessage copies the body out
+func BenchmarkParseMessage(b *testing.B) {
+	reply := benchReply(b, 7, 1)
+	b.ReportAllocs()
+	for b.Loop() {
+		msg, err := icmp.ParseMessage(protocolICMP, reply)
+		if err != nil || msg.Body.(*icmp.Echo).Seq != 1 {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseICMPResponse(b *testing.B) {
+	peer := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	request, err := BuildEchoRequest(WithID(7), WithSeq(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	unreachable := fakeUnreachable(1, peer)(request, peer)[0].data
+
+	for _, bench := range []struct {
+		name string
+		data []byte
+		want Reason
+	}{
+		{"reply", benchReply(b, 7, 1), ReasonNone},
+		{"mismatched", benchReply(b, 7, 0), ReasonMismatchedReply},
+		{"unreachable", unreachable, ReasonUnreachableHost},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				res, _ := parseICMPResponse(protocolICMP, 7, bench.data, peer, 1, packetMeta{ttl: defaultTTL}, float64(time.Millisecond))
+				if res.Reason != bench.want {
+					b.Fatalf("reason %v, want %v", res.Reason, bench.want)
+				}
+			}
+		})
+	}
+}
+
+// icmpError is an ICMP(v6) Destination Unreachable quoting the IP packet of
+// *request*, from *src* to *dst*, with *options* bytes of IPv4 options
+// (NOPs), and cut to *quoted* bytes of the request if not negative
+func icmpError(t *testing.T, src, dst string, options int, request []byte, quoted int) []byte {
+	t.Helper()
+	if quoted >= 0 {
+		request = request[:quoted]
+	}
+	from, to := net.ParseIP(src), net.ParseIP(dst)
+
+	var header []byte
+	typ := byte(ipv4.ICMPTypeDestinationUnreachable)
+	if to.To4() != nil {
+		header = make([]byte, 20+options)
+		header[0] = 0x40 | byte(len(header)/4)
+		binary.BigEndian.PutUint16(header[2:4], uint16(len(header)+len(request)))
+		header[8], header[9] = 64, protocolICMP
+		copy(header[12:16], from.To4())
+		copy(header[16:20], to.To4())
+		for i := 20; i < len(header); i++ {
+			header[i] = 1 // NOP
+		}
+	} else {
+		typ = byte(ipv6.ICMPTypeDestinationUnreachable)
+		header = make([]byte, 40)
+		header[0] = 0x60
+		binary.BigEndian.PutUint16(header[4:6], uint16(len(request)))
+		header[6], header[7] = protocolICMPv6, 64
+		copy(header[8:24], from)
+		copy(header[24:40], to)
+	}
+
+	msg := append([]byte{typ, 1, 0, 0, 0, 0, 0, 0}, header...)
+	return append(msg, request...)
+}
+
+// testEcho is an Echo Request of identifier *id* and sequence number *seq*
+func testEcho(t *testing.T, ipv6 bool, id, seq int) []byte {
+	t.Helper()
+	request, err := BuildEchoRequest(WithIPv6(ipv6), WithID(id), WithSeq(seq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request
+}
+
+func TestQuotedEcho(t *testing.T) {
+	v4, v6 := testEcho(t, false, 7, 5), testEcho(t, true, 7, 5)
+	reply := fakeEchoReplies(defaultTTL, 0)(v4, nil)[0].data
+	udp := icmpError(t, "192.0.2.1", "198.51.100.1", 0, v4, -1)
+	udp[8+9] = 17
+
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		ok      bool
+		dst     string
+		id, seq int
+	}{
+		{"ipv4", icmpError(t, "192.0.2.1", "198.51.100.1", 0, v4, -1), true, "198.51.100.1", 7, 5},
+		{"ipv4 options", icmpError(t, "192.0.2.1", "198.51.100.1", 8, v4, -1), true, "198.51.100.1", 7, 5},
+		{"ipv4 longest options", icmpError(t, "192.0.2.1", "198.51.100.1", 40, v4, -1), true, "198.51.100.1", 7, 5},
+		{"ipv4 first 8 bytes", icmpError(t, "192.0.2.1", "198.51.100.1", 4, v4, 8), true, "198.51.100.1", 7, 5},
+		{"ipv6", icmpError(t, "2001:db8::1", "2001:db8::2", 0, v6, -1), true, "2001:db8::2", 7, 5},
+		{"ipv6 first 8 bytes", icmpError(t, "2001:db8::1", "2001:db8::2", 0, v6, 8), true, "2001:db8::2", 7, 5},
+		{"ipv4 cut in the echo header", icmpError(t, "192.0.2.1", "198.51.100.1", 0, v4, 6), false, "", 0, 0},
+		{"ipv4 options cut before the echo header", icmpError(t, "192.0.2.1", "198.51.100.1", 8, v4, 0), false, "", 0, 0},
+		{"ipv4 cut in the IP header", icmpError(t, "192.0.2.1", "198.51.100.1", 0, v4, -1)[:8+16], false, "", 0, 0},
+		{"ipv6 cut in the echo header", icmpError(t, "2001:db8::1", "2001:db8::2", 0, v6, 4), false, "", 0, 0},
+		{"ipv6 cut in the IP header", icmpError(t, "2001:db8::1", "2001:db8::2", 0, v6, -1)[:8+32], false, "", 0, 0},
+		{"not an echo request", icmpError(t, "192.0.2.1", "198.51.100.1", 0, reply, -1), false, "", 0, 0},
+		{"not icmp", udp, false, "", 0, 0},
+		{"no quote", icmpError(t, "192.0.2.1", "198.51.100.1", 0, v4, -1)[:8], false, "", 0, 0},
+		{"short", []byte{3, 1, 0, 0}, false, "", 0, 0},
+	} {
+		request, id, seq, ok := quotedEcho(tc.data)
+		switch {
+		case ok != tc.ok:
+			t.Errorf("%s: ok %v, want %v", tc.name, ok, tc.ok)
+		case ok && (request.dst.String() != tc.dst || id != tc.id || seq != tc.seq):
+			t.Errorf("%s: to %s id %d seq %d, want to %s id %d seq %d", tc.name, request.dst, id, seq, tc.dst, tc.id, tc.seq)
+		}
+	}
+}
+
+func TestQuotesProbe(t *testing.T) {
+	const id, seq = 7, 5
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		seq    int // of the probe awaited
+		quotes bool
+		reason Reason // of the result after, if not quoted
+	}{
+		{"ipv4", icmpError(t, "192.0.2.1", "198.51.100.1", 0, testEcho(t, false, id, seq), -1), seq, true, ReasonUnreachableHost},
+		{"ipv4 options", icmpError(t, "192.0.2.1", "198.51.100.1", 12, testEcho(t, false, id, seq), -1), seq, true, ReasonUnreachableHost},
+		{"ipv6", icmpError(t, "2001:db8::1", "2001:db8::2", 0, testEcho(t, true, id, seq), -1), seq, true, ReasonUnreachableHost},
+		// The sequence numbers on the wire wrap around
+		{"wrapped seq", icmpError(t, "192.0.2.1", "198.51.100.1", 0, testEcho(t, false, id, seq), -1), 0x10000 + seq, true, ReasonUnreachableHost},
+		// About one of our earlier probes: late, as a mismatched reply
+		{"earlier seq", icmpError(t, "192.0.2.1", "198.51.100.1", 0, testEcho(t, false, id, seq-1), -1), seq, false, ReasonMismatchedReply},
+		{"ipv6 earlier seq", icmpError(t, "2001:db8::1", "2001:db8::2", 0, testEcho(t, true, id, seq-1), -1), seq, false, ReasonMismatchedReply},
+		// About someone else's traffic: not reported
+		{"foreign id", icmpError(t, "192.0.2.1", "198.51.100.1", 0, testEcho(t, false, id+1, seq), -1), seq, false, ReasonNone},
+		{"ipv6 foreign id", icmpError(t, "2001:db8::1", "2001:db8::2", 0, testEcho(t, true, id+1, seq), -1), seq, false, ReasonNone},
+		{"truncated", icmpError(t, "192.0.2.1", "198.51.100.1", 0, testEcho(t, false, id, seq), 4), seq, false, ReasonNone},
+	} {
+		res := ProbeResult{Seq: tc.seq, Peer: "192.0.2.1", Reason: ReasonUnreachableHost, Detail: "Destination Host Unreachable"}
+		quotes := quotesProbe(&res, tc.data, id, tc.seq)
+
+		if quotes != tc.quotes || res.Reason != tc.reason {
+			t.Errorf("%s: quotes %v, reason %v, want %v, %v", tc.name, quotes, res.Reason, tc.quotes, tc.reason)
+		}
+		switch tc.reason {
+		case ReasonUnreachableHost:
+			if res.Detail != "Destination Host Unreachable" {
+				t.Errorf("%s: detail %q changed", tc.name, res.Detail)
+			}
+		case ReasonMismatchedReply:
+			if want := fmt.Sprintf("Destination Host Unreachable (for icmp_seq=%d)", seq-1); res.Detail != want {
+				t.Errorf("%s: detail %q, want %q", tc.name, res.Detail, want)
+			}
+		case ReasonNone:
+			if res.Detail != "" {
+				t.Errorf("%s: detail %q of an ignored error", tc.name, res.Detail)
+			}
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// Magic numbers of pcap files, as written on the host that wrote them: with
+// microsecond or nanosecond timestamps. pcapng files start with another.
+const (
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+	pcapngMagic    = 0x0a0d0d0a
+
+	pcapMaxSnapLen = 262144 // the largest packets tcpdump captures
+)
+
+// Link types of the pcap files read: what precedes the IP header of packets
+const (
+	linkNull      = 0   // BSD loopback: a 4 byte address family, in the byte order of the host
+	linkEthernet  = 1   // Ethernet, VLAN tagged or not
+	linkRaw       = 101 // the IP header, with no link layer header
+	linkLinuxSLL  = 113 // Linux cooked capture, tcpdump -i any
+	linkIPv4      = 228
+	linkIPv6      = 229
+	linkLinuxSLL2 = 276 // Linux cooked capture v2, tcpdump -i any since 4.99
+)
+
+// pcapPacket is an IP packet of a capture, and when it was captured
+type pcapPacket struct {
+	time time.Time
+	data []byte // from the IP header on
+}
+
+// pcapReader reads the IP packets of a pcap(5) file, as tcpdump -w writes
+type pcapReader struct {
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	nano     bool // timestamps are in nanoseconds, not microseconds
+	linkType uint32
+}
+
+// newPcapReader reads the file header of the pcap capture *r*
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	pr := &pcapReader{r: bufio.NewReader(r)}
+
+	var header [24]byte
+	if _, err := io.ReadFull(pr.r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading the pcap header: %v", err)
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[0:4]) {
+		case pcapMagicMicro:
+			pr.order = order
+		case pcapMagicNano:
+			pr.order, pr.nano = order, true
+		case pcapngMagic:
+			return nil, errors.New("pcapng captures are not supported: convert them with editcap -F pcap, or capture with tcpdump -w")
+		}
+	}
+	if pr.order == nil {
+		return nil, errors.New("not a pcap capture")
+	}
+
+	pr.linkType = pr.order.Uint32(header[20:24]) & 0x0fffffff
+	switch pr.linkType {
+	case linkNull, linkEthernet, linkRaw, linkLinuxSLL, linkIPv4, linkIPv6, linkLinuxSLL2:
+	default:
+		return nil, fmt.Errorf("unsupported link type %d", pr.linkType)
+	}
+	return pr, nil
+}
+
+// next is the next IP packet of the capture, skipping those of other network
+// protocols (ARP...); io.EOF at the end
+func (pr *pcapReader) next() (pcapPacket, error) {
+	for {
+		var header [16]byte
+		if _, err := io.ReadFull(pr.r, header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return pcapPacket{}, errors.New("truncated packet header")
+			}
+			return pcapPacket{}, err
+		}
+
+		sec, frac := pr.order.Uint32(header[0:4]), pr.order.Uint32(header[4:8])
+		capLen := pr.order.Uint32(header[8:12])
+		if capLen > pcapMaxSnapLen {
+			return pcapPacket{}, fmt.Errorf("invalid packet length %d", capLen)
+		}
+
+		data := make([]byte, capLen)
+		if _, err := io.ReadFull(pr.r, data); err != nil {
+			return pcapPacket{}, errors.New("truncated packet")
+		}
+
+		nsec := int64(frac)
+		if !pr.nano {
+			nsec *= 1000
+		}
+		if ip := pr.ipPacket(data); ip != nil {
+			return pcapPacket{time: time.Unix(int64(sec), nsec), data: ip}, nil
+		}
+	}
+}
+
+// ipPacket is the IP packet in the frame *data*, nil if it holds none
+func (pr *pcapReader) ipPacket(data []byte) []byte {
+	var etherType uint16
+	switch pr.linkType {
+	case linkRaw, linkIPv4, linkIPv6:
+		return data
+	case linkNull:
+		if len(data) < 4 {
+			return nil
+		}
+		// Written in the byte order of the capturing host, which may not be the file's
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		switch family {
+		case 2, 24, 28, 30: // AF_INET, and AF_INET6 on the BSDs, macOS
+			return data[4:]
+		}
+		return nil
+	case linkEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case linkLinuxSLL2:
+		if len(data) < 20 {
+			return nil
+		}
+		etherType, data = binary.BigEndian.Uint16(data[0:2]), data[20:]
+	}
+
+	if etherType != 0x0800 && etherType != 0x86dd {
+		return nil
+	}
+	return data
+}
+
+// ipICMP is an ICMP message of a captured packet, with the IP header fields
+// that tell whose it is
+type ipICMP struct {
+	src, dst netip.Addr
+	ttl      int    // TTL / Hop Limit
+	proto    int    // protocolICMP or protocolICMPv6
+	data     []byte // the ICMP message, header included
+}
+
+// parseIPICMP is the ICMP message IP packet *data* carries; false if it
+// carries none, or the part of a fragmented one after the first. *data* may be
+// cut short, as ICMP errors quote it.
+func parseIPICMP(data []byte) (ipICMP, bool) {
+	if len(data) < 1 {
+		return ipICMP{}, false
+	}
+
+	switch data[0] >> 4 {
+	case 4:
+		hdrlen := int(data[0]&0x0f) << 2
+		if len(data) < 20 || hdrlen < 20 || len(data) < hdrlen || data[9] != protocolICMP {
+			return ipICMP{}, false
+		}
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+			return ipICMP{}, false
+		}
+		// Frames are padded up to their minimum size, past the end of the packet
+		if total := int(binary.BigEndian.Uint16(data[2:4])); total >= hdrlen && total < len(data) {
+			data = data[:total]
+		}
+		return ipICMP{
+			src:   netip.AddrFrom4([4]byte(data[12:16])),
+			dst:   netip.AddrFrom4([4]byte(data[16:20])),
+			ttl:   int(data[8]),
+			proto: protocolICMP,
+			data:  data[hdrlen:],
+		}, true
+
+	case 6:
+		if len(data) < 40 {
+			return ipICMP{}, false
+		}
+		packet := ipICMP{
+			src:   netip.AddrFrom16([16]byte(data[8:24])),
+			dst:   netip.AddrFrom16([16]byte(data[24:40])),
+			ttl:   int(data[7]),
+			proto: protocolICMPv6,
+		}
+		if payload := int(binary.BigEndian.Uint16(data[4:6])); 40+payload < len(data) {
+			data = data[:40+payload]
+		}
+
+		// Skip the extension headers there may be, up to ICMPv6's
+		next, rest := data[6], data[40:]
+		for next != 58 {
+			if len(rest) < 8 {
+				return ipICMP{}, false
+			}
+			switch next {
+			case 0, 43, 60: // Hop-by-Hop Options, Routing, Destination Options
+				hdrlen := (int(rest[1]) + 1) * 8
+				if len(rest) < hdrlen {
+					return ipICMP{}, false
+				}
+				next, rest = rest[0], rest[hdrlen:]
+			case 44: // Fragment
+				if binary.BigEndian.Uint16(rest[2:4])&0xfff8 != 0 {
+					return ipICMP{}, false
+				}
+				next, rest = rest[0], rest[8:]
+			default:
+				return ipICMP{}, false
+			}
+		}
+		packet.data = rest
+		return packet, true
+	}
+	return ipICMP{}, false
+}
+
+// capture, when set with SetPcap, is where every Echo Request sent and every
+// ICMP packet received is written to
+var capture *pcapWriter
+
+// SetPcap writes every Echo Request sent and every ICMP packet received, with
+// the time it was sent or read at, to a new pcap(5) file at *path*, for
+// Wireshark or tcpdump -r to look into what pinger saw. Sockets hand pinger
+// the ICMP message alone: the IP header of each packet is rebuilt from its
+// addresses and TTL, without the options it may have carried.
+func SetPcap(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("--pcap: %v", err)
+	}
+
+	var header [24]byte
+	binary.NativeEndian.PutUint32(header[0:4], pcapMagicNano)
+	binary.NativeEndian.PutUint16(header[4:6], 2) // version 2.4
+	binary.NativeEndian.PutUint16(header[6:8], 4)
+	binary.NativeEndian.PutUint32(header[16:20], pcapMaxSnapLen)
+	binary.NativeEndian.PutUint32(header[20:24], linkRaw)
+	if _, err := f.Write(header[:]); err != nil {
+		f.Close()
+		return fmt.Errorf("--pcap: %v", err)
+	}
+
+	capture = &pcapWriter{w: f, locals: make(map[netip.Addr]netip.Addr)}
+	return nil
+}
+
+// pcapWriter writes the packets of SetPcap, from any goroutine
+type pcapWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	locals map[netip.Addr]netip.Addr // local address of the route to every remote one seen
+	failed bool                      // a write failed: reported once
+}
+
+// sent writes Echo Request *data*, sent on *conn* to *remote* at *at*
+func (c *pcapWriter) sent(at time.Time, proto int, conn *icmp.PacketConn, remote string, data []byte) {
+	dst := matchAddr(remote)
+	ttl := defaultTTL
+	switch proto {
+	case protocolICMP:
+		if t, err := conn.IPv4PacketConn().TTL(); err == nil {
+			ttl = t
+		}
+	case protocolICMPv6:
+		if t, err := conn.IPv6PacketConn().HopLimit(); err == nil {
+			ttl = t
+		}
+	}
+	c.write(at, proto, c.local(conn, dst), dst, ttl, 0, data)
+}
+
+// received writes ICMP packet *data*, read on *conn* from *peer*
+func (c *pcapWriter) received(at time.Time, proto int, conn *icmp.PacketConn, peer net.Addr, meta packetMeta, data []byte) {
+	src := matchAddr(peerIP(peer))
+	c.write(at, proto, src, c.local(conn, src), meta.ttl, meta.tos, data)
+}
+
+// local is the address of this host packets between *conn* and *remote* go
+// from or to: the one *conn* is bound to, or else that of the route to *remote*
+func (c *pcapWriter) local(conn *icmp.PacketConn, remote netip.Addr) netip.Addr {
+	var bound net.IP
+	switch a := conn.LocalAddr().(type) {
+	case *net.IPAddr:
+		bound = a.IP
+	case *net.UDPAddr:
+		bound = a.IP
+	}
+	if addr, ok := netip.AddrFromSlice(bound); ok && !addr.Unmap().IsUnspecified() {
+		return addr.Unmap()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if addr, ok := c.locals[remote]; ok {
+		return addr
+	}
+	addr := netip.IPv4Unspecified()
+	if remote.Is6() {
+		addr = netip.IPv6Unspecified()
+	}
+	if remote.IsValid() {
+		if udp, err := net.Dial("udp", net.JoinHostPort(remote.String(), "9")); err == nil {
+			addr = udp.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap()
+			udp.Close()
+		}
+	}
+	c.locals[remote] = addr
+	return addr
+}
+
+// write writes ICMP message *data*, from *src* to *dst*, behind the IP header
+// it came with, rebuilt
+func (c *pcapWriter) write(at time.Time, proto int, src, dst netip.Addr, ttl, tos int, data []byte) {
+	var packet []byte
+	switch {
+	case proto == protocolICMP && src.Is4() && dst.Is4():
+		packet = make([]byte, 20, 20+len(data))
+		packet[0], packet[1] = 0x45, byte(tos)
+		binary.BigEndian.PutUint16(packet[2:4], uint16(20+len(data)))
+		packet[8], packet[9] = byte(ttl), protocolICMP
+		copy(packet[12:16], src.AsSlice())
+		copy(packet[16:20], dst.AsSlice())
+		binary.BigEndian.PutUint16(packet[10:12], ^uint16(onesComplementSum(0, packet)))
+		packet = append(packet, data...)
+
+	case proto == protocolICMPv6 && src.Is6() && dst.Is6():
+		packet = make([]byte, 40, 40+len(data))
+		binary.BigEndian.PutUint32(packet[0:4], 6<<28|uint32(tos)<<20)
+		binary.BigEndian.PutUint16(packet[4:6], uint16(len(data)))
+		packet[6], packet[7] = protocolICMPv6, byte(ttl)
+		copy(packet[8:24], src.AsSlice())
+		copy(packet[24:40], dst.AsSlice())
+		packet = append(packet, data...)
+
+		// The kernel computes the checksum of the Echo Requests sent: so here
+		icmp := packet[40:]
+		if len(icmp) >= 4 && icmp[2] == 0 && icmp[3] == 0 {
+			var pseudo [40]byte
+			copy(pseudo[0:32], packet[8:40])
+			binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(icmp)))
+			pseudo[39] = protocolICMPv6
+			binary.BigEndian.PutUint16(icmp[2:4], ^uint16(onesComplementSum(onesComplementSum(0, pseudo[:]), icmp)))
+		}
+
+	default:
+		return
+	}
+
+	record := make([]byte, 16, 16+len(packet))
+	binary.NativeEndian.PutUint32(record[0:4], uint32(at.Unix()))
+	binary.NativeEndian.PutUint32(record[4:8], uint32(at.Nanosecond()))
+	binary.NativeEndian.PutUint32(record[8:12], uint32(len(packet)))
+	binary.NativeEndian.PutUint32(record[12:16], uint32(len(packet)))
+	record = append(record, packet...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.w.Write(record); err != nil && !c.failed {
+		c.failed = true
+		slog.Error("writing the capture", "err", err)
+	}
+}
+
+// onesComplementSum adds *data* to *sum*, as the Internet checksum does
+func onesComplementSum(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return sum
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// summarizeEvery prints, every *every*, the statistics of each of *targets*
+// over the time since the last print, kept alongside their running *stats*,
+// until the returned function is called. It does nothing if *every* is 0.
+func summarizeEvery(every time.Duration, printer Printer, targets []string, stats ...*PingStats) func() {
+	if every <= 0 {
+		return func() {}
+	}
+
+	statsMu.Lock()
+	for _, s := range stats {
+		s.window = &PingStats{}
+	}
+	statsMu.Unlock()
+
+	done, finished := make(chan struct{}), make(chan struct{})
+	ticker := time.NewTicker(every)
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			for i, s := range stats {
+				window := s.takeWindow()
+				printer.Periodic(targets[i], window, every)
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-finished
+	}
+}
+
+// takeWindow is the statistics of what *stats* recorded since the last time,
+// or since summarizeEvery started keeping them. The rolling loss stays that
+// of the last probes of the run.
+func (stats *PingStats) takeWindow() *PingStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	window := stats.window
+	stats.window = &PingStats{}
+	window.recent, window.next = slices.Clone(stats.recent), stats.next
+	return window
+}
+
+// Periodic prints the statistics of the last *every* of the run
+func (p *textPrinter) Periodic(target string, window *PingStats, every time.Duration) {
+	fmt.Fprintf(p.w, "--- %s statistics, last %v ---\n", target, every)
+	fmt.Fprintf(p.w, "%d packets transmitted, %d received, %d errors, %.1f%% packet loss\n",
+		window.transmitted, window.received, window.errors, window.loss())
+	if loss, n := window.recentLoss(); n == lossWindow {
+		fmt.Fprintf(p.w, "%.1f%% packet loss over the last %d probes of the run\n", loss, n)
+	}
+	if window.received > 0 {
+		window.finalStats()
+		fmt.Fprintf(p.w, "round-trip min/avg/max = %s\n", p.rtt.formatMany(window.min, window.mean, window.max))
+	}
+}
+
+func (p *jsonPrinter) Periodic(target string, window *PingStats, every time.Duration) {
+	out := newJSONSummary(target, window, p.rtt)
+	out.Type = "window"
+	out.Window = every.Seconds()
+	p.enc.Encode(out)
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestSummarizeEvery records probes while summarizeEvery prints windows of
+// them, as runs do: the windows, and what was recorded after the last one,
+// add up to the run. Run with -race, it checks that they are taken safely too.
+func TestSummarizeEvery(t *testing.T) {
+	const probes = 20000
+	var out bytes.Buffer
+	printer, err := NewPrinter("json", DefaultRTTFormat, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats PingStats
+	stop := summarizeEvery(time.Millisecond, printer, []string{"192.0.2.1"}, &stats)
+	for i := range probes {
+		stats.sent()
+		if i%4 == 3 {
+			stats.record(ProbeResult{Seq: i, Reason: ReasonTimeout})
+			continue
+		}
+		stats.record(ProbeResult{Seq: i, RTT: 1})
+	}
+	stop()
+
+	rest := stats.takeWindow()
+	transmitted, received := rest.transmitted, rest.received
+	lines := bufio.NewScanner(&out)
+	for lines.Scan() {
+		var window struct {
+			Transmitted int `json:"transmitted"`
+			Received    int `json:"received"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &window); err != nil {
+			t.Fatalf("%v: %s", err, lines.Bytes())
+		}
+		transmitted, received = transmitted+window.Transmitted, received+window.Received
+	}
+
+	if transmitted != probes || received != probes*3/4 {
+		t.Errorf("windows add up to %d transmitted, %d received: want %d and %d", transmitted, received, probes, probes*3/4)
+	}
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package helpers
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminationSignals end a run, after printing the statistics
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// hangupSignals ask ReloadOnHangup to restart pinger
+var hangupSignals = []os.Signal{syscall.SIGHUP}
+
+const (
+	datagramICMP    = true // unprivileged ICMP datagram sockets exist
+	controlMessages = true // IfIndex / TTL can be passed as control messages
+)
+
+// icmpListenAddr is the local address ICMP sockets for *proto* listen on.
+// Interface steering is done with control messages, so the wildcard will do.
+func icmpListenAddr(proto int, iface *net.Interface, dst string) string {
+	return wildcardAddr(proto)
+}
+
+// setSockoptBroadcast sets SO_BROADCAST on socket *fd*
+func setSockoptBroadcast(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+}
+
+// processCPUTime is the user and system CPU time used by pinger so far
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// processMaxRSS is the peak resident set size of pinger so far, in bytes
+func processMaxRSS() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	// macOS counts bytes, the others KiB
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
+
+// systemHostsFile is the hosts(5) file the resolver consults before DNS
+var systemHostsFile = "/etc/hosts"
+
+// terminalWidth is how many columns the terminal on stdout has, 80 if it
+// cannot tell
+func terminalWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
+
+// reexec replaces pinger with a new run of itself, of the same arguments
+func reexec() error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, os.Args, os.Environ())
+}
@@ -0,0 +1,99 @@
+//go:build windows
+
+package helpers
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// terminationSignals end a run, after printing the statistics.
+// Windows only delivers Ctrl + C (and Ctrl + Break) as os.Interrupt.
+var terminationSignals = []os.Signal{os.Interrupt}
+
+// hangupSignals ask ReloadOnHangup to restart pinger: there is no SIGHUP
+var hangupSignals []os.Signal
+
+const (
+	datagramICMP    = false // only raw sockets, which need Administrator
+	controlMessages = false // x/net ignores control messages on Windows
+)
+
+// icmpListenAddr is the local address ICMP sockets for *proto* listen on.
+//
+// Without control messages, the only way to pick the interface is to bind to
+// one of its addresses. Raw sockets bound to the wildcard may also miss replies,
+// so otherwise bind to the source address the routing table picks for *dst*.
+func icmpListenAddr(proto int, iface *net.Interface, dst string) string {
+	if iface != nil {
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				ipnet, ok := addr.(*net.IPNet)
+				if ok && (ipnet.IP.To4() != nil) == (proto == protocolICMP) {
+					return ipnet.IP.String()
+				}
+			}
+		}
+	}
+
+	network := "udp4"
+	if proto == protocolICMPv6 {
+		network = "udp6"
+	}
+
+	// Connecting a UDP socket sends nothing, but does the route lookup
+	if conn, err := net.Dial(network, net.JoinHostPort(dst, "9")); err == nil {
+		defer conn.Close()
+		if local, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			return local.IP.String()
+		}
+	}
+
+	return wildcardAddr(proto)
+}
+
+// setSockoptBroadcast sets SO_BROADCAST on socket *fd*
+func setSockoptBroadcast(fd uintptr) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+}
+
+// processCPUTime is the user and system CPU time used by pinger so far
+func processCPUTime() time.Duration {
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(syscall.Handle(^uintptr(0)), &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	// FILETIMEs of durations, in 100 ns units
+	ticks := func(ft syscall.Filetime) int64 { return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime) }
+	return time.Duration((ticks(kernel) + ticks(user)) * 100)
+}
+
+// processMaxRSS is the peak resident set size of pinger so far, in bytes:
+// unknown (0) on Windows
+func processMaxRSS() int64 {
+	return 0
+}
+
+// systemHostsFile is the hosts file the resolver consults before DNS
+var systemHostsFile = filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+
+// terminalWidth is how many columns the console on stdout has, 80 if it
+// cannot tell
+func terminalWidth() int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 80
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
+
+// reexec replaces pinger with a new run of itself: Windows cannot
+func reexec() error {
+	return errors.New("restarting in place is not supported on Windows")
+}
@@ -0,0 +1,383 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Playbook is a troubleshooting runbook: measurements to run one after the
+// other, each with the objectives (SLOs) its results must meet. For example:
+//
+//	name: branch office
+//	args: [-I, eth0]
+//	steps:
+//	  - ping: 10.0.0.1
+//	    args: [-c, "10"]
+//	    slo: {max_loss_pct: 0, max_avg_ms: 20}
+//	  - trace: example.com
+//	    slo: {reached: true, max_hops: 15}
+//	  - sweep: 10.0.0.0/24
+//	    slo: {min_alive: 3}
+type Playbook struct {
+	Name  string         `yaml:"name"`
+	Args  []string       `yaml:"args"` // pinger flags for every step
+	Steps []PlaybookStep `yaml:"steps"`
+}
+
+// PlaybookStep is one measurement: a ping, trace or sweep of its target
+type PlaybookStep struct {
+	Name  string   `yaml:"name"`
+	Ping  string   `yaml:"ping"`
+	Trace string   `yaml:"trace"`
+	Sweep string   `yaml:"sweep"`
+	Args  []string `yaml:"args"` // more pinger flags for this step, after the playbook's
+	SLO   SLO      `yaml:"slo"`
+}
+
+// SLO is what the results of a step must meet; unset objectives are not checked
+type SLO struct {
+	MaxLoss  *float64 `yaml:"max_loss_pct" json:"max_loss_pct,omitempty"` // ping
+	MaxAvg   *float64 `yaml:"max_avg_ms" json:"max_avg_ms,omitempty"`     // ping
+	MaxRTT   *float64 `yaml:"max_rtt_ms" json:"max_rtt_ms,omitempty"`     // ping
+	Reached  *bool    `yaml:"reached" json:"reached,omitempty"`           // trace
+	MaxHops  *int     `yaml:"max_hops" json:"max_hops,omitempty"`         // trace
+	MinAlive *int     `yaml:"min_alive" json:"min_alive,omitempty"`       // sweep
+}
+
+// LoadPlaybook reads and checks the playbook at *path*. It is named after the
+// file, if it does not name itself.
+func LoadPlaybook(path string) (Playbook, error) {
+	var pb Playbook
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pb, fmt.Errorf("error reading playbook: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&pb); err != nil {
+		return pb, fmt.Errorf("error parsing playbook %s: %v", path, err)
+	}
+
+	if pb.Name == "" {
+		pb.Name = filepath.Base(path)
+	}
+	if len(pb.Steps) == 0 {
+		return pb, fmt.Errorf("playbook %s has no steps", path)
+	}
+	for i, step := range pb.Steps {
+		if err := step.check(); err != nil {
+			return pb, fmt.Errorf("playbook %s, step %d: %v", path, i+1, err)
+		}
+	}
+
+	return pb, nil
+}
+
+// kind is what the step measures: "ping", "trace" or "sweep"
+func (step PlaybookStep) kind() string {
+	switch {
+	case step.Ping != "":
+		return "ping"
+	case step.Trace != "":
+		return "trace"
+	}
+	return "sweep"
+}
+
+// target is what the step measures
+func (step PlaybookStep) target() string {
+	return step.Ping + step.Trace + step.Sweep
+}
+
+// label names the step in the report
+func (step PlaybookStep) label() string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return step.kind() + " " + step.target()
+}
+
+// check makes sure the step measures one thing, with objectives that apply to it
+func (step PlaybookStep) check() error {
+	set := 0
+	for _, target := range []string{step.Ping, step.Trace, step.Sweep} {
+		if target != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("want exactly one of ping, trace or sweep")
+	}
+
+	slo := step.SLO
+	switch step.kind() {
+	case "ping":
+		if slo.Reached != nil || slo.MaxHops != nil || slo.MinAlive != nil {
+			return fmt.Errorf("a ping takes max_loss_pct, max_avg_ms and max_rtt_ms objectives")
+		}
+	case "trace":
+		if slo.MaxLoss != nil || slo.MaxAvg != nil || slo.MaxRTT != nil || slo.MinAlive != nil {
+			return fmt.Errorf("a trace takes reached and max_hops objectives")
+		}
+	case "sweep":
+		if slo.MaxLoss != nil || slo.MaxAvg != nil || slo.MaxRTT != nil || slo.Reached != nil || slo.MaxHops != nil {
+			return fmt.Errorf("a sweep takes a min_alive objective")
+		}
+	}
+	return nil
+}
+
+// command is the pinger command line of the step, JSON output last so that
+// the results can be read back
+func (step PlaybookStep) command(common []string) []string {
+	var args []string
+	if step.kind() != "ping" {
+		args = append(args, step.kind())
+	}
+	args = append(args, common...)
+	args = append(args, step.Args...)
+	return append(args, "--format", "json", "--", step.target())
+}
+
+// playResult is the outcome of one step: its summary record, and whether it met its SLO
+type playResult struct {
+	Type     string          `json:"type"`
+	Step     int             `json:"step"`
+	Name     string          `json:"name"`
+	Kind     string          `json:"kind"`
+	Target   string          `json:"target"`
+	Summary  json.RawMessage `json:"summary,omitempty"` // the step's own summary record
+	SLO      SLO             `json:"slo"`
+	Passed   bool            `json:"passed"`
+	Failures []string        `json:"failures,omitempty"` // objectives missed, or why the step did not run
+
+	measured string // the results the SLO is checked against, in words
+}
+
+// playSummary is the last summary record a step printed, as far as the SLOs go
+type playSummary struct {
+	Type     string   `json:"type"`
+	Loss     float64  `json:"loss_pct"`
+	Avg      *float64 `json:"rtt_avg_ms"`
+	Max      *float64 `json:"rtt_max_ms"`
+	Received int      `json:"received"`
+	Reached  bool     `json:"reached"`
+	Hops     int      `json:"hops"`
+	Alive    int      `json:"alive"`
+}
+
+// summaryTypes are the summary records of each kind of step
+var summaryTypes = map[string]string{"ping": "summary", "trace": "trace_summary", "sweep": "sweep_summary"}
+
+// evaluate checks the step's summary against its SLO
+func (r *playResult) evaluate(summary playSummary) {
+	slo := r.SLO
+	r.Failures = nil
+	fail := func(format string, a ...any) {
+		r.Failures = append(r.Failures, fmt.Sprintf(format, a...))
+	}
+
+	if slo.MaxLoss != nil && summary.Loss > *slo.MaxLoss {
+		fail("loss %.1f%% > %.1f%%", summary.Loss, *slo.MaxLoss)
+	}
+	for _, objective := range []struct {
+		name  string
+		limit *float64
+		value *float64
+	}{{"avg rtt", slo.MaxAvg, summary.Avg}, {"max rtt", slo.MaxRTT, summary.Max}} {
+		switch {
+		case objective.limit == nil:
+		case objective.value == nil:
+			fail("%s: no reply to measure it by", objective.name)
+		case *objective.value > *objective.limit:
+			fail("%s %.3f ms > %.3f ms", objective.name, *objective.value, *objective.limit)
+		}
+	}
+	if slo.Reached != nil && summary.Reached != *slo.Reached {
+		fail("reached is %v, want %v", summary.Reached, *slo.Reached)
+	}
+	if slo.MaxHops != nil && summary.Hops > *slo.MaxHops {
+		fail("%d hops > %d", summary.Hops, *slo.MaxHops)
+	}
+	if slo.MinAlive != nil && summary.Alive < *slo.MinAlive {
+		fail("%d hosts alive < %d", summary.Alive, *slo.MinAlive)
+	}
+
+	r.Passed = len(r.Failures) == 0
+
+	switch r.Kind {
+	case "ping":
+		r.measured = fmt.Sprintf("%d received, %.1f%% loss", summary.Received, summary.Loss)
+		if summary.Avg != nil {
+			r.measured += fmt.Sprintf(", avg %.3f ms", *summary.Avg)
+		}
+	case "trace":
+		if summary.Reached {
+			r.measured = fmt.Sprintf("reached in %d hops", summary.Hops)
+		} else {
+			r.measured = fmt.Sprintf("not reached after %d hops", summary.Hops)
+		}
+	case "sweep":
+		r.measured = fmt.Sprintf("%d alive", summary.Alive)
+	}
+}
+
+// runStep runs step number *n* as a pinger child process, and evaluates its results
+func runStep(pinger string, common []string, n int, step PlaybookStep) playResult {
+	res := playResult{Type: "play_step", Step: n, Name: step.label(), Kind: step.kind(), Target: step.target(), SLO: step.SLO}
+
+	var out bytes.Buffer
+	child := exec.Command(pinger, step.command(common)...)
+	child.Stdout, child.Stderr = &out, &out
+	child.Run()
+
+	// The last summary record, and what else was printed, for when there is none
+	var lastLine string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var record playSummary
+		if json.Unmarshal(line, &record) == nil && record.Type == summaryTypes[res.Kind] {
+			res.Summary = bytes.Clone(line)
+			res.evaluate(record)
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 && line[0] != '{' {
+			lastLine = strings.TrimSpace(string(line))
+		}
+	}
+
+	if res.Summary == nil {
+		if lastLine == "" {
+			lastLine = "no results"
+		}
+		res.Failures = []string{lastLine}
+	}
+	return res
+}
+
+// PlayHandler runs the steps of *pb* in order, and reports how each did
+// against its SLO, then the playbook as a whole. Ctrl + C ends the step
+// running, and skips the rest. It returns the exit status: replied (0) if
+// every step met its SLO.
+func PlayHandler(pb Playbook, info ICMPInfo) int {
+	printer, err := newPlayPrinter(info.Format, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	pinger, err := os.Executable()
+	if err != nil {
+		slog.Error("finding the pinger executable", "err", err)
+		os.Exit(ExitUsage)
+	}
+
+	// Steps get the signal too, and end with their statistics
+	stop := onInterrupt(nil)
+
+	printer.header(pb)
+
+	var results []playResult
+	for i, step := range pb.Steps {
+		if stop.stopped() {
+			break
+		}
+		redactName(step.target())
+		res := runStep(pinger, pb.Args, i+1, step)
+		results = append(results, res)
+		printer.step(res, len(pb.Steps))
+	}
+
+	passed := 0
+	for _, res := range results {
+		if res.Passed {
+			passed++
+		}
+	}
+	printer.summary(pb, passed, len(results))
+
+	if passed < len(pb.Steps) {
+		return ExitNoReply
+	}
+	return ExitReplied
+}
+
+// playPrinter renders playbook runs, as text or JSON lines
+type playPrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+}
+
+func newPlayPrinter(format string, w io.Writer) (*playPrinter, error) {
+	switch format {
+	case "", "text":
+		return &playPrinter{w: w}, nil
+	case "json":
+		return &playPrinter{w: w, enc: newRecordEncoder(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *playPrinter) header(pb Playbook) {
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type  string `json:"type"`
+			Name  string `json:"name"`
+			Steps int    `json:"steps"`
+		}{"play_start", pb.Name, len(pb.Steps)})
+		return
+	}
+
+	fmt.Fprintf(p.w, "PLAYING %s: %d steps\n", pb.Name, len(pb.Steps))
+}
+
+func (p *playPrinter) step(res playResult, steps int) {
+	res.Target, res.Failures = redact(res.Target), redactAll(res.Failures)
+	if p.enc != nil {
+		p.enc.Encode(res)
+		return
+	}
+
+	verdict := "ok"
+	if !res.Passed {
+		verdict = "FAILED: " + strings.Join(res.Failures, "; ")
+	}
+	if res.measured != "" {
+		verdict += " (" + res.measured + ")"
+	}
+	fmt.Fprintf(p.w, "[%d/%d] %s: %s\n", res.Step, steps, res.Name, verdict)
+}
+
+func (p *playPrinter) summary(pb Playbook, passed, ran int) {
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type    string `json:"type"`
+			Name    string `json:"name"`
+			Steps   int    `json:"steps"`
+			Ran     int    `json:"ran"`
+			Passed  int    `json:"passed"`
+			Success bool   `json:"success"`
+		}{"play_summary", pb.Name, len(pb.Steps), ran, passed, passed == len(pb.Steps)})
+		return
+	}
+
+	fmt.Fprintf(p.w, "\n--- playbook %s ---\n%d of %d steps met their SLO", pb.Name, passed, len(pb.Steps))
+	if ran < len(pb.Steps) {
+		fmt.Fprintf(p.w, ", %d not run", len(pb.Steps)-ran)
+	}
+	fmt.Fprintln(p.w)
+}
@@ -0,0 +1,327 @@
+package helpers
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"plugin"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// Extensions registered by the plugins loaded, by name
+var (
+	sinkFactories  = make(map[string]ext.SinkFactory)
+	probeFactories = make(map[string]ext.ProbeFactory)
+)
+
+// sinks are the sinks opened with OpenSink, which every Printer of NewPrinter
+// copies what it prints to
+var sinks []namedSink
+
+type namedSink struct {
+	name string
+	ext.Sink
+}
+
+// registry is the ext.Registry of the plugin at *path*, which notes the first
+// name it registers that is taken already
+type registry struct {
+	path string
+	err  error
+}
+
+func (r *registry) RegisterSink(name string, open ext.SinkFactory) {
+	if _, taken := sinkFactories[name]; taken {
+		r.taken("sink", name)
+		return
+	}
+	sinkFactories[name] = open
+}
+
+func (r *registry) RegisterProbe(name string, open ext.ProbeFactory) {
+	if _, taken := probeFactories[name]; taken || builtinTransport(name) {
+		r.taken("probe", name)
+		return
+	}
+	probeFactories[name] = open
+}
+
+func (r *registry) taken(kind, name string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("plugin %s: %s %q is registered already", r.path, kind, name)
+	}
+}
+
+// builtinTransport reports whether *name* is that of a probe pinger has
+// itself, which plugins cannot take
+func builtinTransport(name string) bool {
+	return slices.Contains([]string{"icmp", "tcp", "udp", "http", "arp", "ndp", "dns"}, name)
+}
+
+// LoadPlugin opens the Go plugin at *path*, and registers the probes and sinks
+// it provides. It must export PingerAPI, of the ext.APIVersion pinger was
+// built with, and Register, see package ext.
+func LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("error loading plugin: %v", err)
+	}
+
+	version, err := p.Lookup("PingerAPI")
+	if err != nil {
+		return fmt.Errorf("plugin %s does not export PingerAPI", path)
+	}
+	if v, ok := version.(*int); !ok || *v != ext.APIVersion {
+		return fmt.Errorf("plugin %s is for another version of the extension API: this pinger has version %d", path, ext.APIVersion)
+	}
+
+	sym, err := p.Lookup("Register")
+	if err != nil {
+		return fmt.Errorf("plugin %s does not export Register", path)
+	}
+	register, ok := sym.(func(ext.Registry))
+	if !ok {
+		return fmt.Errorf("plugin %s: Register must be a func(ext.Registry)", path)
+	}
+
+	r := &registry{path: path}
+	register(r)
+	return r.err
+}
+
+// splitSpec splits "<name>[:<config>]", as --sink and pinger probe take
+func splitSpec(spec string) (string, string) {
+	name, config, _ := strings.Cut(spec, ":")
+	return name, config
+}
+
+// OpenSink opens the sink *spec* ("<name>[:<config>]") asks for, from those of
+// the plugins loaded, and attaches it to the Printers made from now on
+func OpenSink(spec string) error {
+	name, config := splitSpec(spec)
+
+	open, ok := sinkFactories[name]
+	if !ok {
+		return fmt.Errorf("unknown sink %q: the plugins loaded with --plugin have %s", name, extensionNames(sinkFactories))
+	}
+
+	sink, err := open(config)
+	if err != nil {
+		return fmt.Errorf("error opening sink %s: %v", name, err)
+	}
+	sinks = append(sinks, namedSink{name: name, Sink: sink})
+	return nil
+}
+
+// extensionNames lists the names of *factories*, for error messages
+func extensionNames[F any](factories map[string]F) string {
+	if len(factories) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// CloseSinks closes the sinks opened, once they have been given everything
+func CloseSinks() {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			slog.Error("closing sink", "sink", sink.name, "err", err)
+		}
+	}
+	sinks = nil
+}
+
+// Exit closes the sinks, and exits with *status*. Runs that may have sinks
+// attached end with it rather than os.Exit, for them to flush what they hold.
+func Exit(status int) {
+	CloseSinks()
+	os.Exit(status)
+}
+
+// sinkPrinter copies what a Printer prints to sinks, converted to the
+// types of package ext
+type sinkPrinter struct {
+	Printer
+	sinks []namedSink
+}
+
+func (p *sinkPrinter) Probe(res ProbeResult) {
+	p.Printer.Probe(res)
+	for _, sink := range p.sinks {
+		sink.Result(extResult(res))
+	}
+}
+
+func (p *sinkPrinter) Annotate(note Annotation) {
+	p.Printer.Annotate(note)
+	note = redactNote(note)
+	for _, sink := range p.sinks {
+		sink.Event(ext.Event{Time: note.Time, Kind: note.Kind, Message: note.Message, Fields: note.Fields})
+	}
+}
+
+func (p *sinkPrinter) Summary(target string, stats *PingStats) {
+	p.Printer.Summary(target, stats)
+	for _, sink := range p.sinks {
+		sink.Summary(extSummary(target, stats))
+	}
+}
+
+func (p *sinkPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.Printer.SummaryTable(targets, stats)
+	for _, sink := range p.sinks {
+		for i, target := range targets {
+			sink.Summary(extSummary(target, stats[i]))
+		}
+	}
+}
+
+// extResult converts a probe result for sinks, masked as --redact asks. What
+// ext.Result has no field for goes in its Fields, named as in the JSON output.
+func extResult(res ProbeResult) ext.Result {
+	out := ext.Result{
+		Transport: res.Transport,
+		Target:    redact(res.Target),
+		Seq:       res.Seq,
+		Peer:      redact(res.Peer),
+		RTT:       msDuration(res.RTT),
+		Reason:    res.Reason.String(),
+		Detail:    redact(res.Detail),
+		Time:      res.Time,
+		Duplicate: res.Duplicate,
+		Fields:    make(map[string]string),
+	}
+	for name, value := range res.Fields {
+		out.Fields[name] = value
+	}
+	for name, value := range map[string]int{"bytes": res.Bytes, "ttl": res.TTL, "status": res.Status} {
+		if value != 0 {
+			out.Fields[name] = fmt.Sprint(value)
+		}
+	}
+	if res.HWAddr != "" {
+		out.Fields["hwaddr"] = res.HWAddr
+	}
+	if len(res.Answers) > 0 {
+		out.Fields["answers"] = strings.Join(res.Answers, ",")
+	}
+	if res.TOSKnown {
+		out.Fields["tos"] = fmt.Sprintf("0x%02x", res.TOS)
+	}
+	if res.Clock != "" {
+		out.Fields["clock"] = res.Clock
+	}
+	out.Fields = redactFields(out.Fields)
+	return out
+}
+
+// extSummary converts the statistics of *target* for sinks
+func extSummary(target string, stats *PingStats) ext.Summary {
+	out := ext.Summary{
+		Target:      redact(target),
+		Transmitted: stats.transmitted,
+		Received:    stats.received,
+		Errors:      stats.errors,
+		Loss:        stats.loss(),
+	}
+	if stats.received > 0 {
+		stats.finalStats()
+		out.Min, out.Avg, out.Max, out.StdDev = msDuration(stats.min), msDuration(stats.mean), msDuration(stats.max), msDuration(stats.stddev)
+		out.EWMA = msDuration(stats.ewma)
+	}
+	if stats.received > 1 {
+		out.Jitter = msDuration(stats.jitter)
+	}
+	return out
+}
+
+// msDuration converts a time in milliseconds, as pinger keeps them, to a Duration
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// probeResult converts the result of a plugin probe of *transport*
+func probeResult(transport string, res ext.Result) ProbeResult {
+	out := ProbeResult{
+		Transport: transport,
+		Seq:       res.Seq,
+		Peer:      res.Peer,
+		RTT:       float64(res.RTT) / float64(time.Millisecond),
+		Detail:    res.Detail,
+		Time:      res.Time,
+		Duplicate: res.Duplicate,
+		Fields:    res.Fields,
+	}
+	if out.Time.IsZero() {
+		out.Time = time.Now()
+	}
+
+	out.Reason = ReasonUnexpectedType
+	for reason, name := range reasonNames {
+		if name == res.Reason {
+			out.Reason = reason
+		}
+	}
+	return out
+}
+
+// PluginProbeHandler runs the plugin probe *spec* ("<name>[:<config>]") asks
+// for against info.IP, once per second, and prints its results and statistics
+// as for ICMP Echo. It returns the exit status.
+func PluginProbeHandler(spec string, info ICMPInfo) int {
+	stats := newPingStats(info)
+
+	name, config := splitSpec(spec)
+	open, ok := probeFactories[name]
+	if !ok {
+		slog.Error(fmt.Sprintf("unknown probe %q: the plugins loaded with --plugin have %s", name, extensionNames(probeFactories)))
+		return ExitUsage
+	}
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+
+	prober, err := open(info.IP, config)
+	if err != nil {
+		slog.Error("starting probe", "probe", name, "err", err)
+		return ExitUsage
+	}
+	defer prober.Close()
+
+	info.Transport = name
+	stop := onInterrupt(nil)
+
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() { printInterim(printer, info.IP, &stats) })
+	defer summarizeEvery(info.SummaryEvery, printer, []string{info.IP}, &stats)()
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
+		if err := spendPacket(); err != nil {
+			report(info, sendErrorResult(err, i), &stats, printer)
+			continue
+		}
+		report(info, probeResult(name, prober.Probe(i, replyTimeout)), &stats, printer)
+		stop.sleep(time.Second)
+	}
+
+	printer.Summary(info.IP, &stats)
+	return exitStatus(&stats)
+}
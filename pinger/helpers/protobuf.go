@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Protocol Buffers wire types, of the key of every field
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoMessage is a Protocol Buffers message, as encoded on the wire. The
+// methods adding fields leave out those of zero value, as proto3 does.
+type protoMessage []byte
+
+func (m *protoMessage) key(field, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wireType))
+}
+
+// varint adds an integer field: int32, int64, uint32 or uint64, and enums
+func (m *protoMessage) varint(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	m.key(field, protoVarint)
+	*m = binary.AppendUvarint(*m, uint64(v))
+}
+
+func (m *protoMessage) bool(field int, v bool) {
+	if v {
+		m.varint(field, 1)
+	}
+}
+
+func (m *protoMessage) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	m.key(field, protoFixed64)
+	*m = binary.LittleEndian.AppendUint64(*m, math.Float64bits(v))
+}
+
+func (m *protoMessage) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	m.key(field, protoBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(v)))
+	*m = append(*m, v...)
+}
+
+// message adds a message field, even if empty: proto3 tells an empty message
+// from a missing one
+func (m *protoMessage) message(field int, v protoMessage) {
+	m.key(field, protoBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(v)))
+	*m = append(*m, v...)
+}
+
+// stringMap adds a map<string, string> field, its entries sorted by key
+func (m *protoMessage) stringMap(field int, v map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(v)) {
+		var entry protoMessage
+		entry.string(1, key)
+		entry.string(2, v[key])
+		m.message(field, entry)
+	}
+}
+
+// protoField is the value of a field of a decoded message: *varint* for
+// varint and fixed fields, *bytes* for strings and messages
+type protoField struct {
+	varint uint64
+	bytes  []byte
+}
+
+// parseProto decodes the fields of a message by number. Where a field is
+// repeated, the last value wins, as for the scalars of proto3.
+func parseProto(data []byte) (map[int]protoField, error) {
+	fields := make(map[int]protoField)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("protobuf: bad field key")
+		}
+		data = data[n:]
+
+		var f protoField
+		switch key & 7 {
+		case protoVarint:
+			f.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("protobuf: bad varint")
+			}
+		case protoFixed64:
+			if n = 8; len(data) < n {
+				return nil, fmt.Errorf("protobuf: truncated fixed64")
+			}
+			f.varint = binary.LittleEndian.Uint64(data)
+		case protoFixed32:
+			if n = 4; len(data) < n {
+				return nil, fmt.Errorf("protobuf: truncated fixed32")
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(data))
+		case protoBytes:
+			size, m := binary.Uvarint(data)
+			if m <= 0 || size > uint64(len(data)-m) {
+				return nil, fmt.Errorf("protobuf: truncated field")
+			}
+			f.bytes, n = data[m:m+int(size)], m+int(size)
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d", key&7)
+		}
+		fields[int(key>>3)] = f
+		data = data[n:]
+	}
+	return fields, nil
+}
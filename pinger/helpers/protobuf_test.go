@@ -0,0 +1,298 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// protoFieldRE matches a field of a message of pinger.proto: its name and number
+var protoFieldRE = regexp.MustCompile(`^\s*(?:repeated\s+)?(?:map<[^>]+>|[\w.]+)\s+(\w+)\s*=\s*(\d+);`)
+
+// protoFields are the numbers of the *want* fields of *message* in
+// pinger.proto, by name: what the codec is tested against, so that the two
+// cannot drift apart
+func protoFields(t *testing.T, message string, want int) map[string]int {
+	t.Helper()
+
+	proto, err := os.ReadFile("pinger.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := []byte("message " + message + " {")
+	i := bytes.Index(proto, start)
+	if i < 0 {
+		t.Fatalf("no message %s in pinger.proto", message)
+	}
+	body, _, _ := bytes.Cut(proto[i+len(start):], []byte("\n}"))
+
+	fields := make(map[string]int)
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if m := protoFieldRE.FindSubmatch(line); m != nil {
+			fields[string(m[1])], _ = strconv.Atoi(string(m[2]))
+		}
+	}
+	if len(fields) != want {
+		t.Fatalf("%d fields of %s in pinger.proto, want %d: %v", len(fields), message, want, fields)
+	}
+	return fields
+}
+
+// decodeProto parses *m*, failing the test if it does not
+func decodeProto(t *testing.T, m protoMessage) map[int]protoField {
+	t.Helper()
+	fields, err := parseProto(m)
+	if err != nil {
+		t.Fatalf("%v: % x", err, []byte(m))
+	}
+	return fields
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	var inner protoMessage
+	inner.string(1, "inner")
+
+	var m protoMessage
+	m.varint(1, 300)
+	m.varint(2, -5)
+	m.varint(3, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	m.double(4, 1.25)
+	m.string(5, "héllo")
+	m.bool(6, true)
+	m.message(7, inner)
+	m.message(8, nil)
+
+	fields := decodeProto(t, m)
+	if got := int64(fields[1].varint); got != 300 {
+		t.Errorf("varint %d, want 300", got)
+	}
+	if got := int32(fields[2].varint); got != -5 {
+		t.Errorf("negative varint %d, want -5", got)
+	}
+	if got := time.Unix(0, int64(fields[3].varint)).UTC(); got.Year() != 2000 {
+		t.Errorf("time %v, want 2000-01-01", got)
+	}
+	if got := math.Float64frombits(fields[4].varint); got != 1.25 {
+		t.Errorf("double %g, want 1.25", got)
+	}
+	if got := string(fields[5].bytes); got != "héllo" {
+		t.Errorf("string %q, want %q", got, "héllo")
+	}
+	if fields[6].varint != 1 {
+		t.Errorf("bool %d, want 1", fields[6].varint)
+	}
+	if got := string(decodeProto(t, fields[7].bytes)[1].bytes); got != "inner" {
+		t.Errorf("inner message string %q, want %q", got, "inner")
+	}
+	if f, ok := fields[8]; !ok || len(f.bytes) != 0 {
+		t.Errorf("empty message %+v, present: %v, want present and empty", f, ok)
+	}
+}
+
+func TestProtoZeroValues(t *testing.T) {
+	var m protoMessage
+	m.varint(1, 0)
+	m.double(2, 0)
+	m.string(3, "")
+	m.bool(4, false)
+	m.stringMap(5, nil)
+
+	if len(m) != 0 {
+		t.Errorf("zero values encoded as % x, want nothing, as proto3 does", []byte(m))
+	}
+}
+
+func TestParseProtoErrors(t *testing.T) {
+	var m protoMessage
+	m.string(1, "truncated")
+	m.double(2, 1)
+
+	for _, data := range [][]byte{
+		{0x80},          // key without its last byte
+		{0x08, 0x80},    // varint without its last byte
+		{0x11, 1, 2, 3}, // fixed64 of 3 bytes
+		{0x15, 1, 2},    // fixed32 of 2 bytes
+		{0x0a, 5, 'a'},  // string of 1 byte of 5
+		{0x0b},          // start group: unsupported
+		m[:len(m)-1],    // cut in the double
+		m[:3],           // cut in the string
+	} {
+		if _, err := parseProto(data); err == nil {
+			t.Errorf("% x: no error", data)
+		}
+	}
+}
+
+func TestParseStartJob(t *testing.T) {
+	f := protoFields(t, "StartJobRequest", 7)
+
+	var req protoMessage
+	req.string(f["target"], "example.com")
+	req.varint(f["count"], 10)
+	req.varint(f["interval_ms"], 250)
+	req.varint(f["timeout_ms"], 2000)
+	req.bool(f["ipv6"], true)
+	req.string(f["interface"], "eth0")
+	req.varint(f["ttl"], 200)
+
+	want := jobSpec{
+		Target:   "example.com",
+		Count:    10,
+		Interval: 250 * time.Millisecond,
+		Timeout:  2 * time.Second,
+		IPv6:     true,
+		Iface:    "eth0",
+		TTL:      200,
+	}
+	if got := parseStartJob(decodeProto(t, req)); got != want {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+
+	if got := parseStartJob(decodeProto(t, nil)); got != (jobSpec{}) {
+		t.Errorf("empty request parsed as %+v, want the zero jobSpec", got)
+	}
+}
+
+func TestJobMessage(t *testing.T) {
+	f, s := protoFields(t, "Job", 8), protoFields(t, "Summary", 9)
+	started := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	status := jobStatus{
+		ID:      "4e1f6b2a-0c4d-4f0e-9a55-3f1d2c7b8e90",
+		Spec:    jobSpec{Target: "example.com"},
+		Target:  "192.0.2.1",
+		State:   "done",
+		Error:   "",
+		Started: started,
+		Ended:   started.Add(5 * time.Second),
+		Summary: ext.Summary{
+			Transmitted: 5,
+			Received:    4,
+			Errors:      1,
+			Loss:        20,
+			Min:         10 * time.Millisecond,
+			Avg:         12500 * time.Microsecond,
+			Max:         15 * time.Millisecond,
+			StdDev:      2 * time.Millisecond,
+			Jitter:      750 * time.Microsecond,
+		},
+	}
+
+	job := decodeProto(t, jobMessage(status))
+	for name, want := range map[string]string{"id": status.ID, "target": "example.com", "address": "192.0.2.1", "state": "done"} {
+		if got := string(job[f[name]].bytes); got != want {
+			t.Errorf("Job.%s %q, want %q", name, got, want)
+		}
+	}
+	if _, ok := job[f["error"]]; ok {
+		t.Errorf("Job.error present, want it left out when empty")
+	}
+	if got := int64(job[f["started_unix_nano"]].varint); got != started.UnixNano() {
+		t.Errorf("Job.started_unix_nano %d, want %d", got, started.UnixNano())
+	}
+	if got := int64(job[f["ended_unix_nano"]].varint); got != status.Ended.UnixNano() {
+		t.Errorf("Job.ended_unix_nano %d, want %d", got, status.Ended.UnixNano())
+	}
+
+	summary := decodeProto(t, job[f["summary"]].bytes)
+	for name, want := range map[string]int{"transmitted": 5, "received": 4, "errors": 1} {
+		if got := int(summary[s[name]].varint); got != want {
+			t.Errorf("Summary.%s %d, want %d", name, got, want)
+		}
+	}
+	for name, want := range map[string]float64{"loss_pct": 20, "min_ms": 10, "avg_ms": 12.5, "max_ms": 15, "stddev_ms": 2, "jitter_ms": 0.75} {
+		if got := math.Float64frombits(summary[s[name]].varint); got != want {
+			t.Errorf("Summary.%s %g, want %g", name, got, want)
+		}
+	}
+
+	// Running: no end time, and a summary even without results
+	running := decodeProto(t, jobMessage(jobStatus{ID: "1", State: "running", Started: started}))
+	if _, ok := running[f["ended_unix_nano"]]; ok {
+		t.Errorf("Job.ended_unix_nano present while running")
+	}
+	if _, ok := running[f["summary"]]; !ok {
+		t.Errorf("Job.summary missing while running")
+	}
+}
+
+func TestResultMessage(t *testing.T) {
+	f := protoFields(t, "Result", 8)
+	at := time.Date(2000, time.January, 1, 0, 0, 1, 0, time.UTC)
+	res := ext.Result{
+		Seq:       3,
+		Peer:      "192.0.2.1",
+		RTT:       1500 * time.Microsecond,
+		Reason:    "",
+		Time:      at,
+		Duplicate: true,
+		Fields:    map[string]string{"ttl": "57", "bytes": "64"},
+	}
+
+	m := resultMessage(res)
+	result := decodeProto(t, m)
+	if got := int(result[f["seq"]].varint); got != 3 {
+		t.Errorf("Result.seq %d, want 3", got)
+	}
+	if got := string(result[f["peer"]].bytes); got != "192.0.2.1" {
+		t.Errorf("Result.peer %q, want 192.0.2.1", got)
+	}
+	if got := math.Float64frombits(result[f["rtt_ms"]].varint); got != 1.5 {
+		t.Errorf("Result.rtt_ms %g, want 1.5", got)
+	}
+	if _, ok := result[f["reason"]]; ok {
+		t.Errorf("Result.reason present, want it left out for a reply")
+	}
+	if got := int64(result[f["time_unix_nano"]].varint); got != at.UnixNano() {
+		t.Errorf("Result.time_unix_nano %d, want %d", got, at.UnixNano())
+	}
+	if result[f["duplicate"]].varint != 1 {
+		t.Errorf("Result.duplicate not set")
+	}
+
+	// parseProto keeps the last of repeated fields: read the map entries,
+	// each a message of key 1 and value 2, off the wire one by one
+	got := make(map[string]string)
+	for data := []byte(m); len(data) > 0; {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		switch key & 7 {
+		case protoVarint:
+			_, n = binary.Uvarint(data)
+		case protoFixed64:
+			n = 8
+		case protoBytes:
+			size, m := binary.Uvarint(data)
+			if int(key>>3) == f["fields"] {
+				entry := decodeProto(t, data[m:m+int(size)])
+				got[string(entry[1].bytes)] = string(entry[2].bytes)
+			}
+			n = m + int(size)
+		}
+		data = data[n:]
+	}
+	if !maps.Equal(got, res.Fields) {
+		t.Errorf("Result.fields %v, want %v", got, res.Fields)
+	}
+
+	// A timeout: the reason and detail, no peer or RTT
+	timeout := decodeProto(t, resultMessage(ext.Result{Seq: 4, Reason: "timeout", Detail: "no reply in 4s", Time: at}))
+	if got := string(timeout[f["reason"]].bytes); got != "timeout" {
+		t.Errorf("Result.reason %q, want timeout", got)
+	}
+	if got := string(timeout[f["detail"]].bytes); got != "no reply in 4s" {
+		t.Errorf("Result.detail %q, want %q", got, "no reply in 4s")
+	}
+	for _, name := range []string{"peer", "rtt_ms", "duplicate", "fields"} {
+		if _, ok := timeout[f[name]]; ok {
+			t.Errorf("Result.%s present for a timeout", name)
+		}
+	}
+}
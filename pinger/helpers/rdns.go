@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"fmt"
+	"sync"
+)
+
+// nameCache names the peers of probes by reverse DNS, each looked up once.
+// Lookups run in the background, so that a slow resolver does not hold up
+// the probes: a peer is printed numerically until its name is known.
+type nameCache struct {
+	server string // resolver to ask, "" for the system's
+
+	mu      sync.Mutex
+	names   map[string]string // by address, "" if it has no PTR record
+	pending map[string]bool   // lookups under way
+}
+
+func newNameCache(server string) *nameCache {
+	return &nameCache{server: server, names: make(map[string]string), pending: make(map[string]bool)}
+}
+
+// resolve looks *ip* up now, for the target, before any probe is timed
+func (c *nameCache) resolve(ip string) {
+	name := reverseLookup(ip, c.server)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names[ip] = name
+}
+
+// name is the name of *ip*, or "" if it has none, or is not known yet: the
+// first call starts the lookup
+func (c *nameCache) name(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if name, ok := c.names[ip]; ok {
+		return name
+	}
+	if !c.pending[ip] {
+		c.pending[ip] = true
+		go c.resolve(ip)
+	}
+	return ""
+}
+
+// label is *ip* as ping(8) prints peers: "name (ip)", or the bare address
+// when the name is not known
+func (c *nameCache) label(ip string) string {
+	if c == nil || ip == "" {
+		return ip
+	}
+	if name := c.name(ip); name != "" && name != ip {
+		return fmt.Sprintf("%s (%s)", redact(name), ip)
+	}
+	return ip
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import "fmt"
+
+// Reason is a machine-readable classification of why a probe did not
+// produce a matching Echo Reply. ReasonNone marks a successful reply.
+type Reason int
+
+const (
+	ReasonNone              Reason = iota // matching Echo Reply received
+	ReasonTimeout                         // no reply before the read deadline
+	ReasonUnreachableHost                 // Destination Host Unreachable
+	ReasonUnreachableNet                  // Destination Net Unreachable / no route
+	ReasonUnreachable                     // any other Destination Unreachable code
+	ReasonAdminProhibited                 // communication administratively prohibited
+	ReasonTTLExceeded                     // Time To Live / Hop Limit Exceeded
+	ReasonSendError                       // the request could not be built or sent
+	ReasonRecvError                       // reading from the socket failed
+	ReasonParseError                      // the reply could not be parsed
+	ReasonMismatchedReply                 // an Echo Reply, or an ICMP error quoting a request, that is not for this probe
+	ReasonNeighborDiscovery               // IPv6 ND / RA message, not a probe result
+	ReasonUnexpectedType                  // any other ICMP type
+	ReasonRefused                         // TCP connection refused: the host is up, the port closed
+	ReasonPortUnreachable                 // ICMP Port Unreachable, the expected answer to UDP probes
+	ReasonDNSError                        // the resolver answered with an error, e.g. SERVFAIL
+)
+
+var reasonNames = map[Reason]string{
+	ReasonNone:              "",
+	ReasonTimeout:           "timeout",
+	ReasonUnreachableHost:   "unreachable_host",
+	ReasonUnreachableNet:    "unreachable_net",
+	ReasonUnreachable:       "unreachable",
+	ReasonAdminProhibited:   "admin_prohibited",
+	ReasonTTLExceeded:       "ttl_exceeded",
+	ReasonSendError:         "send_error",
+	ReasonRecvError:         "recv_error",
+	ReasonParseError:        "parse_error",
+	ReasonMismatchedReply:   "mismatched_reply",
+	ReasonNeighborDiscovery: "neighbor_discovery",
+	ReasonUnexpectedType:    "unexpected_type",
+	ReasonRefused:           "refused",
+	ReasonPortUnreachable:   "port_unreachable",
+	ReasonDNSError:          "dns_error",
+}
+
+// String returns the stable, snake_case name of the reason.
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason(%d)", int(r))
+}
+
+// MarshalText lets encoding/json emit the reason name instead of its number.
+func (r Reason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText reads the reason name MarshalText emits, e.g. from the
+// records of a run replayed
+func (r *Reason) UnmarshalText(text []byte) error {
+	for reason, name := range reasonNames {
+		if name == string(text) {
+			*r = reason
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown reason %q", text)
+}
+
+// unreachableReason maps a Destination Unreachable code to a Reason.
+// The code spaces differ between ICMP (RFC 792, RFC 1812) and ICMPv6 (RFC 4443).
+func unreachableReason(proto int, code int) Reason {
+	if proto == protocolICMP {
+		switch code {
+		case 0, 6, 11: // net unreachable, net unknown, net unreachable for TOS
+			return ReasonUnreachableNet
+		case 1, 7, 12: // host unreachable, host unknown, host unreachable for TOS
+			return ReasonUnreachableHost
+		case 9, 10, 13: // net / host / communication administratively prohibited
+			return ReasonAdminProhibited
+		}
+		return ReasonUnreachable
+	}
+
+	switch code {
+	case 0, 2: // no route to destination, beyond scope of source address
+		return ReasonUnreachableNet
+	case 3: // address unreachable
+		return ReasonUnreachableHost
+	case 1, 5, 6: // administratively prohibited, source policy, reject route
+		return ReasonAdminProhibited
+	}
+	return ReasonUnreachable
+}
+
+// unreachableMessage is the ping(8) style text for a Destination Unreachable reason.
+func unreachableMessage(reason Reason, code int) string {
+	switch reason {
+	case ReasonUnreachableHost:
+		return "Destination Host Unreachable"
+	case ReasonUnreachableNet:
+		return "Destination Net Unreachable"
+	case ReasonAdminProhibited:
+		return "Communication Administratively Prohibited"
+	}
+	return fmt.Sprintf("Destination Unreachable (code %d)", code)
+}
@@ -0,0 +1,246 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What --redact masks
+const (
+	RedactLastOctet = "last-octet" // the host part of addresses: 10.1.2.x, 2001:db8:1:2::x
+	RedactHostnames = "hostnames"  // hostnames, replaced with host-1, host-2...
+)
+
+// Redaction is what --redact masks in the results printed
+type Redaction struct {
+	Addrs bool // with RedactLastOctet
+	Names bool // with RedactHostnames
+}
+
+// ParseRedaction reads the --redact modes
+func ParseRedaction(modes []string) (Redaction, error) {
+	var r Redaction
+	for _, mode := range modes {
+		switch mode {
+		case RedactLastOctet:
+			r.Addrs = true
+		case RedactHostnames:
+			r.Names = true
+		default:
+			return r, fmt.Errorf("invalid --redact %q: want %s or %s", mode, RedactLastOctet, RedactHostnames)
+		}
+	}
+	return r, nil
+}
+
+// redaction masks the results printed from SetRedaction on, nil if nothing is
+var redaction *redactor
+
+// SetRedaction masks what *r* asks for in the results printed from now on,
+// and in what sinks receive. Statistics are left alone.
+func SetRedaction(r Redaction) {
+	redaction = &redactor{Redaction: r, names: make(map[string]string)}
+}
+
+// redactor masks addresses and the hostnames it is told of. Hostnames cannot
+// be told from other words, so every name pinger learns, from the command
+// line, CNAMEs or PTR records, goes through redactName.
+type redactor struct {
+	Redaction
+
+	mu    sync.Mutex
+	names map[string]string // pseudonyms, by lowercased hostname
+}
+
+var (
+	// hostnamePattern matches words that may be hostnames
+	hostnamePattern = regexp.MustCompile(`[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*`)
+	// ipv4Pattern and ipv6Pattern match what may be addresses, which
+	// net.ParseIP then tells from times and MAC addresses
+	ipv4Pattern = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f:]*:[0-9A-Fa-f:]*`)
+)
+
+// redactName notes *name* as a hostname, to be masked if --redact hostnames
+// is set. Addresses are left to the address patterns, and what is not a
+// hostname, e.g. a URL, is ignored.
+func redactName(name string) {
+	if redaction == nil || !redaction.Names {
+		return
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" || net.ParseIP(name) != nil || hostnamePattern.FindString(name) != name {
+		return
+	}
+
+	redaction.mu.Lock()
+	defer redaction.mu.Unlock()
+	if _, ok := redaction.names[name]; !ok {
+		redaction.names[name] = fmt.Sprintf("host-%d", len(redaction.names)+1)
+	}
+}
+
+// redact masks *s*, as the redaction in effect asks
+func redact(s string) string {
+	if redaction == nil {
+		return s
+	}
+	return redaction.mask(s)
+}
+
+func (r *redactor) mask(s string) string {
+	if r.Names {
+		r.mu.Lock()
+		s = hostnamePattern.ReplaceAllStringFunc(s, func(word string) string {
+			if pseudonym, ok := r.names[strings.ToLower(word)]; ok {
+				return pseudonym
+			}
+			return word
+		})
+		r.mu.Unlock()
+	}
+
+	if r.Addrs {
+		s = ipv4Pattern.ReplaceAllStringFunc(s, maskAddr)
+		s = ipv6Pattern.ReplaceAllStringFunc(s, func(word string) string {
+			// "from fd01::2: icmp_seq=1" takes the colon of the sentence along
+			if strings.HasSuffix(word, ":") && !strings.HasSuffix(word, "::") {
+				return maskAddr(word[:len(word)-1]) + ":"
+			}
+			return maskAddr(word)
+		})
+	}
+	return s
+}
+
+// maskAddr masks the host part of *s* if it is an address: the last octet of
+// an IPv4 address, the interface identifier (last 64 bits) of an IPv6 one
+func maskAddr(s string) string {
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil, ip.IsUnspecified():
+		return s
+	case ip.To4() != nil && !strings.Contains(s, ":"):
+		v4 := ip.To4()
+		return fmt.Sprintf("%d.%d.%d.x", v4[0], v4[1], v4[2])
+	default:
+		// the upper 64 bits, whose last groups are zeros, print as "<prefix>::"
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "x"
+	}
+}
+
+// redactResult is *res* with the addresses and names it holds masked, as
+// the redaction in effect asks
+func redactResult(res ProbeResult) ProbeResult {
+	if redaction == nil {
+		return res
+	}
+	res.Target, res.Peer, res.Detail = redact(res.Target), redact(res.Peer), redact(res.Detail)
+	res.Route = redactAll(res.Route)
+	res.Answers = redactAll(res.Answers)
+	res.Fields = redactFields(res.Fields)
+	if res.Timestamps != nil {
+		timestamps := make([]IPTimestamp, len(res.Timestamps))
+		for i, ts := range res.Timestamps {
+			ts.Addr = redact(ts.Addr)
+			timestamps[i] = ts
+		}
+		res.Timestamps = timestamps
+	}
+	if res.Extensions != nil {
+		extensions := make([]ICMPExtension, len(res.Extensions))
+		for i, ext := range res.Extensions {
+			if ext.Iface != nil {
+				iface := *ext.Iface
+				iface.Addr = redact(iface.Addr)
+				ext.Iface = &iface
+			}
+			extensions[i] = ext
+		}
+		res.Extensions = extensions
+	}
+	return res
+}
+
+// redactInfo is *info* with the destination, and how it was found, masked
+func redactInfo(info ICMPInfo) ICMPInfo {
+	if redaction == nil {
+		return info
+	}
+	info.IP, info.Source = redact(info.IP), redact(info.Source)
+	info.CNAMEs = redactAll(info.CNAMEs)
+	if info.NAT64 != nil {
+		nat64 := *info.NAT64
+		nat64.From = redact(nat64.From)
+		info.NAT64 = &nat64
+	}
+	return info
+}
+
+// redactNote is *note* masked, as the redaction in effect asks
+func redactNote(note Annotation) Annotation {
+	note.Message, note.Fields = redact(note.Message), redactFields(note.Fields)
+	return note
+}
+
+// redactAll masks every one of *ss*, into a new slice
+func redactAll(ss []string) []string {
+	if redaction == nil || ss == nil {
+		return ss
+	}
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = redact(s)
+	}
+	return out
+}
+
+// redactFields masks the values of *fields* as --redact asks
+func redactFields(fields map[string]string) map[string]string {
+	if redaction == nil || fields == nil {
+		return fields
+	}
+	out := make(map[string]string, len(fields))
+	for name, value := range fields {
+		out[name] = redact(value)
+	}
+	return out
+}
+
+// redactPrinter masks what it is given to print before its Printer renders it,
+// so that addresses are found in their fields rather than in the text or JSON
+type redactPrinter struct {
+	Printer
+}
+
+func (p *redactPrinter) Header(info ICMPInfo) {
+	p.Printer.Header(redactInfo(info))
+}
+
+func (p *redactPrinter) Probe(res ProbeResult) {
+	p.Printer.Probe(redactResult(res))
+}
+
+func (p *redactPrinter) Annotate(note Annotation) {
+	p.Printer.Annotate(redactNote(note))
+}
+
+func (p *redactPrinter) Interim(target string, stats *PingStats) {
+	p.Printer.Interim(redact(target), stats)
+}
+
+func (p *redactPrinter) Periodic(target string, window *PingStats, every time.Duration) {
+	p.Printer.Periodic(redact(target), window, every)
+}
+
+func (p *redactPrinter) Summary(target string, stats *PingStats) {
+	p.Printer.Summary(redact(target), stats)
+}
+
+func (p *redactPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.Printer.SummaryTable(redactAll(targets), stats)
+}
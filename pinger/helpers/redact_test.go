@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// setRedaction masks as *r* asks for the rest of the test
+func setRedaction(t *testing.T, r Redaction) {
+	t.Helper()
+	SetRedaction(r)
+	t.Cleanup(func() { redaction = nil })
+}
+
+func TestRedactAddrs(t *testing.T) {
+	setRedaction(t, Redaction{Addrs: true})
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"10.1.2.3", "10.1.2.x"},
+		{"from 10.1.2.3: icmp_seq=1", "from 10.1.2.x: icmp_seq=1"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::x"},
+		{"fd01::2", "fd01::x"},
+		{"from fd01::2: icmp_seq=1", "from fd01::x: icmp_seq=1"},
+		{"FD01::AB", "fd01::x"},
+		// The zone names an interface of this host, not the target
+		{"fe80::1%eth0", "fe80::x%eth0"},
+		// Nothing to mask: unspecified addresses, times, MAC addresses, numbers
+		{"::", "::"},
+		{"listening on [::]:8080", "listening on [::]:8080"},
+		{"0.0.0.0", "0.0.0.0"},
+		{"at 12:30:45", "at 12:30:45"},
+		{"aa:bb:cc:dd:ee:ff", "aa:bb:cc:dd:ee:ff"},
+		{"1.2.3", "1.2.3"},
+		{"", ""},
+	} {
+		if got := redact(tc.in); got != tc.want {
+			t.Errorf("redact(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedactHostnames(t *testing.T) {
+	setRedaction(t, Redaction{Names: true})
+
+	redactName("Router.Example.COM.")
+	redactName("db.example.com")
+	redactName("192.0.2.1")              // an address, not a name
+	redactName("https://db.example.com") // not a hostname
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"router.example.com", "host-1"},
+		{"ROUTER.example.com", "host-1"},
+		{"PINGERING router.example.com.", "PINGERING host-1."},
+		{"db.example.com via router.example.com", "host-2 via host-1"},
+		{"other.example.com", "other.example.com"},
+		// Addresses are left alone without last-octet
+		{"192.0.2.1", "192.0.2.1"},
+	} {
+		if got := redact(tc.in); got != tc.want {
+			t.Errorf("redact(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRedactOff(t *testing.T) {
+	res := ProbeResult{Target: "10.1.2.3", Peer: "fd01::2", Detail: "from fd01::2"}
+	if got := redactResult(res); got.Target != res.Target || got.Peer != res.Peer || got.Detail != res.Detail {
+		t.Errorf("redactResult without --redact: %+v", got)
+	}
+	if got := redact("10.1.2.3"); got != "10.1.2.3" {
+		t.Errorf("redact without --redact: %q", got)
+	}
+}
+
+func TestRedactResult(t *testing.T) {
+	setRedaction(t, Redaction{Addrs: true})
+
+	route := []string{"10.1.2.3", "10.4.5.6"}
+	res := redactResult(ProbeResult{
+		Target:     "fd00::5",
+		Peer:       "fd00::1",
+		Detail:     "Destination Host Unreachable from fd00::1",
+		Route:      route,
+		Timestamps: []IPTimestamp{{Addr: "10.7.8.9", MS: 1, Standard: true}},
+		Extensions: []ICMPExtension{{Class: 2, Iface: &ExtIface{Role: "incoming", Name: "ge-0/0/1", Addr: "192.0.2.77"}}},
+		Fields:     map[string]string{"gateway": "10.1.2.254"},
+	})
+
+	if res.Target != "fd00::x" || res.Peer != "fd00::x" || res.Detail != "Destination Host Unreachable from fd00::x" {
+		t.Errorf("target %q peer %q detail %q", res.Target, res.Peer, res.Detail)
+	}
+	if strings.Join(res.Route, " ") != "10.1.2.x 10.4.5.x" {
+		t.Errorf("route %v", res.Route)
+	}
+	if route[0] != "10.1.2.3" {
+		t.Errorf("the route of the result given was masked: %v", route)
+	}
+	if res.Timestamps[0].Addr != "10.7.8.x" || res.Extensions[0].Iface.Addr != "192.0.2.x" || res.Fields["gateway"] != "10.1.2.x" {
+		t.Errorf("timestamp %q, interface %q, gateway %q", res.Timestamps[0].Addr, res.Extensions[0].Iface.Addr, res.Fields["gateway"])
+	}
+	if res.Extensions[0].Iface.Name != "ge-0/0/1" {
+		t.Errorf("interface name %q, want it kept", res.Extensions[0].Iface.Name)
+	}
+}
+
+// TestRedactJSON checks the JSON output: its escapes must not hide an
+// address from the redaction, nor be taken for one
+func TestRedactJSON(t *testing.T) {
+	setRedaction(t, Redaction{Addrs: true, Names: true})
+	redactName("gw.example.com")
+
+	var out bytes.Buffer
+	printer, err := NewPrinter("json", DefaultRTTFormat, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer.Probe(ProbeResult{
+		Target: "fd00::5",
+		Peer:   "fd00::1",
+		Reason: ReasonUnreachableHost,
+		Detail: "<gw.example.com> said: >fd00::5 & 10.1.2.3 unreachable",
+	})
+
+	var got struct {
+		Target, Peer, Detail string
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", out.Bytes(), err)
+	}
+	if got.Target != "fd00::x" || got.Peer != "fd00::x" {
+		t.Errorf("target %q peer %q, want fd00::x", got.Target, got.Peer)
+	}
+	if want := "<host-1> said: >fd00::x & 10.1.2.x unreachable"; got.Detail != want {
+		t.Errorf("detail %q, want %q", got.Detail, want)
+	}
+	for _, leak := range []string{"fd00::5", "fd00::1", "10.1.2.3", "gw.example.com"} {
+		if bytes.Contains(out.Bytes(), []byte(leak)) {
+			t.Errorf("%s leaked: %s", leak, out.Bytes())
+		}
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	setRedaction(t, Redaction{Addrs: true})
+
+	var out bytes.Buffer
+	printer, err := NewPrinter("text", DefaultRTTFormat, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer.Header(ICMPInfo{IP: "10.1.2.3", Source: "10.1.2.250", Numeric: true, Privileged: true})
+	printer.Probe(ProbeResult{Target: "10.1.2.3", Peer: "10.1.2.3", Seq: 1, Bytes: 64, TTL: 64, RTT: 1})
+
+	for _, want := range []string{"PINGERING 10.1.2.x", "from 10.1.2.x", "64 bytes from 10.1.2.x: icmp_seq=1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("no %q in:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "10.1.2.3") || strings.Contains(out.String(), "10.1.2.250") {
+		t.Errorf("address leaked:\n%s", out.String())
+	}
+}
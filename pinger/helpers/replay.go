@@ -0,0 +1,300 @@
+package helpers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxReplayRecord is the longest line of a results file replayed
+const maxReplayRecord = 1 << 20
+
+// ReplayHandler reads a run written before, and prints it again as *info*
+// asks, with the statistics computed anew: a pcap capture, as --pcap or
+// tcpdump -w write, is matched as AnalyzeHandler does, and a results file,
+// the JSON lines of --format json or --log-results, gzipped or not, has its
+// probes printed in the order it lists them. Runs can so be compared in
+// another format, or after a change to pinger. It returns the exit status.
+func ReplayHandler(path string, info ICMPInfo) int {
+	capture, err := isCapture(path)
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+	if capture {
+		return AnalyzeHandler(path, info)
+	}
+
+	// A first pass for the targets, for the lines of several to be tagged, the
+	// ID of the run, and where its last probe is, for the notes after it to
+	// come after the statistics, as they did
+	var ips []string
+	targets := make(map[string]*PingStats)
+	records, lastProbe := 0, 0
+	err = readResults(path, func(typ string, line []byte) {
+		var record struct {
+			RunID  string `json:"run_id"`
+			Target string `json:"target"`
+		}
+		if json.Unmarshal(line, &record) != nil {
+			return
+		}
+		if records++; records == 1 && record.RunID != "" {
+			RunID = record.RunID
+		}
+		if typ == "probe" {
+			lastProbe = records
+		}
+		if (typ == "start" || typ == "probe") && targets[record.Target] == nil {
+			ips = append(ips, record.Target)
+			stats := newPingStats(info)
+			targets[record.Target] = &stats
+		}
+	})
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+	if len(ips) == 0 {
+		slog.Error(fmt.Sprintf("%s: no probes in the results", path))
+		return ExitNoReply
+	}
+
+	printer, err := newPrinter(info.Format, info.RTT, stdout, len(ips) > 1)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	// A target is headed as its start record says, or else as its first
+	// probe, for files of --log-results, which have none
+	headed := make(map[string]bool)
+	head := func(targetInfo ICMPInfo) {
+		if !headed[targetInfo.IP] {
+			headed[targetInfo.IP] = true
+			printer.Header(targetInfo)
+		}
+	}
+
+	var trailing []Annotation
+	records = 0
+	err = readResults(path, func(typ string, line []byte) {
+		records++
+		switch typ {
+		case "start":
+			var start jsonStart
+			if err := json.Unmarshal(line, &start); err != nil {
+				slog.Warn("skipping a record", "type", typ, "err", err)
+				return
+			}
+			targetInfo := info
+			targetInfo.IP, targetInfo.Transport, targetInfo.Port = start.Target, start.Transport, start.Port
+			targetInfo.Iface, targetInfo.Source = start.Iface, start.Source
+			targetInfo.TTL, targetInfo.KernelTTL, targetInfo.TOS = start.TTL, start.KernelTTL, start.TOS
+			targetInfo.Privileged, targetInfo.CNAMEs, targetInfo.HostsFile = start.Raw, start.CNAMEs, start.HostsFile
+			targetInfo.NAT64 = start.NAT64
+			startSize = start.DataBytes
+			head(targetInfo)
+
+		case "probe":
+			var probe jsonProbe
+			if err := json.Unmarshal(line, &probe); err != nil {
+				slog.Warn("skipping a record", "type", typ, "err", err)
+				return
+			}
+			res := replayedResult(probe)
+			if !headed[res.Target] {
+				targetInfo := info
+				targetInfo.IP, targetInfo.Transport, targetInfo.TTL = res.Target, res.Transport, res.TTL
+				targetInfo.Privileged = true
+				head(targetInfo)
+			}
+
+			stats := targets[res.Target]
+			if !res.Duplicate && res.Reason != ReasonMismatchedReply && res.Reason != ReasonNeighborDiscovery {
+				stats.transmitted++
+			}
+			stats.record(res)
+			printer.Probe(res)
+
+		case "annotation":
+			var note jsonAnnotation
+			if err := json.Unmarshal(line, &note); err != nil {
+				slog.Warn("skipping a record", "type", typ, "err", err)
+				return
+			}
+			annotation := Annotation{Time: note.Time, Kind: note.Kind, Message: note.Message, Fields: note.Fields}
+			if records > lastProbe {
+				trailing = append(trailing, annotation)
+				return
+			}
+			printer.Annotate(annotation)
+		}
+		// Statistics, interim or not, are computed anew
+	})
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+
+	stats := make([]*PingStats, len(ips))
+	for i, ip := range ips {
+		stats[i] = targets[ip]
+	}
+	if len(ips) == 1 {
+		printer.Summary(ips[0], stats[0])
+	} else {
+		printer.SummaryTable(ips, stats)
+	}
+	for _, annotation := range trailing {
+		printer.Annotate(annotation)
+	}
+
+	return exitStatus(stats...)
+}
+
+// isCapture reports whether the file *path* is a pcap or pcapng capture,
+// rather than a results file
+func isCapture(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		return false, nil
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(magic[:]) {
+		case pcapMagicMicro, pcapMagicNano, pcapngMagic:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readResults calls *fn* with every record of the results file *path*, and
+// its type, gunzipping the file if need be
+func readResults(path string, fn func(typ string, line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer zr.Close()
+		r = bufio.NewReader(zr)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxReplayRecord)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var record struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("%s:%d: not a results file: %v", path, n, err)
+		}
+		fn(record.Type, line)
+	}
+	if err := scanner.Err(); err != nil {
+		// A file cut short, e.g. by a crash, is still worth the records before
+		slog.Warn("results end early", "path", path, "err", err)
+	}
+	return nil
+}
+
+// replayedResult is the probe result a JSON record of one is of
+func replayedResult(probe jsonProbe) ProbeResult {
+	res := ProbeResult{
+		Transport: probe.Transport,
+		Target:    probe.Target,
+		Seq:       probe.Seq,
+		Peer:      probe.Peer,
+		Bytes:     probe.Bytes,
+		TTL:       probe.TTL,
+		Reason:    probe.Reason,
+		Detail:    probe.Detail,
+		Time:      probe.Time,
+		Duplicate: probe.Duplicate,
+		Route:     probe.Route,
+		HWAddr:    probe.HWAddr,
+		Answers:   probe.Answers,
+		Status:    probe.Status,
+		Fields:    probe.Fields,
+		Clock:     probe.Clock,
+
+		TimestampsLost: probe.TimestampsLost,
+		Extensions:     probe.Extensions,
+	}
+	if probe.RTT != nil {
+		res.RTT = *probe.RTT
+	}
+	if probe.TOS != nil {
+		res.TOS, res.TOSKnown, res.ECNChanged = *probe.TOS, true, probe.ECNChanged
+	}
+	for _, stamp := range probe.Timestamps {
+		res.Timestamps = append(res.Timestamps, IPTimestamp{Addr: stamp.Addr, MS: stamp.MS, Standard: stamp.Standard})
+	}
+	for _, name := range slices.Sorted(maps.Keys(probe.Phases)) {
+		res.Phases = append(res.Phases, Phase{Name: name, RTT: probe.Phases[name]})
+	}
+	return liftExtFields(res)
+}
+
+// liftExtFields moves what extResult puts among the fields of a result, for
+// sinks, back to where it came from
+func liftExtFields(res ProbeResult) ProbeResult {
+	if len(res.Fields) == 0 {
+		return res
+	}
+	fields := maps.Clone(res.Fields)
+	for name, value := range map[string]*int{"bytes": &res.Bytes, "ttl": &res.TTL, "status": &res.Status} {
+		if n, err := strconv.Atoi(fields[name]); err == nil && *value == 0 {
+			*value = n
+			delete(fields, name)
+		}
+	}
+	if hwaddr, ok := fields["hwaddr"]; ok && res.HWAddr == "" {
+		res.HWAddr = hwaddr
+		delete(fields, "hwaddr")
+	}
+	if clock, ok := fields["clock"]; ok && res.Clock == "" {
+		res.Clock = clock
+		delete(fields, "clock")
+	}
+	if answers, ok := fields["answers"]; ok && len(res.Answers) == 0 {
+		res.Answers = strings.Split(answers, ",")
+		delete(fields, "answers")
+	}
+	if tos, err := strconv.ParseUint(fields["tos"], 0, 8); err == nil && !res.TOSKnown {
+		res.TOS, res.TOSKnown = int(tos), true
+		delete(fields, "tos")
+	}
+	res.Fields = fields
+	if len(fields) == 0 {
+		res.Fields = nil
+	}
+	return res
+}
@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ParseDNSServer checks a --dns resolver, an IP address with an optional port
+// ("9.9.9.9", "[2620:fe::fe]:53"), and returns it as "host:port"
+func ParseDNSServer(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "53"
+	}
+
+	if addr, _ := SplitZone(host); net.ParseIP(addr) == nil {
+		return "", fmt.Errorf("invalid --dns %q: want the IP address of a resolver, with an optional port", server)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// lookupResolver is the resolver of name lookups: the system's, or one that
+// sends every query to *server* ("host:port") instead, if set
+func lookupResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// dotResolver is a resolver sending every query to *server* ("host[:port]",
+// port 853 by default) over DNS-over-TLS, RFC 7858, checking its certificate
+// against the host
+func dotResolver(server string) *net.Resolver {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "853"
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		// Not a net.PacketConn: the resolver frames queries as over TCP
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := tls.Dialer{Config: &tls.Config{ServerName: host}}
+			return dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		},
+	}
+}
+
+// dohLookupHost is the A and AAAA records of *host*, as the DNS-over-HTTPS
+// server at *url* answers, RFC 8484
+func dohLookupHost(ctx context.Context, url, host string) ([]string, error) {
+	var (
+		addrs   []string
+		lastErr error
+	)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := dohQuery(ctx, url, host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addrs = append(addrs, answers...)
+	}
+
+	if len(addrs) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("lookup %s on %s: no such host", host, url)
+	}
+	return addrs, nil
+}
+
+// dohQuery POSTs a query for *host* to the DNS-over-HTTPS server at *url*, and
+// returns the addresses answered
+func dohQuery(ctx context.Context, url, host string, qtype dnsmessage.Type) ([]string, error) {
+	query, _, err := dnsQuery(host, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lookup %s on %s: %s", host, url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(body); err != nil {
+		return nil, fmt.Errorf("lookup %s on %s: %v", host, url, err)
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("lookup %s on %s: %s", host, url, rcodeName(msg.Header.RCode))
+	}
+
+	var addrs []string
+	for _, answer := range msg.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		}
+	}
+	return addrs, nil
+}
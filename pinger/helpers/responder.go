@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// DefaultResponderPort is the UDP port pinger responder listens on by default
+const DefaultResponderPort = 7047
+
+// responderMagic starts the datagrams that ask the responder for a reply of a
+// given size; anything else is echoed back as it came
+var responderMagic = []byte("PNGA")
+
+// responderHeaderLen is the magic, the sequence number and the wanted reply size
+const responderHeaderLen = 8
+
+// maxUDPPayload is the largest payload of an IPv4 UDP datagram
+const maxUDPPayload = 65507
+
+// responderRequest is a datagram of probe *seq*, *size* bytes long, asking the
+// responder for a reply of *replySize* bytes
+func responderRequest(seq, size, replySize int) []byte {
+	payload := make([]byte, max(size, responderHeaderLen))
+	copy(payload, responderMagic)
+	binary.BigEndian.PutUint16(payload[4:], uint16(seq))
+	binary.BigEndian.PutUint16(payload[6:], uint16(replySize))
+	return payload
+}
+
+// parseResponderHeader is the sequence number and wanted reply size of a
+// responder datagram. ok is false for datagrams without the header.
+func parseResponderHeader(b []byte) (seq, replySize int, ok bool) {
+	if len(b) < responderHeaderLen || !bytes.Equal(b[:4], responderMagic) {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(b[4:])), int(binary.BigEndian.Uint16(b[6:])), true
+}
+
+// responderReply is the answer to datagram *req*: its header, padded to the
+// size it asks for, or *req* itself if it has no header
+func responderReply(req []byte) []byte {
+	_, size, ok := parseResponderHeader(req)
+	if !ok {
+		return req
+	}
+
+	reply := make([]byte, min(max(size, responderHeaderLen), maxUDPPayload))
+	copy(reply, req[:responderHeaderLen])
+	return reply
+}
+
+// ResponderHandler answers UDP probes on *listen* ("host:port"), until killed:
+// pinger udp --asymmetric's requests get replies of the size they ask for, any
+// other datagram is echoed back
+func ResponderHandler(listen string) {
+	conn, err := net.ListenPacket("udp", listen)
+	if err != nil {
+		slog.Error("listening", "addr", listen, "err", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	fmt.Printf("pinger responder listening on udp %s\n", conn.LocalAddr())
+
+	buf := make([]byte, maxUDPPayload)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			slog.Error("receiving", "err", err)
+			continue
+		}
+
+		if _, err := conn.WriteTo(responderReply(buf[:n]), peer); err != nil {
+			slog.Error("answering", "peer", peer, "err", err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// restMaxRequest is the largest request body ServeREST reads: they are small
+const restMaxRequest = 64 << 10
+
+// ServeREST serves an HTTP API on *listen* for other programs to start ping
+// jobs, stop them, and fetch their results, until killed: the JSON
+// alternative to ServeGRPC, for simpler clients. Jobs run as *config* asks,
+// with the options of the job on top.
+//
+//	POST /jobs                 start a job, as a restJobRequest asks
+//	GET  /jobs                 every job kept
+//	GET  /jobs/{id}            a job, and the statistics of its results so far
+//	GET  /jobs/{id}/results    its results, from the ?since=<n>th on
+//	POST /jobs/{id}/stop       stop it before it sent all its Echo Requests
+func ServeREST(listen string, config JobConfig) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		slog.Error("listening", "addr", listen, "err", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("pinger api listening on http %s\n", ln.Addr())
+	notifyReady(fmt.Sprintf("listening on http %s", ln.Addr()), nil)
+
+	api := &restServer{jobs: newJobManager(config)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", api.startJob)
+	mux.HandleFunc("GET /jobs", api.listJobs)
+	mux.HandleFunc("GET /jobs/{id}", api.getJob)
+	mux.HandleFunc("GET /jobs/{id}/results", api.getResults)
+	mux.HandleFunc("POST /jobs/{id}/stop", api.stopJob)
+
+	err = http.Serve(ln, mux)
+	slog.Error("serving", "err", err)
+	os.Exit(1)
+}
+
+// restJobRequest is the body of POST /jobs: the zero value of every field but
+// Target is its default, as for the StartJobRequest of pinger.proto
+type restJobRequest struct {
+	Target     string `json:"target"`
+	Count      int    `json:"count"`
+	IntervalMs int64  `json:"interval_ms"`
+	TimeoutMs  int64  `json:"timeout_ms"`
+	IPv6       bool   `json:"ipv6"`
+	Iface      string `json:"iface"`
+	TTL        int    `json:"ttl"`
+}
+
+// restJob is a job, as the API returns it
+type restJob struct {
+	ID      string      `json:"id"`
+	Target  string      `json:"target"`  // as asked for
+	Address string      `json:"address"` // address pinged
+	State   string      `json:"state"`
+	Error   string      `json:"error,omitempty"`
+	Started time.Time   `json:"started"`
+	Ended   *time.Time  `json:"ended,omitempty"`
+	Summary restSummary `json:"summary"`
+}
+
+// restSummary is the statistics of the results of a job
+type restSummary struct {
+	Transmitted int      `json:"transmitted"`
+	Received    int      `json:"received"`
+	Errors      int      `json:"errors"`
+	Loss        float64  `json:"loss_pct"`
+	Min         *float64 `json:"rtt_min_ms,omitempty"`
+	Avg         *float64 `json:"rtt_avg_ms,omitempty"`
+	Max         *float64 `json:"rtt_max_ms,omitempty"`
+	StdDev      *float64 `json:"rtt_stddev_ms,omitempty"`
+	Jitter      *float64 `json:"jitter_ms,omitempty"`
+}
+
+// restResult is the outcome of an Echo Request of a job, with a nil RTT if
+// it got no reply
+type restResult struct {
+	Seq       int               `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Peer      string            `json:"peer,omitempty"`
+	RTT       *float64          `json:"rtt_ms,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Detail    string            `json:"detail,omitempty"`
+	Duplicate bool              `json:"duplicate,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// restResults is the body of GET /jobs/{id}/results: Next is the ?since= to
+// poll for the results that come after these
+type restResults struct {
+	Job     restJob      `json:"job"`
+	Results []restResult `json:"results"`
+	Next    int          `json:"next"`
+}
+
+// restServer answers the requests of API clients, on its jobs
+type restServer struct {
+	jobs *jobManager
+}
+
+func (api *restServer) startJob(w http.ResponseWriter, r *http.Request) {
+	var req restJobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, restMaxRequest))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("bad job request: %v", err))
+		return
+	}
+
+	j, err := api.jobs.start(jobSpec{
+		Target:   req.Target,
+		Count:    req.Count,
+		Interval: time.Duration(req.IntervalMs) * time.Millisecond,
+		Timeout:  time.Duration(req.TimeoutMs) * time.Millisecond,
+		IPv6:     req.IPv6,
+		Iface:    req.Iface,
+		TTL:      req.TTL,
+	})
+	if errors.Is(err, errTooManyJobs) {
+		writeRESTError(w, http.StatusTooManyRequests, err)
+		return
+	} else if err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeREST(w, http.StatusCreated, newRESTJob(j.status()))
+}
+
+func (api *restServer) listJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := []restJob{}
+	for _, j := range api.jobs.list() {
+		jobs = append(jobs, newRESTJob(j.status()))
+	}
+	writeREST(w, http.StatusOK, jobs)
+}
+
+func (api *restServer) getJob(w http.ResponseWriter, r *http.Request) {
+	if j := api.job(w, r); j != nil {
+		writeREST(w, http.StatusOK, newRESTJob(j.status()))
+	}
+}
+
+func (api *restServer) getResults(w http.ResponseWriter, r *http.Request) {
+	j := api.job(w, r)
+	if j == nil {
+		return
+	}
+	since := 0
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		if since, err = strconv.Atoi(value); err != nil || since < 0 {
+			writeRESTError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q: must be a result number", value))
+			return
+		}
+	}
+
+	// The status first, so that a job over has all of its results in these
+	status := j.status()
+	results, _, _ := j.next(since)
+	out := restResults{Job: newRESTJob(status), Results: []restResult{}, Next: since + len(results)}
+	for _, res := range results {
+		out.Results = append(out.Results, newRESTResult(res))
+	}
+	writeREST(w, http.StatusOK, out)
+}
+
+func (api *restServer) stopJob(w http.ResponseWriter, r *http.Request) {
+	j := api.jobs.stop(r.PathValue("id"))
+	if j == nil {
+		writeRESTError(w, http.StatusNotFound, fmt.Errorf("no job %q", r.PathValue("id")))
+		return
+	}
+	writeREST(w, http.StatusOK, newRESTJob(j.status()))
+}
+
+// job is the job of the request, or nil once the request is answered with 404
+func (api *restServer) job(w http.ResponseWriter, r *http.Request) *job {
+	j := api.jobs.get(r.PathValue("id"))
+	if j == nil {
+		writeRESTError(w, http.StatusNotFound, fmt.Errorf("no job %q", r.PathValue("id")))
+	}
+	return j
+}
+
+// writeREST answers with *body*, as JSON
+func writeREST(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
+
+// writeRESTError answers with *err*, as {"error": "..."}
+func writeRESTError(w http.ResponseWriter, code int, err error) {
+	writeREST(w, code, map[string]string{"error": err.Error()})
+}
+
+// newRESTJob is *status* as the API returns it
+func newRESTJob(status jobStatus) restJob {
+	out := restJob{
+		ID:      status.ID,
+		Target:  status.Spec.Target,
+		Address: status.Target,
+		State:   status.State,
+		Error:   status.Error,
+		Started: status.Started,
+		Summary: restSummary{
+			Transmitted: status.Summary.Transmitted,
+			Received:    status.Summary.Received,
+			Errors:      status.Summary.Errors,
+			Loss:        status.Summary.Loss,
+		},
+	}
+	if !status.Ended.IsZero() {
+		out.Ended = &status.Ended
+	}
+	if status.Summary.Received > 0 {
+		out.Summary.Min, out.Summary.Avg, out.Summary.Max = msPointer(status.Summary.Min), msPointer(status.Summary.Avg), msPointer(status.Summary.Max)
+		out.Summary.StdDev = msPointer(status.Summary.StdDev)
+	}
+	if status.Summary.Received > 1 {
+		out.Summary.Jitter = msPointer(status.Summary.Jitter)
+	}
+	return out
+}
+
+// newRESTResult is *res* as the API returns it
+func newRESTResult(res ext.Result) restResult {
+	out := restResult{
+		Seq:       res.Seq,
+		Time:      res.Time,
+		Peer:      res.Peer,
+		Reason:    res.Reason,
+		Detail:    res.Detail,
+		Duplicate: res.Duplicate,
+	}
+	if len(res.Fields) > 0 {
+		out.Fields = res.Fields
+	}
+	if res.Reason == ext.ReasonNone {
+		out.RTT = msPointer(res.RTT)
+	}
+	return out
+}
+
+// msPointer is *d* in milliseconds, for an optional JSON field
+func msPointer(d time.Duration) *float64 {
+	ms := durationMs(d)
+	return &ms
+}
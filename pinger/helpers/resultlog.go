@@ -0,0 +1,253 @@
+package helpers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// resultLogStamp is the suffix of a rotated result log: when it was rotated
+const resultLogStamp = "20060102T150405Z"
+
+// ResultLogOptions are how SetResultLog rotates its file
+type ResultLogOptions struct {
+	MaxSize  int64         // bytes a file may hold before it is rotated, 0 for no limit
+	Every    time.Duration // how long a file is written to before it is rotated, 0 for no limit
+	Keep     int           // rotated files kept, the oldest removed beyond, 0 for all
+	Compress bool          // gzip rotated files
+}
+
+// SetResultLog appends every result, event and summary to the file *path*,
+// as the JSON lines of --format json, whatever the output format, rotating it
+// as *opts* says: the file is renamed with the time of its rotation appended,
+// gzipped if asked, and a new one started, so that a capture can run
+// unattended for weeks without filling the disk.
+func SetResultLog(path string, opts ResultLogOptions) error {
+	f, err := openResultLog(path, opts)
+	if err != nil {
+		return fmt.Errorf("--log-results: %v", err)
+	}
+	sinks = append(sinks, namedSink{name: "result log", Sink: &resultLogSink{file: f, enc: newRecordEncoder(f)}})
+	return nil
+}
+
+// resultLogSink is the sink of SetResultLog
+type resultLogSink struct {
+	file *rotatingFile
+	enc  *recordEncoder
+}
+
+func (s *resultLogSink) Result(res ext.Result) {
+	probe := probeResult(res.Transport, res)
+	probe.Target = res.Target
+	s.encode(newJSONProbe(liftExtFields(probe), DefaultRTTFormat))
+}
+
+func (s *resultLogSink) Event(ev ext.Event) {
+	s.encode(jsonAnnotation{Type: "annotation", Time: ev.Time, Kind: ev.Kind, Message: ev.Message, Fields: ev.Fields})
+}
+
+func (s *resultLogSink) Summary(sum ext.Summary) {
+	out := jsonSummary{
+		Type:        "summary",
+		Target:      sum.Target,
+		Transmitted: sum.Transmitted,
+		Received:    sum.Received,
+		Errors:      sum.Errors,
+		Loss:        sum.Loss,
+	}
+	ms := func(d time.Duration) *float64 {
+		rounded := DefaultRTTFormat.round(durationMs(d))
+		return &rounded
+	}
+	if sum.Received > 0 {
+		out.Min, out.Avg, out.Max = ms(sum.Min), ms(sum.Avg), ms(sum.Max)
+		out.StdDev, out.EWMA = ms(sum.StdDev), ms(sum.EWMA)
+	}
+	if sum.Received > 1 {
+		out.Jitter = ms(sum.Jitter)
+	}
+	s.encode(out)
+}
+
+func (s *resultLogSink) Close() error {
+	return s.file.Close()
+}
+
+// encode writes a record, reporting the first failure only, not every one
+func (s *resultLogSink) encode(record any) {
+	if err := s.enc.Encode(record); err != nil && !s.file.failed {
+		s.file.failed = true
+		slog.Error("writing the result log", "err", err)
+	}
+}
+
+// rotatingFile is a file appended to, rotated as its options say. Every
+// Write goes whole to one file, so that records are never split between two.
+type rotatingFile struct {
+	path   string
+	opts   ResultLogOptions
+	file   *os.File
+	size   int64
+	opened time.Time
+	failed bool // a write failed: reported once
+
+	pending sync.WaitGroup // compressions of rotated files under way
+	prune   sync.Mutex     // held while removing the rotated files beyond opts.Keep
+}
+
+func openResultLog(path string, opts ResultLogOptions) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file, to append to what it holds already, if anything
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size, r.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.file == nil {
+		return 0, fmt.Errorf("%s is closed", r.path)
+	}
+	full := r.opts.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxSize
+	old := r.opts.Every > 0 && time.Since(r.opened) >= r.opts.Every
+	if full || old {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("rotating %s: %v", r.path, err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the file with the time appended, and starts a new one. The
+// file rotated is compressed, and the oldest removed, in the background.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	rotated := r.path + "." + time.Now().UTC().Format(resultLogStamp)
+	for i := 1; exists(rotated) || exists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s.%s-%d", r.path, time.Now().UTC().Format(resultLogStamp), i)
+	}
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		if r.opts.Compress {
+			if err := compressFile(rotated); err != nil {
+				slog.Error("compressing the result log", "file", rotated, "err", err)
+			}
+		}
+		r.removeOldest()
+	}()
+	return nil
+}
+
+// removeOldest removes the rotated files beyond opts.Keep, oldest first: the
+// time appended to their names sorts them
+func (r *rotatingFile) removeOldest() {
+	if r.opts.Keep == 0 {
+		return
+	}
+	r.prune.Lock()
+	defer r.prune.Unlock()
+
+	rotated, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	rotated = slices.DeleteFunc(rotated, func(name string) bool {
+		return strings.HasSuffix(name, ".tmp")
+	})
+	slices.Sort(rotated)
+	for len(rotated) > r.opts.Keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			slog.Warn("removing an old result log", "file", rotated[0], "err", err)
+		}
+		rotated = rotated[1:]
+	}
+}
+
+// Close closes the file, once the files rotated are compressed
+func (r *rotatingFile) Close() error {
+	r.pending.Wait()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// compressFile replaces the file *path* with *path*.gz, written to a
+// temporary file first, for a crash never to leave half of it
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// exists reports whether there is a file at *path*
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,356 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	rotationSlower = 1.2 // a member is slow when its mean RTT is 20% above the fastest's
+	rotationBias   = 0.1 // rotation follows latency when clients see 10% more or less than an even spread
+)
+
+// RotationOptions are the settings of `pinger rotation`
+type RotationOptions struct {
+	Every  time.Duration // time between lookups
+	Server string        // resolver to ask ("host:port"), "" for the first of /etc/resolv.conf
+	IPv6   bool          // ask for AAAA records, rather than A
+}
+
+// rotationMember is one address the hostname resolved to, at some point
+type rotationMember struct {
+	addr   string
+	first  int // lookups it came first in: the address clients connect to
+	listed int // lookups it was in
+	stats  PingStats
+}
+
+// rotationVerdict is whether the order of the answers follows latency
+type rotationVerdict struct {
+	Lookups  int      `json:"lookups"`   // lookups whose first address answered our probes
+	Rotated  bool     `json:"rotated"`   // the first address changed between lookups
+	Weighted float64  `json:"client_ms"` // mean RTT clients see, going by the first address of each lookup
+	Even     float64  `json:"even_ms"`   // mean RTT of the members, as an even spread would see
+	Fastest  float64  `json:"fastest_ms"`
+	Slow     float64  `json:"slow_pct"`              // lookups that sent clients to a slow member
+	Pearson  *float64 `json:"correlation,omitempty"` // of the share of lookups of members and their mean RTT
+	Message  string   `json:"message"`
+}
+
+// RotationHandler resolves *host* every opts.Every, info.CNT times, and each
+// time sends an Echo Request to every address seen so far. It then reports
+// whether the address the resolver lists first, which clients connect to,
+// tends to be a slow one: whether DNS round robin sends users to slow members.
+// The resolver is asked directly, as the system's would sort the answers.
+// It returns the exit status.
+func RotationHandler(host string, info ICMPInfo, opts RotationOptions) int {
+	redactName(host)
+
+	printer, err := newRotationPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		return ExitUsage
+	}
+
+	var members []*rotationMember
+	index := make(map[string]*rotationMember)
+	var d *demux // opened once the first address is known
+
+	stop := onInterrupt(nil)
+
+	printer.header(host, info.CNT, opts)
+
+	for lookup := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		if lookup > 0 {
+			stop.sleep(opts.Every)
+		}
+
+		answer, err := queryAddrs(host, opts.IPv6, opts.Server)
+		if err != nil {
+			printer.failed(lookup, err)
+			continue
+		}
+
+		for i, addr := range answer {
+			m, ok := index[addr]
+			if !ok {
+				if d == nil {
+					info.IP = addr
+					if d, err = newDemux(info, opts.IPv6); err != nil {
+						slog.Error(err.Error())
+						return ExitUsage
+					}
+					defer d.close()
+					d.done = stop.done
+				}
+
+				m = &rotationMember{addr: addr}
+				index[addr] = m
+				members = append(members, m)
+			}
+			if i == 0 {
+				m.first++
+			}
+			m.listed++
+		}
+
+		results := make([]ProbeResult, len(members))
+		parallel(len(members), len(members), func(i int) {
+			results[i] = d.probe(members[i].addr, lookup)
+		})
+		if stop.stopped() {
+			break
+		}
+		for i, m := range members {
+			m.stats.transmitted++
+			m.stats.record(results[i])
+		}
+
+		printer.lookup(lookup, answer, members, results)
+	}
+
+	printer.summary(host, members, rotationVerdictOf(members))
+
+	all := make([]*PingStats, len(members))
+	for i, m := range members {
+		all[i] = &m.stats
+	}
+	return exitStatus(all...)
+}
+
+// rotationVerdictOf compares the RTTs clients see, following the first
+// address of each lookup, with those of the members spread evenly
+func rotationVerdictOf(members []*rotationMember) rotationVerdict {
+	var v rotationVerdict
+
+	var answered []*rotationMember
+	firsts := make(map[string]bool)
+	for _, m := range members {
+		if m.first > 0 {
+			firsts[m.addr] = true
+		}
+		if m.stats.received > 0 {
+			m.stats.finalStats()
+			answered = append(answered, m)
+		}
+	}
+	v.Rotated = len(firsts) > 1
+
+	if len(answered) < 2 {
+		v.Message = "fewer than two addresses answered: there are no latencies to compare"
+		return v
+	}
+
+	v.Fastest = answered[0].stats.mean
+	for _, m := range answered {
+		v.Fastest = math.Min(v.Fastest, m.stats.mean)
+		v.Even += m.stats.mean / float64(len(answered))
+		v.Lookups += m.first
+	}
+	if v.Lookups == 0 {
+		v.Message = "no lookup listed first an address that answered"
+		return v
+	}
+
+	var slow int
+	for _, m := range answered {
+		v.Weighted += m.stats.mean * float64(m.first) / float64(v.Lookups)
+		if m.stats.mean > v.Fastest*rotationSlower {
+			slow += m.first
+		}
+	}
+	v.Slow = float64(slow) / float64(v.Lookups) * 100
+
+	if len(answered) > 2 {
+		shares, rtts := make([]float64, len(answered)), make([]float64, len(answered))
+		for i, m := range answered {
+			shares[i], rtts[i] = float64(m.first), m.stats.mean
+		}
+		if r, ok := pearson(shares, rtts); ok {
+			v.Pearson = &r
+		}
+	}
+
+	switch {
+	case !v.Rotated:
+		v.Message = fmt.Sprintf("the first address never changed over %d lookups: there is no rotation to judge", v.Lookups)
+	case v.Weighted > v.Even*(1+rotationBias):
+		v.Message = "the rotation favours slow members: clients see higher RTTs than an even spread would give"
+	case v.Weighted < v.Even*(1-rotationBias):
+		v.Message = "the rotation favours fast members: clients see lower RTTs than an even spread would give"
+	default:
+		v.Message = "the rotation does not follow latency: clients see the RTTs of an even spread"
+	}
+	return v
+}
+
+// pearson is the correlation coefficient of *x* and *y*, if neither is constant
+func pearson(x, y []float64) (float64, bool) {
+	n := float64(len(x))
+	var mx, my float64
+	for i := range x {
+		mx += x[i] / n
+		my += y[i] / n
+	}
+
+	var sxy, sxx, syy float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// rotationPrinter renders a rotation check as text, or as JSON lines
+type rotationPrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+	rtt RTTFormat
+}
+
+func newRotationPrinter(format string, rtt RTTFormat, w io.Writer) (*rotationPrinter, error) {
+	switch format {
+	case "", "text":
+		return &rotationPrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &rotationPrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *rotationPrinter) header(host string, lookups int, opts RotationOptions) {
+	host = redact(host)
+	if p.enc != nil {
+		if lookups == Continuous {
+			lookups = 0
+		}
+		p.enc.Encode(struct {
+			Type    string  `json:"type"`
+			Host    string  `json:"host"`
+			Lookups int     `json:"lookups,omitempty"` // none if Continuous
+			Every   float64 `json:"every_s"`
+		}{"rotation_start", host, lookups, opts.Every.Seconds()})
+		return
+	}
+
+	if lookups == Continuous {
+		fmt.Fprintf(p.w, "RESOLVING %s: every %v until interrupted, pinging every address seen\n", host, opts.Every)
+		return
+	}
+	fmt.Fprintf(p.w, "RESOLVING %s: %d lookups, every %v, pinging every address seen\n", host, lookups, opts.Every)
+}
+
+func (p *rotationPrinter) failed(lookup int, err error) {
+	msg := redact(err.Error())
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type   string `json:"type"`
+			Lookup int    `json:"lookup"`
+			Error  string `json:"error"`
+		}{"rotation_lookup", lookup, msg})
+		return
+	}
+
+	fmt.Fprintf(p.w, "lookup %d: %s\n", lookup, msg)
+}
+
+// lookup prints the answer of one lookup, and the outcome of the probe of
+// every member
+func (p *rotationPrinter) lookup(lookup int, answer []string, members []*rotationMember, results []ProbeResult) {
+	answer = redactAll(answer)
+	if p.enc != nil {
+		rtts := make(map[string]*float64, len(members))
+		for i, m := range members {
+			addr := redact(m.addr)
+			rtts[addr] = nil
+			if results[i].Reason == ReasonNone {
+				rtt := p.rtt.round(results[i].RTT)
+				rtts[addr] = &rtt
+			}
+		}
+		p.enc.Encode(struct {
+			Type   string              `json:"type"`
+			Lookup int                 `json:"lookup"`
+			Answer []string            `json:"answer"`
+			RTTs   map[string]*float64 `json:"rtt_ms"`
+		}{"rotation_lookup", lookup, answer, rtts})
+		return
+	}
+
+	fmt.Fprintf(p.w, "lookup %d: %s first of %d:", lookup, answer[0], len(answer))
+	for i, m := range members {
+		if results[i].Reason == ReasonNone {
+			fmt.Fprintf(p.w, " %s %s", redact(m.addr), p.rtt.format(results[i].RTT))
+		} else {
+			fmt.Fprintf(p.w, " %s %s", redact(m.addr), results[i].Reason)
+		}
+		if i < len(members)-1 {
+			fmt.Fprint(p.w, ",")
+		}
+	}
+	fmt.Fprintln(p.w)
+}
+
+func (p *rotationPrinter) summary(host string, members []*rotationMember, v rotationVerdict) {
+	host = redact(host)
+	if p.enc != nil {
+		for _, m := range members {
+			out := newJSONSummary(redact(m.addr), &m.stats, p.rtt)
+			p.enc.Encode(struct {
+				jsonSummary
+				First  int `json:"first"`
+				Listed int `json:"listed"`
+			}{out, m.first, m.listed})
+		}
+		v.Weighted, v.Even, v.Fastest = p.rtt.round(v.Weighted), p.rtt.round(v.Even), p.rtt.round(v.Fastest)
+		v.Slow = math.Round(v.Slow*10) / 10
+		if v.Pearson != nil {
+			r := math.Round(*v.Pearson*100) / 100
+			v.Pearson = &r
+		}
+		p.enc.Encode(struct {
+			Type string `json:"type"`
+			Host string `json:"host"`
+			rotationVerdict
+		}{"rotation_summary", host, v})
+		return
+	}
+
+	fmt.Fprintf(p.w, "\n--- %s rotation statistics ---\n", host)
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ADDRESS\tFIRST\tLISTED\tSENT\tRECV\tLOSS\tAVG\tSTDDEV")
+	for _, m := range members {
+		avg, stddev := "-", "-"
+		if m.stats.received > 0 {
+			m.stats.finalStats()
+			avg, stddev = p.rtt.format(m.stats.mean), p.rtt.format(m.stats.stddev)
+		}
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t%s\n",
+			redact(m.addr), m.first, m.listed, m.stats.transmitted, m.stats.received, m.stats.loss(), avg, stddev)
+	}
+	table.Flush()
+
+	if v.Lookups > 0 {
+		fmt.Fprintf(p.w, "clients see %s on average (the first address of each lookup), an even spread %s, the fastest member %s\n",
+			p.rtt.format(v.Weighted), p.rtt.format(v.Even), p.rtt.format(v.Fastest))
+		fmt.Fprintf(p.w, "%.1f%% of lookups sent clients to a member more than %.0f%% slower than the fastest\n", v.Slow, (rotationSlower-1)*100)
+	}
+	if v.Pearson != nil {
+		fmt.Fprintf(p.w, "correlation of how often members come first with their RTT: %+.2f\n", *v.Pearson)
+	}
+	fmt.Fprintln(p.w, strings.ToUpper(v.Message[:1])+v.Message[1:])
+	fmt.Fprintf(p.w, "run %s\n", RunID)
+}
@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RTTFormat controls how round trip times are rendered, by every printer
+type RTTFormat struct {
+	Precision int  // digits after the decimal point
+	SI        bool // scale to s / ms / µs / ns instead of always using ms
+}
+
+// DefaultRTTFormat is ping(8)'s: milliseconds, to the microsecond
+var DefaultRTTFormat = RTTFormat{Precision: 3}
+
+// siUnits are the units RTTs may be scaled to, largest first, in milliseconds
+var siUnits = []struct {
+	name  string
+	scale float64
+}{
+	{"s", 1e3},
+	{"ms", 1},
+	{"µs", 1e-3},
+	{"ns", 1e-6},
+}
+
+// unit picks the unit for an RTT of *ms* milliseconds
+func (f RTTFormat) unit(ms float64) (string, float64) {
+	if !f.SI {
+		return "ms", 1
+	}
+
+	for _, u := range siUnits {
+		if math.Abs(ms) >= u.scale {
+			return u.name, u.scale
+		}
+	}
+	last := siUnits[len(siUnits)-1]
+	return last.name, last.scale
+}
+
+// format renders one RTT, with its unit, e.g. "12.345 ms"
+func (f RTTFormat) format(ms float64) string {
+	name, scale := f.unit(ms)
+	return f.fixed(ms/scale) + " " + name
+}
+
+// formatMany renders related RTTs in a common unit, picked by the largest,
+// e.g. "1.234/5.678/9.012 ms" for min/avg/max
+func (f RTTFormat) formatMany(ms ...float64) string {
+	largest := 0.0
+	for _, v := range ms {
+		largest = math.Max(largest, math.Abs(v))
+	}
+	name, scale := f.unit(largest)
+
+	values := make([]string, len(ms))
+	for i, v := range ms {
+		values[i] = f.fixed(v / scale)
+	}
+
+	return strings.Join(values, "/") + " " + name
+}
+
+// fixed renders *v* to the configured precision, without the sign of the
+// negative values that round to zero, such as an IPDV of -0.0001 ms: "0.000"
+func (f RTTFormat) fixed(v float64) string {
+	s := fmt.Sprintf("%.*f", f.Precision, v)
+	if strings.HasPrefix(s, "-") && strings.Trim(s, "-0.") == "" {
+		return s[1:]
+	}
+	return s
+}
+
+// round cuts an RTT in milliseconds down to the configured precision, for JSON,
+// where negative values that round to zero are 0 too, not -0
+func (f RTTFormat) round(ms float64) float64 {
+	pow := math.Pow(10, float64(f.Precision))
+	if r := math.Round(ms*pow) / pow; r != 0 {
+		return r
+	}
+	return 0
+}
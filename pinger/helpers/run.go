@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RunID identifies this run of pinger: a random (version 4) UUID, stamped on
+// every JSON record, so that results gathered from many hosts into one sink
+// can be grouped back into the runs they come from
+var RunID = newRunID()
+
+func newRunID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// recordEncoder writes JSON lines like json.Encoder, adding a leading
+// "run_id" member to each object
+type recordEncoder struct {
+	w      io.Writer
+	prefix []byte
+}
+
+func newRecordEncoder(w io.Writer) *recordEncoder {
+	return &recordEncoder{w: w, prefix: []byte(fmt.Sprintf(`{"run_id":%q`, RunID))}
+}
+
+func (e *recordEncoder) Encode(v any) error {
+	record, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var line bytes.Buffer
+	line.Write(e.prefix)
+	if len(record) > 2 {
+		line.WriteByte(',')
+	}
+	line.Write(record[1:])
+	line.WriteByte('\n')
+
+	_, err = e.w.Write(line.Bytes())
+	return err
+}
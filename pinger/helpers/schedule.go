@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// lagSamples is how many lags a sendSchedule keeps, at most, for percentiles
+const lagSamples = 10000
+
+// sendSchedule records how late probes are sent, compared to when they were
+// due: that lateness is the host's scheduling, not the network. Its mean and
+// worst are exact; its percentiles, past lagSamples probes, are those of a
+// uniform sample of them, for runs until interrupted to keep their memory.
+type sendSchedule struct {
+	due   time.Time // when the next probe is due, zero if it is not timed
+	count int       // timed probes
+	sum   float64   // of their lateness, in ms
+	worst float64   // the greatest lateness
+	lags  []float64 // lateness of the timed probes, or of lagSamples of them, in ms
+}
+
+// expect notes that the next probe is due *interval* from now
+func (s *sendSchedule) expect(interval time.Duration) {
+	s.due = clk.Now().Add(interval)
+}
+
+// sent records the lateness of the probe sent at *at*, if it was due
+func (s *sendSchedule) sent(at time.Time) {
+	if s.due.IsZero() {
+		return
+	}
+	lag := float64(at.Sub(s.due)) / float64(time.Millisecond)
+	s.due = time.Time{}
+
+	s.count++
+	s.sum += lag
+	if s.count == 1 || lag > s.worst {
+		s.worst = lag
+	}
+
+	// Algorithm R: the lag of the nth probe replaces one of the sample with
+	// probability lagSamples / n, keeping it uniform
+	if len(s.lags) < lagSamples {
+		s.lags = append(s.lags, lag)
+	} else if i := rand.IntN(s.count); i < lagSamples {
+		s.lags[i] = lag
+	}
+}
+
+// percentile is the *p*th percentile of *sorted*, by nearest rank
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// annotation sums the lateness up as a "send_schedule" annotation. When the
+// worst of it is more than the mean RTT, it says so: at that point, the host
+// blurs the timing of probes more than the network does.
+func (s *sendSchedule) annotation(stats *PingStats, rtt RTTFormat) Annotation {
+	sorted := slices.Clone(s.lags)
+	slices.Sort(sorted)
+	mean, p99, worst := s.sum/float64(s.count), percentile(sorted, 99), s.worst
+
+	msg := fmt.Sprintf("send schedule: %d probes sent late by %s on average, %s at the 99th percentile, %s at most",
+		s.count, rtt.format(mean), rtt.format(p99), rtt.format(worst))
+	if stats.received > 0 {
+		stats.finalStats()
+		if worst > stats.mean {
+			msg += fmt.Sprintf(", more than the mean RTT (%s): at this scale, host scheduling, not the network, is the bottleneck", rtt.format(stats.mean))
+		}
+	}
+
+	return Annotation{Time: clk.Now(), Kind: "send_schedule", Message: msg, Fields: map[string]string{
+		"probes":      strconv.Itoa(s.count),
+		"lag_mean_ms": strconv.FormatFloat(rtt.round(mean), 'f', -1, 64),
+		"lag_p99_ms":  strconv.FormatFloat(rtt.round(p99), 'f', -1, 64),
+		"lag_max_ms":  strconv.FormatFloat(rtt.round(worst), 'f', -1, 64),
+	}}
+}
+
+// annotateSchedule notes how late probes were sent, in verbose mode and in
+// JSON output, as annotateFootprint does
+func annotateSchedule(info ICMPInfo, printer Printer, s *sendSchedule, stats *PingStats) {
+	if (!info.Verbose && info.Format != "json") || s.count == 0 {
+		return
+	}
+	printer.Annotate(s.annotation(stats, info.RTT))
+}
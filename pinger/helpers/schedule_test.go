@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSendScheduleBounded records more lags than a sendSchedule keeps: the
+// mean and worst stay exact, and the 99th percentile that of the sample
+func TestSendScheduleBounded(t *testing.T) {
+	const probes = 5 * lagSamples
+	due := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var s sendSchedule
+	for i := range probes {
+		// Every probe 1 ms late, but every 1000th 51 ms late
+		lag := time.Millisecond
+		if i%1000 == 999 {
+			lag = 51 * time.Millisecond
+		}
+		s.due = due
+		s.sent(due.Add(lag))
+	}
+
+	if len(s.lags) != lagSamples {
+		t.Errorf("%d lags kept, want %d", len(s.lags), lagSamples)
+	}
+	note := s.annotation(&PingStats{}, DefaultRTTFormat)
+	for field, want := range map[string]string{"probes": "50000", "lag_mean_ms": "1.05", "lag_p99_ms": "1", "lag_max_ms": "51"} {
+		if got := note.Fields[field]; got != want {
+			t.Errorf("%s is %s, want %s", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// selfCheck is one component `pinger self-test` exercises: run returns
+// what it saw on success, or why it failed
+type selfCheck struct {
+	name string
+	run  func(privileged bool) (string, error)
+}
+
+// selfChecks run in order, each through the same code a real ping goes through
+var selfChecks = []selfCheck{
+	{"loopback-ipv4", func(privileged bool) (string, error) { return checkLoopback("127.0.0.1", false, privileged) }},
+	{"loopback-ipv6", func(privileged bool) (string, error) { return checkLoopback("::1", true, privileged) }},
+	{"resolution", checkResolution},
+	{"transport", checkTransport},
+	{"timeout-simulation", checkTimeout},
+	{"stats", checkStats},
+}
+
+// selfTestReport is one line of `pinger self-test`
+type selfTestReport struct {
+	Component string `json:"component"`
+	Pass      bool   `json:"pass"`
+	Detail    string `json:"detail"`
+}
+
+// SelfTestHandler runs every self check and prints PASS / FAIL for each,
+// exiting with status 1 if any failed
+func SelfTestHandler(format string, privileged bool) {
+	var reports []selfTestReport
+	failed := false
+	for _, check := range selfChecks {
+		detail, err := check.run(privileged)
+		if err != nil {
+			detail, failed = err.Error(), true
+		}
+		reports = append(reports, selfTestReport{Component: check.name, Pass: err == nil, Detail: detail})
+	}
+
+	if err := printSelfTest(format, reports, os.Stdout); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// printSelfTest writes the self check reports as a table, or as JSON lines
+func printSelfTest(format string, reports []selfTestReport, w io.Writer) error {
+	switch format {
+	case "", "text":
+	case "json":
+		enc := newRecordEncoder(w)
+		for _, report := range reports {
+			enc.Encode(struct {
+				Type string `json:"type"`
+				selfTestReport
+			}{"self_test", report})
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", format)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, report := range reports {
+		result := "PASS"
+		if !report.Pass {
+			result = "FAIL"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", result, report.Component, report.Detail)
+	}
+	return table.Flush()
+}
+
+// checkLoopback pings *ip* once, over a raw socket if *privileged* and allowed,
+// or a datagram one, and expects an Echo Reply from it
+func checkLoopback(ip string, isIPv6 bool, privileged bool) (string, error) {
+	s, err := openSession(ICMPInfo{IP: ip, CNT: 1, Privileged: privileged}, isIPv6)
+	if err != nil {
+		return "", err
+	}
+	defer s.close()
+	s.timeout = time.Second
+
+	res := s.probe(0)
+	switch {
+	case res.Reason != ReasonNone:
+		return "", fmt.Errorf("%s: %s", res.Reason, res.Detail)
+	case res.Peer != ip:
+		return "", fmt.Errorf("reply from %s, not %s", res.Peer, ip)
+	}
+
+	kind := "raw"
+	if !s.raw {
+		kind = "unprivileged"
+	}
+	return fmt.Sprintf("reply from %s in %s (%s socket)", ip, DefaultRTTFormat.format(res.RTT), kind), nil
+}
+
+// checkTransport probes over a fakeTransport, which needs neither root nor a
+// network, and checks the outcome of each path: reply, timeout, ICMP error,
+// and a send failing
+func checkTransport(bool) (string, error) {
+	const ip = "192.0.2.1"
+	router := &net.UDPAddr{IP: net.ParseIP("198.51.100.1")}
+	failing := newFakeTransport(nil)
+	failing.sendErr = errors.New("network is unreachable")
+
+	for _, path := range []struct {
+		name string
+		t    *fakeTransport
+		want Reason
+		peer string
+	}{
+		{"echo", newFakeTransport(fakeEchoReplies(57, 5*time.Millisecond)), ReasonNone, ip},
+		{"timeout", newFakeTransport(nil), ReasonTimeout, ""},
+		{"unreachable", newFakeTransport(fakeUnreachable(1, router)), ReasonUnreachableHost, router.IP.String()},
+		{"send error", failing, ReasonSendError, ""},
+	} {
+		s := &session{info: ICMPInfo{IP: ip}, proto: protocolICMP, t: path.t, id: 7, timeout: 50 * time.Millisecond}
+		res := s.probe(0)
+		s.close()
+
+		switch {
+		case res.Reason != path.want:
+			return "", fmt.Errorf("%s: got %s (%s), want %s", path.name, res.Reason, res.Detail, path.want)
+		case res.Peer != path.peer:
+			return "", fmt.Errorf("%s: reply from %q, want %q", path.name, res.Peer, path.peer)
+		case path.want == ReasonNone && (res.TTL != 57 || res.RTT < 5):
+			return "", fmt.Errorf("%s: ttl %d, RTT %s: want ttl 57, at least 5 ms", path.name, res.TTL, DefaultRTTFormat.format(res.RTT))
+		}
+	}
+	return "echo, timeout, unreachable and send error outcomes as expected", nil
+}
+
+// checkResolution resolves localhost, and an address literal of each family
+func checkResolution(bool) (string, error) {
+	addr, err := AddrResolution("localhost", AddrOptions{V4: true})
+	if err != nil {
+		return "", fmt.Errorf("localhost: %v", err)
+	}
+	if ip := net.ParseIP(addr.Addr); ip == nil || !ip.IsLoopback() {
+		return "", fmt.Errorf("localhost resolved to %q, not a loopback address", addr.Addr)
+	}
+
+	for _, literal := range []struct {
+		host   string
+		isIPv6 bool
+	}{{"127.0.0.1", false}, {"::1", true}} {
+		got, err := AddrResolution(literal.host, AddrOptions{V6: literal.isIPv6})
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", literal.host, err)
+		}
+		if got.Addr != literal.host || got.IsIPv6 != literal.isIPv6 {
+			return "", fmt.Errorf("%s resolved to %q (IPv6: %v)", literal.host, got.Addr, got.IsIPv6)
+		}
+	}
+
+	return fmt.Sprintf("localhost is %s", addr.Addr), nil
+}
+
+// checkTimeout runs simulated timeouts through the statistics and the text
+// printer, as --force-fail timeout does, and checks what comes out
+func checkTimeout(bool) (string, error) {
+	const probes = 3
+	var (
+		out   bytes.Buffer
+		stats PingStats
+		info  = ICMPInfo{IP: "192.0.2.1", CNT: probes}
+	)
+
+	printer, err := NewPrinter("text", DefaultRTTFormat, &out)
+	if err != nil {
+		return "", err
+	}
+	for i := range probes {
+		stats.transmitted++
+		report(info, forcedResult(FailTimeout, info.IP, i), &stats, printer)
+	}
+	printer.Summary(info.IP, &stats)
+
+	switch {
+	case stats.received != 0 || stats.loss() != 100:
+		return "", fmt.Errorf("%d received, %.1f%% loss: want 0 and 100%%", stats.received, stats.loss())
+	case strings.Count(out.String(), "Request timeout") != probes:
+		return "", fmt.Errorf("want %d timeout lines, got:\n%s", probes, out.String())
+	}
+	return fmt.Sprintf("%d timeouts, 100%% loss", probes), nil
+}
+
+// checkStats feeds known round trip times to the statistics, and checks the figures
+func checkStats(bool) (string, error) {
+	stats := PingStats{transmitted: 5}
+	for i, rtt := range []float64{1, 3, 2, 4} {
+		stats.record(ProbeResult{Seq: i, RTT: rtt})
+	}
+	stats.finalStats()
+
+	for _, figure := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"min", stats.min, 1},
+		{"max", stats.max, 4},
+		{"mean", stats.mean, 2.5},
+		{"stddev", stats.stddev, math.Sqrt(1.25)},
+		{"loss", stats.loss(), 20},
+	} {
+		if math.Abs(figure.got-figure.want) > 1e-9 {
+			return "", fmt.Errorf("%s is %g, want %g", figure.name, figure.got, figure.want)
+		}
+	}
+	return "min/avg/max/stddev and loss as expected", nil
+}
@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// session is one open ICMP endpoint, sending Echo Requests to info.IP.
+// Unlike ICMP4Handler / ICMP6Handler, it neither prints nor exits, so that
+// several of them can run side by side.
+type session struct {
+	info    ICMPInfo
+	proto   int
+	conn    *icmp.PacketConn // for socket options: nil over a fakeTransport
+	t       transport
+	raw     bool
+	id      int
+	iface   *net.Interface // may be nil
+	timeout time.Duration  // how long to wait for each reply
+	notes   []Annotation   // on how the socket was set up, to print after the header
+}
+
+// openSession sets up the socket for pinging info.IP, as configured by info.
+// info.TTL and info.Privileged are updated to what is actually in use.
+// Errors of the command line, such as an unknown interface, are usageErrors.
+func openSession(info ICMPInfo, isIPv6 bool) (*session, error) {
+	f := &familyIPv4
+	if isIPv6 {
+		f = &familyIPv6
+	}
+	s := &session{info: info, proto: f.proto, timeout: replyTimeout}
+
+	if info.Iface != "" {
+		iface, err := net.InterfaceByName(info.Iface)
+		if err != nil {
+			return nil, usageError{fmt.Errorf("error finding interface %s: %v", info.Iface, err)}
+		}
+		s.iface = iface
+	}
+
+	conn, raw, err := listenICMP(s.proto, info.Privileged, s.iface, info.IP, info.Source)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s connection: %v", f.name, err)
+	}
+	s.conn, s.raw, s.id = conn, raw, echoID(conn, raw)
+	if raw {
+		filterEcho(conn, s.proto, s.id)
+	}
+	t := newICMPTransport(conn, s.proto, info.headerFields())
+	s.t = t
+	s.info.Privileged = raw
+
+	// Time RTTs by the timestamps of the NIC, or else of the kernel
+	if info.HWTimestamps {
+		if s.iface == nil {
+			conn.Close()
+			return nil, usageError{fmt.Errorf("hardware timestamps are those of a NIC: give it with -I")}
+		}
+		clockNote, err := t.enableHWTimestamps(s.iface)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		s.notes = append(s.notes, clockNote)
+	}
+
+	// Set TTL / Hop Limit, or find out what the kernel uses if not configured
+	// and ask for the received TTL / Hop Limit in control messages
+	f.applyHopLimit(conn, &s.info)
+	f.recvControl(conn)
+
+	if info.TOS != 0 {
+		if err := setTOS(conn, s.proto, info.TOS); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error setting TOS: %v", err)
+		}
+	}
+	if info.ECN {
+		if err := enableRecvTOS(conn, s.proto); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error asking for the TOS of replies: %v", err)
+		}
+	}
+	if err := f.options(conn, info); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error %v", err)
+	}
+	if info.Multicast {
+		if err := setMulticast(conn, s.proto, s.iface, s.info.TTL); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error setting up multicast: %v", err)
+		}
+	}
+
+	return s, nil
+}
+
+// setTTL changes the TTL / Hop Limit of the following probes
+func (s *session) setTTL(ttl int) error {
+	s.info.TTL, s.info.KernelTTL = ttl, false
+
+	return familyOf(s.proto).setHopLimit(s.conn, ttl)
+}
+
+// destination is how to address probes sent through the session's socket
+func (s *session) destination() Destination {
+	return Destination{Iface: s.iface, Raw: s.raw}
+}
+
+// isIPv6 reports whether the session pings over ICMPv6
+func (s *session) isIPv6() bool {
+	return s.proto == protocolICMPv6
+}
+
+// probe sends Echo Request *seq* and waits for its outcome.
+// Packets that say nothing about this probe are skipped.
+func (s *session) probe(seq int) ProbeResult {
+	request, err := BuildEchoRequest(WithIPv6(s.isIPv6()), WithID(s.id), WithSeq(wireSeq(seq)))
+	if err != nil {
+		return s.tag(sendErrorResult(err, seq))
+	}
+
+	s.t.SetDeadline(clk.Now().Add(s.timeout))
+
+	startTime, err := sendICMPRequest(s.destination(), s.info.IP, s.t, request)
+	if err != nil {
+		return s.tag(sendErrorResult(err, seq))
+	}
+
+	for {
+		reply, elapsedMs, meta, peerAddr, err := recvICMPRequest(startTime, s.t)
+		if err != nil {
+			return s.tag(readErrorResult(err, seq))
+		}
+
+		res, final := parseICMPResponse(s.proto, s.id, reply, peerAddr, seq, meta, elapsedMs)
+		putPacket(reply)
+		if final {
+			return s.tag(res)
+		}
+	}
+}
+
+// tag marks a result as belonging to this session's target
+func (s *session) tag(res ProbeResult) ProbeResult {
+	res.Target = s.info.IP
+	return res
+}
+
+// close releases the socket
+func (s *session) close() error {
+	return s.t.Close()
+}
@@ -0,0 +1,13 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package helpers
+
+import (
+	"os"
+	"syscall"
+)
+
+// interimSignals ask for the statistics so far, without stopping: Ctrl + T
+// sends SIGINFO to the foreground process on macOS and the BSDs, and Ctrl + \
+// SIGQUIT, as ping(8) takes it
+var interimSignals = []os.Signal{syscall.SIGINFO, syscall.SIGQUIT}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package helpers
+
+import "os"
+
+// interimSignals ask for the statistics so far, without stopping.
+// There is no SIGQUIT nor SIGINFO here.
+var interimSignals = []os.Signal{}
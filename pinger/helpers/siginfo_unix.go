@@ -0,0 +1,12 @@
+//go:build unix && !(darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package helpers
+
+import (
+	"os"
+	"syscall"
+)
+
+// interimSignals ask for the statistics so far, without stopping: Ctrl + \
+// sends SIGQUIT, as ping(8) takes it. There is no SIGINFO here.
+var interimSignals = []os.Signal{syscall.SIGQUIT}
@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+)
+
+// listenICMP opens the ICMP endpoint for *proto*, to send to *dst* via *iface* (may be nil),
+// from local address *src*, or one the kernel picks if empty.
+//
+// With *privileged* set, a raw socket is tried first, and if the kernel refuses
+// it (EPERM / EACCES, i.e. not root and no CAP_NET_RAW), pinger falls back to an
+// unprivileged ICMP datagram socket, like ping(8) does on Linux when the group
+// is in net.ipv4.ping_group_range (and on macOS, always).
+// Platforms without datagram sockets always get a raw socket.
+// The returned bool reports whether the socket is raw.
+func listenICMP(proto int, privileged bool, iface *net.Interface, dst string, src string) (*icmp.PacketConn, bool, error) {
+	var network, dgramNetwork string
+
+	switch proto {
+	case protocolICMP:
+		network, dgramNetwork = "ip4:icmp", "udp4"
+	case protocolICMPv6:
+		network, dgramNetwork = "ip6:ipv6-icmp", "udp6"
+	}
+
+	listenAddr := src
+	if listenAddr == "" {
+		listenAddr = icmpListenAddr(proto, iface, dst)
+	}
+
+	var (
+		conn *icmp.PacketConn
+		raw  bool
+		err  error
+	)
+	if privileged || !datagramICMP {
+		conn, err = icmp.ListenPacket(network, listenAddr)
+		raw = err == nil || !datagramICMP || !isPermissionError(err)
+	}
+	if !raw {
+		if err != nil {
+			slog.Info("raw socket refused, opening an ICMP datagram socket instead", "err", err)
+		}
+		network = dgramNetwork
+		conn, err = icmp.ListenPacket(dgramNetwork, listenAddr)
+	}
+	if err != nil {
+		return conn, raw, err
+	}
+	slog.Info("opened socket", "network", network, "laddr", conn.LocalAddr(), "dst", dst)
+
+	// Best effort: control messages steer what we send already, but binding also
+	// keeps out what other interfaces receive. Older kernels want CAP_NET_RAW for it.
+	if iface != nil {
+		err := controlSocket(conn, proto, func(fd uintptr) error { return bindToDevice(fd, iface.Name) })
+		slog.Info("bound socket to device", "iface", iface.Name, "err", err)
+	}
+
+	return conn, raw, nil
+}
+
+// wildcardAddr is the "any" address of *proto*'s family
+func wildcardAddr(proto int) string {
+	if proto == protocolICMPv6 {
+		return "::"
+	}
+	return "0.0.0.0"
+}
+
+// isPermissionError checks if err is the kernel refusing a raw socket
+func isPermissionError(err error) bool {
+	return errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)
+}
+
+// rawSockets counts raw sockets opened so far, see echoID
+var rawSockets atomic.Int32
+
+// echoID is the ICMP Echo identifier that replies to us will carry.
+// On Linux datagram sockets the kernel replaces ours with the socket's local
+// port; macOS leaves it alone.
+// Every raw socket sees every ICMP packet, so each one gets its own
+// identifier, starting from the process ID, to tell their replies apart.
+func echoID(conn *icmp.PacketConn, raw bool) int {
+	if !raw && runtime.GOOS == "linux" {
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			return addr.Port
+		}
+	}
+
+	return (os.Getpid() + int(rawSockets.Add(1)) - 1) & 0xffff
+}
+
+// Destination builds the addresses Echo Requests are sent to, for one socket.
+// Iface (may be nil) only becomes the zone of IPv6 addresses: IPv4 has no
+// zones, and is steered to the interface by control messages and SO_BINDTODEVICE
+// instead, see sendICMPRequest and listenICMP.
+type Destination struct {
+	Iface *net.Interface // interface to send through, may be nil
+	Raw   bool           // raw socket, rather than an ICMP datagram socket
+}
+
+// Addr is the address to send to *ipaddr*: datagram sockets want a UDPAddr
+func (d Destination) Addr(ipaddr string) net.Addr {
+	ip := net.ParseIP(ipaddr)
+
+	zone := ""
+	if d.Iface != nil && ip.To4() == nil {
+		zone = d.Iface.Name
+	}
+
+	if !d.Raw {
+		return &net.UDPAddr{IP: ip, Zone: zone}
+	}
+	return &net.IPAddr{IP: ip, Zone: zone}
+}
+
+// controlSocket runs *fn* on the file descriptor of *conn*, an ICMP socket for *proto*
+func controlSocket(conn *icmp.PacketConn, proto int, fn func(fd uintptr) error) error {
+	var pc net.PacketConn
+	if proto == protocolICMPv6 {
+		pc = conn.IPv6PacketConn().PacketConn
+	} else {
+		pc = conn.IPv4PacketConn().PacketConn
+	}
+
+	sc, ok := pc.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("socket options are not supported on %T", pc)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := raw.Control(func(fd uintptr) { fnErr = fn(fd) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// peerIP strips the (meaningless) port from addresses read off datagram sockets
+func peerIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return (&net.IPAddr{IP: a.IP, Zone: a.Zone}).String()
+	case nil:
+		return ""
+	}
+
+	return addr.String()
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDestinationAddr(t *testing.T) {
+	eth0 := &net.Interface{Index: 2, Name: "eth0"}
+
+	for _, tc := range []struct {
+		dst  Destination
+		ip   string
+		want net.Addr
+	}{
+		// Datagram sockets want a UDPAddr, raw ones an IPAddr
+		{Destination{}, "192.0.2.1", &net.UDPAddr{IP: net.ParseIP("192.0.2.1")}},
+		{Destination{Raw: true}, "192.0.2.1", &net.IPAddr{IP: net.ParseIP("192.0.2.1")}},
+		{Destination{}, "2001:db8::1", &net.UDPAddr{IP: net.ParseIP("2001:db8::1")}},
+		{Destination{Raw: true}, "2001:db8::1", &net.IPAddr{IP: net.ParseIP("2001:db8::1")}},
+		// The interface is the zone of IPv6 addresses only
+		{Destination{Iface: eth0}, "192.0.2.1", &net.UDPAddr{IP: net.ParseIP("192.0.2.1")}},
+		{Destination{Iface: eth0, Raw: true}, "192.0.2.1", &net.IPAddr{IP: net.ParseIP("192.0.2.1")}},
+		{Destination{Iface: eth0}, "fe80::1", &net.UDPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}},
+		{Destination{Iface: eth0, Raw: true}, "fe80::1", &net.IPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}},
+	} {
+		got := tc.dst.Addr(tc.ip)
+		if got.Network() != tc.want.Network() || got.String() != tc.want.String() {
+			t.Errorf("%+v: Addr(%s) = %s %v, want %s %v", tc.dst, tc.ip, got.Network(), got, tc.want.Network(), tc.want)
+		}
+	}
+}
+
+func TestFamilyOf(t *testing.T) {
+	for _, tc := range []struct {
+		proto int
+		want  *family
+		ipv6  bool
+	}{
+		{protocolICMP, &familyIPv4, false},
+		{protocolICMPv6, &familyIPv6, true},
+	} {
+		f := familyOf(tc.proto)
+		if f != tc.want || f.proto != tc.proto || f.ipv6 != tc.ipv6 {
+			t.Errorf("familyOf(%d) = %s (protocol %d, IPv6 %v), want %s", tc.proto, f.name, f.proto, f.ipv6, tc.want.name)
+		}
+	}
+
+	// The Echo Requests of a family are of its protocol
+	for _, f := range []*family{&familyIPv4, &familyIPv6} {
+		b, err := BuildEchoRequest(WithIPv6(f.ipv6))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]byte{false: 8, true: 128}[f.ipv6]; b[0] != want {
+			t.Errorf("%s Echo Request of type %d, want %d", f.name, b[0], want)
+		}
+	}
+}
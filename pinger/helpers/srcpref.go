@@ -0,0 +1,19 @@
+package helpers
+
+import "golang.org/x/net/icmp"
+
+// Source address preferences for IPv6 probes, when privacy extensions (RFC 8981)
+// give an interface both temporary and public addresses
+const (
+	PreferTemporary = "temporary"
+	PreferPublic    = "public"
+)
+
+// preferSource makes IPv6 socket *conn* pick a source address of the kind *pref*
+// says, if there is one; the kernel's default otherwise
+func preferSource(conn *icmp.PacketConn, pref string) error {
+	if pref == "" {
+		return nil
+	}
+	return controlSocket(conn, protocolICMPv6, func(fd uintptr) error { return setSourcePreference(fd, pref) })
+}
@@ -0,0 +1,18 @@
+package helpers
+
+import "golang.org/x/sys/unix"
+
+// IPV6_ADDR_PREFERENCES flags, from linux/in6.h (RFC 5014)
+const (
+	ipv6PreferSrcTmp    = 0x0001
+	ipv6PreferSrcPublic = 0x0002
+)
+
+// setSourcePreference sets IPV6_ADDR_PREFERENCES on IPv6 socket *fd*, as *pref* says
+func setSourcePreference(fd uintptr, pref string) error {
+	flags := ipv6PreferSrcPublic
+	if pref == PreferTemporary {
+		flags = ipv6PreferSrcTmp
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_ADDR_PREFERENCES, flags)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package helpers
+
+import "fmt"
+
+// setSourcePreference needs IPV6_ADDR_PREFERENCES, which only Linux has
+func setSourcePreference(fd uintptr, pref string) error {
+	return fmt.Errorf("choosing between temporary and public source addresses is only supported on Linux")
+}
@@ -1,33 +1,170 @@
 package helpers
 
 import (
-	"fmt"
 	"math"
+	"slices"
+	"sync"
+	"time"
 )
 
+// lossWindow is how many of the last probes the rolling loss is over
+const lossWindow = 60
+
+// statsMu guards the PingStats runs record into while other goroutines read
+// them, like that of summarizeEvery: sent and record take it
+var statsMu sync.Mutex
+
 // Statistics for ping results
 type PingStats struct {
 	transmitted int     // requests sent
 	received    int     // replies received
 	errors      int     // errors like Destination Host Unreachable
+	duplicates  int     // further replies to answered probes, e.g. from other hosts to a broadcast
+	ecnChanged  int     // replies whose ECN bits differ from the probe's, see checkECN
 	min         float64 // min time RTT
 	max         float64 // max time RTT
-	sum1        float64 // cumulative sum RTT
-	sum2        float64 // squared sum RTT
-	mean        float64 // mean RTT
+	mean        float64 // mean RTT, see iterativeStats
+	m2          float64 // sum of squared differences from the mean RTT, see iterativeStats
 	stddev      float64 // std deviation RTT
+	jitter      float64 // interarrival jitter, see iterativeStats
+	ewma        float64 // moving average of RTTs, weighting recent ones, see iterativeStats
+	last        float64 // RTT of the last reply
+	ipdvMin     float64 // smallest delay variation between consecutive replies, see iterativeStats
+	ipdvMax     float64 // largest
+	ipdvAbs     float64 // sum of the sizes of the delay variations, see ipdvMean
+
+	keepSamples bool          // keep every RTT in samples, see newPingStats
+	samples     []float64     // every RTT, for the histogram, if kept
+	rowLength   time.Duration // of the rows of the interval table, 0 for none, see newPingStats
+	rows        []interval    // of the interval table: those finished, then the current one
+	recent      []bool        // whether each of the last lossWindow probes was answered, a ring
+	next        int           // index in recent of the oldest outcome, once it is full
+
+	window *PingStats // what was recorded since the last takeWindow, if kept, see summarizeEvery
+}
+
+// newPingStats is the statistics of a run configured by *info*. Every RTT is
+// only kept for the histogram of --histogram, and outcomes only counted into
+// the rows of the interval table of --interval-table: runs until interrupted,
+// and daemons, would otherwise grow them without end.
+func newPingStats(info ICMPInfo) PingStats {
+	return PingStats{keepSamples: info.Histogram, rowLength: info.Intervals}
+}
+
+// sent counts a request sent
+func (stats *PingStats) sent() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats.transmitted++
+	if stats.window != nil {
+		stats.window.transmitted++
+	}
+}
+
+// record folds the outcome of one probe into the statistics.
+// A Port Unreachable is what UDP probes to a closed port are after, so it counts as a reply.
+// IPv6 Neighbor Discovery chatter and other hosts' replies are not outcomes
+// of our probe, so they are not counted. Nor are duplicates, beyond their number.
+func (stats *PingStats) record(res ProbeResult) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	stats.add(res)
+}
+
+// add is record, statsMu held
+func (stats *PingStats) add(res ProbeResult) {
+	if stats.window != nil {
+		stats.window.add(res)
+	}
+
+	if res.Duplicate {
+		stats.duplicates++
+		return
+	}
+
+	if res.ECNChanged {
+		stats.ecnChanged++
+	}
+
+	switch res.Reason {
+	case ReasonNone, ReasonPortUnreachable:
+		stats.received++
+		stats.iterativeStats(res.RTT)
+		stats.remember(true)
+		stats.tally(res.Time, res.RTT, true)
+	case ReasonNeighborDiscovery, ReasonMismatchedReply:
+	default:
+		stats.errors++
+		stats.remember(false)
+		stats.tally(res.Time, 0, false)
+	}
+}
+
+// snapshot is a copy of the statistics, taken under statsMu: one the run
+// recording into them does not change
+func (stats *PingStats) snapshot() PingStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	copied := *stats
+	copied.recent, copied.window = slices.Clone(stats.recent), nil
+	return copied
+}
+
+// remember adds the outcome of a probe to the last lossWindow ones
+func (stats *PingStats) remember(answered bool) {
+	if len(stats.recent) < lossWindow {
+		stats.recent = append(stats.recent, answered)
+		return
+	}
+	stats.recent[stats.next] = answered
+	stats.next = (stats.next + 1) % lossWindow
+}
+
+// recentLoss is the percentage of the last lossWindow probes (or fewer, if
+// there were fewer) that got no reply, and how many probes that is over: a
+// burst of loss stands out in it, where it is lost in the loss of a long run
+func (stats *PingStats) recentLoss() (float64, int) {
+	if len(stats.recent) == 0 {
+		return 0, 0
+	}
+	lost := 0
+	for _, answered := range stats.recent {
+		if !answered {
+			lost++
+		}
+	}
+	return float64(lost) / float64(len(stats.recent)) * 100, len(stats.recent)
 }
 
 // iterativeStats incrementally calculate the
-// min, max, avg, S1, S2 RTT using the following formulas:
+// min, max, mean and M2 RTT with Welford's algorithm, which stays accurate
+// over millions of close RTTs, where sum(Ti ^ 2) / n - mean ^ 2 does not:
+//
+// mean = mean + (Ti - mean) / n
+//
+// M2 = M2 + (Ti - mean before) * (Ti - mean after)
+//
+// and the interarrival jitter of RFC 3550, a running average of the
+// difference between the RTTs of successive replies:
+//
+// J = J + (|Ti - Ti-1| - J) / 16
 //
-// S1 = sum(Ti)
+// and, as ping(8) does, an exponentially weighted moving average of the RTTs:
 //
-// S2 = sum(Ti ^ 2)
+// EWMA = EWMA + (Ti - EWMA) / 8
+//
+// and the range of the inter-packet delay variation of RFC 3393 between
+// successive replies: how much later the second arrived after the first than
+// it was sent after it, which is the difference between their RTTs:
+//
+// IPDV = Ti - Ti-1
 func (stats *PingStats) iterativeStats(time float64) {
-	// min is initialized as the first RTT
+	// min and max are initialized as the first RTT
 	if stats.received == 1 {
-		stats.min = time
+		stats.min, stats.max, stats.ewma = time, time, time
 	}
 
 	if time < stats.min {
@@ -37,35 +174,48 @@ func (stats *PingStats) iterativeStats(time float64) {
 		stats.max = time
 	}
 
-	stats.sum1 += time
-	stats.sum2 += math.Pow(time, 2)
+	delta := time - stats.mean
+	stats.mean += delta / float64(stats.received)
+	stats.m2 += delta * (time - stats.mean)
+
+	if stats.received > 1 {
+		stats.jitter += (math.Abs(time-stats.last) - stats.jitter) / 16
+		stats.ewma += (time - stats.ewma) / 8
+
+		ipdv := time - stats.last
+		if stats.received == 2 {
+			stats.ipdvMin, stats.ipdvMax = ipdv, ipdv
+		}
+		stats.ipdvMin, stats.ipdvMax = math.Min(stats.ipdvMin, ipdv), math.Max(stats.ipdvMax, ipdv)
+		stats.ipdvAbs += math.Abs(ipdv)
+	}
+	stats.last = time
+	if stats.keepSamples {
+		stats.samples = append(stats.samples, time)
+	}
 }
 
-// finalStats calculate the mean and stddev using the following formulas:
-//
-// mean = S1 / received
+// finalStats calculate the stddev using the following formula:
 //
-// stddev = sqrt(S2 / n - (S1 / n) ^ 2)
+// stddev = sqrt(M2 / received)
 func (stats *PingStats) finalStats() {
-	stats.mean = stats.sum1 / float64(stats.received)
-	stats.stddev = math.Sqrt(stats.sum2/float64(stats.received) - math.Pow(stats.mean, 2))
-
+	stats.stddev = math.Sqrt(stats.m2 / float64(stats.received))
 }
 
-// PrintStatistics is used to summarize all calculated RTT statistics
-func PrintStatistics(stats *PingStats) {
-	dropPercentage := 0.0
-	if stats.transmitted > 0 {
-		dropPercentage = float64(stats.transmitted-stats.received) / float64(stats.transmitted) * 100.0
+// ipdvMean is the mean size of the delay variations between successive
+// replies: their mean itself is only how far the last RTT is from the first,
+// spread over the run
+func (stats *PingStats) ipdvMean() float64 {
+	if stats.received < 2 {
+		return 0
 	}
+	return stats.ipdvAbs / float64(stats.received-1)
+}
 
-	fmt.Printf("\n--- %s ping statistics ---\n", "target")
-	fmt.Printf("%d packets transmitted, %d received, %d errors, %.1f%% packet loss\n",
-		stats.transmitted, stats.received, stats.errors, dropPercentage)
-
-	if stats.received > 0 {
-		stats.finalStats()
-		fmt.Printf("round-trip min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms\n",
-			stats.min, stats.mean, stats.max, stats.stddev)
+// loss is the percentage of transmitted requests that got no reply
+func (stats *PingStats) loss() float64 {
+	if stats.transmitted == 0 {
+		return 0.0
 	}
+	return float64(stats.transmitted-stats.received) / float64(stats.transmitted) * 100.0
 }
@@ -0,0 +1,239 @@
+package helpers
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStatsPrecision feeds millions of round trip times a microsecond apart
+// around a second, as a long run to a slow but steady host gives, and checks
+// that their stddev is not lost to rounding
+func TestStatsPrecision(t *testing.T) {
+	const samples = 2_000_000
+	var stats PingStats
+	for i := range samples {
+		rtt := 1000.0 + 0.001
+		if i%2 == 1 {
+			rtt = 1000.0 - 0.001
+		}
+		stats.received++
+		stats.iterativeStats(rtt)
+	}
+	stats.finalStats()
+
+	if math.Abs(stats.mean-1000) > 1e-9 || math.Abs(stats.stddev-0.001) > 1e-9 {
+		t.Errorf("mean %g and stddev %g of %d RTTs, want 1000 and 0.001", stats.mean, stats.stddev, samples)
+	}
+}
+
+// TestSamplesKept checks that every RTT is only kept for --histogram
+func TestSamplesKept(t *testing.T) {
+	for _, histogram := range []bool{false, true} {
+		stats := newPingStats(ICMPInfo{Histogram: histogram})
+		for i, rtt := range []float64{1, 1.5, 2, 12} {
+			stats.sent()
+			stats.record(ProbeResult{Seq: i, RTT: rtt})
+		}
+
+		buckets := stats.histogram()
+		if !histogram {
+			if len(stats.samples) != 0 || buckets != nil {
+				t.Errorf("without --histogram, %d RTTs kept, histogram %v", len(stats.samples), buckets)
+			}
+			continue
+		}
+		if len(buckets) != 7 || buckets[0].Count != 2 || buckets[1].Count != 1 || buckets[6].From != 12 || buckets[6].Count != 1 {
+			t.Errorf("histogram %v, want 2 RTTs in [0, 2), 1 in [2, 4), 1 in [12, 14)", buckets)
+		}
+	}
+}
+
+// TestIntervalRows checks that outcomes are counted into the rows of the
+// interval table as they come, empty rows included, and only for --interval-table
+func TestIntervalRows(t *testing.T) {
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	outcomes := []ProbeResult{
+		{Time: start, RTT: 10},
+		{Time: start.Add(500 * time.Millisecond), Reason: ReasonTimeout},
+		{Time: start.Add(900 * time.Millisecond), RTT: 20},
+		{Time: start.Add(2500 * time.Millisecond), RTT: 30},
+	}
+
+	for _, every := range []time.Duration{0, time.Second} {
+		stats := newPingStats(ICMPInfo{Intervals: every})
+		for i, res := range outcomes {
+			res.Seq = i
+			stats.sent()
+			stats.record(res)
+		}
+
+		if every == 0 {
+			if stats.rows != nil {
+				t.Errorf("without --interval-table, rows %v", stats.rows)
+			}
+			continue
+		}
+		want := []interval{
+			{start: start, probes: 3, received: 2, mean: 15},
+			{start: start.Add(time.Second)},
+			{start: start.Add(2 * time.Second), probes: 1, received: 1, mean: 30},
+		}
+		if !slices.Equal(stats.rows, want) {
+			t.Errorf("rows %v, want %v", stats.rows, want)
+		}
+	}
+}
+
+// TestIPDV feeds known RTTs to iterativeStats, and checks the range of the
+// delay variations between successive replies, and the mean of their size
+func TestIPDV(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		rtts          []float64
+		min, avg, max float64
+		line          string // of the text summary, "" for none
+	}{
+		// No variation before a second reply
+		{"first reply", []float64{5}, 0, 0, 0, ""},
+		{"steady", []float64{2, 2, 2}, 0, 0, 0, "ipdv min/avg/max = 0.000/0.000/0.000 ms"},
+		{"mixed", []float64{1, 3, 2, 4}, -1, 5.0 / 3, 2, "ipdv min/avg/max = -1.000/1.667/2.000 ms"},
+		{"falling", []float64{4, 3, 1}, -2, 1.5, -1, "ipdv min/avg/max = -2.000/1.500/-1.000 ms"},
+		// Variations too small for the precision print as 0, not -0.000
+		{"below precision", []float64{1.0002, 1.0001}, -0.0001, 0.0001, -0.0001, "ipdv min/avg/max = 0.000/0.000/0.000 ms"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stats PingStats
+			for _, rtt := range tc.rtts {
+				stats.transmitted++
+				stats.received++
+				stats.iterativeStats(rtt)
+			}
+
+			for _, figure := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"min", stats.ipdvMin, tc.min},
+				{"avg", stats.ipdvMean(), tc.avg},
+				{"max", stats.ipdvMax, tc.max},
+			} {
+				if math.Abs(figure.got-figure.want) > 1e-9 {
+					t.Errorf("ipdv %s %g, want %g", figure.name, figure.got, figure.want)
+				}
+			}
+
+			line := strings.TrimSuffix(summaryLine(&stats, "ipdv"), " (RFC 3393, between successive replies; avg of its size)")
+			if line != tc.line {
+				t.Errorf("summary line %q, want %q", line, tc.line)
+			}
+		})
+	}
+}
+
+func TestRTTFormatZero(t *testing.T) {
+	for _, tc := range []struct {
+		f    RTTFormat
+		ms   float64
+		want string
+	}{
+		{DefaultRTTFormat, -0.0001, "0.000 ms"},
+		{DefaultRTTFormat, math.Copysign(0, -1), "0.000 ms"},
+		{DefaultRTTFormat, -0.0006, "-0.001 ms"},
+		{RTTFormat{Precision: 0}, -0.4, "0 ms"},
+		{RTTFormat{Precision: 1, SI: true}, -0.00001, "-10.0 ns"},
+	} {
+		if got := tc.f.format(tc.ms); got != tc.want {
+			t.Errorf("%+v format(%g) = %q, want %q", tc.f, tc.ms, got, tc.want)
+		}
+	}
+	if got := DefaultRTTFormat.formatMany(-0.0001, 0, 0.0001); got != "0.000/0.000/0.000 ms" {
+		t.Errorf("formatMany: %q", got)
+	}
+	if got := DefaultRTTFormat.round(-0.0001); got != 0 || math.Signbit(got) {
+		t.Errorf("round(-0.0001) = %g, want 0", got)
+	}
+}
+
+// summaryLine is the line of the text summary of *stats* that starts with *prefix*
+func summaryLine(stats *PingStats, prefix string) string {
+	var out strings.Builder
+	(&textPrinter{w: &out, rtt: DefaultRTTFormat}).Summary("192.0.2.1", stats)
+	for line := range strings.Lines(out.String()) {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSuffix(line, "\n")
+		}
+	}
+	return ""
+}
+
+// TestEWMA checks the moving average of ping(8): the first RTT, then each
+// next one weighted by 1/8
+func TestEWMA(t *testing.T) {
+	for _, tc := range []struct {
+		rtts []float64
+		want float64
+	}{
+		{[]float64{8}, 8},
+		{[]float64{8, 16}, 9},
+		{[]float64{8, 16, 16}, 9.875},
+		{[]float64{8, 16, 16, 0}, 8.640625},
+		// A step settles towards the new level, 7/8 of the way left each time
+		{[]float64{10, 2, 2, 2}, 2 + 8*7.0/8*7.0/8*7.0/8},
+	} {
+		var stats PingStats
+		for _, rtt := range tc.rtts {
+			stats.transmitted++
+			stats.received++
+			stats.iterativeStats(rtt)
+		}
+		if math.Abs(stats.ewma-tc.want) > 1e-9 {
+			t.Errorf("EWMA of %v: %g, want %g", tc.rtts, stats.ewma, tc.want)
+		}
+	}
+
+	var stats PingStats
+	for _, rtt := range []float64{8, 16, 16, 0} {
+		stats.transmitted++
+		stats.received++
+		stats.iterativeStats(rtt)
+	}
+	if got, want := summaryLine(&stats, "ewma"), "ewma = 8.641 ms (moving average, weighting recent replies)"; got != want {
+		t.Errorf("summary line %q, want %q", got, want)
+	}
+}
+
+// TestJitter checks the interarrival jitter of RFC 3550 (6.4.1): the mean
+// deviation of the differences between successive RTTs, each weighted by 1/16
+func TestJitter(t *testing.T) {
+	for _, tc := range []struct {
+		rtts []float64
+		want float64
+		line string // of the text summary, "" for none
+	}{
+		// None before a second reply
+		{[]float64{5}, 0, ""},
+		{[]float64{8, 16}, 0.5, "jitter = 0.500 ms (RFC 3550)"},
+		{[]float64{8, 16, 16}, 0.46875, "jitter = 0.469 ms (RFC 3550)"},
+		{[]float64{8, 16, 16, 0}, 0.46875 + (16-0.46875)/16, "jitter = 1.439 ms (RFC 3550)"},
+		// Steady RTTs, however high, do not jitter
+		{[]float64{100, 100, 100}, 0, "jitter = 0.000 ms (RFC 3550)"},
+		// The size of the difference counts, not its sign
+		{[]float64{10, 20, 10, 20}, 10.0 / 16 * (1 + 15.0/16 + 15.0/16*15.0/16), "jitter = 1.760 ms (RFC 3550)"},
+	} {
+		var stats PingStats
+		for _, rtt := range tc.rtts {
+			stats.transmitted++
+			stats.received++
+			stats.iterativeStats(rtt)
+		}
+		if math.Abs(stats.jitter-tc.want) > 1e-9 {
+			t.Errorf("jitter of %v: %g, want %g", tc.rtts, stats.jitter, tc.want)
+		}
+		if got := summaryLine(&stats, "jitter"); got != tc.line {
+			t.Errorf("%v: summary line %q, want %q", tc.rtts, got, tc.line)
+		}
+	}
+}
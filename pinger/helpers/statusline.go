@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// clearLine returns the cursor to the start of the line, and erases it
+const clearLine = "\r\033[K"
+
+// statusLine, when set with SetStatusLine, is where every Printer of
+// NewPrinter keeps a line of running statistics up to date
+var statusLine io.Writer
+
+// SetStatusLine keeps a line of running statistics at the bottom of the
+// terminal on stderr, below the results printed to stdout
+func SetStatusLine() error {
+	fi, err := os.Stderr.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("--statusline: stderr is not a terminal")
+	}
+	statusLine = os.Stderr
+	return nil
+}
+
+// statusPrinter redraws the status line after everything a Printer prints,
+// erasing it first so that the results scroll by above it. The statistics
+// are those of every target together.
+type statusPrinter struct {
+	Printer
+	w       io.Writer
+	rtt     RTTFormat
+	stats   PingStats
+	targets map[string]bool
+	done    bool // the run is summed up: the status line is gone for good
+}
+
+// around erases the status line, prints with *print*, and draws the line again
+func (p *statusPrinter) around(print func()) {
+	fmt.Fprint(p.w, clearLine)
+	print()
+	if !p.done {
+		p.draw()
+	}
+}
+
+func (p *statusPrinter) draw() {
+	line := fmt.Sprintf("sent %d, received %d, %.1f%% loss", p.stats.transmitted, p.stats.received, p.stats.loss())
+	if p.stats.received > 0 {
+		line += ", avg " + p.rtt.format(p.stats.mean)
+	}
+	if len(p.targets) > 1 {
+		line += fmt.Sprintf(" (%d targets)", len(p.targets))
+	}
+	fmt.Fprint(p.w, line)
+}
+
+func (p *statusPrinter) Header(info ICMPInfo) {
+	p.targets[info.IP] = true
+	p.around(func() { p.Printer.Header(info) })
+}
+
+func (p *statusPrinter) Probe(res ProbeResult) {
+	if !res.Duplicate && res.Reason != ReasonNeighborDiscovery && res.Reason != ReasonMismatchedReply {
+		p.stats.transmitted++
+	}
+	p.stats.record(res)
+	p.around(func() { p.Printer.Probe(res) })
+}
+
+func (p *statusPrinter) Annotate(note Annotation) {
+	p.around(func() { p.Printer.Annotate(note) })
+}
+
+func (p *statusPrinter) Interim(target string, stats *PingStats) {
+	p.around(func() { p.Printer.Interim(target, stats) })
+}
+
+func (p *statusPrinter) Periodic(target string, window *PingStats, every time.Duration) {
+	p.around(func() { p.Printer.Periodic(target, window, every) })
+}
+
+func (p *statusPrinter) Summary(target string, stats *PingStats) {
+	p.done = true
+	p.around(func() { p.Printer.Summary(target, stats) })
+}
+
+func (p *statusPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.done = true
+	p.around(func() { p.Printer.SummaryTable(targets, stats) })
+}
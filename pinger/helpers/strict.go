@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"fmt"
+)
+
+// strictViolation reports whether *res* fails a --strict run: any answer but
+// the matching Echo Reply, e.g. an Unreachable from a firewall, a Time
+// Exceeded, a reply to the wrong probe or a duplicate. Losses and local
+// socket errors are not answers, and are counted as usual; IPv6 Neighbor
+// Discovery is not about the probe at all.
+// The annotation says what was received instead, and from whom.
+func strictViolation(res ProbeResult) (Annotation, bool) {
+	switch res.Reason {
+	case ReasonNone:
+		if !res.Duplicate {
+			return Annotation{}, false
+		}
+	case ReasonTimeout, ReasonSendError, ReasonRecvError, ReasonNeighborDiscovery:
+		return Annotation{}, false
+	}
+
+	detail := res.Detail
+	if res.Duplicate {
+		detail = "duplicate Echo Reply"
+	}
+
+	reason := res.Reason.String()
+	if reason == "" {
+		reason = "duplicate"
+	}
+
+	return Annotation{
+		Time:    clk.Now(),
+		Kind:    "strict_violation",
+		Message: fmt.Sprintf("strict: icmp_seq=%d from %s answered with %s (%s), not a matching Echo Reply", res.Seq, res.Peer, reason, detail),
+		Fields: map[string]string{
+			"seq":    fmt.Sprint(res.Seq),
+			"peer":   res.Peer,
+			"reason": reason,
+			"detail": detail,
+		},
+	}, true
+}
@@ -0,0 +1,294 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxSweepHosts bounds the size of a sweep: a /16, or an IPv6 /112
+const maxSweepHosts = 1 << 16
+
+// reverseLookupTimeout bounds the PTR lookup of each responder, for --inventory
+const reverseLookupTimeout = 2 * time.Second
+
+// SweepOptions are the settings of `pinger sweep`
+type SweepOptions struct {
+	Probes   int           // Echo Requests per address
+	Timeout  time.Duration // wait for each probe's reply
+	Parallel int           // addresses probed at the same time
+
+	Inventory bool // also name responders by reverse DNS and the neighbor table, as a table
+}
+
+// sweepHost is what came back from one address of the prefix
+type sweepHost struct {
+	addr     netip.Addr
+	alive    bool
+	best     float64 // lowest RTT, in milliseconds, if alive
+	received int
+	sent     int
+	mac      net.HardwareAddr // in verbose / inventory mode, for on-link hosts
+	hostname string           // in inventory mode, from the PTR record
+}
+
+// expandPrefix lists the addresses of *cidr* worth probing: for IPv4 prefixes
+// shorter than /31, the network and broadcast addresses are left out
+func expandPrefix(cidr string) ([]netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix %q: %v", cidr, err)
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("prefix %v is too large to sweep: at most %d addresses (a /%d)", prefix, maxSweepHosts, prefix.Addr().BitLen()-16)
+	}
+
+	var addrs []netip.Addr
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		addrs = append(addrs, addr)
+	}
+
+	if prefix.Addr().Is4() && hostBits > 1 {
+		addrs = addrs[1 : len(addrs)-1]
+	}
+
+	return addrs, nil
+}
+
+// SweepHandler probes every address of the *cidr* prefix, opts.Parallel at a time,
+// and prints the ones that answered with their best RTT: a quick host discovery.
+func SweepHandler(cidr string, info ICMPInfo, opts SweepOptions) {
+	addrs, err := expandPrefix(cidr)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	printer, err := newSweepPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	// abstracted "socket" information: every probe goes through the one socket
+	info.IP = addrs[0].String()
+	d, err := newDemux(info, addrs[0].Is6())
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer d.close()
+	d.s.timeout = opts.Timeout
+
+	printer.header(cidr, len(addrs), opts)
+	start := time.Now()
+
+	hosts := make([]sweepHost, len(addrs))
+	parallel(opts.Parallel, len(addrs), func(i int) {
+		hosts[i] = sweepAddr(d, addrs[i], opts.Probes)
+	})
+
+	var responders []sweepHost
+	for _, host := range hosts {
+		if host.alive {
+			responders = append(responders, host)
+		}
+	}
+
+	// The neighbor table is only filled in once we talked to each host
+	parallel(opts.Parallel, len(responders), func(i int) {
+		host := &responders[i]
+		if info.Verbose || opts.Inventory {
+			if neighbor, ok := lookupNeighbor(net.IP(host.addr.AsSlice())); ok {
+				host.mac = neighbor.MAC
+			}
+		}
+		if opts.Inventory {
+			host.hostname = reverseLookup(host.addr.String(), "")
+		}
+	})
+
+	if opts.Inventory {
+		printer.inventory(responders)
+	} else {
+		for _, host := range responders {
+			printer.host(host)
+		}
+	}
+
+	alive := len(responders)
+	printer.summary(cidr, len(addrs), alive, time.Since(start))
+}
+
+// parallel calls *fn* for 0 to count-1, from *n* goroutines at most, and waits for them
+func parallel(n int, count int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(n, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := range count {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// reverseLookup names *ip* from its PTR record, as *server* (or the system's
+// resolver, if "") answers, or returns "" if it has none
+func reverseLookup(ip, server string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), reverseLookupTimeout)
+	defer cancel()
+
+	names, err := lookupResolver(server).LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	redactName(names[0])
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// sweepAddr sends *probes* Echo Requests to one address, one after the other
+func sweepAddr(d *demux, addr netip.Addr, probes int) sweepHost {
+	host := sweepHost{addr: addr}
+
+	for seq := range probes {
+		host.sent++
+		res := d.probe(addr.String(), seq)
+		if res.Reason != ReasonNone {
+			continue
+		}
+
+		if !host.alive || res.RTT < host.best {
+			host.best = res.RTT
+		}
+		host.alive = true
+		host.received++
+	}
+
+	return host
+}
+
+// sweepPrinter renders a sweep as a list of live hosts, or as JSON lines
+type sweepPrinter struct {
+	w   io.Writer
+	enc *recordEncoder // nil for text
+	rtt RTTFormat
+}
+
+func newSweepPrinter(format string, rtt RTTFormat, w io.Writer) (*sweepPrinter, error) {
+	switch format {
+	case "", "text":
+		return &sweepPrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &sweepPrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *sweepPrinter) header(cidr string, addrs int, opts SweepOptions) {
+	cidr = redact(cidr)
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type      string `json:"type"`
+			Prefix    string `json:"prefix"`
+			Addresses int    `json:"addresses"`
+			Probes    int    `json:"probes"`
+		}{"sweep_start", cidr, addrs, opts.Probes})
+		return
+	}
+
+	fmt.Fprintf(p.w, "SWEEPING %s: %d addresses, %d probes each\n", cidr, addrs, opts.Probes)
+}
+
+func (p *sweepPrinter) host(host sweepHost) {
+	addr, hostname := redact(host.addr.String()), redact(host.hostname)
+	var mac, vendor string
+	if host.mac != nil {
+		mac, vendor = host.mac.String(), ouiVendor(host.mac)
+	}
+
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type     string  `json:"type"`
+			Addr     string  `json:"addr"`
+			Best     float64 `json:"rtt_best_ms"`
+			Sent     int     `json:"sent"`
+			Received int     `json:"received"`
+			Hostname string  `json:"hostname,omitempty"`
+			MAC      string  `json:"mac,omitempty"`
+			Vendor   string  `json:"vendor,omitempty"`
+		}{"alive", addr, p.rtt.round(host.best), host.sent, host.received, hostname, mac, vendor})
+		return
+	}
+
+	fmt.Fprintf(p.w, "%s is alive: best %s, %d/%d replies", addr, p.rtt.format(host.best), host.received, host.sent)
+	if mac != "" {
+		fmt.Fprintf(p.w, ", at %s", mac)
+	}
+	if vendor != "" {
+		fmt.Fprintf(p.w, " (%s)", vendor)
+	}
+	fmt.Fprintln(p.w)
+}
+
+// inventory lists the responders as a table, or as "alive" JSON records
+func (p *sweepPrinter) inventory(hosts []sweepHost) {
+	if p.enc != nil {
+		for _, host := range hosts {
+			p.host(host)
+		}
+		return
+	}
+
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ADDRESS\tHOSTNAME\tMAC\tVENDOR\tBEST RTT")
+	for _, host := range hosts {
+		hostname, mac, vendor := "-", "-", "-"
+		if host.hostname != "" {
+			hostname = redact(host.hostname)
+		}
+		if host.mac != nil {
+			mac = host.mac.String()
+			if v := ouiVendor(host.mac); v != "" {
+				vendor = v
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", redact(host.addr.String()), hostname, mac, vendor, p.rtt.format(host.best))
+	}
+	table.Flush()
+}
+
+func (p *sweepPrinter) summary(cidr string, addrs int, alive int, took time.Duration) {
+	cidr = redact(cidr)
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type      string  `json:"type"`
+			Prefix    string  `json:"prefix"`
+			Addresses int     `json:"addresses"`
+			Alive     int     `json:"alive"`
+			Seconds   float64 `json:"seconds"`
+		}{"sweep_summary", cidr, addrs, alive, took.Seconds()})
+		return
+	}
+
+	fmt.Fprintf(p.w, "\n--- %s sweep ---\n%d of %d addresses alive, in %.1f s\n", cidr, alive, addrs, took.Seconds())
+}
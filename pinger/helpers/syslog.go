@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// SyslogLocal is the --syslog destination of the syslog daemon of this host
+const SyslogLocal = "local"
+
+// syslogSockets are where syslog daemons listen locally: Linux, macOS, the BSDs
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogTime is the TIMESTAMP of RFC 5424, with microseconds at most
+const syslogTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// Syslog severities, of RFC 5424, and the facility pinger logs as: daemon
+const (
+	syslogErr      = 3
+	syslogWarning  = 4
+	syslogNotice   = 5
+	syslogInfo     = 6
+	syslogFacility = 3
+)
+
+// SetSyslog sends every probe result, event, e.g. the state changes of
+// monitor, and summary to syslog, as RFC 5424 messages, to *dest*:
+// SyslogLocal, or a remote collector as udp://<host>[:<port>] or
+// tcp://<host>[:<port>], of port 514 by default.
+func SetSyslog(dest string) error {
+	var conn net.Conn
+	var err error
+	stream := false
+	if dest == SyslogLocal {
+		for _, path := range syslogSockets {
+			if conn, err = net.Dial("unixgram", path); err == nil {
+				break
+			}
+		}
+		if conn == nil {
+			return fmt.Errorf("--syslog %s: no syslog daemon listening on %s", dest, strings.Join(syslogSockets, ", "))
+		}
+	} else {
+		u, perr := url.Parse(dest)
+		if perr != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Hostname() == "" {
+			return fmt.Errorf("invalid --syslog %q: want %s, udp://<host>[:<port>] or tcp://<host>[:<port>]", dest, SyslogLocal)
+		}
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "514")
+		}
+		if conn, err = net.DialTimeout(u.Scheme, addr, 5*time.Second); err != nil {
+			return fmt.Errorf("--syslog %s: %v", dest, err)
+		}
+		stream = u.Scheme == "tcp"
+	}
+
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "-"
+	}
+	sinks = append(sinks, namedSink{name: "syslog", Sink: &syslogSink{conn: conn, stream: stream, host: host, pid: os.Getpid()}})
+	return nil
+}
+
+// syslogSink is the sink of SetSyslog
+type syslogSink struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool // TCP, where messages are framed by octet counting (RFC 6587)
+	host   string
+	pid    int
+	failed bool // a message could not be sent: reported once, not for every one
+}
+
+func (s *syslogSink) Result(res ext.Result) {
+	severity := syslogInfo
+	fields := map[string]string{"target": res.Target, "seq": strconv.Itoa(res.Seq)}
+	if res.Transport != "" {
+		fields["transport"] = res.Transport
+	}
+	if res.Peer != "" {
+		fields["peer"] = res.Peer
+	}
+	if res.Reason == ext.ReasonNone {
+		fields["rtt_ms"] = strconv.FormatFloat(durationMs(res.RTT), 'f', -1, 64)
+	} else {
+		severity = syslogWarning
+		fields["reason"] = res.Reason
+	}
+	if res.Duplicate {
+		fields["duplicate"] = "true"
+	}
+	s.send(severity, "probe", res.Time, fields, res.Detail)
+}
+
+func (s *syslogSink) Event(ev ext.Event) {
+	severity := syslogNotice
+	if ev.Fields["state"] == StateDown {
+		severity = syslogErr
+	}
+	s.send(severity, ev.Kind, ev.Time, ev.Fields, ev.Message)
+}
+
+func (s *syslogSink) Summary(sum ext.Summary) {
+	fields := map[string]string{
+		"target":      sum.Target,
+		"transmitted": strconv.Itoa(sum.Transmitted),
+		"received":    strconv.Itoa(sum.Received),
+		"loss_pct":    strconv.FormatFloat(sum.Loss, 'f', 1, 64),
+	}
+	if sum.Received > 0 {
+		fields["rtt_avg_ms"] = strconv.FormatFloat(durationMs(sum.Avg), 'f', -1, 64)
+	}
+	s.send(syslogInfo, "summary", time.Now(), fields, "")
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// send sends a message of *severity*, with *fields* as key=value pairs, for
+// log aggregators to parse, then *text*:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME pinger PROCID MSGID - target=... text
+func (s *syslogSink) send(severity int, msgID string, at time.Time, fields map[string]string, text string) {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "<%d>1 %s %s pinger %d %s -", syslogFacility*8+severity, at.UTC().Format(syslogTime), s.host, s.pid, msgID)
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		fmt.Fprintf(&msg, " %s=%s", key, strconv.Quote(fields[key]))
+	}
+	if text != "" {
+		fmt.Fprintf(&msg, " %s", text)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.stream {
+		_, err = fmt.Fprintf(s.conn, "%d %s", msg.Len(), msg.String())
+	} else {
+		_, err = s.conn.Write([]byte(msg.String()))
+	}
+	if err != nil && !s.failed {
+		s.failed = true
+		slog.Error("sending to syslog", "err", err)
+	}
+}
@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serviceReady is done once pinger told systemd it is ready
+var serviceReady sync.Once
+
+// sdNotify sends *state* to the service manager, as sd_notify(3) does, if
+// pinger runs as a systemd service of Type=notify, and does nothing otherwise
+func sdNotify(state string) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return
+	}
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		slog.Warn("notifying systemd", "err", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Warn("notifying systemd", "err", err)
+	}
+}
+
+// watchdogInterval is how often systemd expects WATCHDOG=1 of pinger, as
+// WatchdogSec= of the service sets: 0 without a watchdog
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifyReady tells systemd the service is up, once, with *status*, and
+// keeps its watchdog fed, at half its interval, for as long as *alive*
+// reports progress over the interval: nil for as long as pinger runs
+func notifyReady(status string, alive func(within time.Duration) bool) {
+	serviceReady.Do(func() {
+		sdNotify("READY=1\nSTATUS=" + status)
+
+		interval := watchdogInterval()
+		if interval == 0 {
+			return
+		}
+		go func() {
+			for range time.Tick(interval / 2) {
+				if alive == nil || alive(interval) {
+					sdNotify("WATCHDOG=1")
+				}
+			}
+		}()
+	})
+}
+
+// ReloadOnHangup restarts pinger, with the same arguments, on SIGHUP, for it
+// to read its files and resolve its targets again, e.g. after editing the
+// --targets-file of monitor, as systemctl reload asks with
+// ExecReload=kill -HUP $MAINPID. Without SIGHUP, on Windows, it does nothing.
+func ReloadOnHangup() {
+	if len(hangupSignals) == 0 {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, hangupSignals...)
+	go func() {
+		<-c
+		slog.Warn("reloading on SIGHUP: restarting")
+		sdNotify("RELOADING=1\nSTATUS=reloading")
+		CloseSinks()
+		err := reexec()
+		slog.Error(fmt.Sprintf("error restarting: %v", err))
+		os.Exit(ExitUsage)
+	}()
+}
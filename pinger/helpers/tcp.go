@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ifaceLocalIP picks an address of the named interface, of the wanted family,
+// to bind outgoing connections to
+func ifaceLocalIP(name string, isIPv6 bool) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("error finding interface %s: %v", name, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && (ipnet.IP.To4() == nil) == isIPv6 {
+			return ipnet.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s has no IPv%s address", name, map[bool]string{false: "4", true: "6"}[isIPv6])
+}
+
+// localDialer is the dialer of TCP / UDP probes, bound to info.Source or info.Iface if set
+func localDialer(info ICMPInfo, isIPv6 bool) (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: replyTimeout}
+
+	if isIPv6 && info.PreferSrc != "" {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) { err = setSourcePreference(fd, info.PreferSrc) }); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+
+	// Link-local targets are steered by the zone of dialTarget instead
+	if linkLocal := net.ParseIP(info.IP).IsLinkLocalUnicast(); (info.Iface != "" && !linkLocal) || info.Source != "" {
+		ip := net.ParseIP(info.Source)
+		if ip == nil {
+			var err error
+			if ip, err = ifaceLocalIP(info.Iface, isIPv6); err != nil {
+				return nil, err
+			}
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		if info.Transport == "udp" {
+			dialer.LocalAddr = &net.UDPAddr{IP: ip}
+		}
+	}
+
+	return dialer, nil
+}
+
+// dialTarget is the "host:port" TCP / UDP probes go to: IPv6 link-local
+// addresses take info.Iface as their zone
+func dialTarget(info ICMPInfo) string {
+	host := info.IP
+	if info.Iface != "" && net.ParseIP(host).IsLinkLocalUnicast() && net.ParseIP(host).To4() == nil {
+		host += "%" + info.Iface
+	}
+	return net.JoinHostPort(host, strconv.Itoa(info.Port))
+}
+
+// tcpProbe times the TCP handshake with *target* ("host:port"): a refused
+// connection took a round trip too, and says the host is up. Cancelling *ctx*
+// cuts it short.
+func tcpProbe(ctx context.Context, dialer *net.Dialer, target string, seq int) ProbeResult {
+	res := ProbeResult{Transport: "tcp", Seq: seq, Peer: target}
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	res.RTT = elapsedMsSince(start)
+	res.Time = time.Now()
+
+	if err == nil {
+		conn.Close()
+		return res
+	}
+
+	res.Detail = err.Error()
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		res.Reason, res.Detail = ReasonRefused, "connection refused"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		res.Reason = ReasonTimeout
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		res.Reason, res.Detail = ReasonUnreachableHost, unreachableMessage(ReasonUnreachableHost, 1)
+	case errors.Is(err, syscall.ENETUNREACH):
+		res.Reason, res.Detail = ReasonUnreachableNet, unreachableMessage(ReasonUnreachableNet, 0)
+	default:
+		res.Reason = ReasonSendError
+	}
+
+	return res
+}
+
+// TCPHandler measures TCP connect latency to info.IP port info.Port, once per
+// second, for networks that filter ICMP. Results and statistics are printed
+// as for ICMP Echo. It returns the exit status.
+func TCPHandler(info ICMPInfo, isIPv6 bool) int {
+	stats := newPingStats(info)
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	info.Transport = "tcp"
+	dialer, err := localDialer(info, isIPv6)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	target := dialTarget(info)
+
+	// Graceful termination, the usual ending, with Ctrl + C: the handshake in
+	// flight is cut short, and the statistics printed as at the end
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := onInterrupt(cancel)
+
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() { printInterim(printer, target, &stats) })
+	defer summarizeEvery(info.SummaryEvery, printer, []string{target}, &stats)()
+
+	if info.SelfTarget {
+		printer.Annotate(selfTargetAnnotation(info.IP))
+	}
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
+		res := tcpProbe(ctx, dialer, target, i)
+		// Cut short by Ctrl + C: not an outcome of the probe
+		if res.Reason != ReasonNone && stop.stopped() {
+			break
+		}
+		report(info, res, &stats, printer)
+		stop.sleep(time.Second)
+	}
+
+	printer.Summary(target, &stats)
+	return exitStatus(&stats)
+}
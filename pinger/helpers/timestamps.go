@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Formats of the timestamps -D prefixes output lines with
+const (
+	TimestampUnix    = "unix"    // [1700000000.123456], as ping -D
+	TimestampRFC3339 = "rfc3339" // [2023-11-14T22:13:20.123456Z]
+)
+
+// ParseTimestampFormat checks that *format* is one -D knows
+func ParseTimestampFormat(format string) (string, error) {
+	switch format {
+	case TimestampUnix, TimestampRFC3339:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid -D format %q: want %s or %s", format, TimestampUnix, TimestampRFC3339)
+}
+
+// stdout is where handlers print their results: os.Stdout, or a
+// timestampWriter over it after SetLineTimestamps
+var stdout io.Writer = os.Stdout
+
+// SetLineTimestamps prefixes every line of results printed from now on with
+// the wall-clock time it was printed at, in *format*
+func SetLineTimestamps(format string) {
+	stdout = &timestampWriter{w: os.Stdout, format: format}
+}
+
+// timestampWriter prefixes the lines written through it with the time.
+// Empty lines are left alone, so that blocks stay apart.
+type timestampWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	format  string
+	midLine bool // the last write did not end its line
+}
+
+func (t *timestampWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stamp := t.stamp(time.Now())
+	out := make([]byte, 0, len(b)+len(stamp))
+	for rest := b; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+
+		if !t.midLine && line[0] != '\n' {
+			out = append(out, stamp...)
+		}
+		out = append(out, line...)
+		t.midLine = line[len(line)-1] != '\n'
+	}
+
+	if _, err := t.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// stamp is the prefix of lines printed at *now*
+func (t *timestampWriter) stamp(now time.Time) string {
+	if t.format == TimestampRFC3339 {
+		return "[" + now.Format("2006-01-02T15:04:05.000000Z07:00") + "] "
+	}
+	return fmt.Sprintf("[%d.%06d] ", now.Unix(), now.Nanosecond()/1000)
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/icmp"
+)
+
+// dscpNames are the usual DiffServ code points, by name (RFC 2474, 2597, 3246)
+var dscpNames = map[string]int{
+	"be": 0, "ef": 46,
+	"cs0": 0, "cs1": 8, "cs2": 16, "cs3": 24, "cs4": 32, "cs5": 40, "cs6": 48, "cs7": 56,
+	"af11": 10, "af12": 12, "af13": 14,
+	"af21": 18, "af22": 20, "af23": 22,
+	"af31": 26, "af32": 28, "af33": 30,
+	"af41": 34, "af42": 36, "af43": 38,
+}
+
+// ParseTOS reads the value for the IPv4 TOS byte / IPv6 Traffic Class: a number
+// (decimal, or hex with 0x, like ping(8)'s -Q), or a DSCP name such as ef or af41
+func ParseTOS(s string) (int, error) {
+	if dscp, ok := dscpNames[strings.ToLower(s)]; ok {
+		return dscp << 2, nil
+	}
+
+	tos, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TOS %q: want 0 to 255, or a DSCP name such as ef or af41", s)
+	}
+	return int(tos), nil
+}
+
+// setTOS marks what is sent over *conn* with *tos*, as the IPv4 TOS byte or the IPv6 Traffic Class
+func setTOS(conn *icmp.PacketConn, proto int, tos int) error {
+	if proto == protocolICMPv6 {
+		return conn.IPv6PacketConn().SetTrafficClass(tos)
+	}
+	return conn.IPv4PacketConn().SetTOS(tos)
+}
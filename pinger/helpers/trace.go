@@ -0,0 +1,295 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// TraceOptions are the traceroute specific settings of `pinger trace`
+type TraceOptions struct {
+	MaxHops int           // give up after this TTL / Hop Limit
+	Queries int           // probes per hop
+	Timeout time.Duration // wait for each probe's reply
+}
+
+// traceHop is what came back for the probes sent with one TTL
+type traceHop struct {
+	ttl     int
+	results []ProbeResult
+}
+
+// reached reports whether the destination itself answered at this hop
+func (hop traceHop) reached() bool {
+	for _, res := range hop.results {
+		if res.Reason == ReasonNone {
+			return true
+		}
+	}
+	return false
+}
+
+// final reports whether going further is pointless: the destination answered,
+// or a router said it cannot be reached
+func (hop traceHop) final() bool {
+	for _, res := range hop.results {
+		switch res.Reason {
+		case ReasonNone, ReasonUnreachableHost, ReasonUnreachableNet, ReasonUnreachable, ReasonAdminProhibited:
+			return true
+		}
+	}
+	return false
+}
+
+// hopStats are the statistics of the probes sent with one TTL. Routers further
+// away answer slower, so pooling the RTTs of several TTLs would mean nothing.
+type hopStats struct {
+	ttl  int
+	sent int
+	rtts []float64 // of the answered probes, sorted
+}
+
+// newHopStats aggregates the probes of *hop*: any answer with an RTT counts
+func newHopStats(hop traceHop) hopStats {
+	stats := hopStats{ttl: hop.ttl, sent: len(hop.results)}
+	for _, res := range hop.results {
+		if res.answered() {
+			stats.rtts = append(stats.rtts, res.RTT)
+		}
+	}
+	slices.Sort(stats.rtts)
+
+	return stats
+}
+
+// loss is the percentage of probes of the hop that got no answer
+func (stats hopStats) loss() float64 {
+	if stats.sent == 0 {
+		return 0.0
+	}
+	return float64(stats.sent-len(stats.rtts)) / float64(stats.sent) * 100.0
+}
+
+// percentile is the nearest-rank *p*th percentile of the RTTs, e.g. p = 50 for the median
+func (stats hopStats) percentile(p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(stats.rtts))))
+	return stats.rtts[max(rank, 1)-1]
+}
+
+// TraceHandler sends Echo Requests to info.IP with an incrementing TTL / Hop Limit,
+// and prints the router that answered each hop with a Time Exceeded, and the RTTs.
+func TraceHandler(info ICMPInfo, isIPv6 bool, opts TraceOptions) {
+	printer, err := newTracePrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	s, err := openSession(info, isIPv6)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer s.close()
+	s.timeout = opts.Timeout
+
+	// Linux queues ICMP errors for datagram sockets on the error queue, out of our reach
+	if !s.raw {
+		slog.Error("trace needs a raw socket to see Time Exceeded replies: run as root, or with CAP_NET_RAW")
+		os.Exit(1)
+	}
+
+	printer.header(info, opts)
+
+	var stats []hopStats
+	seq := 0
+	for ttl := 1; ttl <= opts.MaxHops; ttl++ {
+		hop := traceHop{ttl: ttl}
+
+		if err := s.setTTL(ttl); err != nil {
+			slog.Error("setting TTL", "ttl", ttl, "err", err)
+			os.Exit(1)
+		}
+
+		for range opts.Queries {
+			hop.results = append(hop.results, s.probe(seq))
+			seq++
+		}
+
+		stats = append(stats, newHopStats(hop))
+		printer.hop(hop, stats[len(stats)-1])
+
+		if hop.final() {
+			printer.summary(info.IP, hop.reached(), ttl, stats)
+			return
+		}
+	}
+
+	printer.summary(info.IP, false, opts.MaxHops, stats)
+}
+
+// tracePrinter renders hops traceroute(8) style, or as JSON lines
+type tracePrinter struct {
+	w       io.Writer
+	enc     *recordEncoder // nil for text
+	rtt     RTTFormat
+	verbose bool // ICMPInfo.Verbose: print the extensions of Time Exceeded, like traceroute(8) -e
+}
+
+func newTracePrinter(format string, rtt RTTFormat, w io.Writer) (*tracePrinter, error) {
+	switch format {
+	case "", "text":
+		return &tracePrinter{w: w, rtt: rtt}, nil
+	case "json":
+		return &tracePrinter{w: w, enc: newRecordEncoder(w), rtt: rtt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *tracePrinter) header(info ICMPInfo, opts TraceOptions) {
+	info = redactInfo(info)
+	p.verbose = info.Verbose
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type      string `json:"type"`
+			Target    string `json:"target"`
+			Iface     string `json:"iface,omitempty"`
+			MaxHops   int    `json:"max_hops"`
+			Queries   int    `json:"queries"`
+			HostsFile string `json:"hosts_file,omitempty"`
+
+			NAT64 *NAT64Mapping `json:"nat64,omitempty"`
+		}{"trace_start", info.IP, info.Iface, opts.MaxHops, opts.Queries, info.HostsFile, info.NAT64})
+		return
+	}
+
+	fmt.Fprintf(p.w, "TRACING %s: %d hops max, %d data bytes", info.IP, opts.MaxHops, pingDataSize)
+	if info.Iface != "" {
+		fmt.Fprintf(p.w, " (via %s)", info.Iface)
+	}
+	fmt.Fprintln(p.w)
+	if info.HostsFile != "" {
+		fmt.Fprintf(p.w, "Address from hosts file %s, not DNS\n", info.HostsFile)
+	}
+	printNAT64(p.w, info)
+}
+
+// jsonHopStats is the JSON form of hopStats
+type jsonHopStats struct {
+	Sent     int      `json:"sent"`
+	Received int      `json:"received"`
+	Loss     float64  `json:"loss_pct"`
+	Min      *float64 `json:"rtt_min_ms,omitempty"`
+	P50      *float64 `json:"rtt_p50_ms,omitempty"`
+	P90      *float64 `json:"rtt_p90_ms,omitempty"`
+	Max      *float64 `json:"rtt_max_ms,omitempty"`
+}
+
+func (p *tracePrinter) hop(hop traceHop, stats hopStats) {
+	results := make([]ProbeResult, len(hop.results))
+	for i, res := range hop.results {
+		results[i] = redactResult(res)
+	}
+	hop.results = results
+
+	if p.enc != nil {
+		out := struct {
+			Type   string       `json:"type"`
+			TTL    int          `json:"ttl"`
+			Probes []jsonProbe  `json:"probes"`
+			Stats  jsonHopStats `json:"stats"`
+		}{Type: "hop", TTL: hop.ttl}
+		for _, res := range hop.results {
+			out.Probes = append(out.Probes, newJSONProbe(res, p.rtt))
+		}
+
+		out.Stats = jsonHopStats{Sent: stats.sent, Received: len(stats.rtts), Loss: stats.loss()}
+		if len(stats.rtts) > 0 {
+			min, p50, p90, max := p.rtt.round(stats.rtts[0]), p.rtt.round(stats.percentile(50)),
+				p.rtt.round(stats.percentile(90)), p.rtt.round(stats.rtts[len(stats.rtts)-1])
+			out.Stats.Min, out.Stats.P50, out.Stats.P90, out.Stats.Max = &min, &p50, &p90, &max
+		}
+		p.enc.Encode(out)
+		return
+	}
+
+	// Like traceroute(8): name the router again only when it changes
+	var line strings.Builder
+	fmt.Fprintf(&line, "%2d ", hop.ttl)
+
+	peer := ""
+	for _, res := range hop.results {
+		if res.Peer != "" && res.Peer != peer {
+			peer = res.Peer
+			fmt.Fprintf(&line, " %s", peer)
+		}
+		fmt.Fprintf(&line, "  %s", p.traceMark(res))
+		if p.verbose {
+			for _, ext := range res.Extensions {
+				fmt.Fprintf(&line, " <%s>", ext)
+			}
+		}
+	}
+
+	fmt.Fprintln(p.w, line.String())
+}
+
+// traceMark is the RTT of a probe, with traceroute(8)'s annotation for errors
+func (p *tracePrinter) traceMark(res ProbeResult) string {
+	switch res.Reason {
+	case ReasonNone, ReasonTTLExceeded:
+		return p.rtt.format(res.RTT)
+	case ReasonUnreachableHost:
+		return p.rtt.format(res.RTT) + " !H"
+	case ReasonUnreachableNet:
+		return p.rtt.format(res.RTT) + " !N"
+	case ReasonAdminProhibited:
+		return p.rtt.format(res.RTT) + " !X"
+	case ReasonUnreachable:
+		return p.rtt.format(res.RTT) + " !U"
+	case ReasonTimeout:
+		return "*"
+	}
+	return "!?"
+}
+
+// summary ends the trace, with a table of the per-hop statistics in text
+func (p *tracePrinter) summary(target string, reached bool, hops int, stats []hopStats) {
+	target = redact(target)
+	if p.enc != nil {
+		p.enc.Encode(struct {
+			Type    string `json:"type"`
+			Target  string `json:"target"`
+			Reached bool   `json:"reached"`
+			Hops    int    `json:"hops"`
+		}{"trace_summary", target, reached, hops})
+		return
+	}
+
+	if reached {
+		fmt.Fprintf(p.w, "\n--- %s reached in %d hops ---\n", target, hops)
+	} else {
+		fmt.Fprintf(p.w, "\n--- %s not reached after %d hops ---\n", target, hops)
+	}
+
+	table := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "TTL\tSENT\tRECV\tLOSS\tMIN\tP50\tP90\tMAX\n")
+	for _, hop := range stats {
+		fmt.Fprintf(table, "%d\t%d\t%d\t%.1f%%", hop.ttl, hop.sent, len(hop.rtts), hop.loss())
+
+		if len(hop.rtts) > 0 {
+			fmt.Fprintf(table, "\t%s\t%s\t%s\t%s\n", p.rtt.format(hop.rtts[0]), p.rtt.format(hop.percentile(50)),
+				p.rtt.format(hop.percentile(90)), p.rtt.format(hop.rtts[len(hop.rtts)-1]))
+		} else {
+			fmt.Fprintf(table, "\t-\t-\t-\t-\n")
+		}
+	}
+	table.Flush()
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// transport is what Echo Requests go out and ICMP packets come in through:
+// an ICMP socket, as icmpTransport, or, for the echo, timeout and error paths
+// to be exercised without root nor a network, a fakeTransport in memory.
+// Socket options are set on the socket itself, before it is wrapped.
+type transport interface {
+	// Send sends the ICMP message *msg* to *dst*, through *iface* if not nil
+	Send(msg []byte, dst net.Addr, iface *net.Interface) error
+	// Recv reads the next ICMP message into *b*, with what its IP header
+	// said and who it came from
+	Recv(b []byte) (int, packetMeta, net.Addr, error)
+	// SetDeadline sets when Recv gives up, with a timeout net.Error
+	SetDeadline(t time.Time) error
+	Close() error
+}
+
+// icmpTransport is the transport of an ICMP socket, raw or datagram
+type icmpTransport struct {
+	conn    *icmp.PacketConn
+	proto   int
+	want    headerFields // header fields to read besides the TTL
+	stamped bool         // the kernel stamps what the socket receives, see kernelTimestamps
+
+	// With hardware timestamps, see enableHWTimestamps
+	hw      bool    // RTTs are timed by transmit and receive timestamps
+	hwClock bool    // by those of the NIC, rather than the kernel's
+	txSeen  uint32  // the last transmit timestamp read before the last Send
+	txAny   bool    // if any was
+	tx      txStamp // that of the last request, once txKnown
+	txKnown bool
+}
+
+// newICMPTransport wraps *conn*, of *proto*, reading the header fields *want*ed,
+// and asks the kernel to stamp what it receives, where it can
+func newICMPTransport(conn *icmp.PacketConn, proto int, want headerFields) *icmpTransport {
+	t := &icmpTransport{conn: conn, proto: proto, want: want}
+	if kernelTimestamps {
+		err := controlSocket(conn, proto, setRecvTimestamps)
+		t.stamped = err == nil
+		slog.Debug("kernel receive timestamps", "enabled", t.stamped, "err", err)
+	}
+	return t
+}
+
+func (t *icmpTransport) Send(msg []byte, dst net.Addr, iface *net.Interface) error {
+	if t.hw {
+		t.beforeSend()
+	}
+
+	var err error
+	switch t.proto {
+	case protocolICMP:
+		if iface != nil && controlMessages {
+			_, err = t.conn.IPv4PacketConn().WriteTo(msg, &ipv4.ControlMessage{IfIndex: iface.Index}, dst)
+		} else {
+			_, err = t.conn.WriteTo(msg, dst)
+		}
+	case protocolICMPv6:
+		if iface != nil && controlMessages {
+			_, err = t.conn.IPv6PacketConn().WriteTo(msg, &ipv6.ControlMessage{IfIndex: iface.Index}, dst)
+		} else {
+			_, err = t.conn.WriteTo(msg, dst)
+		}
+	}
+
+	if capture != nil && err == nil {
+		capture.sent(clk.Now(), t.proto, t.conn, peerIP(dst), msg)
+	}
+	return err
+}
+
+func (t *icmpTransport) Recv(b []byte) (int, packetMeta, net.Addr, error) {
+	var (
+		meta = packetMeta{ttl: defaultTTL}
+		n    int
+		peer net.Addr
+		err  error
+	)
+
+	switch t.proto {
+	case protocolICMP:
+		// x/net does not pass the TOS, the options nor the timestamps on
+		if (t.want != 0 || t.stamped) && ipv4HeaderReadable {
+			n, meta, peer, err = readIPv4Header(t.conn, b, t.want)
+			break
+		}
+
+		// The TTL of the reply comes in a control message
+		var controlMessage *ipv4.ControlMessage
+		n, controlMessage, peer, err = t.conn.IPv4PacketConn().ReadFrom(b)
+		if controlMessage != nil {
+			slog.Debug("control message", "peer", peer, "cm", controlMessage)
+			meta.ttl = controlMessage.TTL
+		}
+
+	case protocolICMPv6:
+		if t.stamped {
+			n, meta, peer, err = readIPv6Header(t.conn, b, t.want)
+			break
+		}
+
+		// The Hop Limit and Traffic Class of the reply come in a control message
+		var controlMessage *ipv6.ControlMessage
+		n, controlMessage, peer, err = t.conn.IPv6PacketConn().ReadFrom(b)
+		if controlMessage != nil {
+			slog.Debug("control message", "peer", peer, "cm", controlMessage)
+			meta.ttl = controlMessage.HopLimit
+			meta.tos, meta.tosKnown = controlMessage.TrafficClass, t.want&fieldTOS != 0
+		}
+	}
+
+	if t.hw && err == nil {
+		t.stampRTT(&meta)
+	}
+
+	if capture != nil && err == nil {
+		at := meta.received
+		if at.IsZero() {
+			at = clk.Now()
+		}
+		capture.received(at, t.proto, t.conn, peer, meta, b[:n])
+	}
+	return n, meta, peer, err
+}
+
+// SetDeadline sets the read deadline of the socket only: sends never block
+func (t *icmpTransport) SetDeadline(deadline time.Time) error {
+	return t.conn.SetReadDeadline(deadline)
+}
+
+func (t *icmpTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Escape sequences of the full-screen view: the alternate screen keeps the
+// terminal's scrollback as it was, and is left for the summary
+const (
+	enterAltScreen = "\033[?1049h\033[?25l" // and hide the cursor
+	leaveAltScreen = "\033[?25h\033[?1049l"
+	cursorHome     = "\033[H"
+	clearToEnd     = "\033[J"
+)
+
+// sparkBars are the levels of sparklines, from the lowest RTT to the highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// tuiGaugeWidth is how many cells the loss gauge has
+const tuiGaugeWidth = 10
+
+// tuiScreen, when set with SetTUI, is the terminal every Printer of
+// NewPrinter draws its live view on, instead of printing lines
+var tuiScreen io.Writer
+
+// SetTUI has Printers of the text format render a live full-screen view of
+// the run on stdout, refreshed in place, rather than scrolling lines: the
+// current RTT, a sparkline of the last ones and the loss of every target.
+// The summary is printed as usual once the run ends.
+func SetTUI() error {
+	fi, err := os.Stdout.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("tui: stdout is not a terminal")
+	}
+	tuiScreen = os.Stdout
+	return nil
+}
+
+// tuiRow is what the view shows of a target
+type tuiRow struct {
+	target string
+	stats  PingStats
+	last   ProbeResult
+	recent []float64 // the last RTTs, NaN for probes lost, oldest first
+}
+
+// tuiPrinter draws the live view, with a row per target, after every probe.
+// Lines are not printed, but for the summary, by the Printer it wraps, once
+// the view is gone.
+type tuiPrinter struct {
+	Printer
+	w     io.Writer
+	rtt   RTTFormat
+	start time.Time
+	rows  []*tuiRow
+	note  string // the last annotation, e.g. the local address changing
+	shown bool   // on the alternate screen
+}
+
+// row is the row of *target*, added if it has none yet
+func (p *tuiPrinter) row(target string) *tuiRow {
+	for _, row := range p.rows {
+		if row.target == target {
+			return row
+		}
+	}
+	row := &tuiRow{target: target}
+	p.rows = append(p.rows, row)
+	return row
+}
+
+func (p *tuiPrinter) Header(info ICMPInfo) {
+	if !p.shown {
+		p.shown, p.start = true, time.Now()
+		fmt.Fprint(p.w, enterAltScreen)
+	}
+	p.row(info.IP)
+	p.draw()
+}
+
+func (p *tuiPrinter) Probe(res ProbeResult) {
+	if res.Reason == ReasonNeighborDiscovery || res.Reason == ReasonMismatchedReply {
+		return
+	}
+
+	row := p.row(res.Target)
+	if !res.Duplicate {
+		row.stats.transmitted++
+		row.last = res
+		rtt := math.NaN()
+		if res.Reason == ReasonNone || res.Reason == ReasonPortUnreachable {
+			rtt = res.RTT
+		}
+		row.recent = append(row.recent, rtt)
+		if len(row.recent) > lossWindow {
+			row.recent = row.recent[1:]
+		}
+	}
+	row.stats.record(res)
+	p.draw()
+}
+
+func (p *tuiPrinter) Annotate(note Annotation) {
+	p.note = note.Message
+	p.draw()
+}
+
+// The view shows the statistics so far already
+func (p *tuiPrinter) Interim(target string, stats *PingStats)                        {}
+func (p *tuiPrinter) Periodic(target string, window *PingStats, every time.Duration) {}
+
+func (p *tuiPrinter) Summary(target string, stats *PingStats) {
+	p.leave()
+	p.Printer.Summary(target, stats)
+}
+
+func (p *tuiPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.leave()
+	p.Printer.SummaryTable(targets, stats)
+}
+
+// leave returns to the terminal as it was before the view
+func (p *tuiPrinter) leave() {
+	if p.shown {
+		p.shown = false
+		fmt.Fprint(p.w, leaveAltScreen)
+	}
+}
+
+// draw renders the whole view again, over the last one
+func (p *tuiPrinter) draw() {
+	if !p.shown {
+		return
+	}
+	width := terminalWidth()
+
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	line := func(format string, a ...any) {
+		text := []rune(fmt.Sprintf(format, a...))
+		if len(text) > width {
+			text = text[:width]
+		}
+		b.WriteString(string(text))
+		b.WriteString("\033[K\n")
+	}
+
+	elapsed := time.Since(p.start).Truncate(time.Second)
+	line("pinger: %d target(s), %v, Ctrl + C to stop", len(p.rows), elapsed)
+	line("")
+
+	targetWidth := len("TARGET")
+	for _, row := range p.rows {
+		targetWidth = max(targetWidth, len(row.target))
+	}
+	const columns = "%-*s  %-10s  %-10s  %-*s  %6s  %5v  %5v  "
+	header := fmt.Sprintf(columns, targetWidth, "TARGET", "LAST", "AVG", tuiGaugeWidth+2, "LOSS", "", "SENT", "RECV")
+	line("%sRECENT", header)
+
+	sparkWidth := max(width-len([]rune(header)), 0)
+	for _, row := range p.rows {
+		last, avg := "-", "-"
+		if row.stats.transmitted > 0 {
+			last = row.last.Reason.String()
+			if row.last.Reason == ReasonNone || row.last.Reason == ReasonPortUnreachable {
+				last = p.rtt.format(row.last.RTT)
+			}
+		}
+		if row.stats.received > 0 {
+			avg = p.rtt.format(row.stats.mean)
+		}
+		loss := row.stats.loss()
+		line(columns+"%s", targetWidth, row.target, last, avg, tuiGaugeWidth+2, lossGauge(loss),
+			fmt.Sprintf("%.1f%%", loss), row.stats.transmitted, row.stats.received, sparkline(row.recent, sparkWidth))
+	}
+
+	if p.note != "" {
+		line("")
+		line("%s", p.note)
+	}
+	b.WriteString(clearToEnd)
+	fmt.Fprint(p.w, b.String())
+}
+
+// lossGauge is a bar of how much of *loss*, a percentage, there is
+func lossGauge(loss float64) string {
+	filled := int(math.Ceil(loss / 100 * tuiGaugeWidth))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", tuiGaugeWidth-filled) + "]"
+}
+
+// sparkline draws the last *width* of *rtts* as bars, from the lowest to the
+// highest of them; lost probes are blanks
+func sparkline(rtts []float64, width int) string {
+	if len(rtts) > width {
+		rtts = rtts[len(rtts)-width:]
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, rtt := range rtts {
+		if !math.IsNaN(rtt) {
+			low, high = min(low, rtt), max(high, rtt)
+		}
+	}
+
+	var b strings.Builder
+	for _, rtt := range rtts {
+		switch {
+		case math.IsNaN(rtt):
+			b.WriteRune(' ')
+		case high == low:
+			b.WriteRune(sparkBars[0])
+		default:
+			b.WriteRune(sparkBars[int((rtt-low)/(high-low)*float64(len(sparkBars)-1))])
+		}
+	}
+	return b.String()
+}
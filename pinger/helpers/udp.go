@@ -0,0 +1,171 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DefaultUDPPort is where UDP probes go by default: the first port traceroute(8)
+// uses, as it is unlikely to be listened on, and so draws a Port Unreachable
+const DefaultUDPPort = 33434
+
+// udpPayload is the datagram of probe *seq*: its sequence number, then padding
+func udpPayload(seq int) []byte {
+	payload := make([]byte, pingDataSize)
+	binary.BigEndian.PutUint16(payload, uint16(seq))
+	return payload
+}
+
+// udpProbe sends datagram *seq* over the connected *conn*, and times whatever
+// comes back: an application's echo, or, if nothing listens on the port, the
+// ICMP Port Unreachable, which the kernel hands to connected sockets as an error
+func udpProbe(conn *net.UDPConn, seq int) ProbeResult {
+	res := ProbeResult{Transport: "udp", Seq: seq, Peer: conn.RemoteAddr().String()}
+	payload := udpPayload(seq)
+
+	if err := spendPacket(); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	conn.SetReadDeadline(time.Now().Add(replyTimeout))
+
+	start := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		res.Time, res.Reason, res.Detail = time.Now(), ReasonSendError, err.Error()
+		return res
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, err := conn.Read(reply)
+		res.RTT = elapsedMsSince(start)
+		res.Time = time.Now()
+
+		if err != nil {
+			res.Reason, res.Detail = udpReadError(err)
+			return res
+		}
+
+		// A late echo of an earlier probe: keep waiting for ours
+		if n == len(payload) && int(binary.BigEndian.Uint16(reply)) != seq&0xffff {
+			continue
+		}
+
+		res.Bytes = n
+		return res
+	}
+}
+
+// udpReadError classifies the error reading the answer to a UDP probe
+func udpReadError(err error) (Reason, string) {
+	var netErr net.Error
+	switch {
+	// Windows reports Port Unreachable as a reset connection
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return ReasonPortUnreachable, "Port Unreachable"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout, err.Error()
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return ReasonUnreachableHost, unreachableMessage(ReasonUnreachableHost, 1)
+	case errors.Is(err, syscall.ENETUNREACH):
+		return ReasonUnreachableNet, unreachableMessage(ReasonUnreachableNet, 0)
+	}
+	return ReasonRecvError, err.Error()
+}
+
+// dialUDP connects a UDP socket to info.IP port info.Port, so that ICMP errors
+// about it are reported to us, with the TTL and TOS of *info*
+func dialUDP(info ICMPInfo, isIPv6 bool) (*net.UDPConn, string, error) {
+	info.Transport = "udp" // for localDialer
+	dialer, err := localDialer(info, isIPv6)
+	if err != nil {
+		return nil, "", err
+	}
+	target := dialTarget(info)
+
+	c, err := dialer.Dial("udp", target)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error creating UDP connection: %v", err)
+	}
+	conn := c.(*net.UDPConn)
+
+	if info.TTL > 0 {
+		if isIPv6 {
+			ipv6.NewConn(conn).SetHopLimit(info.TTL)
+		} else {
+			ipv4.NewConn(conn).SetTTL(info.TTL)
+		}
+	}
+	if info.TOS != 0 {
+		if isIPv6 {
+			ipv6.NewConn(conn).SetTrafficClass(info.TOS)
+		} else {
+			ipv4.NewConn(conn).SetTOS(info.TOS)
+		}
+	}
+
+	return conn, target, nil
+}
+
+// UDPHandler sends a datagram to info.IP port info.Port once per second, and
+// measures how long the application's echo, or else the ICMP Port Unreachable,
+// takes to come back: for paths where ICMP Echo is blocked. Results and
+// statistics are printed as for ICMP Echo. It returns the exit status.
+func UDPHandler(info ICMPInfo, isIPv6 bool) int {
+	stats := newPingStats(info)
+
+	printer, err := NewPrinter(info.Format, info.RTT, stdout)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(ExitUsage)
+	}
+
+	info.Transport = "udp"
+	conn, target, err := dialUDP(info, isIPv6)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	// Graceful termination, the usual ending, with Ctrl + C: the probe in
+	// flight is cut short, and the statistics printed as at the end
+	stop := onInterrupt(func() { conn.SetReadDeadline(time.Now()) })
+
+	printer.Header(info)
+
+	// Print the statistics so far on request (Ctrl + \, or Ctrl + T on macOS)
+	notifyInterim(func() { printInterim(printer, target, &stats) })
+	defer summarizeEvery(info.SummaryEvery, printer, []string{target}, &stats)()
+
+	if info.SelfTarget {
+		printer.Annotate(selfTargetAnnotation(info.IP))
+	}
+
+	for i := range info.CNT {
+		if stop.stopped() {
+			break
+		}
+		stats.sent()
+		res := udpProbe(conn, i)
+		// Cut short by Ctrl + C: not an outcome of the probe
+		if res.Reason != ReasonNone && res.Reason != ReasonPortUnreachable && stop.stopped() {
+			break
+		}
+		report(info, res, &stats, printer)
+		stop.sleep(time.Second)
+	}
+
+	printer.Summary(target, &stats)
+	return exitStatus(&stats)
+}
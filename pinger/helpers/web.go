@@ -0,0 +1,240 @@
+package helpers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dashboardPage is the page of the web dashboard: it draws what /events streams
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// webHistory is how many probes of every target the dashboard keeps, for
+// pages opened mid-run to start with
+const webHistory = 300
+
+// dashboard, when set with ServeDashboard, is where every Printer of
+// NewPrinter publishes the probes of the run
+var dashboard *webHub
+
+// ServeDashboard serves a web page with live RTT charts and the loss of the
+// targets of the run on *listen*, e.g. ":8080", with the results streamed to
+// it as Server-Sent Events, for a wall monitor. It returns the URL of the
+// page once listening.
+func ServeDashboard(listen string) (string, error) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return "", fmt.Errorf("web: %v", err)
+	}
+
+	hub := &webHub{clients: make(map[chan webEvent]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+	})
+	mux.HandleFunc("GET /events", hub.serveEvents)
+
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			slog.Error("web dashboard stopped", "err", err)
+		}
+	}()
+
+	dashboard = hub
+	return "http://" + ln.Addr().String() + "/", nil
+}
+
+// webEvent is a message to the dashboard pages: "target" for a target and its
+// probes so far, "probe" for the outcome of a probe, "end" once the run is over
+type webEvent struct {
+	Type   string      `json:"-"`
+	Target *webTarget  `json:"target,omitempty"`
+	Probe  *webProbe   `json:"probe,omitempty"`
+	Stats  *webSummary `json:"stats,omitempty"`
+}
+
+// webTarget is what the dashboard knows of a target
+type webTarget struct {
+	IP     string      `json:"ip"`
+	Probes []webProbe  `json:"probes"` // the last webHistory of them
+	Stats  *webSummary `json:"stats"`
+}
+
+// webProbe is the outcome of a probe, with a nil RTT if it got no reply
+type webProbe struct {
+	Target string    `json:"target"`
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	RTT    *float64  `json:"rtt_ms"`
+	Reason Reason    `json:"reason,omitempty"`
+}
+
+// webSummary is the statistics of a target so far
+type webSummary struct {
+	Target      string   `json:"target"`
+	Transmitted int      `json:"transmitted"`
+	Received    int      `json:"received"`
+	Loss        float64  `json:"loss_pct"`
+	RecentLoss  float64  `json:"recent_loss_pct"` // over the last lossWindow probes
+	Avg         *float64 `json:"avg_ms,omitempty"`
+	Last        *float64 `json:"last_ms,omitempty"`
+}
+
+// webHub fans the events of the run out to the dashboard pages open, and
+// keeps the targets for pages opened later
+type webHub struct {
+	mu      sync.Mutex
+	targets []*webTarget
+	clients map[chan webEvent]bool
+	ended   bool
+}
+
+// publish hands *event* to every page. Pages too slow to keep up miss it,
+// rather than hold the run up.
+func (h *webHub) publish(event webEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch event.Type {
+	case "target":
+		h.targets = append(h.targets, event.Target)
+	case "probe":
+		for _, t := range h.targets {
+			if t.IP == event.Probe.Target {
+				t.Probes = append(t.Probes, *event.Probe)
+				if len(t.Probes) > webHistory {
+					t.Probes = t.Probes[1:]
+				}
+				t.Stats = event.Stats
+			}
+		}
+	case "end":
+		h.ended = true
+	}
+
+	for c := range h.clients {
+		select {
+		case c <- event:
+		default:
+		}
+	}
+}
+
+// serveEvents streams the targets so far, then the events of the run, to a page
+func (h *webHub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	c := make(chan webEvent, 64)
+	h.mu.Lock()
+	for _, t := range h.targets {
+		snapshot := *t
+		snapshot.Probes = append([]webProbe(nil), t.Probes...)
+		writeEvent(w, webEvent{Type: "target", Target: &snapshot})
+	}
+	if h.ended {
+		writeEvent(w, webEvent{Type: "end"})
+	}
+	h.clients[c] = true
+	h.mu.Unlock()
+	flusher.Flush()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-c:
+			writeEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes *event* as a Server-Sent Event, named after its type
+func writeEvent(w http.ResponseWriter, event webEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+// webPrinter publishes the probes of a Printer's run to the dashboard, as
+// they are printed, with the statistics of their target so far
+type webPrinter struct {
+	Printer
+	hub   *webHub
+	stats map[string]*PingStats
+}
+
+func (p *webPrinter) Header(info ICMPInfo) {
+	if p.stats[info.IP] == nil {
+		p.stats[info.IP] = &PingStats{}
+		p.hub.publish(webEvent{Type: "target", Target: &webTarget{IP: info.IP, Probes: []webProbe{}, Stats: newWebSummary(info.IP, p.stats[info.IP])}})
+	}
+	p.Printer.Header(info)
+}
+
+func (p *webPrinter) Probe(res ProbeResult) {
+	p.Printer.Probe(res)
+
+	stats := p.stats[res.Target]
+	if stats == nil || res.Duplicate || res.Reason == ReasonNeighborDiscovery || res.Reason == ReasonMismatchedReply {
+		return
+	}
+	stats.transmitted++
+	stats.record(res)
+
+	probe := webProbe{Target: res.Target, Seq: res.Seq, Time: res.Time, Reason: res.Reason}
+	if res.Reason == ReasonNone || res.Reason == ReasonPortUnreachable {
+		rtt := res.RTT
+		probe.RTT = &rtt
+	}
+	p.hub.publish(webEvent{Type: "probe", Probe: &probe, Stats: newWebSummary(res.Target, stats)})
+}
+
+func (p *webPrinter) Summary(target string, stats *PingStats) {
+	p.Printer.Summary(target, stats)
+	p.hub.publish(webEvent{Type: "end"})
+}
+
+func (p *webPrinter) SummaryTable(targets []string, stats []*PingStats) {
+	p.Printer.SummaryTable(targets, stats)
+	p.hub.publish(webEvent{Type: "end"})
+}
+
+// newWebSummary is the statistics of *target* so far, for the dashboard
+func newWebSummary(target string, stats *PingStats) *webSummary {
+	recent, _ := stats.recentLoss()
+	out := &webSummary{
+		Target:      target,
+		Transmitted: stats.transmitted,
+		Received:    stats.received,
+		Loss:        stats.loss(),
+		RecentLoss:  recent,
+	}
+	if stats.received > 0 {
+		avg, last := stats.mean, stats.last
+		out.Avg, out.Last = &avg, &last
+	}
+	return out
+}
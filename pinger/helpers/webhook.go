@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+)
+
+// Delivery of webhook alerts: an alert is POSTed up to webhookAttempts
+// times, waiting webhookBackoff before the first retry, twice as long before
+// every other; alerts still undelivered webhookGrace after the end of the
+// run are given up on
+const (
+	webhookAttempts = 5
+	webhookBackoff  = time.Second
+	webhookTimeout  = 10 * time.Second
+	webhookGrace    = 20 * time.Second
+)
+
+// SetWebhook POSTs an alert to *target*, as JSON, whenever a target of
+// SetMonitor changes state, e.g. goes DEGRADED as its loss crosses the
+// threshold. Alerts are sent in order, in the background, and retried with
+// backoff while the receiver fails, so that a slow receiver never holds up
+// the probes. Their "text" makes them Slack-compatible messages.
+func SetWebhook(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid --webhook %q: want an http:// or https:// URL", target)
+	}
+
+	host, _ := os.Hostname()
+	hook := &webhookSink{
+		url:    target,
+		host:   host,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan webhookAlert, 64),
+		done:   make(chan struct{}),
+	}
+	go hook.deliver()
+	sinks = append(sinks, namedSink{name: "webhook", Sink: hook})
+	return nil
+}
+
+// webhookAlert is the body of a webhook POST
+type webhookAlert struct {
+	Text     string    `json:"text"` // for Slack, and the receivers compatible with it
+	Time     time.Time `json:"time"`
+	Target   string    `json:"target"`
+	State    string    `json:"state"`
+	Previous string    `json:"previous,omitempty"`
+	Why      string    `json:"why,omitempty"`
+	Host     string    `json:"host"` // hostname of the monitor
+}
+
+// webhookSink is the sink of SetWebhook: it alerts on the state_change events
+// of the monitorPrinter, and ignores all else
+type webhookSink struct {
+	url    string
+	host   string
+	client *http.Client
+	queue  chan webhookAlert
+	done   chan struct{} // closed once the queue is closed, and drained
+}
+
+func (h *webhookSink) Result(res ext.Result) {}
+
+func (h *webhookSink) Summary(sum ext.Summary) {}
+
+func (h *webhookSink) Event(ev ext.Event) {
+	if ev.Kind != "state_change" {
+		return
+	}
+	alert := webhookAlert{
+		Text:     "pinger: " + ev.Message,
+		Time:     ev.Time,
+		Target:   ev.Fields["target"],
+		State:    ev.Fields["state"],
+		Previous: ev.Fields["previous"],
+		Why:      ev.Fields["why"],
+		Host:     h.host,
+	}
+
+	select {
+	case h.queue <- alert:
+	default:
+		slog.Error("webhook alerts are not being delivered fast enough: dropping one", "target", alert.Target, "state", alert.State)
+	}
+}
+
+// Close waits for the alerts queued to be delivered, up to webhookGrace
+func (h *webhookSink) Close() error {
+	close(h.queue)
+	select {
+	case <-h.done:
+		return nil
+	case <-time.After(webhookGrace):
+		return fmt.Errorf("alerts still undelivered after %v: giving up on them", webhookGrace)
+	}
+}
+
+// deliver POSTs the alerts queued, in order
+func (h *webhookSink) deliver() {
+	defer close(h.done)
+	for alert := range h.queue {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			continue
+		}
+
+		backoff := webhookBackoff
+		for attempt := 1; ; attempt++ {
+			retry, err := h.post(body)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == webhookAttempts {
+				slog.Error("webhook alert not delivered", "target", alert.Target, "state", alert.State, "attempts", attempt, "err", err)
+				break
+			}
+			slog.Warn("webhook alert not delivered: retrying", "in", backoff, "err", err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// post POSTs *body* once, and tells whether to try again if it failed: not on
+// a 4xx status, which the same alert will get again, but for 429 Too Many
+// Requests
+func (h *webhookSink) post(body []byte) (bool, error) {
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s", resp.Status)
+	}
+	return false, fmt.Errorf("%s", resp.Status)
+}
@@ -0,0 +1,183 @@
+// Package ping is the Go API of pinger, for programs that ping hosts
+// themselves and want the results back, rather than printed:
+//
+//	report, err := ping.Ping(ctx, "nitk.ac.in", ping.WithCount(3), ping.WithIPv6(true))
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(report.Summary.Avg)
+//
+// Ping takes functional options; Run takes the Options struct they fill in,
+// for settings kept in configuration. Results and statistics are the types
+// of package ext, which sinks receive too.
+//
+// This package is stable across releases of pinger, as package ext is: the
+// fields of Options and the With... functions are only ever added, never
+// changed or removed, and the zero value of every field is its default, so
+// that Options written against an older version keep their meaning. Options
+// says which version it was written against in Version; Run refuses those of
+// a newer version than its own, whose fields it would silently ignore.
+package ping
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/ext"
+	"github.com/Vishy70/custom-ping-utility-Vishy70/pinger/helpers"
+)
+
+// OptionsVersion is the version of Options of this release: it is bumped
+// whenever fields are added
+const OptionsVersion = 1
+
+// Defaults of the zero values of Options
+const (
+	DefaultCount    = 5
+	DefaultInterval = time.Second
+	DefaultTimeout  = 4 * time.Second
+)
+
+// Options are the settings of a ping. The zero value of every field is its
+// default: Options{} sends DefaultCount Echo Requests, DefaultInterval apart,
+// as pinger -c 5 does, rather than pinging until interrupted as pinger does
+// without -c.
+type Options struct {
+	Version int // OptionsVersion the Options were written against; 0 for the current one
+
+	Count        int           // Echo Requests to send, DefaultCount if 0
+	Interval     time.Duration // time between Echo Requests, DefaultInterval if 0
+	Timeout      time.Duration // how long to wait for each reply, DefaultTimeout if 0
+	IPv6         bool          // resolve hostnames to IPv6 addresses, rather than IPv4, as -6 does
+	Iface        string        // network interface to send from, "" for the one of the route
+	Source       string        // local address to send from, "" for the kernel's choice
+	TTL          int           // TTL / Hop Limit, 0 for the kernel's default
+	TOS          int           // IPv4 TOS byte / IPv6 Traffic Class, see helpers.ParseTOS
+	Unprivileged bool          // always use ICMP datagram sockets, rather than raw ones if permitted
+}
+
+// Option sets one of the Options, for Ping
+type Option func(*Options)
+
+// WithCount sets the number of Echo Requests to send
+func WithCount(count int) Option {
+	return func(o *Options) { o.Count = count }
+}
+
+// WithInterval sets the time between Echo Requests
+func WithInterval(interval time.Duration) Option {
+	return func(o *Options) { o.Interval = interval }
+}
+
+// WithTimeout sets how long to wait for each reply
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *Options) { o.Timeout = timeout }
+}
+
+// WithIPv6 resolves hostnames to IPv6 addresses, rather than IPv4
+func WithIPv6(ipv6 bool) Option {
+	return func(o *Options) { o.IPv6 = ipv6 }
+}
+
+// WithInterface sends from the network interface *iface*
+func WithInterface(iface string) Option {
+	return func(o *Options) { o.Iface = iface }
+}
+
+// WithSource sends from the local address *source*
+func WithSource(source string) Option {
+	return func(o *Options) { o.Source = source }
+}
+
+// WithTTL sets the TTL / Hop Limit of Echo Requests
+func WithTTL(ttl int) Option {
+	return func(o *Options) { o.TTL = ttl }
+}
+
+// WithTOS sets the IPv4 TOS byte / IPv6 Traffic Class of Echo Requests
+func WithTOS(tos int) Option {
+	return func(o *Options) { o.TOS = tos }
+}
+
+// WithUnprivileged always uses ICMP datagram sockets, rather than raw ones
+func WithUnprivileged(unprivileged bool) Option {
+	return func(o *Options) { o.Unprivileged = unprivileged }
+}
+
+// NewOptions is the Options *opts* set, of the current OptionsVersion
+func NewOptions(opts ...Option) Options {
+	o := Options{Version: OptionsVersion}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Report is the outcome of a ping
+type Report struct {
+	Target  string       // address pinged, the target given once resolved
+	Results []ext.Result // outcome of every Echo Request, in order
+	Summary ext.Summary  // statistics of Results
+}
+
+// Ping pings *target*, a hostname or an IP address, as *opts* set.
+// Cancelling *ctx* ends it early, with the results so far.
+func Ping(ctx context.Context, target string, opts ...Option) (Report, error) {
+	return Run(ctx, target, NewOptions(opts...))
+}
+
+// Run pings *target*, a hostname or an IP address, with *opts*.
+// Cancelling *ctx* ends it early, with the results so far.
+func Run(ctx context.Context, target string, opts Options) (Report, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return Report{}, err
+	}
+
+	addr, err := helpers.AddrResolution(target, helpers.AddrOptions{V6: opts.IPv6})
+	if err != nil {
+		return Report{}, err
+	}
+
+	results, summary, err := helpers.Ping(ctx, helpers.ICMPInfo{
+		IP:         addr.Addr,
+		Iface:      opts.Iface,
+		Source:     opts.Source,
+		TTL:        opts.TTL,
+		TOS:        opts.TOS,
+		CNT:        opts.Count,
+		Privileged: !opts.Unprivileged,
+	}, addr.IsIPv6, opts.Interval, opts.Timeout)
+	if err != nil {
+		return Report{}, err
+	}
+	return Report{Target: addr.Addr, Results: results, Summary: summary}, nil
+}
+
+// withDefaults checks *o*, and fills in the defaults of its zero fields
+func (o Options) withDefaults() (Options, error) {
+	switch {
+	case o.Version < 0 || o.Version > OptionsVersion:
+		return o, fmt.Errorf("ping: options of version %d, but this pinger knows version %d at most", o.Version, OptionsVersion)
+	case o.Count < 0:
+		return o, fmt.Errorf("ping: invalid count %d: must be at least 1", o.Count)
+	case o.Interval < 0 || o.Timeout < 0:
+		return o, fmt.Errorf("ping: the interval and timeout cannot be negative")
+	case o.TTL < 0 || o.TTL > 255:
+		return o, fmt.Errorf("ping: invalid TTL %d: must be between 1 and 255, or 0 for the kernel's default", o.TTL)
+	case o.TOS < 0 || o.TOS > 255:
+		return o, fmt.Errorf("ping: invalid TOS %d: must be between 0 and 255", o.TOS)
+	}
+
+	if o.Count == 0 {
+		o.Count = DefaultCount
+	}
+	if o.Interval == 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultTimeout
+	}
+	return o, nil
+}
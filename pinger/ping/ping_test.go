@@ -0,0 +1,115 @@
+package ping
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// optionsV1 are the fields of Options of OptionsVersion 1, with their types:
+// they must never change, nor go away, for Options written against version 1
+// to keep their meaning
+var optionsV1 = map[string]reflect.Type{
+	"Version":      reflect.TypeFor[int](),
+	"Count":        reflect.TypeFor[int](),
+	"Interval":     reflect.TypeFor[time.Duration](),
+	"Timeout":      reflect.TypeFor[time.Duration](),
+	"IPv6":         reflect.TypeFor[bool](),
+	"Iface":        reflect.TypeFor[string](),
+	"Source":       reflect.TypeFor[string](),
+	"TTL":          reflect.TypeFor[int](),
+	"TOS":          reflect.TypeFor[int](),
+	"Unprivileged": reflect.TypeFor[bool](),
+}
+
+func TestOptionsFieldsKept(t *testing.T) {
+	options := reflect.TypeFor[Options]()
+	for name, want := range optionsV1 {
+		field, ok := options.FieldByName(name)
+		switch {
+		case !ok:
+			t.Errorf("Options.%s of version 1 is gone", name)
+		case field.Type != want:
+			t.Errorf("Options.%s is a %v, a %v in version 1", name, field.Type, want)
+		}
+	}
+	if OptionsVersion == 1 && options.NumField() != len(optionsV1) {
+		t.Errorf("Options has %d fields, %d in version 1: bump OptionsVersion for new ones", options.NumField(), len(optionsV1))
+	}
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	for _, version := range []int{0, 1, OptionsVersion} {
+		got, err := Options{Version: version}.withDefaults()
+		if err != nil {
+			t.Fatalf("Options of version %d: %v", version, err)
+		}
+		want := Options{Version: version, Count: DefaultCount, Interval: DefaultInterval, Timeout: DefaultTimeout}
+		if got != want {
+			t.Errorf("Options of version %d with defaults: %+v, want %+v", version, got, want)
+		}
+	}
+
+	// Set fields are kept
+	set := Options{Count: 1, Interval: 200 * time.Millisecond, Timeout: time.Second, TTL: 64}
+	if got, err := set.withDefaults(); err != nil || got != set {
+		t.Errorf("%+v with defaults: %+v, %v, want it unchanged", set, got, err)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	got := NewOptions(
+		WithCount(3),
+		WithInterval(500*time.Millisecond),
+		WithTimeout(2*time.Second),
+		WithIPv6(true),
+		WithInterface("eth0"),
+		WithSource("2001:db8::1"),
+		WithTTL(200),
+		WithTOS(0xb8),
+		WithUnprivileged(true),
+	)
+	want := Options{
+		Version:      OptionsVersion,
+		Count:        3,
+		Interval:     500 * time.Millisecond,
+		Timeout:      2 * time.Second,
+		IPv6:         true,
+		Iface:        "eth0",
+		Source:       "2001:db8::1",
+		TTL:          200,
+		TOS:          0xb8,
+		Unprivileged: true,
+	}
+	if got != want {
+		t.Errorf("NewOptions: %+v, want %+v", got, want)
+	}
+
+	if got := NewOptions(); got != (Options{Version: OptionsVersion}) {
+		t.Errorf("NewOptions(): %+v, want only the version set", got)
+	}
+}
+
+func TestOptionsRefused(t *testing.T) {
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Version: OptionsVersion + 1}, "version"},
+		{Options{Version: -1}, "version"},
+		{Options{Count: -1}, "count"},
+		{Options{Interval: -time.Second}, "interval"},
+		{Options{Timeout: -time.Second}, "timeout"},
+		{Options{TTL: 256}, "TTL"},
+		{Options{TTL: -1}, "TTL"},
+		{Options{TOS: 256}, "TOS"},
+	} {
+		// Refused before anything is resolved or sent
+		_, err := Run(context.Background(), "192.0.2.1", tc.opts)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Run with %+v: error %v, want one about the %s", tc.opts, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+#!/bin/bash
+
+# Builds and vets pinger for every supported platform, so platform specific
+# files (helpers/platform_*.go) are checked without needing those machines.
+# Expects an absolute path to the pinger directory, like test.sh.
+set -e
+cd $1
+
+for target in linux/amd64 darwin/arm64 windows/amd64; do
+	export GOOS=${target%/*} GOARCH=${target#*/}
+	echo "--- $GOOS/$GOARCH"
+	go vet ./...
+	go build -o /dev/null .
+done